@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+type BackupRestoreRequest struct {
+	Name string `form:"name" json:"name"`
+}
+
+// RestoreBackup imports a backup archive, either uploaded as the multipart
+// "file" field or named from the backups folder.
+func RestoreBackup(c *gin.Context) {
+	var (
+		result service.BackupRestoreResult
+		err    error
+	)
+	if upload, formErr := c.FormFile("file"); formErr == nil {
+		file, openErr := upload.Open()
+		if openErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		defer file.Close()
+		result, err = service.RestoreBackup(file)
+	} else {
+		var request BackupRestoreRequest
+		if bindErr := c.ShouldBind(&request); bindErr != nil || request.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A backup file or name is required"})
+			return
+		}
+		result, err = service.RestoreBackupFile(request.Name)
+	}
+
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBackup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to restore backup", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to restore backup"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/ctaylor1/briefcast/service"
 	"github.com/gin-gonic/gin"
 	glebarezsqlite "github.com/glebarez/sqlite"
+	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
 )
 
@@ -60,6 +61,14 @@ func makeRouter() *gin.Engine {
 	router.POST("/podcastitems/:id/cancel", CancelPodcastItemDownload)
 	router.POST("/podcastitems/:id/resume", ResumePodcastItemDownload)
 	router.GET("/search/local", SearchLocalRecords)
+	router.GET("/podcastitems/:id/progress", GetPodcastItemProgress)
+	router.POST("/podcastitems/:id/progress", UpdatePodcastItemProgress)
+	router.GET("/podcasts/:id/retention", GetPodcastRetention)
+	router.PATCH("/podcasts/:id/retention", PatchPodcastRetention)
+	router.GET("/retention/preview", GetRetentionPreview)
+	router.POST("/backups/restore", RestoreBackup)
+	router.GET("/websub/:id", VerifyWebSubSubscription)
+	router.POST("/websub/:id", ReceiveWebSubPush)
 	return router
 }
 
@@ -114,7 +123,7 @@ func TestSettingsEndpoints(t *testing.T) {
 		t.Fatalf("expected 400 from invalid PATCH /settings, got %d", resp.Code)
 	}
 
-	validPatch := `{"keepAllEpisodes":false,"keepLatestEpisodes":3,"deleteAfterDays":10,"deleteOnlyPlayed":true}`
+	validPatch := `{"keepAllEpisodes":false,"keepLatestEpisodes":3,"deleteAfterDays":10,"deleteOnlyPlayed":true,"maxDiskGB":1.5}`
 	req = httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBufferString(validPatch))
 	req.Header.Set("Content-Type", "application/json")
 	resp = httptest.NewRecorder()
@@ -130,6 +139,28 @@ func TestSettingsEndpoints(t *testing.T) {
 	if payload["keepLatestEpisodes"] != float64(3) {
 		t.Fatalf("expected keepLatestEpisodes=3, got %+v", payload)
 	}
+	if payload["maxDiskGB"] != 1.5 {
+		t.Fatalf("expected maxDiskGB=1.5, got %+v", payload)
+	}
+	if payload["feedParserBackend"] != "native" {
+		t.Fatalf("expected native feed parser by default, got %+v", payload)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBufferString(`{"feedParserBackend":"perl"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown feed parser backend, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBufferString(`{"episodePathTemplate":"{podcast}/{date}"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a template without an episode field, got %d", resp.Code)
+	}
 }
 
 func TestEpisodeMediaEndpoints(t *testing.T) {
@@ -327,3 +358,583 @@ func TestSearchLocalLimitValidation(t *testing.T) {
 		t.Fatalf("expected 400 for over-max search limit, got %d", resp.Code)
 	}
 }
+
+func TestPodcastItemProgressEndpoints(t *testing.T) {
+	setupControllersTestDB(t)
+	router := makeRouter()
+	_, item := createControllerPodcastAndItem(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/podcastitems/"+item.ID+"/progress", bytes.NewBufferString(`{"position":-5}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative position, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/podcastitems/"+item.ID+"/progress", bytes.NewBufferString(`{"position":42,"duration":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from progress update, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/podcastitems/"+item.ID+"/progress", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from progress endpoint, got %d", resp.Code)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode progress payload: %v", err)
+	}
+	if payload["position"] != float64(42) {
+		t.Fatalf("expected position 42, got %+v", payload)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/podcastitems/unknown-id/progress", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for missing progress item, got %d", resp.Code)
+	}
+}
+
+func TestPodcastRetentionEndpoints(t *testing.T) {
+	setupControllersTestDB(t)
+	router := makeRouter()
+	podcast, _ := createControllerPodcastAndItem(t)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPatch, "/podcasts/"+podcast.ID+"/retention", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := patch(`{}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty patch, got %d", resp.Code)
+	}
+	if resp := patch(`{"keepLatest":-1}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative keepLatest, got %d", resp.Code)
+	}
+	if resp := patch(`{"maxDiskGB":-0.5}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative maxDiskGB, got %d", resp.Code)
+	}
+	if resp := patch(`{"keepLatest":3,"deleteOnlyPlayed":true}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from retention patch, got %d", resp.Code)
+	}
+	if resp := patch(`{"deleteAfterDays":7}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from retention patch, got %d", resp.Code)
+	}
+
+	var refreshed db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &refreshed); err != nil {
+		t.Fatalf("reload podcast failed: %v", err)
+	}
+	if refreshed.RetentionKeepLatest == nil || *refreshed.RetentionKeepLatest != 3 ||
+		refreshed.RetentionDeleteAfterDays == nil || *refreshed.RetentionDeleteAfterDays != 7 {
+		t.Fatalf("expected overrides to be merged, got %+v", refreshed)
+	}
+
+	if resp := patch(`{"keepLatest":null}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 when clearing override, got %d", resp.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/podcasts/"+podcast.ID+"/retention", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from retention endpoint, got %d", resp.Code)
+	}
+	var payload struct {
+		Overrides struct {
+			KeepLatest       *int  `json:"keepLatest"`
+			DeleteAfterDays  *int  `json:"deleteAfterDays"`
+			DeleteOnlyPlayed *bool `json:"deleteOnlyPlayed"`
+		} `json:"overrides"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode retention payload: %v", err)
+	}
+	if payload.Overrides.KeepLatest != nil || payload.Overrides.DeleteAfterDays == nil || payload.Overrides.DeleteOnlyPlayed == nil {
+		t.Fatalf("expected keepLatest cleared and other overrides kept, got %+v", payload.Overrides)
+	}
+}
+
+func TestWebSubCallbackEndpoints(t *testing.T) {
+	setupControllersTestDB(t)
+	t.Setenv("WEBSUB_CALLBACK_URL", "https://briefcast.test")
+	podcast, _ := createControllerPodcastAndItem(t)
+	requestedAt := time.Now()
+	podcast.WebSubHub = "https://hub.example.com/"
+	podcast.WebSubTopic = podcast.URL
+	podcast.WebSubSecret = "secret"
+	podcast.WebSubRequestedAt = &requestedAt
+	if err := db.DB.Save(&podcast).Error; err != nil {
+		t.Fatalf("save podcast failed: %v", err)
+	}
+	router := makeRouter()
+	serve := func(method string, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader("<rss/>"))
+		req.Header.Set("X-Hub-Signature", "sha256=00")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	verify := "/websub/" + podcast.ID + "?hub.mode=subscribe&hub.challenge=abc&hub.lease_seconds=3600&hub.topic="
+	if resp := serve(http.MethodGet, verify+"https://other.example.com/feed"); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected another topic to be refused, got %d", resp.Code)
+	}
+	if resp := serve(http.MethodPost, "/websub/"+podcast.ID); resp.Code != http.StatusGone {
+		t.Fatalf("expected pushes before verification to be refused, got %d", resp.Code)
+	}
+	resp := serve(http.MethodGet, verify+podcast.URL)
+	if resp.Code != http.StatusOK || resp.Body.String() != "abc" {
+		t.Fatalf("expected the challenge to be echoed, got %d %q", resp.Code, resp.Body.String())
+	}
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err != nil || stored.WebSubExpiresAt == nil {
+		t.Fatalf("expected the lease to be stored, got %+v %v", stored.WebSubExpiresAt, err)
+	}
+	if resp := serve(http.MethodPost, "/websub/"+podcast.ID); resp.Code != http.StatusAccepted {
+		t.Fatalf("expected a push with a bad signature to be acknowledged, got %d", resp.Code)
+	}
+	if resp := serve(http.MethodPost, "/websub/missing"); resp.Code != http.StatusGone {
+		t.Fatalf("expected pushes for unknown podcasts to be refused, got %d", resp.Code)
+	}
+}
+
+func TestRetentionPreviewEndpoint(t *testing.T) {
+	setupControllersTestDB(t)
+	router := makeRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/retention/preview", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from retention preview, got %d", resp.Code)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode retention preview: %v", err)
+	}
+	if _, ok := payload["podcasts"].([]interface{}); !ok {
+		t.Fatalf("expected podcasts list in preview, got %+v", payload)
+	}
+}
+
+func TestRestoreBackupValidation(t *testing.T) {
+	setupControllersTestDB(t)
+	router := makeRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/backups/restore", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a backup, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/backups/restore", bytes.NewBufferString(`{"name":"../briefcast.db"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a path outside the backups folder, got %d", resp.Code)
+	}
+}
+
+func TestGpodderEndpointsRequireAuth(t *testing.T) {
+	setupControllersTestDB(t)
+	createControllerPodcastAndItem(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if _, err := service.EnsureDefaultUser("secret"); err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	group := router.Group("/api/2", GpodderAuth(true))
+	group.POST("/auth/:username/login.json", GpodderLogin)
+	group.GET("/devices/:username", GpodderListDevices)
+	group.POST("/devices/:username/:deviceid", GpodderUpdateDevice)
+	group.GET("/subscriptions/:username/:deviceid", GpodderGetSubscriptions)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/2/devices/briefcast.json", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/2/auth/briefcast/login.json", nil)
+	req.SetBasicAuth("briefcast", "secret")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from login, got %d", resp.Code)
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("expected session cookie from login")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/2/devices/briefcast/phone.json", bytes.NewBufferString(`{"caption":"Phone","type":"mobile"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from device update, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/2/devices/someone-else.json", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for another user's devices, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/2/devices/briefcast.json", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	var devices []map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &devices); err != nil {
+		t.Fatalf("failed to decode devices payload: %v", err)
+	}
+	if len(devices) != 1 || devices[0]["id"] != "phone" || devices[0]["subscriptions"] != float64(1) {
+		t.Fatalf("unexpected devices payload %+v", devices)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/2/subscriptions/briefcast/phone.json?since=0", nil)
+	req.SetBasicAuth("briefcast", "secret")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	var changes map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &changes); err != nil {
+		t.Fatalf("failed to decode subscriptions payload: %v", err)
+	}
+	add, _ := changes["add"].([]interface{})
+	if len(add) != 1 || add[0] != "https://example.com/controller.xml" {
+		t.Fatalf("unexpected subscriptions payload %+v", changes)
+	}
+
+	// The session is stored like a web login, so it outlives the process
+	// and ends when the password changes.
+	if user, err := service.GetSessionUser(cookies[0].Value); err != nil || user.Username != "briefcast" {
+		t.Fatalf("expected the gpodder session to be stored, got %+v %v", user, err)
+	}
+	if _, err := service.EnsureDefaultUser("changed"); err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/2/devices/briefcast.json", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after a password change, got %d", resp.Code)
+	}
+
+	listener, err := service.CreateUser("listener", "secret", false)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/2/auth/listener/login.json", nil)
+	req.SetBasicAuth("listener", "secret")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	cookies = resp.Result().Cookies()
+	if resp.Code != http.StatusOK || len(cookies) == 0 {
+		t.Fatalf("expected listener login to succeed, got %d", resp.Code)
+	}
+	if err := service.DeleteUser(listener.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/2/devices/listener.json", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after the user is deleted, got %d", resp.Code)
+	}
+}
+
+func TestRequireUserScopesEpisodeState(t *testing.T) {
+	setupControllersTestDB(t)
+	_, item := createControllerPodcastAndItem(t)
+	if _, err := service.EnsureDefaultUser("secret"); err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	if _, err := service.CreateUser("listener", "hunter2", false); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", Login)
+	group := router.Group("/", RequireUser(true))
+	group.GET("/auth/me", GetCurrentUser)
+	group.GET("/podcastitems/:id", GetPodcastItemById)
+	group.GET("/podcastitems/:id/markPlayed", MarkPodcastItemAsPlayed)
+	group.GET("/users", RequireAdmin(), GetAllUsers)
+
+	req := httptest.NewRequest(http.MethodGet, "/podcastitems/"+item.ID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"listener","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 from login, got %d", resp.Code)
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("expected session cookie from login")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/podcastitems/"+item.ID+"/markPlayed", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	isPlayedFor := func(authorize func(*http.Request)) bool {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/podcastitems/"+item.ID, nil)
+		authorize(req)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected 200 for episode, got %d", resp.Code)
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("failed to decode episode payload: %v", err)
+		}
+		return payload["IsPlayed"] == true
+	}
+	if !isPlayedFor(func(req *http.Request) { req.AddCookie(cookies[0]) }) {
+		t.Fatalf("expected episode to be played for the listener")
+	}
+	if isPlayedFor(func(req *http.Request) { req.SetBasicAuth("briefcast", "secret") }) {
+		t.Fatalf("expected episode to stay unplayed for the default user")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.AddCookie(cookies[0])
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin user, got %d", resp.Code)
+	}
+}
+
+func TestRequireUserAcceptsAPITokens(t *testing.T) {
+	setupControllersTestDB(t)
+	podcast, item := createControllerPodcastAndItem(t)
+	user, err := service.EnsureDefaultUser("secret")
+	if err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	feedToken, _, err := service.CreateAPIToken(user.ID, "podcast app", service.APITokenScopeFeeds)
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	fullToken, _, err := service.CreateAPIToken(user.ID, "script", "")
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	readToken, _, err := service.CreateAPIToken(user.ID, "dashboard", service.APITokenScopeRead)
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		setting := db.GetOrCreateSetting()
+		setting.BaseUrl = "http://briefcast.test"
+		c.Set("setting", setting)
+		c.Next()
+	})
+	group := router.Group("/", RequireUser(true))
+	group.GET("/podcasts/:id/rss", GetRssForPodcastById)
+	group.GET("/podcastitems/:id", GetPodcastItemById)
+	group.GET("/podcastitems/:id/delete", DeletePodcastItem)
+	group.GET("/podcastitems/:id/markPlayed", MarkPodcastItemAsPlayed)
+
+	serve := func(target string, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("/podcasts/"+podcast.ID+"/rss?token="+feedToken, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a feed with a query token, got %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "/podcastitems/"+item.ID+"/file?token="+feedToken) {
+		t.Fatalf("expected enclosure links to carry the token, got %s", resp.Body.String())
+	}
+	if resp := serve("/podcastitems/"+item.ID+"?token="+fullToken, ""); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected query tokens to be ignored outside feed routes, got %d", resp.Code)
+	}
+	if resp := serve("/podcastitems/"+item.ID, feedToken); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected feeds token to be refused outside feed routes, got %d", resp.Code)
+	}
+	if resp := serve("/podcastitems/"+item.ID, fullToken); resp.Code != http.StatusOK {
+		t.Fatalf("expected full bearer token to be accepted, got %d", resp.Code)
+	}
+	if resp := serve("/podcastitems/"+item.ID, "bc_unknown"); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unknown token to be rejected, got %d", resp.Code)
+	}
+	if resp := serve("/podcastitems/"+item.ID, readToken); resp.Code != http.StatusOK {
+		t.Fatalf("expected read token to be accepted on a read-only route, got %d", resp.Code)
+	}
+	for _, target := range []string{"/podcastitems/" + item.ID + "/delete", "/podcastitems/" + item.ID + "/markPlayed"} {
+		if resp := serve(target, readToken); resp.Code != http.StatusForbidden {
+			t.Fatalf("expected read token to be refused on %s, got %d", target, resp.Code)
+		}
+	}
+	var stored db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &stored); err != nil || stored.DownloadStatus != db.NotDownloaded {
+		t.Fatalf("expected the episode to be untouched, got %v %v", stored.DownloadStatus, err)
+	}
+}
+
+func TestAdminRoutesRefuseNonAdmins(t *testing.T) {
+	setupControllersTestDB(t)
+	podcast, item := createControllerPodcastAndItem(t)
+	if _, err := service.EnsureDefaultUser("secret"); err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	if _, err := service.CreateUser("listener", "hunter2", false); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Group("/api/2", GpodderAuth(true)).POST("/subscriptions/:username/:deviceid", GpodderUploadSubscriptions)
+	admin := router.Group("/", RequireUser(true), RequireAdmin())
+	admin.PATCH("/settings", PatchSettings)
+	admin.POST("/settings", UpdateSetting)
+	admin.POST("/backups/restore", RestoreBackup)
+	admin.DELETE("/podcasts/:id", DeletePodcastById)
+	admin.DELETE("/podcasts/:id/items", DeletePodcastEpisodesById)
+	admin.DELETE("/podcasts/:id/podcast", DeleteOnlyPodcastById)
+	admin.PATCH("/podcasts/:id/retention", PatchPodcastRetention)
+	admin.PATCH("/podcasts/:id/processing", PatchPodcastProcessing)
+	admin.POST("/podcastitems/:id/tags", WritePodcastItemTags)
+	admin.POST("/podcastitems/:id/process", ProcessPodcastItem)
+	admin.GET("/podcastitems/:id/delete", DeletePodcastItem)
+
+	routes := []struct {
+		method string
+		target string
+	}{
+		{http.MethodPatch, "/settings"},
+		{http.MethodPost, "/settings"},
+		{http.MethodPost, "/backups/restore"},
+		{http.MethodDelete, "/podcasts/" + podcast.ID},
+		{http.MethodDelete, "/podcasts/" + podcast.ID + "/items"},
+		{http.MethodDelete, "/podcasts/" + podcast.ID + "/podcast"},
+		{http.MethodPatch, "/podcasts/" + podcast.ID + "/retention"},
+		{http.MethodPatch, "/podcasts/" + podcast.ID + "/processing"},
+		{http.MethodPost, "/podcastitems/" + item.ID + "/tags"},
+		{http.MethodPost, "/podcastitems/" + item.ID + "/process"},
+		{http.MethodGet, "/podcastitems/" + item.ID + "/delete"},
+	}
+	for _, route := range routes {
+		req := httptest.NewRequest(route.method, route.target, bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("listener", "hunter2")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for a non-admin on %s %s, got %d", route.method, route.target, resp.Code)
+		}
+	}
+	// gPodder subscriptions are shared, so a listener's client cannot remove
+	// podcasts either.
+	req := httptest.NewRequest(http.MethodPost, "/api/2/subscriptions/listener/phone.json", bytes.NewBufferString(`{"add":[],"remove":["`+podcast.URL+`"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("listener", "hunter2")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a listener's gpodder removal, got %d", resp.Code)
+	}
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err != nil {
+		t.Fatalf("expected the podcast to survive, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/podcasts/"+podcast.ID+"/podcast", nil)
+	req.SetBasicAuth("briefcast", "secret")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code == http.StatusForbidden {
+		t.Fatalf("expected the admin to be allowed, got %d", resp.Code)
+	}
+}
+
+func TestWebsocketRequiresUser(t *testing.T) {
+	setupControllersTestDB(t)
+	_, item := createControllerPodcastAndItem(t)
+	admin, err := service.EnsureDefaultUser("secret")
+	if err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	listener, err := service.CreateUser("listener", "hunter2", false)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Group("/", RequireUser(true)).GET("/ws", Wshandler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	go HandleWebsocketMessages()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an anonymous socket to be refused with 401, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.SetBasicAuth("listener", "hunter2")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {req.Header.Get("Authorization")}})
+	if err != nil {
+		t.Fatalf("expected the listener to connect, got %v", err)
+	}
+	defer conn.Close()
+	payload := `{"podcastItemId":"` + item.ID + `","position":42}`
+	if err := conn.WriteJSON(Message{Identifier: "player", MessageType: "PlaybackProgress", Payload: payload}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		progress, err := service.GetPodcastItemProgress(listener.ID, item.ID)
+		if err == nil && progress.Position == 42 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the listener's progress to be saved, got %+v %v", progress, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if progress, err := service.GetPodcastItemProgress(admin.ID, item.ID); err != nil || progress.Position != 0 {
+		t.Fatalf("expected the admin's progress to be untouched, got %+v %v", progress, err)
+	}
+}
@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ctaylor1/briefcast/db"
 	"github.com/ctaylor1/briefcast/service"
@@ -14,6 +16,14 @@ type DownloadQueueQuery struct {
 	Limit int `form:"limit" query:"limit"`
 }
 
+type DownloadQueueReorderRequest struct {
+	IDs []string `binding:"required" json:"ids"`
+}
+
+type DownloadPriorityPatch struct {
+	Priority *int `json:"priority"`
+}
+
 const (
 	defaultDownloadQueueLimit = 50
 	maxDownloadQueueLimit     = 200
@@ -35,7 +45,7 @@ func GetDownloadQueue(c *gin.Context) {
 		limit = parsed
 	}
 
-	items, err := db.GetPodcastItemsByDownloadStatuses([]db.DownloadStatus{db.NotDownloaded, db.Downloading, db.Paused}, limit)
+	items, queue, err := service.GetDownloadQueue(limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load download queue."})
 		return
@@ -46,26 +56,31 @@ func GetDownloadQueue(c *gin.Context) {
 		"downloading": 0,
 		"downloaded":  0,
 		"paused":      0,
+		"failed":      0,
 	}
 	if stats, err := db.GetPodcastEpisodeStats(); err == nil {
 		for _, stat := range *stats {
 			switch stat.DownloadStatus {
 			case db.NotDownloaded:
-				counts["queued"] = stat.Count
+				counts["queued"] += stat.Count
 			case db.Downloading:
-				counts["downloading"] = stat.Count
+				counts["downloading"] += stat.Count
 			case db.Downloaded:
-				counts["downloaded"] = stat.Count
+				counts["downloaded"] += stat.Count
 			case db.Paused:
-				counts["paused"] = stat.Count
+				counts["paused"] += stat.Count
+			case db.Failed:
+				counts["failed"] += stat.Count
 			}
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"paused": service.DownloadsPaused(),
-		"counts": counts,
-		"items":  items,
+		"paused":     service.DownloadsPaused(),
+		"windowOpen": service.DownloadWindowOpen(db.GetOrCreateSetting(), time.Now()),
+		"counts":     counts,
+		"items":      items,
+		"queue":      queue,
 	})
 }
 
@@ -124,3 +139,121 @@ func ResumePodcastItemDownload(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+func GetFailedDownloads(c *gin.Context) {
+	limit := defaultDownloadQueueLimit
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxDownloadQueueLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := service.GetFailedDownloads(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load failed downloads."})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func RetryFailedDownloads(c *gin.Context) {
+	count, err := service.RetryFailedDownloads()
+	if err != nil {
+		controllerLogger.Errorw("failed to retry failed downloads", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry downloads."})
+		return
+	}
+	if count > 0 && !service.DownloadsPaused() {
+		go service.DownloadMissingEpisodes()
+	}
+	c.JSON(http.StatusOK, gin.H{"queued": count})
+}
+
+func RetryPodcastItemDownload(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.RetryFailedDownload(searchByIdQuery.Id); err != nil {
+		if errors.Is(err, service.ErrNoFailedDownload) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Warnw("failed to retry download", "podcast_item_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !service.DownloadsPaused() {
+		go service.DownloadSingleEpisode(searchByIdQuery.Id)
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func MoveDownloadToTop(c *gin.Context) {
+	moveDownload(c, service.MoveDownloadToTop)
+}
+
+func MoveDownloadToBottom(c *gin.Context) {
+	moveDownload(c, service.MoveDownloadToBottom)
+}
+
+func moveDownload(c *gin.Context, move func(string) error) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := move(searchByIdQuery.Id); err != nil {
+		downloadQueueError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func ReorderDownloadQueue(c *gin.Context) {
+	var request DownloadQueueReorderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.ReorderDownloadQueue(request.IDs); err != nil {
+		downloadQueueError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func PatchDownloadQueueEntry(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	var patch DownloadPriorityPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.Priority == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "priority is required"})
+		return
+	}
+	if err := service.SetDownloadPriority(searchByIdQuery.Id, *patch.Priority); err != nil {
+		downloadQueueError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func downloadQueueError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrNotInDownloadQueue) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	controllerLogger.Errorw("failed to update download queue", "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update download queue."})
+}
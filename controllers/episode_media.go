@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -63,3 +64,44 @@ func GetPodcastItemTranscript(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, payload)
 }
+
+// WritePodcastItemTags rewrites the ID3 tags and chapters of a downloaded
+// episode, whether or not tagging after download is switched on.
+func WritePodcastItemTags(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.TagPodcastItem(searchByIdQuery.Id); err != nil {
+		if errors.Is(err, service.ErrNotDownloaded) || errors.Is(err, service.ErrNotID3Taggable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to write id3 tags", "podcast_item_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to write tags"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// ProcessPodcastItem queues a downloaded episode for the audio processing
+// pipeline of its podcast and starts the processing job.
+func ProcessPodcastItem(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.QueueEpisodeProcessing(searchByIdQuery.Id); err != nil {
+		if errors.Is(err, service.ErrNotDownloaded) || errors.Is(err, service.ErrProcessingDisabled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Warnw("failed to queue audio processing", "podcast_item_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	go service.ProcessDownloadedAudio()
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/model"
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+const gpodderSessionCookie = "sessionid"
+
+// GpodderAuth protects the gPodder sync API. Clients authenticate with basic
+// auth or the session cookie handed out by the login endpoint, which is a
+// regular user session so it survives restarts and ends with the user. The
+// username in the path must match the authenticated user. With authentication
+// disabled the path username picks the user, falling back to the default one.
+func GpodderAuth(authRequired bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := gpodderAuthenticatedUser(c, authRequired)
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if pathUser := gpodderParam(c, "username"); pathUser != "" && authRequired && pathUser != user.Username {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(userContextKey, user)
+		c.Set(gin.AuthUserKey, user.Username)
+		c.Next()
+	}
+}
+
+func gpodderAuthenticatedUser(c *gin.Context, authRequired bool) (db.User, bool) {
+	if sessionID, err := c.Cookie(gpodderSessionCookie); err == nil && sessionID != "" {
+		if user, err := service.GetSessionUser(sessionID); err == nil {
+			return user, true
+		}
+	}
+	if username, password, hasAuth := c.Request.BasicAuth(); hasAuth {
+		if user, err := service.AuthenticateUser(username, password); err == nil {
+			return user, true
+		}
+		if authRequired {
+			return db.User{}, false
+		}
+	}
+	if authRequired {
+		return db.User{}, false
+	}
+	var user db.User
+	if err := db.GetUserByUsername(gpodderParam(c, "username"), &user); err == nil {
+		return user, true
+	}
+	user, err := service.GetDefaultUser()
+	return user, err == nil
+}
+
+// gpodderParam returns a path parameter without the ".json" format suffix the
+// gPodder API appends to the last path segment.
+func gpodderParam(c *gin.Context, name string) string {
+	return strings.TrimSuffix(c.Param(name), ".json")
+}
+
+func GpodderLogin(c *gin.Context) {
+	token, session, err := service.CreateUserSession(currentUserID(c))
+	if err != nil {
+		controllerLogger.Errorw("failed to create gpodder session", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create session"})
+		return
+	}
+	maxAge := int(session.ExpiresAt.Sub(session.CreatedAt).Seconds())
+	c.SetCookie(gpodderSessionCookie, token, maxAge, "/api/2", "", false, true)
+	c.Status(http.StatusOK)
+}
+
+func GpodderLogout(c *gin.Context) {
+	if token, err := c.Cookie(gpodderSessionCookie); err == nil && token != "" {
+		if err := service.DeleteUserSession(token); err != nil {
+			controllerLogger.Warnw("failed to delete gpodder session", "error", err)
+		}
+	}
+	c.SetCookie(gpodderSessionCookie, "", -1, "/api/2", "", false, true)
+	c.Status(http.StatusOK)
+}
+
+func GpodderListDevices(c *gin.Context) {
+	devices, err := service.ListGpodderDevices()
+	if err != nil {
+		controllerLogger.Errorw("failed to list gpodder devices", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to list devices"})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+func GpodderUpdateDevice(c *gin.Context) {
+	deviceID := gpodderParam(c, "deviceid")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	var update model.GpodderDeviceUpdate
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := service.UpsertGpodderDevice(deviceID, update); err != nil {
+		controllerLogger.Errorw("failed to save gpodder device", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to save device"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func GpodderGetSubscriptions(c *gin.Context) {
+	since, err := gpodderSince(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+		return
+	}
+	if deviceID := gpodderParam(c, "deviceid"); deviceID != "" {
+		if err := service.UpsertGpodderDevice(deviceID, model.GpodderDeviceUpdate{}); err != nil {
+			controllerLogger.Warnw("failed to register gpodder device", "device_id", deviceID, "error", err)
+		}
+	}
+	changes, err := service.GetSubscriptionChanges(since)
+	if err != nil {
+		controllerLogger.Errorw("failed to load subscription changes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load subscriptions"})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+func GpodderUploadSubscriptions(c *gin.Context) {
+	var changes model.GpodderSubscriptionChanges
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if deviceID := gpodderParam(c, "deviceid"); deviceID != "" {
+		if err := service.UpsertGpodderDevice(deviceID, model.GpodderDeviceUpdate{}); err != nil {
+			controllerLogger.Warnw("failed to register gpodder device", "device_id", deviceID, "error", err)
+		}
+	}
+	user, err := currentUser(c)
+	if err != nil {
+		controllerLogger.Errorw("failed to resolve current user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to apply subscription changes"})
+		return
+	}
+	response, err := service.ApplySubscriptionChanges(changes, user.IsAdmin)
+	if err != nil {
+		if errors.Is(err, service.ErrGpodderConflictingChanges) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrGpodderRemoveForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to apply subscription changes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to apply subscription changes"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GpodderGetEpisodeActions(c *gin.Context) {
+	since, err := gpodderSince(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+		return
+	}
+	aggregated := c.Query("aggregated") == "true"
+	actions, err := service.ListEpisodeActions(currentUserID(c), since, c.Query("podcast"), c.Query("device"), aggregated)
+	if err != nil {
+		controllerLogger.Errorw("failed to load episode actions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load episode actions"})
+		return
+	}
+	c.JSON(http.StatusOK, actions)
+}
+
+func GpodderUploadEpisodeActions(c *gin.Context) {
+	var actions []model.GpodderEpisodeAction
+	if err := c.ShouldBindJSON(&actions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response, err := service.ApplyEpisodeActions(currentUserID(c), actions)
+	if err != nil {
+		if errors.Is(err, service.ErrGpodderInvalidAction) || errors.Is(err, service.ErrGpodderInvalidTimestamp) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to apply episode actions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to apply episode actions"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func gpodderSince(c *gin.Context) (int64, error) {
+	value := c.Query("since")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
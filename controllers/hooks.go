@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type HookRequest struct {
+	Name           string   `form:"name" json:"name"`
+	Kind           string   `binding:"required" form:"kind" json:"kind"`
+	Target         string   `binding:"required" form:"target" json:"target"`
+	Events         []string `binding:"required" form:"events" json:"events"`
+	Secret         string   `form:"secret" json:"secret"`
+	TimeoutSeconds int      `form:"timeoutSeconds" json:"timeoutSeconds"`
+	MaxAttempts    int      `form:"maxAttempts" json:"maxAttempts"`
+	Enabled        *bool    `form:"enabled" json:"enabled"`
+}
+
+func (request HookRequest) hook() db.Hook {
+	return db.Hook{
+		Name:           request.Name,
+		Kind:           request.Kind,
+		Target:         request.Target,
+		Events:         strings.Join(request.Events, ","),
+		Secret:         request.Secret,
+		TimeoutSeconds: request.TimeoutSeconds,
+		MaxAttempts:    request.MaxAttempts,
+		Enabled:        request.Enabled == nil || *request.Enabled,
+	}
+}
+
+func isHookValidationError(err error) bool {
+	return errors.Is(err, service.ErrInvalidHookKind) || errors.Is(err, service.ErrInvalidHookTarget) || errors.Is(err, service.ErrInvalidHookEvent)
+}
+
+func GetAllHooks(c *gin.Context) {
+	hooks, err := service.GetAllHooks()
+	if err != nil {
+		controllerLogger.Errorw("failed to load hooks", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load hooks"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func AddHook(c *gin.Context) {
+	var request HookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hook, err := service.CreateHook(request.hook())
+	if err != nil {
+		if isHookValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to create hook", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create hook"})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// UpdateHook replaces a hook's settings. Leaving the secret empty keeps the
+// current one.
+func UpdateHook(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	var request HookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hook, err := service.UpdateHook(searchByIdQuery.Id, request.hook())
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+		case isHookValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			controllerLogger.Errorw("failed to update hook", "hook_id", searchByIdQuery.Id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to update hook"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+func DeleteHookById(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.DeleteHook(searchByIdQuery.Id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hook not found"})
+			return
+		}
+		controllerLogger.Errorw("failed to delete hook", "hook_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to delete hook"})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// GetHookDeliveries returns the most recent delivery attempts of a hook,
+// newest first.
+func GetHookDeliveries(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	limit := 50
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	deliveries, err := service.GetHookDeliveries(searchByIdQuery.Id, limit)
+	if err != nil {
+		controllerLogger.Errorw("failed to load hook deliveries", "hook_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetLibraryReorganizePreview lists the moves a reorganization would make
+// without touching any file.
+func GetLibraryReorganizePreview(c *gin.Context) {
+	result, err := service.ReorganizeLibrary(true)
+	if err != nil {
+		controllerLogger.Errorw("failed to build reorganize preview", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to build reorganize preview"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func ReorganizeLibrary(c *gin.Context) {
+	result, err := service.ReorganizeLibrary(c.Query("dryRun") == "true")
+	if errors.Is(err, service.ErrLibraryBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		controllerLogger.Errorw("failed to reorganize library", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to reorganize library"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+type LibraryImportRequest struct {
+	PodcastItemID string `binding:"required" form:"podcastItemId" json:"podcastItemId"`
+	Path          string `binding:"required" form:"path" json:"path"`
+}
+
+func GetLibraryScanReport(c *gin.Context) {
+	report := service.GetLibraryScanReport()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No library scan has run"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func StartLibraryScan(c *gin.Context) {
+	if err := service.StartLibraryScan(c.Query("dryRun") == "true"); err != nil {
+		if errors.Is(err, service.ErrLibraryScanRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to start library scan", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to start library scan"})
+		return
+	}
+	c.JSON(http.StatusAccepted, service.GetLibraryScanReport())
+}
+
+func ImportLibraryFile(c *gin.Context) {
+	var request LibraryImportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	err := service.ImportLibraryFile(request.PodcastItemID, request.Path)
+	switch {
+	case errors.Is(err, service.ErrInvalidImportPath), errors.Is(err, service.ErrAlreadyDownloaded), errors.Is(err, service.ErrImportPathOwned):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Episode not found"})
+	case err != nil:
+		controllerLogger.Errorw("failed to import library file", "podcast_item_id", request.PodcastItemID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to import file"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	}
+}
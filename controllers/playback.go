@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+func GetPodcastItemProgress(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	progress, err := service.GetPodcastItemProgress(currentUserID(c), searchByIdQuery.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Episode not found"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+func UpdatePodcastItemProgress(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var update service.PlaybackProgressUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	progress, err := service.SetPodcastItemProgress(currentUserID(c), searchByIdQuery.Id, update)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPlaybackPosition) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Episode not found"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
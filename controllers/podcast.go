@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/ctaylor1/briefcast/internal/logging"
@@ -14,6 +17,7 @@ import (
 
 	"github.com/ctaylor1/briefcast/db"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const (
@@ -47,18 +51,65 @@ type AddRemoveTagQuery struct {
 }
 
 type PatchPodcastItem struct {
-	IsPlayed bool   `json:"isPlayed" form:"isPlayed" query:"isPlayed"`
+	IsPlayed *bool  `json:"isPlayed" form:"isPlayed" query:"isPlayed"`
 	Title    string `form:"title" json:"title" query:"title"`
 }
 
+// optionalField tells an explicit JSON null apart from a missing field: Set is
+// true whenever the key is present, and Value is nil when it was null.
+type optionalField[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (field *optionalField[T]) UnmarshalJSON(data []byte) error {
+	field.Set = true
+	if string(data) == "null" {
+		field.Value = nil
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	field.Value = &value
+	return nil
+}
+
+// PodcastRetentionPatch updates the per-podcast retention overrides. Omitted
+// fields are left as they are; null clears an override so the podcast
+// inherits the global setting again.
 type PodcastRetentionPatch struct {
-	KeepAll *bool `json:"keepAll"`
+	KeepAll          *bool                  `json:"keepAll"`
+	KeepLatest       optionalField[int]     `json:"keepLatest"`
+	DeleteAfterDays  optionalField[int]     `json:"deleteAfterDays"`
+	DeleteOnlyPlayed optionalField[bool]    `json:"deleteOnlyPlayed"`
+	MaxDiskGB        optionalField[float64] `json:"maxDiskGB"`
 }
 
 type PodcastSponsorSkipPatch struct {
 	AutoSkipSponsorChapters *bool `json:"autoSkipSponsorChapters"`
 }
 
+type PodcastDownloadPriorityPatch struct {
+	DownloadPriority *int `json:"downloadPriority"`
+}
+
+// PodcastRefreshIntervalPatch fixes how often a podcast is refreshed, in
+// minutes. 0 returns it to the schedule derived from its publish cadence.
+type PodcastRefreshIntervalPatch struct {
+	RefreshIntervalMinutes *int `json:"refreshIntervalMinutes"`
+}
+
+// PodcastProcessingPatch updates a podcast's audio processing options. Omitted
+// fields are left as they are.
+type PodcastProcessingPatch struct {
+	Loudnorm     *bool   `json:"loudnorm"`
+	StripSilence *bool   `json:"stripSilence"`
+	Codec        *string `json:"codec"`
+	BitrateKbps  *int    `json:"bitrateKbps"`
+}
+
 type AddPodcastData struct {
 	Url string `binding:"required" form:"url" json:"url"`
 }
@@ -104,6 +155,7 @@ func GetPodcastById(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
+		applyUserEpisodeState(c, podcast.PodcastItems)
 		c.JSON(200, podcast)
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
@@ -150,18 +202,62 @@ func PatchPodcastRetention(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if patch.KeepAll == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "keepAll is required"})
+	if patch.KeepAll == nil && !patch.KeepLatest.Set && !patch.DeleteAfterDays.Set && !patch.DeleteOnlyPlayed.Set && !patch.MaxDiskGB.Set {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one retention field is required"})
+		return
+	}
+	if (patch.KeepLatest.Value != nil && *patch.KeepLatest.Value < 0) ||
+		(patch.DeleteAfterDays.Value != nil && *patch.DeleteAfterDays.Value < 0) ||
+		(patch.MaxDiskGB.Value != nil && *patch.MaxDiskGB.Value < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retention values must not be negative"})
 		return
 	}
 
-	if err := db.DB.Model(&db.Podcast{}).Where("id = ?", searchByIdQuery.Id).
-		Update("retention_keep_all", *patch.KeepAll).Error; err != nil {
+	current, err := service.GetPodcastRetention(searchByIdQuery.Id)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	overrides := current.Overrides
+	if patch.KeepAll != nil {
+		overrides.KeepAll = *patch.KeepAll
+	}
+	if patch.KeepLatest.Set {
+		overrides.KeepLatest = patch.KeepLatest.Value
+	}
+	if patch.DeleteAfterDays.Set {
+		overrides.DeleteAfterDays = patch.DeleteAfterDays.Value
+	}
+	if patch.DeleteOnlyPlayed.Set {
+		overrides.DeleteOnlyPlayed = patch.DeleteOnlyPlayed.Value
+	}
+	if patch.MaxDiskGB.Set {
+		overrides.MaxDiskGB = patch.MaxDiskGB.Value
+	}
+
+	retention, err := service.SetPodcastRetention(searchByIdQuery.Id, overrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "retention": retention})
+}
+
+func GetPodcastRetention(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	retention, err := service.GetPodcastRetention(searchByIdQuery.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, retention)
 }
 
 func PatchPodcastSponsorSkip(c *gin.Context) {
@@ -190,6 +286,173 @@ func PatchPodcastSponsorSkip(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+func PatchPodcastDownloadPriority(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var patch PodcastDownloadPriorityPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.DownloadPriority == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "downloadPriority is required"})
+		return
+	}
+
+	if err := service.SetPodcastDownloadPriority(searchByIdQuery.Id, *patch.DownloadPriority); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func GetPodcastProcessing(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	processing, err := service.GetPodcastProcessing(searchByIdQuery.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, processing)
+}
+
+// GetPodcastEvents returns the newest entries of a podcast's event log,
+// including feed URL changes.
+func GetPodcastEvents(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	limit := 100
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	events, err := service.GetPodcastEvents(searchByIdQuery.Id, limit)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Podcast not found"})
+			return
+		}
+		controllerLogger.Errorw("failed to load podcast events", "podcast_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load events"})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+func RefreshPodcastById(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	podcast, err := service.RefreshPodcast(searchByIdQuery.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Podcast not found"})
+		case errors.Is(err, service.ErrPodcastRefreshing):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case podcast != nil:
+			controllerLogger.Warnw("manual podcast refresh failed", "podcast_id", searchByIdQuery.Id, "error", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "podcast": podcast})
+		default:
+			controllerLogger.Errorw("failed to refresh podcast", "podcast_id", searchByIdQuery.Id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to refresh podcast"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, podcast)
+}
+
+func PatchPodcastRefreshInterval(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var patch PodcastRefreshIntervalPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.RefreshIntervalMinutes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refreshIntervalMinutes is required"})
+		return
+	}
+
+	if err := service.SetPodcastRefreshInterval(searchByIdQuery.Id, *patch.RefreshIntervalMinutes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Podcast not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func PatchPodcastProcessing(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var patch PodcastProcessingPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.Loudnorm == nil && patch.StripSilence == nil && patch.Codec == nil && patch.BitrateKbps == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one processing field is required"})
+		return
+	}
+
+	processing, err := service.GetPodcastProcessing(searchByIdQuery.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.Loudnorm != nil {
+		processing.Loudnorm = *patch.Loudnorm
+	}
+	if patch.StripSilence != nil {
+		processing.StripSilence = *patch.StripSilence
+	}
+	if patch.Codec != nil {
+		processing.Codec = *patch.Codec
+	}
+	if patch.BitrateKbps != nil {
+		processing.BitrateKbps = *patch.BitrateKbps
+	}
+
+	processing, err = service.SetPodcastProcessing(searchByIdQuery.Id, processing)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "processing": processing})
+}
+
 func DeletePodcastById(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 
@@ -250,6 +513,7 @@ func GetPodcastItemsByPodcastId(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
+		applyUserEpisodeState(c, podcastItems)
 		for i := range podcastItems {
 			decoratePodcastItem(&podcastItems[i])
 		}
@@ -284,7 +548,9 @@ func GetAllPodcastItems(c *gin.Context) {
 		controllerLogger.Warnw("failed to bind episode filter query", "error", err)
 	}
 	filter.VerifyPaginationValues()
+	filter.UserID = currentUserID(c)
 	if podcastItems, totalCount, err := db.GetPaginatedPodcastItemsNew(filter); err == nil {
+		applyUserEpisodeState(c, *podcastItems)
 		for i := range *podcastItems {
 			decoratePodcastItem(&(*podcastItems)[i])
 		}
@@ -313,6 +579,7 @@ func GetPodcastItemById(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
+		applyUserEpisodeStateToItem(c, &podcast)
 		decoratePodcastItem(&podcast)
 		c.JSON(200, podcast)
 	} else {
@@ -374,7 +641,7 @@ func GetPodcastItemFileById(c *gin.Context) {
 				c.Header("Content-Description", "File Transfer")
 				c.Header("Content-Transfer-Encoding", "binary")
 				c.Header("Content-Disposition", "attachment; filename="+path.Base(podcast.DownloadPath))
-				c.Header("Content-Type", GetFileContentType(podcast.DownloadPath))
+				c.Header("Content-Type", GetFileContentType(podcast))
 				c.File(podcast.DownloadPath)
 			} else {
 				c.Redirect(302, podcast.FileURL)
@@ -385,8 +652,13 @@ func GetPodcastItemFileById(c *gin.Context) {
 	}
 }
 
-func GetFileContentType(filePath string) string {
-	file, err := os.Open(filePath)
+// GetFileContentType returns the stored enclosure type of an episode, falling
+// back to its extension and then to sniffing the downloaded file.
+func GetFileContentType(item db.PodcastItem) string {
+	if contentType := service.EpisodeMediaType(item); contentType != "" {
+		return contentType
+	}
+	file, err := os.Open(item.DownloadPath)
 	if err != nil {
 		return "application/octet-stream"
 	}
@@ -402,7 +674,7 @@ func MarkPodcastItemAsUnplayed(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 
 	if c.ShouldBindUri(&searchByIdQuery) == nil {
-		service.SetPodcastItemPlayedStatus(searchByIdQuery.Id, false)
+		service.SetPodcastItemPlayedStatus(currentUserID(c), searchByIdQuery.Id, false)
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 	}
@@ -411,7 +683,7 @@ func MarkPodcastItemAsPlayed(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 
 	if c.ShouldBindUri(&searchByIdQuery) == nil {
-		service.SetPodcastItemPlayedStatus(searchByIdQuery.Id, true)
+		service.SetPodcastItemPlayedStatus(currentUserID(c), searchByIdQuery.Id, true)
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 	}
@@ -420,7 +692,7 @@ func BookmarkPodcastItem(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 
 	if c.ShouldBindUri(&searchByIdQuery) == nil {
-		service.SetPodcastItemBookmarkStatus(searchByIdQuery.Id, true)
+		service.SetPodcastItemBookmarkStatus(currentUserID(c), searchByIdQuery.Id, true)
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 	}
@@ -429,7 +701,7 @@ func UnbookmarkPodcastItem(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 
 	if c.ShouldBindUri(&searchByIdQuery) == nil {
-		service.SetPodcastItemBookmarkStatus(searchByIdQuery.Id, false)
+		service.SetPodcastItemBookmarkStatus(currentUserID(c), searchByIdQuery.Id, false)
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 	}
@@ -454,7 +726,18 @@ func PatchPodcastItemById(c *gin.Context) {
 			return
 		}
 
-		db.DB.Model(&podcast).Updates(input)
+		if input.Title != "" {
+			db.DB.Model(&podcast).Update("title", input.Title)
+		}
+		userID := currentUserID(c)
+		if input.IsPlayed != nil {
+			if err := service.SetPodcastItemPlayedStatus(userID, podcast.ID, *input.IsPlayed); err != nil {
+				controllerLogger.Errorw("failed to update played status", "podcast_item_id", podcast.ID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to update episode"})
+				return
+			}
+		}
+		applyUserEpisodeStateToItem(c, &podcast)
 		c.JSON(200, podcast)
 
 	} else {
@@ -544,20 +827,29 @@ func getBaseUrl(c *gin.Context) string {
 func createRss(items []db.PodcastItem, title, description, image string, c *gin.Context) model.RssPodcastData {
 	var rssItems []model.RssItem
 	url := getBaseUrl(c)
+	tokenQuery := feedTokenQuery(c)
 	for _, item := range items {
+		enclosureType := service.EpisodeMediaType(item)
+		if enclosureType == "" {
+			enclosureType = "audio/mpeg"
+		}
+		enclosureLength := item.FileSize
+		if enclosureLength <= 0 {
+			enclosureLength = item.EnclosureLength
+		}
 		rssItem := model.RssItem{
 			Title:       item.Title,
 			Description: item.Summary,
 			Summary:     item.Summary,
 			Image: model.RssItemImage{
 				Text: item.Title,
-				Href: fmt.Sprintf("%s/podcastitems/%s/image", url, item.ID),
+				Href: fmt.Sprintf("%s/podcastitems/%s/image%s", url, item.ID, tokenQuery),
 			},
 			EpisodeType: item.EpisodeType,
 			Enclosure: model.RssItemEnclosure{
-				URL:    fmt.Sprintf("%s/podcastitems/%s/file", url, item.ID),
-				Length: fmt.Sprint(item.FileSize),
-				Type:   "audio/mpeg",
+				URL:    fmt.Sprintf("%s/podcastitems/%s/file%s", url, item.ID, tokenQuery),
+				Length: fmt.Sprint(enclosureLength),
+				Type:   enclosureType,
 			},
 			PubDate: item.PubDate.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
 			Guid: model.RssItemGuid{
@@ -595,6 +887,22 @@ func createRss(items []db.PodcastItem, title, description, image string, c *gin.
 	}
 }
 
+// userRssItems applies the user's episode state and, with ?unplayed=true,
+// drops the episodes they have already played.
+func userRssItems(c *gin.Context, items []db.PodcastItem) []db.PodcastItem {
+	applyUserEpisodeState(c, items)
+	if unplayed, _ := strconv.ParseBool(c.Query("unplayed")); !unplayed {
+		return items
+	}
+	filtered := make([]db.PodcastItem, 0, len(items))
+	for _, item := range items {
+		if !item.IsPlayed {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
 func GetRssForPodcastById(c *gin.Context) {
 	var searchByIdQuery SearchByIdQuery
 	if c.ShouldBindUri(&searchByIdQuery) == nil {
@@ -605,7 +913,7 @@ func GetRssForPodcastById(c *gin.Context) {
 		}
 		var podIds []string
 		podIds = append(podIds, searchByIdQuery.Id)
-		items := *service.GetAllPodcastItemsByPodcastIds(podIds)
+		items := userRssItems(c, *service.GetAllPodcastItemsByPodcastIds(podIds))
 
 		description := podcast.Summary
 		title := podcast.Title
@@ -625,7 +933,7 @@ func GetRssForTagById(c *gin.Context) {
 		for _, pod := range tag.Podcasts {
 			podIds = append(podIds, pod.ID)
 		}
-		items := *service.GetAllPodcastItemsByPodcastIds(podIds)
+		items := userRssItems(c, *service.GetAllPodcastItemsByPodcastIds(podIds))
 
 		description := fmt.Sprintf("Playing episodes with tag : %s", tag.Label)
 		title := fmt.Sprintf(" %s | Briefcast", tag.Label)
@@ -649,8 +957,22 @@ func GetRss(c *gin.Context) {
 	title := "Briefcast"
 	description := "Pograb playlist"
 
-	c.XML(200, createRss(items, title, description, "", c))
+	c.XML(200, createRss(userRssItems(c, items), title, description, "", c))
+
+}
+
+// applyUserEpisodeState swaps in the requesting user's played, bookmark and
+// progress state for the items.
+func applyUserEpisodeState(c *gin.Context, items []db.PodcastItem) {
+	if err := service.ApplyUserEpisodeState(currentUserID(c), items); err != nil {
+		controllerLogger.Warnw("failed to load user episode state", "error", err)
+	}
+}
 
+func applyUserEpisodeStateToItem(c *gin.Context, item *db.PodcastItem) {
+	items := []db.PodcastItem{*item}
+	applyUserEpisodeState(c, items)
+	*item = items[0]
 }
 
 func decoratePodcastItem(item *db.PodcastItem) {
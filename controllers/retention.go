@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GetRetentionPreview reports what the retention job would delete right now
+// without touching any files.
+func GetRetentionPreview(c *gin.Context) {
+	plan, err := service.PreviewRetention()
+	if err != nil {
+		controllerLogger.Errorw("failed to build retention preview", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to build retention preview"})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
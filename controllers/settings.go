@@ -2,32 +2,64 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/service"
 	"github.com/gin-gonic/gin"
 )
 
-type RetentionSettingsResponse struct {
-	KeepAllEpisodes    bool `json:"keepAllEpisodes"`
-	KeepLatestEpisodes int  `json:"keepLatestEpisodes"`
-	DeleteAfterDays    int  `json:"deleteAfterDays"`
-	DeleteOnlyPlayed   bool `json:"deleteOnlyPlayed"`
+type SettingsResponse struct {
+	KeepAllEpisodes             bool    `json:"keepAllEpisodes"`
+	KeepLatestEpisodes          int     `json:"keepLatestEpisodes"`
+	DeleteAfterDays             int     `json:"deleteAfterDays"`
+	DeleteOnlyPlayed            bool    `json:"deleteOnlyPlayed"`
+	MaxDiskGB                   float64 `json:"maxDiskGB"`
+	PlayedThresholdPercent      int     `json:"playedThresholdPercent"`
+	FeedParserBackend           string  `json:"feedParserBackend"`
+	BackupIncludeArtwork        bool    `json:"backupIncludeArtwork"`
+	DownloadMaxAttempts         int     `json:"downloadMaxAttempts"`
+	DownloadRetryBackoffMinutes int     `json:"downloadRetryBackoffMinutes"`
+	DownloadWindowStart         string  `json:"downloadWindowStart"`
+	DownloadWindowEnd           string  `json:"downloadWindowEnd"`
+	DownloadMaxBytesPerSecond   int64   `json:"downloadMaxBytesPerSecond"`
+	DownloadSegments            int     `json:"downloadSegments"`
+	SegmentedDownloadMinMB      int     `json:"segmentedDownloadMinMB"`
+	WriteID3Tags                bool    `json:"writeID3Tags"`
+	ProcessingKeepOriginal      bool    `json:"processingKeepOriginal"`
+	LoudnessTargetLUFS          float64 `json:"loudnessTargetLUFS"`
+	EpisodePathTemplate         string  `json:"episodePathTemplate"`
 }
 
-type RetentionSettingsPatch struct {
-	KeepAllEpisodes    *bool `json:"keepAllEpisodes"`
-	KeepLatestEpisodes *int  `json:"keepLatestEpisodes"`
-	DeleteAfterDays    *int  `json:"deleteAfterDays"`
-	DeleteOnlyPlayed   *bool `json:"deleteOnlyPlayed"`
+type SettingsPatch struct {
+	KeepAllEpisodes             *bool    `json:"keepAllEpisodes"`
+	KeepLatestEpisodes          *int     `json:"keepLatestEpisodes"`
+	DeleteAfterDays             *int     `json:"deleteAfterDays"`
+	DeleteOnlyPlayed            *bool    `json:"deleteOnlyPlayed"`
+	MaxDiskGB                   *float64 `json:"maxDiskGB"`
+	PlayedThresholdPercent      *int     `json:"playedThresholdPercent"`
+	FeedParserBackend           *string  `json:"feedParserBackend"`
+	BackupIncludeArtwork        *bool    `json:"backupIncludeArtwork"`
+	DownloadMaxAttempts         *int     `json:"downloadMaxAttempts"`
+	DownloadRetryBackoffMinutes *int     `json:"downloadRetryBackoffMinutes"`
+	DownloadWindowStart         *string  `json:"downloadWindowStart"`
+	DownloadWindowEnd           *string  `json:"downloadWindowEnd"`
+	DownloadMaxBytesPerSecond   *int64   `json:"downloadMaxBytesPerSecond"`
+	DownloadSegments            *int     `json:"downloadSegments"`
+	SegmentedDownloadMinMB      *int     `json:"segmentedDownloadMinMB"`
+	WriteID3Tags                *bool    `json:"writeID3Tags"`
+	ProcessingKeepOriginal      *bool    `json:"processingKeepOriginal"`
+	LoudnessTargetLUFS          *float64 `json:"loudnessTargetLUFS"`
+	EpisodePathTemplate         *string  `json:"episodePathTemplate"`
 }
 
 func GetSettings(c *gin.Context) {
 	setting := db.GetOrCreateSetting()
-	c.JSON(http.StatusOK, retentionSettingsFromSetting(setting))
+	c.JSON(http.StatusOK, settingsResponseFromSetting(setting))
 }
 
 func PatchSettings(c *gin.Context) {
-	var patch RetentionSettingsPatch
+	var patch SettingsPatch
 	if err := c.ShouldBindJSON(&patch); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -54,20 +86,139 @@ func PatchSettings(c *gin.Context) {
 	if patch.DeleteOnlyPlayed != nil {
 		setting.RetentionDeleteOnlyPlayed = *patch.DeleteOnlyPlayed
 	}
+	if patch.MaxDiskGB != nil {
+		if *patch.MaxDiskGB < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "maxDiskGB must be 0 or greater"})
+			return
+		}
+		setting.RetentionMaxDiskGB = *patch.MaxDiskGB
+	}
+	if patch.PlayedThresholdPercent != nil {
+		if *patch.PlayedThresholdPercent < 0 || *patch.PlayedThresholdPercent > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "playedThresholdPercent must be between 0 and 100"})
+			return
+		}
+		setting.PlayedThresholdPercent = *patch.PlayedThresholdPercent
+	}
+	if patch.FeedParserBackend != nil {
+		backend := strings.ToLower(strings.TrimSpace(*patch.FeedParserBackend))
+		if backend != service.FeedParserBackendNative && backend != service.FeedParserBackendPython {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feedParserBackend must be native or python"})
+			return
+		}
+		setting.FeedParserBackend = backend
+	}
+	if patch.BackupIncludeArtwork != nil {
+		setting.BackupIncludeArtwork = *patch.BackupIncludeArtwork
+	}
+	if patch.DownloadMaxAttempts != nil {
+		if *patch.DownloadMaxAttempts < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadMaxAttempts must be 0 or greater"})
+			return
+		}
+		setting.DownloadMaxAttempts = *patch.DownloadMaxAttempts
+	}
+	if patch.DownloadRetryBackoffMinutes != nil {
+		if *patch.DownloadRetryBackoffMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadRetryBackoffMinutes must be 0 or greater"})
+			return
+		}
+		setting.DownloadRetryBackoffMinutes = *patch.DownloadRetryBackoffMinutes
+	}
+	if patch.DownloadWindowStart != nil || patch.DownloadWindowEnd != nil {
+		start, end := setting.DownloadWindowStart, setting.DownloadWindowEnd
+		if patch.DownloadWindowStart != nil {
+			start = strings.TrimSpace(*patch.DownloadWindowStart)
+		}
+		if patch.DownloadWindowEnd != nil {
+			end = strings.TrimSpace(*patch.DownloadWindowEnd)
+		}
+		_, startErr := service.ParseDownloadWindowTime(start)
+		_, endErr := service.ParseDownloadWindowTime(end)
+		if startErr != nil || endErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadWindowStart and downloadWindowEnd must use HH:MM"})
+			return
+		}
+		if (start == "") != (end == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadWindowStart and downloadWindowEnd must be set together"})
+			return
+		}
+		setting.DownloadWindowStart = start
+		setting.DownloadWindowEnd = end
+	}
+	if patch.DownloadMaxBytesPerSecond != nil {
+		if *patch.DownloadMaxBytesPerSecond < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadMaxBytesPerSecond must be 0 or greater"})
+			return
+		}
+		setting.DownloadMaxBytesPerSecond = *patch.DownloadMaxBytesPerSecond
+	}
+	if patch.DownloadSegments != nil {
+		if *patch.DownloadSegments < 1 || *patch.DownloadSegments > 16 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "downloadSegments must be between 1 and 16"})
+			return
+		}
+		setting.DownloadSegments = *patch.DownloadSegments
+	}
+	if patch.SegmentedDownloadMinMB != nil {
+		if *patch.SegmentedDownloadMinMB < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "segmentedDownloadMinMB must be 0 or greater"})
+			return
+		}
+		setting.SegmentedDownloadMinMB = *patch.SegmentedDownloadMinMB
+	}
+	if patch.WriteID3Tags != nil {
+		setting.WriteID3Tags = *patch.WriteID3Tags
+	}
+	if patch.ProcessingKeepOriginal != nil {
+		setting.ProcessingKeepOriginal = *patch.ProcessingKeepOriginal
+	}
+	if patch.LoudnessTargetLUFS != nil {
+		if err := service.ValidateLoudnessTarget(*patch.LoudnessTargetLUFS); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setting.LoudnessTargetLUFS = *patch.LoudnessTargetLUFS
+	}
+	if patch.EpisodePathTemplate != nil {
+		template := strings.Trim(strings.TrimSpace(*patch.EpisodePathTemplate), "/")
+		if err := service.ValidateNamingTemplate(template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setting.EpisodePathTemplate = template
+	}
 
 	if err := db.UpdateSettings(setting); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, retentionSettingsFromSetting(setting))
+	service.SetDownloadBandwidthLimit(setting.DownloadMaxBytesPerSecond)
+
+	c.JSON(http.StatusOK, settingsResponseFromSetting(setting))
 }
 
-func retentionSettingsFromSetting(setting *db.Setting) RetentionSettingsResponse {
-	return RetentionSettingsResponse{
-		KeepAllEpisodes:    setting.RetentionKeepAll,
-		KeepLatestEpisodes: setting.RetentionKeepLatest,
-		DeleteAfterDays:    setting.RetentionDeleteAfterDays,
-		DeleteOnlyPlayed:   setting.RetentionDeleteOnlyPlayed,
+func settingsResponseFromSetting(setting *db.Setting) SettingsResponse {
+	return SettingsResponse{
+		KeepAllEpisodes:             setting.RetentionKeepAll,
+		KeepLatestEpisodes:          setting.RetentionKeepLatest,
+		DeleteAfterDays:             setting.RetentionDeleteAfterDays,
+		DeleteOnlyPlayed:            setting.RetentionDeleteOnlyPlayed,
+		MaxDiskGB:                   setting.RetentionMaxDiskGB,
+		PlayedThresholdPercent:      setting.PlayedThresholdPercent,
+		FeedParserBackend:           service.NormalizeFeedParserBackend(setting.FeedParserBackend),
+		BackupIncludeArtwork:        setting.BackupIncludeArtwork,
+		DownloadMaxAttempts:         setting.DownloadMaxAttempts,
+		DownloadRetryBackoffMinutes: setting.DownloadRetryBackoffMinutes,
+		DownloadWindowStart:         setting.DownloadWindowStart,
+		DownloadWindowEnd:           setting.DownloadWindowEnd,
+		DownloadMaxBytesPerSecond:   setting.DownloadMaxBytesPerSecond,
+		DownloadSegments:            setting.DownloadSegments,
+		SegmentedDownloadMinMB:      setting.SegmentedDownloadMinMB,
+		WriteID3Tags:                setting.WriteID3Tags,
+		ProcessingKeepOriginal:      setting.ProcessingKeepOriginal,
+		LoudnessTargetLUFS:          setting.LoudnessTargetLUFS,
+		EpisodePathTemplate:         setting.EpisodePathTemplate,
 	}
 }
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+// feedRoutes are the endpoints podcast apps fetch directly. They accept an API
+// token in the query string and are the only ones open to feeds tokens.
+var feedRoutes = map[string]bool{
+	"/rss":                    true,
+	"/podcasts/:id/rss":       true,
+	"/podcasts/:id/image":     true,
+	"/tags/:id/rss":           true,
+	"/podcastitems/:id/file":  true,
+	"/podcastitems/:id/image": true,
+}
+
+// readRoutes are the GET endpoints that change nothing, the only ones open to
+// read tokens besides feedRoutes. GET endpoints such as
+// /podcastitems/:id/delete or /podcasts/:id/pause are left out on purpose.
+var readRoutes = map[string]bool{
+	"/assets/*filepath":            true,
+	"/podcasts":                    true,
+	"/podcasts/:id":                true,
+	"/podcasts/:id/items":          true,
+	"/podcasts/:id/retention":      true,
+	"/podcasts/:id/processing":     true,
+	"/podcasts/:id/events":         true,
+	"/podcastitems":                true,
+	"/podcastitems/:id":            true,
+	"/podcastitems/:id/progress":   true,
+	"/podcastitems/:id/chapters":   true,
+	"/podcastitems/:id/transcript": true,
+	"/downloads/queue":             true,
+	"/downloads/failed":            true,
+	"/tags":                        true,
+	"/tags/:id":                    true,
+	"/search":                      true,
+	"/search/local":                true,
+	"/retention/preview":           true,
+	"/settings":                    true,
+	"/opml":                        true,
+	"/auth/me":                     true,
+	"/tokens":                      true,
+	"/users":                       true,
+	"/hooks":                       true,
+	"/hooks/:id/deliveries":        true,
+	"/library/reorganize":          true,
+	"/library/scan":                true,
+}
+
+type AddAPITokenRequest struct {
+	Name  string `binding:"required" form:"name" json:"name"`
+	Scope string `form:"scope" json:"scope"`
+}
+
+// requestAPIToken returns the token from the bearer header or, on feed routes,
+// the token query parameter. fromQuery reports where it was found.
+func requestAPIToken(c *gin.Context, feedRoute bool) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):]), false
+	}
+	if feedRoute {
+		if token := c.Query("token"); token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// feedTokenQuery returns the query string that carries the request's API token
+// onto links in generated feeds, so apps can fetch the media they point to.
+func feedTokenQuery(c *gin.Context) string {
+	token := c.GetString(feedTokenContextKey)
+	if token == "" {
+		return ""
+	}
+	return "?token=" + token
+}
+
+func GetAPITokens(c *gin.Context) {
+	tokens, err := service.GetAPITokens(currentUserID(c))
+	if err != nil {
+		controllerLogger.Errorw("failed to load API tokens", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// AddAPIToken creates a token for the current user. The token itself is only
+// returned in this response.
+func AddAPIToken(c *gin.Context) {
+	var request AddAPITokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	secret, token, err := service.CreateAPIToken(currentUserID(c), request.Name, request.Scope)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPITokenScope) || errors.Is(err, service.ErrAPITokenNameRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to create API token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": secret, "apiToken": token})
+}
+
+func DeleteAPITokenById(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.DeleteAPIToken(currentUserID(c), searchByIdQuery.Id); err != nil {
+		if !errors.Is(err, service.ErrInvalidAPIToken) {
+			controllerLogger.Errorw("failed to delete API token", "token_id", searchByIdQuery.Id, "error", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
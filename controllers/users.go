@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userContextKey      = "currentUser"
+	feedTokenContextKey = "feedToken"
+	userSessionCookie   = "briefcast_session"
+)
+
+type LoginRequest struct {
+	Username string `binding:"required" form:"username" json:"username"`
+	Password string `binding:"required" form:"password" json:"password"`
+}
+
+type AddUserRequest struct {
+	Username string `binding:"required" form:"username" json:"username"`
+	Password string `binding:"required" form:"password" json:"password"`
+	IsAdmin  bool   `form:"isAdmin" json:"isAdmin"`
+}
+
+// RequireUser resolves the user behind a request from an API token, the
+// session cookie or basic auth credentials. Tokens are read from a bearer
+// header, or from the token query parameter on feed routes so podcast apps can
+// subscribe without custom headers. With authRequired unset, requests without
+// credentials run as the default user.
+func RequireUser(authRequired bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feedRoute := feedRoutes[c.FullPath()]
+		readRoute := readRoutes[c.FullPath()]
+		if secret, fromQuery := requestAPIToken(c, feedRoute); secret != "" {
+			user, token, err := service.AuthenticateAPIToken(secret)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			if !service.APITokenAllows(token, c.Request.Method, readRoute, feedRoute) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token scope does not allow this request"})
+				return
+			}
+			if fromQuery {
+				c.Set(feedTokenContextKey, secret)
+			}
+			setCurrentUser(c, user)
+			c.Next()
+			return
+		}
+
+		user, ok := authenticateRequest(c)
+		if !ok && authRequired {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if ok {
+			setCurrentUser(c, user)
+		}
+		c.Next()
+	}
+}
+
+func setCurrentUser(c *gin.Context, user db.User) {
+	c.Set(userContextKey, user)
+	c.Set(gin.AuthUserKey, user.Username)
+}
+
+// RequireAdmin rejects requests from users without the admin flag.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(c)
+		if err != nil || !user.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func authenticateRequest(c *gin.Context) (db.User, bool) {
+	if token, err := c.Cookie(userSessionCookie); err == nil && token != "" {
+		if user, err := service.GetSessionUser(token); err == nil {
+			return user, true
+		}
+	}
+	username, password, hasAuth := c.Request.BasicAuth()
+	if !hasAuth {
+		return db.User{}, false
+	}
+	user, err := service.AuthenticateUser(username, password)
+	if err != nil {
+		return db.User{}, false
+	}
+	return user, true
+}
+
+// currentUser returns the authenticated user, falling back to the default user
+// when authentication is disabled.
+func currentUser(c *gin.Context) (db.User, error) {
+	if value, ok := c.Get(userContextKey); ok {
+		if user, ok := value.(db.User); ok {
+			return user, nil
+		}
+	}
+	return service.GetDefaultUser()
+}
+
+func currentUserID(c *gin.Context) string {
+	user, err := currentUser(c)
+	if err != nil {
+		controllerLogger.Errorw("failed to resolve current user", "error", err)
+		return ""
+	}
+	return user.ID
+}
+
+func Login(c *gin.Context) {
+	var request LoginRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	user, err := service.AuthenticateUser(request.Username, request.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to authenticate user", "username", request.Username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to log in"})
+		return
+	}
+	token, session, err := service.CreateUserSession(user.ID)
+	if err != nil {
+		controllerLogger.Errorw("failed to create user session", "user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create session"})
+		return
+	}
+	maxAge := int(session.ExpiresAt.Sub(session.CreatedAt).Seconds())
+	c.SetCookie(userSessionCookie, token, maxAge, "/", "", false, true)
+	c.JSON(http.StatusOK, user)
+}
+
+func Logout(c *gin.Context) {
+	if token, err := c.Cookie(userSessionCookie); err == nil && token != "" {
+		if err := service.DeleteUserSession(token); err != nil {
+			controllerLogger.Warnw("failed to delete user session", "error", err)
+		}
+	}
+	c.SetCookie(userSessionCookie, "", -1, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func GetCurrentUser(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		controllerLogger.Errorw("failed to resolve current user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load user"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func GetAllUsers(c *gin.Context) {
+	users, err := service.GetAllUsers()
+	if err != nil {
+		controllerLogger.Errorw("failed to load users", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to load users"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func AddUser(c *gin.Context) {
+	var request AddUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := service.CreateUser(request.Username, request.Password, request.IsAdmin)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUser) || errors.Is(err, service.ErrUserExists) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Errorw("failed to create user", "username", request.Username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create user"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func DeleteUserById(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := service.DeleteUser(searchByIdQuery.Id); err != nil {
+		if errors.Is(err, service.ErrLastAdmin) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		controllerLogger.Warnw("failed to delete user", "user_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/ctaylor1/briefcast/internal/logging"
+	"github.com/ctaylor1/briefcast/service"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -14,6 +15,11 @@ type EnqueuePayload struct {
 	TagIds    []string `json:"tagIds"`
 }
 
+type PlaybackProgressPayload struct {
+	PodcastItemId string `json:"podcastItemId"`
+	service.PlaybackProgressUpdate
+}
+
 var wsupgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -21,6 +27,7 @@ var wsupgrader = websocket.Upgrader{
 
 var activePlayers = make(map[*websocket.Conn]string)
 var allConnections = make(map[*websocket.Conn]string)
+var connectionUsers = make(map[*websocket.Conn]string)
 
 var broadcast = make(chan Message) // broadcast channel
 
@@ -29,6 +36,7 @@ type Message struct {
 	MessageType string          `json:"messageType"`
 	Payload     string          `json:"payload"`
 	Connection  *websocket.Conn `json:"-"`
+	UserID      string          `json:"-"`
 }
 
 func Wshandler(c *gin.Context) {
@@ -39,6 +47,7 @@ func Wshandler(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
+	userID := currentUserID(c)
 	for {
 		var mess Message
 		err := conn.ReadJSON(&mess)
@@ -52,10 +61,13 @@ func Wshandler(c *gin.Context) {
 				}
 			}
 			delete(allConnections, conn)
+			delete(connectionUsers, conn)
 			break
 		}
 		mess.Connection = conn
+		mess.UserID = userID
 		allConnections[conn] = mess.Identifier
+		connectionUsers[conn] = userID
 		broadcast <- mess
 	}
 }
@@ -109,6 +121,31 @@ func HandleWebsocketMessages() {
 			} else {
 				logger.Errorw("enqueue payload decode failed", "identifier", msg.Identifier, "error", err)
 			}
+		case "PlaybackProgress":
+			var payload PlaybackProgressPayload
+			if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
+				logger.Errorw("playback progress payload decode failed", "identifier", msg.Identifier, "error", err)
+				continue
+			}
+			progress, err := service.SetPodcastItemProgress(msg.UserID, payload.PodcastItemId, payload.PlaybackProgressUpdate)
+			if err != nil {
+				logger.Warnw("playback progress update failed", "identifier", msg.Identifier, "podcast_item_id", payload.PodcastItemId, "error", err)
+				continue
+			}
+			payloadStr, err := json.Marshal(progress)
+			if err != nil {
+				continue
+			}
+			for connection := range allConnections {
+				if connection == msg.Connection || connectionUsers[connection] != msg.UserID {
+					continue
+				}
+				connection.WriteJSON(Message{
+					Identifier:  msg.Identifier,
+					MessageType: "PlaybackProgress",
+					Payload:     string(payloadStr),
+				})
+			}
 		case "Register":
 			var player *websocket.Conn
 			for connection, id := range activePlayers {
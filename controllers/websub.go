@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ctaylor1/briefcast/service"
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyWebSubSubscription answers a hub's subscribe, unsubscribe or denied
+// request by echoing hub.challenge. Hubs call it without credentials.
+func VerifyWebSubSubscription(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	challenge, err := service.VerifyWebSubChallenge(
+		searchByIdQuery.Id,
+		c.Query("hub.mode"),
+		c.Query("hub.topic"),
+		c.Query("hub.challenge"),
+		c.Query("hub.lease_seconds"),
+		c.Query("hub.reason"),
+	)
+	if err != nil {
+		controllerLogger.Warnw("websub verification refused", "podcast_id", searchByIdQuery.Id, "mode", c.Query("hub.mode"), "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown subscription"})
+		return
+	}
+	c.String(http.StatusOK, challenge)
+}
+
+// ReceiveWebSubPush accepts a hub's content distribution request. Pushes with
+// a bad signature are acknowledged but ignored, as WebSub recommends.
+func ReceiveWebSubPush(c *gin.Context) {
+	var searchByIdQuery SearchByIdQuery
+	if c.ShouldBindUri(&searchByIdQuery) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	err := service.HandleWebSubPush(searchByIdQuery.Id, c.GetHeader("X-Hub-Signature"), c.Request.Body)
+	if err != nil && !errors.Is(err, service.ErrWebSubSignature) {
+		controllerLogger.Warnw("websub push refused", "podcast_id", searchByIdQuery.Id, "error", err)
+		c.JSON(http.StatusGone, gin.H{"error": "Unknown subscription"})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PodcastTag is a row of the podcast_tags join table.
+type PodcastTag struct {
+	PodcastID string `json:"podcastId"`
+	TagID     string `json:"tagId"`
+}
+
+// LibrarySnapshot is the database state captured by logical backups.
+type LibrarySnapshot struct {
+	Podcasts     []Podcast
+	PodcastItems []PodcastItem
+	Tags         []Tag
+	PodcastTags  []PodcastTag
+	Setting      *Setting
+	// Users is nil when the snapshot leaves the existing accounts alone.
+	Users             []User
+	UserEpisodeStates []UserEpisodeState
+}
+
+const restoreBatchSize = 100
+
+// DriverName returns the name of the active gorm dialector.
+func DriverName() string {
+	return DB.Dialector.Name()
+}
+
+func LoadLibrarySnapshot() (LibrarySnapshot, error) {
+	var snapshot LibrarySnapshot
+	if err := DB.Order("created_at").Find(&snapshot.Podcasts).Error; err != nil {
+		return snapshot, err
+	}
+	if err := DB.Order("created_at").Find(&snapshot.PodcastItems).Error; err != nil {
+		return snapshot, err
+	}
+	if err := DB.Order("created_at").Find(&snapshot.Tags).Error; err != nil {
+		return snapshot, err
+	}
+	if err := DB.Table("podcast_tags").Select("podcast_id, tag_id").Find(&snapshot.PodcastTags).Error; err != nil {
+		return snapshot, err
+	}
+	if err := DB.Order("created_at").Find(&snapshot.Users).Error; err != nil {
+		return snapshot, err
+	}
+	if err := DB.Order("created_at").Find(&snapshot.UserEpisodeStates).Error; err != nil {
+		return snapshot, err
+	}
+	snapshot.Setting = GetOrCreateSetting()
+	return snapshot, nil
+}
+
+// ReplaceLibrary swaps the podcasts, episodes, tags and settings for the ones in
+// the snapshot inside a single transaction. Users and their episode state are
+// replaced too when the snapshot has users; otherwise only the state of
+// episodes that no longer exist is dropped. Queue entries, episode actions,
+// hook deliveries and podcast events left pointing at removed rows are
+// deleted with them.
+func ReplaceLibrary(snapshot LibrarySnapshot) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		global := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		// Skip hooks so Base.BeforeCreate keeps the IDs from the snapshot.
+		insert := tx.Session(&gorm.Session{SkipHooks: true})
+		if err := global.Exec("DELETE FROM podcast_tags").Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&PodcastItem{}, &Podcast{}, &Tag{}} {
+			if err := global.Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(snapshot.Podcasts) > 0 {
+			if err := insert.Omit(clause.Associations).CreateInBatches(&snapshot.Podcasts, restoreBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.PodcastItems) > 0 {
+			if err := insert.Omit(clause.Associations).CreateInBatches(&snapshot.PodcastItems, restoreBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.Tags) > 0 {
+			if err := insert.Omit(clause.Associations).CreateInBatches(&snapshot.Tags, restoreBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.PodcastTags) > 0 {
+			if err := insert.Table("podcast_tags").CreateInBatches(&snapshot.PodcastTags, restoreBatchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		if snapshot.Users != nil {
+			for _, model := range []interface{}{&UserSession{}, &UserEpisodeState{}, &User{}} {
+				if err := global.Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if len(snapshot.Users) > 0 {
+				if err := insert.CreateInBatches(&snapshot.Users, restoreBatchSize).Error; err != nil {
+					return err
+				}
+			}
+			if len(snapshot.UserEpisodeStates) > 0 {
+				if err := insert.CreateInBatches(&snapshot.UserEpisodeStates, restoreBatchSize).Error; err != nil {
+					return err
+				}
+			}
+			if err := global.Exec("DELETE FROM api_tokens WHERE user_id NOT IN (SELECT id FROM users)").Error; err != nil {
+				return err
+			}
+			if err := global.Exec("DELETE FROM episode_actions WHERE user_id NOT IN (SELECT id FROM users)").Error; err != nil {
+				return err
+			}
+		} else if err := global.Exec("DELETE FROM user_episode_states WHERE podcast_item_id NOT IN (SELECT id FROM podcast_items)").Error; err != nil {
+			return err
+		}
+		for _, query := range []string{
+			"DELETE FROM download_queue_entries WHERE podcast_item_id NOT IN (SELECT id FROM podcast_items)",
+			// Actions gPodder clients uploaded for episodes Briefcast never
+			// matched have no episode and are kept for syncing.
+			"DELETE FROM episode_actions WHERE podcast_item_id <> '' AND podcast_item_id NOT IN (SELECT id FROM podcast_items)",
+			"DELETE FROM hook_deliveries WHERE podcast_item_id <> '' AND podcast_item_id NOT IN (SELECT id FROM podcast_items)",
+			"DELETE FROM podcast_events WHERE podcast_id NOT IN (SELECT id FROM podcasts)",
+		} {
+			if err := global.Exec(query).Error; err != nil {
+				return err
+			}
+		}
+
+		if snapshot.Setting != nil {
+			if err := global.Delete(&Setting{}).Error; err != nil {
+				return err
+			}
+			// Create swaps zero values for column defaults (and writes them
+			// back into the struct), so insert a copy and then write every
+			// field again to keep restored false and 0 settings intact.
+			created := *snapshot.Setting
+			if err := insert.Create(&created).Error; err != nil {
+				return err
+			}
+			if err := insert.Model(&created).Select("*").Updates(snapshot.Setting).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
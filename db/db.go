@@ -55,7 +55,7 @@ func Init() (*gorm.DB, error) {
 
 // Migrate Database
 func Migrate() {
-	DB.AutoMigrate(&Podcast{}, &PodcastItem{}, &Setting{}, &Migration{}, &JobLock{}, &Tag{})
+	DB.AutoMigrate(&Podcast{}, &PodcastItem{}, &Setting{}, &Migration{}, &JobLock{}, &Tag{}, &GpodderDevice{}, &SubscriptionChange{}, &EpisodeAction{}, &User{}, &UserSession{}, &UserEpisodeState{}, &APIToken{}, &DownloadQueueEntry{}, &Hook{}, &HookDelivery{}, &PodcastEvent{})
 	RunMigrations()
 }
 
@@ -50,6 +50,10 @@ func getSortOrder(sorting model.EpisodeSort) string {
 		return "duration asc"
 	case model.DURATION_DESC:
 		return "duration desc"
+	case model.EPISODE_ASC:
+		return "season asc, episode_number asc, pub_date asc"
+	case model.EPISODE_DESC:
+		return "season desc, episode_number desc, pub_date desc"
 	default:
 		return "pub_date desc"
 	}
@@ -68,7 +72,7 @@ func GetPaginatedPodcastItemsNew(queryModel model.EpisodesFilter) (*[]PodcastIte
 	if queryModel.IsPlayed != nil {
 		isPlayed, err := strconv.ParseBool(*queryModel.IsPlayed)
 		if err == nil {
-			query = applyPlayedStatusFilter(query, &isPlayed)
+			query = applyPlayedStatusFilter(query, &isPlayed, queryModel.UserID)
 		}
 	}
 
@@ -84,6 +88,35 @@ func GetPaginatedPodcastItemsNew(queryModel model.EpisodesFilter) (*[]PodcastIte
 		query = query.Where("podcast_id in ?", queryModel.PodcastIds)
 	}
 
+	if queryModel.Season != nil {
+		query = query.Where("season = ?", *queryModel.Season)
+	}
+	if person := strings.TrimSpace(queryModel.Person); person != "" {
+		query = applyPodcastingTextFilter(query, "persons_json", person)
+	}
+	if location := strings.TrimSpace(queryModel.Location); location != "" {
+		query = applyPodcastingTextFilter(query, "location_json", location)
+	}
+	if queryModel.HasSoundbites != nil {
+		if hasSoundbites, err := strconv.ParseBool(*queryModel.HasSoundbites); err == nil {
+			if hasSoundbites {
+				query = query.Where("coalesce(soundbites_json, '') != ''")
+			} else {
+				query = query.Where("coalesce(soundbites_json, '') = ''")
+			}
+		}
+	}
+	if queryModel.HasFunding != nil {
+		if hasFunding, err := strconv.ParseBool(*queryModel.HasFunding); err == nil {
+			funded := "select id from podcasts where coalesce(funding_json, '') != ''"
+			if hasFunding {
+				query = query.Where("podcast_id in (" + funded + ")")
+			} else {
+				query = query.Where("podcast_id not in (" + funded + ")")
+			}
+		}
+	}
+
 	totalsQuery := query.Order(getSortOrder(queryModel.Sorting)).Find(&podcasts)
 	totalsQuery.Count(&total)
 
@@ -94,7 +127,7 @@ func GetPaginatedPodcastItemsNew(queryModel model.EpisodesFilter) (*[]PodcastIte
 func GetPaginatedPodcastItems(page int, count int, downloadedOnly *bool, playedOnly *bool, fromDate time.Time, podcasts *[]PodcastItem, total *int64) error {
 	query := podcastItemsWithPodcast(DB)
 	query = applyDownloadStatusFilter(query, downloadedOnly)
-	query = applyPlayedStatusFilter(query, playedOnly)
+	query = applyPlayedStatusFilter(query, playedOnly, "")
 	if (fromDate != time.Time{}) {
 		query = query.Where("pub_date>=?", fromDate)
 	}
@@ -180,6 +213,166 @@ func UpdateLastEpisodeDateForPodcast(podcastId string, lastEpisode time.Time) er
 	return result.Error
 }
 
+func UpdatePodcastRetention(podcastId string, keepAll bool, keepLatest *int, deleteAfterDays *int, deleteOnlyPlayed *bool, maxDiskGB *float64) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"retention_keep_all":           keepAll,
+		"retention_keep_latest":        keepLatest,
+		"retention_delete_after_days":  deleteAfterDays,
+		"retention_delete_only_played": deleteOnlyPlayed,
+		"retention_max_disk_gb":        maxDiskGB,
+	})
+	return result.Error
+}
+
+func UpdatePodcastProcessing(podcastId string, loudnorm bool, stripSilence bool, codec string, bitrateKbps int) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"process_loudnorm":      loudnorm,
+		"process_strip_silence": stripSilence,
+		"process_codec":         codec,
+		"process_bitrate_kbps":  bitrateKbps,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func UpdatePodcastFeedValidators(podcastId string, etag string, lastModified string, bodyHash string) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"feed_e_tag":         etag,
+		"feed_last_modified": lastModified,
+		"feed_body_hash":     bodyHash,
+	})
+	return result.Error
+}
+
+func UpdatePodcastItemEnclosure(podcastItemId string, enclosureType string, enclosureLength int64) error {
+	result := DB.Model(PodcastItem{}).Where("id=?", podcastItemId).Updates(map[string]interface{}{
+		"enclosure_type":   enclosureType,
+		"enclosure_length": enclosureLength,
+	})
+	return result.Error
+}
+
+// UpdatePodcastRefreshSchedule stores the outcome of a feed refresh and when
+// the podcast is due again.
+func UpdatePodcastRefreshSchedule(podcastId string, lastRefreshAt time.Time, nextRefreshAt time.Time, failures int, lastError string) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"last_refresh_at":    lastRefreshAt,
+		"next_refresh_at":    nextRefreshAt,
+		"refresh_failures":   failures,
+		"last_refresh_error": lastError,
+	})
+	return result.Error
+}
+
+// UpdatePodcastRefreshInterval sets a fixed refresh interval, 0 for the
+// adaptive schedule, and when the podcast is due next.
+func UpdatePodcastRefreshInterval(podcastId string, minutes int, nextRefreshAt time.Time) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"refresh_interval_minutes": minutes,
+		"next_refresh_at":          nextRefreshAt,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// UpdatePodcastWebSubHub stores the hub and topic a feed advertises. A
+// different hub or topic needs a new subscription, so the lease and request
+// time are cleared.
+func UpdatePodcastWebSubHub(podcastId string, hub string, topic string) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"web_sub_hub":          hub,
+		"web_sub_topic":        topic,
+		"web_sub_requested_at": nil,
+		"web_sub_expires_at":   nil,
+	})
+	return result.Error
+}
+
+func UpdatePodcastWebSubRequest(podcastId string, secret string, requestedAt time.Time) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Updates(map[string]interface{}{
+		"web_sub_secret":       secret,
+		"web_sub_requested_at": requestedAt,
+	})
+	return result.Error
+}
+
+// UpdatePodcastWebSubLease stores when the verified subscription ends, or
+// nil when the hub denied or ended it.
+func UpdatePodcastWebSubLease(podcastId string, expiresAt *time.Time) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcastId).Update("web_sub_expires_at", expiresAt)
+	return result.Error
+}
+
+// GetRecentPubDates returns the publish dates of a podcast's newest
+// episodes, newest first.
+func GetRecentPubDates(podcastId string, limit int) ([]time.Time, error) {
+	var dates []time.Time
+	result := DB.Model(PodcastItem{}).Where("podcast_id=?", podcastId).Order("pub_date desc").Limit(limit).Pluck("pub_date", &dates)
+	return dates, result.Error
+}
+
+func UpdatePodcastItemFileURL(podcastItemId string, fileURL string) error {
+	result := DB.Model(PodcastItem{}).Where("id=?", podcastItemId).Update("file_url", fileURL)
+	return result.Error
+}
+
+func UpdatePodcastPodcastingFields(podcast *Podcast) error {
+	result := DB.Model(Podcast{}).Where("id=?", podcast.ID).Updates(map[string]interface{}{
+		"podcast_guid":  podcast.PodcastGUID,
+		"persons_json":  podcast.PersonsJSON,
+		"funding_json":  podcast.FundingJSON,
+		"location_json": podcast.LocationJSON,
+	})
+	return result.Error
+}
+
+func UpdatePodcastItemPodcastingFields(podcastItem *PodcastItem) error {
+	result := DB.Model(PodcastItem{}).Where("id=?", podcastItem.ID).Updates(map[string]interface{}{
+		"season":          podcastItem.Season,
+		"episode_number":  podcastItem.EpisodeNumber,
+		"persons_json":    podcastItem.PersonsJSON,
+		"soundbites_json": podcastItem.SoundbitesJSON,
+		"location_json":   podcastItem.LocationJSON,
+	})
+	return result.Error
+}
+
+// GetPodcastsWithoutPodcastingFields returns podcasts stored before the
+// Podcasting 2.0 columns were added, whose columns are still null.
+func GetPodcastsWithoutPodcastingFields() (*[]Podcast, error) {
+	var podcasts []Podcast
+	result := DB.Where("persons_json is null").Find(&podcasts)
+	return &podcasts, result.Error
+}
+
+// GetPodcastItemsWithoutPodcastingFields is GetPodcastsWithoutPodcastingFields
+// for episodes.
+func GetPodcastItemsWithoutPodcastingFields() (*[]PodcastItem, error) {
+	var podcastItems []PodcastItem
+	result := DB.Where("persons_json is null").Find(&podcastItems)
+	return &podcastItems, result.Error
+}
+
+// UpdatePodcastItemPaths stores the new file locations of moved episodes in
+// one transaction.
+func UpdatePodcastItemPaths(items []PodcastItem) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := tx.Model(&PodcastItem{}).Where("id=?", item.ID).Updates(map[string]interface{}{
+				"download_path": item.DownloadPath,
+				"original_path": item.OriginalPath,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func UpdatePodcastItemFileSize(podcastItemId string, size int64) error {
 	result := DB.Model(PodcastItem{}).Where("id=?", podcastItemId).Update("file_size", size)
 	return result.Error
@@ -192,9 +385,16 @@ func GetAllPodcastItemsWithoutImage() (*[]PodcastItem, error) {
 	return &podcastItems, result.Error
 }
 
+// GetAllPodcastItemsToBeDownloaded returns the queued episodes in queue order,
+// leaving out failed ones whose next retry is still in the future.
 func GetAllPodcastItemsToBeDownloaded() (*[]PodcastItem, error) {
 	var podcastItems []PodcastItem
-	result := podcastItemsWithAssociations(DB).Where("download_status=?", NotDownloaded).Find(&podcastItems)
+	result := podcastItemsWithAssociations(DB).
+		Joins(downloadQueueJoin).
+		Where("podcast_items.download_status=?", NotDownloaded).
+		Where("podcast_items.next_download_attempt is null or podcast_items.next_download_attempt<=?", time.Now().UTC()).
+		Order(downloadQueueOrder).
+		Find(&podcastItems)
 	//fmt.Println("To be downloaded : " + string(len(podcastItems)))
 	return &podcastItems, result.Error
 }
@@ -218,6 +418,22 @@ func GetPodcastItemsForWhisperx(statuses []string, limit int) (*[]PodcastItem, e
 	return &podcastItems, result.Error
 }
 
+// GetPodcastItemsForProcessing returns downloaded episodes whose audio
+// processing is in one of the given statuses, oldest download first.
+func GetPodcastItemsForProcessing(statuses []string, limit int) (*[]PodcastItem, error) {
+	var podcastItems []PodcastItem
+	query := DB.Preload("Podcast").
+		Where("download_status=?", Downloaded).
+		Where("processing_status IN ?", statuses).
+		Where("download_path <> ''").
+		Order("download_date asc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&podcastItems)
+	return &podcastItems, result.Error
+}
+
 func GetPodcastItemsByDownloadStatuses(statuses []DownloadStatus, limit int) ([]PodcastItem, error) {
 	var podcastItems []PodcastItem
 	query := podcastItemsWithAssociations(DB).
@@ -230,6 +446,27 @@ func GetPodcastItemsByDownloadStatuses(statuses []DownloadStatus, limit int) ([]
 	return podcastItems, result.Error
 }
 
+// GetFailedPodcastItems returns episodes that gave up downloading and the
+// queued ones waiting for a retry, most recent failure first.
+func GetFailedPodcastItems(limit int) ([]PodcastItem, error) {
+	var podcastItems []PodcastItem
+	query := podcastItemsWithAssociations(DB).
+		Where("download_status=? or (download_status=? and download_attempts>0)", Failed, NotDownloaded).
+		Order("last_download_attempt desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&podcastItems)
+	return podcastItems, result.Error
+}
+
+func UpdatePodcastItemChecksum(podcastItemId string, sha256 string, checkedAt time.Time) error {
+	return DB.Model(&PodcastItem{}).Where("id=?", podcastItemId).Updates(map[string]interface{}{
+		"file_sha256":          sha256,
+		"integrity_checked_at": checkedAt,
+	}).Error
+}
+
 func UpdatePodcastItemDownloadProgress(podcastItemId string, downloadedBytes int64, totalBytes int64) error {
 	updates := map[string]interface{}{
 		"downloaded_bytes": downloadedBytes,
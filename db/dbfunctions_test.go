@@ -455,3 +455,81 @@ func TestSettingsLocksTagsAndSearchHelpers(t *testing.T) {
 		t.Fatalf("DeletePodcastById failed: %v", err)
 	}
 }
+
+func TestPodcastingFieldsFiltersAndSorting(t *testing.T) {
+	setupDBForTest(t)
+
+	funded := newPodcast(t, "Funded", "https://example.com/funded.xml")
+	funded.PodcastGUID = "917393e3-1b1e-5cef-ace4-edaa54e1f810"
+	funded.PersonsJSON = `[{"name":"Jane Host","role":"host"}]`
+	funded.FundingJSON = `[{"url":"https://example.com/donate","title":"Support us"}]`
+	if err := UpdatePodcastPodcastingFields(&funded); err != nil {
+		t.Fatalf("UpdatePodcastPodcastingFields failed: %v", err)
+	}
+	other := newPodcast(t, "Other", "https://example.com/other.xml")
+
+	now := time.Now().UTC()
+	first := newPodcastItem(t, funded.ID, "f-1", "S1E2", Downloaded, now.Add(-3*time.Hour))
+	second := newPodcastItem(t, funded.ID, "f-2", "S2E1", Downloaded, now.Add(-2*time.Hour))
+	third := newPodcastItem(t, other.ID, "o-1", "S1E1", Downloaded, now.Add(-time.Hour))
+	first.Season, first.EpisodeNumber = 1, 2
+	first.SoundbitesJSON = `[{"startTime":10,"duration":30}]`
+	second.Season, second.EpisodeNumber = 2, 1
+	second.LocationJSON = `{"name":"Austin, TX"}`
+	third.Season, third.EpisodeNumber = 1, 1
+	third.PersonsJSON = `[{"name":"Guest Person","role":"guest"}]`
+	for _, item := range []*PodcastItem{&first, &second, &third} {
+		if err := UpdatePodcastItemPodcastingFields(item); err != nil {
+			t.Fatalf("UpdatePodcastItemPodcastingFields failed: %v", err)
+		}
+	}
+
+	titles := func(filter model.EpisodesFilter) []string {
+		t.Helper()
+		filter.VerifyPaginationValues()
+		items, _, err := GetPaginatedPodcastItemsNew(filter)
+		if err != nil {
+			t.Fatalf("GetPaginatedPodcastItemsNew failed: %v", err)
+		}
+		var result []string
+		for _, item := range *items {
+			result = append(result, item.Title)
+		}
+		return result
+	}
+
+	if got := titles(model.EpisodesFilter{Sorting: model.EPISODE_ASC}); strings.Join(got, ",") != "S1E1,S1E2,S2E1" {
+		t.Fatalf("unexpected episode order %v", got)
+	}
+	season := 1
+	if got := titles(model.EpisodesFilter{Season: &season, Sorting: model.EPISODE_DESC}); strings.Join(got, ",") != "S1E2,S1E1" {
+		t.Fatalf("unexpected season filter %v", got)
+	}
+	if got := titles(model.EpisodesFilter{Person: "jane", Sorting: model.EPISODE_ASC}); strings.Join(got, ",") != "S1E2,S2E1" {
+		t.Fatalf("expected podcast credits to apply to its episodes, got %v", got)
+	}
+	if got := titles(model.EpisodesFilter{Person: "guest"}); strings.Join(got, ",") != "S1E1" {
+		t.Fatalf("unexpected person filter %v", got)
+	}
+	if got := titles(model.EpisodesFilter{Location: "austin"}); strings.Join(got, ",") != "S2E1" {
+		t.Fatalf("unexpected location filter %v", got)
+	}
+	yes, no := "true", "false"
+	if got := titles(model.EpisodesFilter{HasSoundbites: &yes}); strings.Join(got, ",") != "S1E2" {
+		t.Fatalf("unexpected soundbite filter %v", got)
+	}
+	if got := titles(model.EpisodesFilter{HasFunding: &no}); strings.Join(got, ",") != "S1E1" {
+		t.Fatalf("unexpected funding filter %v", got)
+	}
+
+	var loaded PodcastItem
+	if err := GetPodcastItemById(first.ID, &loaded); err != nil {
+		t.Fatalf("GetPodcastItemById failed: %v", err)
+	}
+	if len(loaded.Soundbites) != 1 || loaded.Soundbites[0].Duration != 30 {
+		t.Fatalf("expected soundbites to be decoded, got %+v", loaded.Soundbites)
+	}
+	if len(loaded.Podcast.Funding) != 1 || len(loaded.Podcast.Persons) != 1 || loaded.Podcast.PodcastGUID == "" {
+		t.Fatalf("expected podcast fields to be decoded, got %+v", loaded.Podcast)
+	}
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	downloadQueueJoin  = "left join download_queue_entries on download_queue_entries.podcast_item_id = podcast_items.id"
+	downloadQueueOrder = "download_queue_entries.priority desc, download_queue_entries.position asc, podcast_items.pub_date desc"
+)
+
+// QueuedDownloadStatuses are the statuses of episodes that hold a place in the
+// download queue.
+var QueuedDownloadStatuses = []DownloadStatus{NotDownloaded, Downloading, Paused}
+
+// SyncDownloadQueue gives every queued episode a queue entry and removes the
+// entries of episodes that left the queue. New entries go to the bottom with
+// their podcast's download priority, newest episodes first.
+func SyncDownloadQueue() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("podcast_item_id not in (select id from podcast_items where download_status in ?)", QueuedDownloadStatuses).
+			Delete(&DownloadQueueEntry{}).Error; err != nil {
+			return err
+		}
+
+		var missing []PodcastItem
+		if err := tx.Preload("Podcast").
+			Where("download_status in ?", QueuedDownloadStatuses).
+			Where("id not in (select podcast_item_id from download_queue_entries)").
+			Order("pub_date desc").
+			Find(&missing).Error; err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		var position int
+		if err := tx.Model(&DownloadQueueEntry{}).Select("coalesce(max(position), 0)").Scan(&position).Error; err != nil {
+			return err
+		}
+		entries := make([]DownloadQueueEntry, 0, len(missing))
+		for _, item := range missing {
+			position++
+			entries = append(entries, DownloadQueueEntry{
+				PodcastItemID: item.ID,
+				Position:      position,
+				Priority:      item.Podcast.DownloadPriority,
+			})
+		}
+		return tx.Create(&entries).Error
+	})
+}
+
+// GetDownloadQueueItems returns the episodes in the download queue in the order
+// they will be downloaded.
+func GetDownloadQueueItems(limit int) ([]PodcastItem, error) {
+	var podcastItems []PodcastItem
+	query := podcastItemsWithAssociations(DB).
+		Joins(downloadQueueJoin).
+		Where("podcast_items.download_status in ?", QueuedDownloadStatuses).
+		Order(downloadQueueOrder)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&podcastItems)
+	return podcastItems, result.Error
+}
+
+func GetDownloadQueueEntries() ([]DownloadQueueEntry, error) {
+	var entries []DownloadQueueEntry
+	result := DB.Order("priority desc, position asc").Find(&entries)
+	return entries, result.Error
+}
+
+func GetDownloadQueueEntryByPodcastItemId(podcastItemId string, entry *DownloadQueueEntry) error {
+	result := DB.Where("podcast_item_id=?", podcastItemId).First(entry)
+	return result.Error
+}
+
+// UpdateDownloadQueueEntries saves the position and priority of the given
+// entries in one transaction.
+func UpdateDownloadQueueEntries(entries []DownloadQueueEntry) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			if err := tx.Model(&DownloadQueueEntry{}).Where("id=?", entry.ID).Updates(map[string]interface{}{
+				"position": entry.Position,
+				"priority": entry.Priority,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdatePodcastDownloadPriority sets the podcast's default priority and applies
+// it to its episodes already in the queue.
+func UpdatePodcastDownloadPriority(podcastId string, priority int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Podcast{}).Where("id=?", podcastId).Update("download_priority", priority)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&DownloadQueueEntry{}).
+			Where("podcast_item_id in (select id from podcast_items where podcast_id=?)", podcastId).
+			Update("priority", priority).Error
+	})
+}
+
+func SetDownloadsPaused(paused bool) error {
+	setting := GetOrCreateSetting()
+	return DB.Model(setting).Update("downloads_paused", paused).Error
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetAllGpodderDevices() (*[]GpodderDevice, error) {
+	var devices []GpodderDevice
+	result := DB.Order("created_at").Find(&devices)
+	return &devices, result.Error
+}
+
+func SaveGpodderDevice(deviceID string, caption string, deviceType string) (*GpodderDevice, error) {
+	var device GpodderDevice
+	result := DB.Where("device_id=?", deviceID).First(&device)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+	device.DeviceID = deviceID
+	if caption != "" {
+		device.Caption = caption
+	}
+	if deviceType != "" {
+		device.Type = deviceType
+	}
+	if device.ID == "" {
+		return &device, DB.Create(&device).Error
+	}
+	return &device, DB.Save(&device).Error
+}
+
+func CreateSubscriptionChange(url string, action string) error {
+	return DB.Create(&SubscriptionChange{URL: url, Action: action}).Error
+}
+
+func GetSubscriptionChangesSince(since time.Time) (*[]SubscriptionChange, error) {
+	var changes []SubscriptionChange
+	result := DB.Where("created_at>=?", since).Order("created_at asc").Find(&changes)
+	return &changes, result.Error
+}
+
+func CreateEpisodeAction(action *EpisodeAction) error {
+	return DB.Create(action).Error
+}
+
+func GetEpisodeActionsSince(userID string, since time.Time, podcastURL string, device string) (*[]EpisodeAction, error) {
+	var actions []EpisodeAction
+	query := DB.Where("user_id=? and created_at>=?", userID, since)
+	if podcastURL != "" {
+		query = query.Where("podcast_url=?", podcastURL)
+	}
+	if device != "" {
+		query = query.Where("device=?", device)
+	}
+	result := query.Order("created_at asc").Find(&actions)
+	return &actions, result.Error
+}
+
+func GetPodcastItemByPodcastIdAndFileURL(podcastId string, fileURL string, podcastItem *PodcastItem) error {
+	result := podcastItemsWithAssociations(DB).Where(&PodcastItem{PodcastID: podcastId, FileURL: fileURL}).First(&podcastItem)
+	return result.Error
+}
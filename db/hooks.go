@@ -0,0 +1,68 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+func GetAllHooks() (*[]Hook, error) {
+	var hooks []Hook
+	result := DB.Order("created_at").Find(&hooks)
+	return &hooks, result.Error
+}
+
+func GetEnabledHooks() (*[]Hook, error) {
+	var hooks []Hook
+	result := DB.Where("enabled=?", true).Order("created_at").Find(&hooks)
+	return &hooks, result.Error
+}
+
+func GetHookById(id string, hook *Hook) error {
+	result := DB.Where("id=?", id).First(hook)
+	return result.Error
+}
+
+func CreateHook(hook *Hook) error {
+	return DB.Create(hook).Error
+}
+
+func UpdateHook(hook *Hook) error {
+	return DB.Save(hook).Error
+}
+
+// DeleteHookById removes a hook together with its delivery log.
+func DeleteHookById(id string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("hook_id=?", id).Delete(&HookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id=?", id).Delete(&Hook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func CreateHookDelivery(delivery *HookDelivery) error {
+	return DB.Create(delivery).Error
+}
+
+func GetHookDeliveries(hookID string, limit int) (*[]HookDelivery, error) {
+	var deliveries []HookDelivery
+	query := DB.Where("hook_id=?", hookID).Order("created_at desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&deliveries)
+	return &deliveries, result.Error
+}
+
+// PruneHookDeliveries keeps only the newest deliveries of a hook.
+func PruneHookDeliveries(hookID string, keep int) error {
+	return DB.Where("hook_id=?", hookID).
+		Where("id not in (?)", DB.Model(&HookDelivery{}).Select("id").Where("hook_id=?", hookID).Order("created_at desc").Limit(keep)).
+		Delete(&HookDelivery{}).Error
+}
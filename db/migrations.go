@@ -60,6 +60,294 @@ var migrations = []localMigration{
 		Name:  "2026_02_17_01_01_BackfillAutoSkipSponsorChapters",
 		Query: "update podcasts set auto_skip_sponsor_chapters = false where auto_skip_sponsor_chapters is null",
 	},
+	{
+		Name:  "2026_10_16_01_00_AddPlaybackPositionPodcastItems",
+		Query: "alter table podcast_items add column if not exists playback_position integer default 0",
+	},
+	{
+		Name:  "2026_10_16_01_01_AddPlaybackCompletionPodcastItems",
+		Query: "alter table podcast_items add column if not exists playback_completion real default 0",
+	},
+	{
+		Name:  "2026_10_16_01_02_AddPlayedThresholdPercentSettings",
+		Query: "alter table settings add column if not exists played_threshold_percent integer default 95",
+	},
+	{
+		Name:  "2026_10_16_01_03_BackfillPlayedThresholdPercent",
+		Query: "update settings set played_threshold_percent = 95 where played_threshold_percent is null",
+	},
+	{
+		Name:  "2026_10_16_02_00_AddFeedETagPodcasts",
+		Query: "alter table podcasts add column if not exists feed_e_tag text default ''",
+	},
+	{
+		Name:  "2026_10_16_02_01_AddFeedLastModifiedPodcasts",
+		Query: "alter table podcasts add column if not exists feed_last_modified text default ''",
+	},
+	{
+		Name:  "2026_10_16_02_02_AddFeedBodyHashPodcasts",
+		Query: "alter table podcasts add column if not exists feed_body_hash text default ''",
+	},
+	{
+		Name:  "2026_10_16_03_00_AddFeedParserBackendSettings",
+		Query: "alter table settings add column if not exists feed_parser_backend text default 'native'",
+	},
+	{
+		Name:  "2026_10_16_03_01_BackfillFeedParserBackend",
+		Query: "update settings set feed_parser_backend = 'native' where feed_parser_backend is null or feed_parser_backend = ''",
+	},
+	{
+		Name:  "2026_10_16_04_00_AddPodcastRetentionKeepLatest",
+		Query: "alter table podcasts add column if not exists retention_keep_latest integer",
+	},
+	{
+		Name:  "2026_10_16_04_01_AddPodcastRetentionDeleteAfterDays",
+		Query: "alter table podcasts add column if not exists retention_delete_after_days integer",
+	},
+	{
+		Name:  "2026_10_16_04_02_AddPodcastRetentionDeleteOnlyPlayed",
+		Query: "alter table podcasts add column if not exists retention_delete_only_played boolean",
+	},
+	{
+		Name:  "2026_10_16_05_00_AddSettingsRetentionMaxDiskGB",
+		Query: "alter table settings add column if not exists retention_max_disk_gb double precision default 0",
+	},
+	{
+		Name:  "2026_10_16_05_01_BackfillSettingsRetentionMaxDiskGB",
+		Query: "update settings set retention_max_disk_gb = 0 where retention_max_disk_gb is null",
+	},
+	{
+		Name:  "2026_10_16_05_02_AddPodcastRetentionMaxDiskGB",
+		Query: "alter table podcasts add column if not exists retention_max_disk_gb double precision",
+	},
+	{
+		Name:  "2026_10_16_06_00_AddSettingsBackupIncludeArtwork",
+		Query: "alter table settings add column if not exists backup_include_artwork boolean default false",
+	},
+	{
+		Name:  "2026_10_16_07_00_AddDownloadAttemptsPodcastItems",
+		Query: "alter table podcast_items add column if not exists download_attempts integer default 0",
+	},
+	{
+		Name:  "2026_10_16_07_01_AddLastDownloadErrorPodcastItems",
+		Query: "alter table podcast_items add column if not exists last_download_error text default ''",
+	},
+	{
+		Name:  "2026_10_16_07_02_AddSettingsDownloadMaxAttempts",
+		Query: "alter table settings add column if not exists download_max_attempts integer default 5",
+	},
+	{
+		Name:  "2026_10_16_07_03_BackfillSettingsDownloadMaxAttempts",
+		Query: "update settings set download_max_attempts = 5 where download_max_attempts is null",
+	},
+	{
+		Name:  "2026_10_16_07_04_AddSettingsDownloadRetryBackoffMinutes",
+		Query: "alter table settings add column if not exists download_retry_backoff_minutes integer default 15",
+	},
+	{
+		Name:  "2026_10_16_07_05_BackfillSettingsDownloadRetryBackoffMinutes",
+		Query: "update settings set download_retry_backoff_minutes = 15 where download_retry_backoff_minutes is null",
+	},
+	{
+		Name:  "2026_10_16_08_00_AddSettingsDownloadWindowStart",
+		Query: "alter table settings add column if not exists download_window_start text default ''",
+	},
+	{
+		Name:  "2026_10_16_08_01_AddSettingsDownloadWindowEnd",
+		Query: "alter table settings add column if not exists download_window_end text default ''",
+	},
+	{
+		Name:  "2026_10_16_08_02_AddSettingsDownloadMaxBytesPerSecond",
+		Query: "alter table settings add column if not exists download_max_bytes_per_second bigint default 0",
+	},
+	{
+		Name:  "2026_10_16_08_03_BackfillSettingsDownloadMaxBytesPerSecond",
+		Query: "update settings set download_max_bytes_per_second = 0 where download_max_bytes_per_second is null",
+	},
+	{
+		Name:  "2026_10_16_09_00_AddFileSHA256PodcastItems",
+		Query: "alter table podcast_items add column if not exists file_sha256 text default ''",
+	},
+	{
+		Name:  "2026_10_16_10_00_AddPodcastDownloadPriority",
+		Query: "alter table podcasts add column if not exists download_priority integer default 0",
+	},
+	{
+		Name:  "2026_10_16_10_01_BackfillPodcastDownloadPriority",
+		Query: "update podcasts set download_priority = 0 where download_priority is null",
+	},
+	{
+		Name:  "2026_10_16_10_02_AddSettingsDownloadsPaused",
+		Query: "alter table settings add column if not exists downloads_paused boolean default false",
+	},
+	{
+		Name:  "2026_10_16_10_03_BackfillSettingsDownloadsPaused",
+		Query: "update settings set downloads_paused = false where downloads_paused is null",
+	},
+	{
+		Name:  "2026_10_16_11_00_AddSettingsDownloadSegments",
+		Query: "alter table settings add column if not exists download_segments integer default 1",
+	},
+	{
+		Name:  "2026_10_16_11_01_BackfillSettingsDownloadSegments",
+		Query: "update settings set download_segments = 1 where download_segments is null",
+	},
+	{
+		Name:  "2026_10_16_11_02_AddSettingsSegmentedDownloadMinMB",
+		Query: "alter table settings add column if not exists segmented_download_min_mb integer default 100",
+	},
+	{
+		Name:  "2026_10_16_11_03_BackfillSettingsSegmentedDownloadMinMB",
+		Query: "update settings set segmented_download_min_mb = 100 where segmented_download_min_mb is null",
+	},
+	{
+		Name:  "2026_10_16_12_00_AddSettingsWriteID3Tags",
+		Query: "alter table settings add column if not exists write_id3_tags boolean default false",
+	},
+	{
+		Name:  "2026_10_16_12_01_BackfillSettingsWriteID3Tags",
+		Query: "update settings set write_id3_tags = false where write_id3_tags is null",
+	},
+	{
+		Name:  "2026_10_16_13_00_AddPodcastProcessLoudnorm",
+		Query: "alter table podcasts add column if not exists process_loudnorm boolean default false",
+	},
+	{
+		Name:  "2026_10_16_13_01_BackfillPodcastProcessLoudnorm",
+		Query: "update podcasts set process_loudnorm = false where process_loudnorm is null",
+	},
+	{
+		Name:  "2026_10_16_13_02_AddPodcastProcessStripSilence",
+		Query: "alter table podcasts add column if not exists process_strip_silence boolean default false",
+	},
+	{
+		Name:  "2026_10_16_13_03_BackfillPodcastProcessStripSilence",
+		Query: "update podcasts set process_strip_silence = false where process_strip_silence is null",
+	},
+	{
+		Name:  "2026_10_16_13_04_AddPodcastProcessCodec",
+		Query: "alter table podcasts add column if not exists process_codec text default ''",
+	},
+	{
+		Name:  "2026_10_16_13_05_AddPodcastProcessBitrateKbps",
+		Query: "alter table podcasts add column if not exists process_bitrate_kbps integer default 0",
+	},
+	{
+		Name:  "2026_10_16_13_06_BackfillPodcastProcessBitrateKbps",
+		Query: "update podcasts set process_bitrate_kbps = 0 where process_bitrate_kbps is null",
+	},
+	{
+		Name:  "2026_10_16_13_07_AddProcessingStatusPodcastItems",
+		Query: "alter table podcast_items add column if not exists processing_status text default ''",
+	},
+	{
+		Name:  "2026_10_16_13_08_AddSettingsProcessingKeepOriginal",
+		Query: "alter table settings add column if not exists processing_keep_original boolean default false",
+	},
+	{
+		Name:  "2026_10_16_13_09_BackfillSettingsProcessingKeepOriginal",
+		Query: "update settings set processing_keep_original = false where processing_keep_original is null",
+	},
+	{
+		Name:  "2026_10_16_13_10_AddSettingsLoudnessTargetLUFS",
+		Query: "alter table settings add column if not exists loudness_target_lufs double precision default -16",
+	},
+	{
+		Name:  "2026_10_16_13_11_BackfillSettingsLoudnessTargetLUFS",
+		Query: "update settings set loudness_target_lufs = -16 where loudness_target_lufs is null",
+	},
+	{
+		Name:  "2026_10_16_14_00_AddPodcastItemsEnclosureType",
+		Query: "alter table podcast_items add column if not exists enclosure_type text",
+	},
+	{
+		Name:  "2026_10_16_14_01_AddPodcastItemsEnclosureLength",
+		Query: "alter table podcast_items add column if not exists enclosure_length bigint default 0",
+	},
+	{
+		Name:  "2026_10_16_14_02_BackfillPodcastItemsEnclosureLength",
+		Query: "update podcast_items set enclosure_length = 0 where enclosure_length is null",
+	},
+	{
+		Name:  "2026_10_16_15_00_AddSettingsEpisodePathTemplate",
+		Query: "alter table settings add column if not exists episode_path_template text",
+	},
+	{
+		Name:  "2026_10_16_16_00_AddPodcastItemsSeason",
+		Query: "alter table podcast_items add column if not exists season integer default 0",
+	},
+	{
+		Name:  "2026_10_16_16_01_BackfillPodcastItemsSeason",
+		Query: "update podcast_items set season = 0 where season is null",
+	},
+	{
+		Name:  "2026_10_16_16_02_AddPodcastItemsEpisodeNumber",
+		Query: "alter table podcast_items add column if not exists episode_number integer default 0",
+	},
+	{
+		Name:  "2026_10_16_16_03_BackfillPodcastItemsEpisodeNumber",
+		Query: "update podcast_items set episode_number = 0 where episode_number is null",
+	},
+	{
+		Name:  "2026_10_16_16_04_AddPodcastItemsPersonsJSON",
+		Query: "alter table podcast_items add column if not exists persons_json text",
+	},
+	{
+		Name:  "2026_10_16_16_05_AddPodcastItemsSoundbitesJSON",
+		Query: "alter table podcast_items add column if not exists soundbites_json text",
+	},
+	{
+		Name:  "2026_10_16_16_06_AddPodcastItemsLocationJSON",
+		Query: "alter table podcast_items add column if not exists location_json text",
+	},
+	{
+		Name:  "2026_10_16_16_07_AddPodcastsPodcastGUID",
+		Query: "alter table podcasts add column if not exists podcast_guid text",
+	},
+	{
+		Name:  "2026_10_16_16_08_AddPodcastsPersonsJSON",
+		Query: "alter table podcasts add column if not exists persons_json text",
+	},
+	{
+		Name:  "2026_10_16_16_09_AddPodcastsFundingJSON",
+		Query: "alter table podcasts add column if not exists funding_json text",
+	},
+	{
+		Name:  "2026_10_16_16_10_AddPodcastsLocationJSON",
+		Query: "alter table podcasts add column if not exists location_json text",
+	},
+	{
+		Name:  "2026_10_16_17_00_AddPodcastsRefreshIntervalMinutes",
+		Query: "alter table podcasts add column if not exists refresh_interval_minutes integer default 0",
+	},
+	{
+		Name:  "2026_10_16_17_01_BackfillPodcastsRefreshIntervalMinutes",
+		Query: "update podcasts set refresh_interval_minutes = 0 where refresh_interval_minutes is null",
+	},
+	{
+		Name:  "2026_10_16_17_02_AddPodcastsRefreshFailures",
+		Query: "alter table podcasts add column if not exists refresh_failures integer default 0",
+	},
+	{
+		Name:  "2026_10_16_17_03_BackfillPodcastsRefreshFailures",
+		Query: "update podcasts set refresh_failures = 0 where refresh_failures is null",
+	},
+	{
+		Name:  "2026_10_16_17_04_AddPodcastsLastRefreshError",
+		Query: "alter table podcasts add column if not exists last_refresh_error text",
+	},
+	{
+		Name:  "2026_10_16_18_00_AddPodcastsWebSubHub",
+		Query: "alter table podcasts add column if not exists web_sub_hub text",
+	},
+	{
+		Name:  "2026_10_16_18_01_AddPodcastsWebSubTopic",
+		Query: "alter table podcasts add column if not exists web_sub_topic text",
+	},
+	{
+		Name:  "2026_10_16_18_02_AddPodcastsWebSubSecret",
+		Query: "alter table podcasts add column if not exists web_sub_secret text",
+	},
 }
 
 var addColumnIfNotExistsRe = regexp.MustCompile(`(?i)alter\s+table\s+(\S+)\s+add\s+column\s+if\s+not\s+exists\s+(\S+)`)
@@ -1,7 +1,11 @@
 package db
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
+	"gorm.io/gorm"
 )
 
 // Podcast is
@@ -38,7 +42,60 @@ type Podcast struct {
 
 	RetentionKeepAll bool `gorm:"default:false"`
 
+	// Per-podcast retention overrides; nil inherits the global setting.
+	RetentionKeepLatest       *int
+	RetentionDeleteAfterDays  *int
+	RetentionDeleteOnlyPlayed *bool
+	RetentionMaxDiskGB        *float64
+
 	AutoSkipSponsorChapters bool `gorm:"default:false"`
+
+	// DownloadPriority is given to this podcast's episodes when they join the
+	// download queue; higher priorities download first.
+	DownloadPriority int `gorm:"default:0"`
+
+	// Audio processing run on downloaded episodes by the
+	// ProcessDownloadedAudio job. An empty ProcessCodec keeps the codec of the
+	// download; a ProcessBitrateKbps of 0 uses the encoder default.
+	ProcessLoudnorm     bool `gorm:"default:false"`
+	ProcessStripSilence bool `gorm:"default:false"`
+	ProcessCodec        string
+	ProcessBitrateKbps  int `gorm:"default:0"`
+
+	// Cache validators from the last successful feed fetch, used to send
+	// conditional requests and to skip re-parsing an unchanged feed.
+	FeedETag         string `json:"-"`
+	FeedLastModified string `json:"-"`
+	FeedBodyHash     string `json:"-"`
+
+	// The RefreshEpisodes job only refreshes podcasts whose NextRefreshAt has
+	// passed; nil means due. The next time follows the publish cadence unless
+	// RefreshIntervalMinutes fixes it, and backs off after RefreshFailures
+	// consecutive failed refreshes.
+	NextRefreshAt          *time.Time
+	LastRefreshAt          *time.Time
+	RefreshIntervalMinutes int `gorm:"default:0"`
+	RefreshFailures        int `gorm:"default:0"`
+	LastRefreshError       string
+
+	// WebSub push subscription. The hub and topic come from the feed's
+	// rel="hub" and rel="self" links; WebSubExpiresAt is set once the hub
+	// verifies the subscription and WebSubRequestedAt when it was last asked.
+	WebSubHub         string
+	WebSubTopic       string
+	WebSubSecret      string `json:"-"`
+	WebSubRequestedAt *time.Time
+	WebSubExpiresAt   *time.Time
+
+	// Podcasting 2.0 channel fields, refreshed with the feed. The JSON
+	// columns are decoded into Persons, Funding and Location when loaded.
+	PodcastGUID  string
+	PersonsJSON  string             `gorm:"type:text" json:"-"`
+	FundingJSON  string             `gorm:"type:text" json:"-"`
+	LocationJSON string             `gorm:"type:text" json:"-"`
+	Persons      []feedmeta.Person  `gorm:"-"`
+	Funding      []feedmeta.Funding `gorm:"-"`
+	Location     *feedmeta.Location `gorm:"-"`
 }
 
 // PodcastItem is
@@ -58,9 +115,29 @@ type PodcastItem struct {
 
 	FileURL string
 
+	// EnclosureType and EnclosureLength are the MIME type and size the feed
+	// declares for FileURL. EnclosureType follows the local file when
+	// processing transcodes it.
+	EnclosureType   string
+	EnclosureLength int64
+
 	GUID  string
 	Image string
 
+	// Season and EpisodeNumber come from itunes:season and itunes:episode or
+	// their podcast: equivalents; 0 when the feed gives none.
+	Season        int `gorm:"default:0"`
+	EpisodeNumber int `gorm:"default:0"`
+
+	// Podcasting 2.0 episode fields, decoded into Persons, Soundbites and
+	// Location when loaded.
+	PersonsJSON    string               `gorm:"type:text" json:"-"`
+	SoundbitesJSON string               `gorm:"type:text" json:"-"`
+	LocationJSON   string               `gorm:"type:text" json:"-"`
+	Persons        []feedmeta.Person    `gorm:"-"`
+	Soundbites     []feedmeta.Soundbite `gorm:"-"`
+	Location       *feedmeta.Location   `gorm:"-"`
+
 	ChaptersURL     string
 	ChaptersType    string
 	ChaptersJSON    string `gorm:"type:text" json:"-"`
@@ -71,9 +148,16 @@ type PodcastItem struct {
 	DownloadPath   string
 	DownloadStatus DownloadStatus `gorm:"default:0"`
 
+	// IsPlayed is stored as true once every user has played the episode; API
+	// responses replace it with the requesting user's own state.
 	IsPlayed bool `gorm:"default:false"`
 
-	BookmarkDate time.Time
+	// Listener state is kept per user in UserEpisodeState and copied onto the
+	// item for the requesting user.
+	BookmarkDate       time.Time `gorm:"-"`
+	PlaybackPosition   int       `gorm:"-"`
+	PlaybackCompletion float64   `gorm:"-"`
+	PlaybackUpdatedAt  time.Time `gorm:"-"`
 
 	LocalImage string
 
@@ -82,12 +166,56 @@ type PodcastItem struct {
 	DownloadedBytes    int64
 	DownloadTotalBytes int64
 
+	// Failed downloads are retried with exponential backoff until the
+	// DownloadMaxAttempts setting is reached, then the item becomes Failed.
+	DownloadAttempts    int `gorm:"default:0"`
+	LastDownloadError   string
+	LastDownloadAttempt time.Time
+	NextDownloadAttempt time.Time
+
+	// FileSHA256 is the checksum of the downloaded file, compared by the
+	// verification job at IntegrityCheckedAt.
+	FileSHA256         string
+	IntegrityCheckedAt time.Time
+
 	HasChapters   bool `gorm:"-"`
 	HasTranscript bool `gorm:"-"`
 
 	ItemMetadata     string `gorm:"type:text" json:"-"`
 	TranscriptJSON   string `gorm:"type:text" json:"-"`
 	TranscriptStatus string `gorm:"type:text"`
+
+	// ProcessingStatus tracks audio post-processing the way TranscriptStatus
+	// tracks transcription. OriginalPath is the unprocessed download, kept
+	// when the ProcessingKeepOriginal setting is on.
+	ProcessingStatus string
+	ProcessingError  string
+	OriginalPath     string
+}
+
+// AfterFind decodes the Podcasting 2.0 columns.
+func (podcast *Podcast) AfterFind(tx *gorm.DB) error {
+	decodeJSONColumn(podcast.PersonsJSON, &podcast.Persons)
+	decodeJSONColumn(podcast.FundingJSON, &podcast.Funding)
+	decodeJSONColumn(podcast.LocationJSON, &podcast.Location)
+	return nil
+}
+
+// AfterFind decodes the Podcasting 2.0 columns.
+func (podcastItem *PodcastItem) AfterFind(tx *gorm.DB) error {
+	decodeJSONColumn(podcastItem.PersonsJSON, &podcastItem.Persons)
+	decodeJSONColumn(podcastItem.SoundbitesJSON, &podcastItem.Soundbites)
+	decodeJSONColumn(podcastItem.LocationJSON, &podcastItem.Location)
+	return nil
+}
+
+// decodeJSONColumn fills target from a JSON text column, leaving it empty
+// when the column is empty or invalid.
+func decodeJSONColumn(raw string, target interface{}) {
+	if raw == "" {
+		return
+	}
+	_ = json.Unmarshal([]byte(raw), target)
 }
 
 type DownloadStatus int
@@ -98,6 +226,8 @@ const (
 	Downloaded
 	Deleted
 	Paused
+	// Failed items exhausted their download attempts and wait for a retry.
+	Failed
 )
 
 type Setting struct {
@@ -119,6 +249,51 @@ type Setting struct {
 	RetentionKeepLatest       int  `gorm:"default:0"`
 	RetentionDeleteAfterDays  int  `gorm:"default:0"`
 	RetentionDeleteOnlyPlayed bool `gorm:"default:true"`
+	// RetentionMaxDiskGB caps the space used by all downloads; 0 disables it.
+	RetentionMaxDiskGB float64 `gorm:"default:0"`
+
+	PlayedThresholdPercent int `gorm:"default:95"`
+
+	FeedParserBackend string `gorm:"default:native"`
+
+	BackupIncludeArtwork bool `gorm:"default:false"`
+
+	// DownloadMaxAttempts marks an episode Failed after that many failed
+	// downloads; 0 retries forever. Retries wait DownloadRetryBackoffMinutes,
+	// doubled after every further failure.
+	DownloadMaxAttempts         int `gorm:"default:5"`
+	DownloadRetryBackoffMinutes int `gorm:"default:15"`
+
+	// Scheduled downloads only start between DownloadWindowStart and
+	// DownloadWindowEnd ("HH:MM" server time, empty means any time).
+	// DownloadMaxBytesPerSecond caps the combined speed of all downloads; 0
+	// means unlimited.
+	DownloadWindowStart       string
+	DownloadWindowEnd         string
+	DownloadMaxBytesPerSecond int64 `gorm:"default:0"`
+
+	// Files of at least SegmentedDownloadMinMB are fetched as DownloadSegments
+	// parallel byte ranges when the host supports them; 1 disables it.
+	DownloadSegments       int `gorm:"default:1"`
+	SegmentedDownloadMinMB int `gorm:"default:100"`
+
+	// DownloadsPaused keeps a global download pause across restarts.
+	DownloadsPaused bool `gorm:"default:false"`
+
+	// WriteID3Tags rewrites the tags and chapters of downloaded MP3s from the
+	// feed.
+	WriteID3Tags bool `gorm:"default:false"`
+
+	// ProcessingKeepOriginal keeps the download next to the processed file
+	// instead of replacing it. Loudness normalization aims for
+	// LoudnessTargetLUFS integrated loudness.
+	ProcessingKeepOriginal bool    `gorm:"default:false"`
+	LoudnessTargetLUFS     float64 `gorm:"default:-16"`
+
+	// EpisodePathTemplate places new downloads, relative to DATA, using
+	// fields such as {podcast}/{date} {title}. Empty keeps the podcast folder
+	// and the AppendDateToFileName/AppendEpisodeNumberToFileName prefixes.
+	EpisodePathTemplate string
 }
 type Migration struct {
 	Base
@@ -140,6 +315,128 @@ type Tag struct {
 	Podcasts    []*Podcast `gorm:"many2many:podcast_tags;"`
 }
 
+// GpodderDevice is a client registered through the gPodder sync API.
+type GpodderDevice struct {
+	Base
+	DeviceID string `gorm:"uniqueIndex"`
+	Caption  string
+	Type     string
+}
+
+// SubscriptionChange records podcast additions and removals so gPodder clients
+// can pull the changes made since their last sync.
+type SubscriptionChange struct {
+	Base
+	URL    string `gorm:"index"`
+	Action string
+}
+
+// EpisodeAction is a gPodder episode action, either uploaded by a client or
+// recorded for playback happening inside Briefcast.
+type EpisodeAction struct {
+	Base
+	UserID        string `gorm:"index"`
+	PodcastItemID string `gorm:"index"`
+	PodcastURL    string
+	EpisodeURL    string
+	GUID          string
+	Device        string
+	Action        string
+	Timestamp     time.Time
+	Started       *int
+	Position      *int
+	Total         *int
+}
+
+// User is a listener account. Downloads are shared by every user while played,
+// bookmark and progress state is tracked per user.
+type User struct {
+	Base
+	Username     string `gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"`
+	IsAdmin      bool   `gorm:"default:false"`
+}
+
+// UserSession is a login session. Only the SHA-256 of the token is stored.
+type UserSession struct {
+	Base
+	UserID    string `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+}
+
+// APIToken is a personal access token for scripts and podcast apps. Only the
+// SHA-256 of the token is stored; Prefix identifies it in listings.
+type APIToken struct {
+	Base
+	UserID     string `gorm:"index"`
+	Name       string
+	Prefix     string
+	TokenHash  string `gorm:"uniqueIndex" json:"-"`
+	Scope      string
+	LastUsedAt time.Time
+}
+
+// UserEpisodeState holds one user's listening state for an episode.
+type UserEpisodeState struct {
+	Base
+	UserID             string `gorm:"uniqueIndex:idx_user_episode_state"`
+	PodcastItemID      string `gorm:"uniqueIndex:idx_user_episode_state;index"`
+	IsPlayed           bool   `gorm:"default:false"`
+	BookmarkDate       time.Time
+	PlaybackPosition   int     `gorm:"default:0"`
+	PlaybackCompletion float64 `gorm:"default:0"`
+	PlaybackUpdatedAt  time.Time
+}
+
+// DownloadQueueEntry orders an episode waiting to download. The queue runs by
+// priority, highest first, then by position.
+type DownloadQueueEntry struct {
+	Base
+	PodcastItemID string `gorm:"uniqueIndex"`
+	Position      int    `gorm:"index"`
+	Priority      int    `gorm:"default:0"`
+}
+
+// Hook runs a local command or calls a webhook when one of its Events (a
+// comma-separated list) happens.
+type Hook struct {
+	Base
+	Name           string
+	Kind           string
+	Target         string
+	Events         string
+	Secret         string `json:"-"`
+	TimeoutSeconds int    `gorm:"default:30"`
+	MaxAttempts    int    `gorm:"default:3"`
+	Enabled        bool
+}
+
+// HookDelivery logs one attempt to deliver an event to a hook.
+type HookDelivery struct {
+	Base
+	HookID        string `gorm:"index"`
+	Event         string
+	PodcastItemID string `gorm:"index"`
+	Attempt       int
+	Success       bool
+	StatusCode    int
+	Output        string `gorm:"type:text"`
+	Error         string
+	DurationMs    int64
+}
+
+// PodcastEvent is an entry in a podcast's event log. Feed moves record the
+// previous and new URL, which makes the log the podcast's URL history.
+type PodcastEvent struct {
+	Base
+	PodcastID string `gorm:"index"`
+	Kind      string
+	Message   string
+	OldURL    string `gorm:"index"`
+	NewURL    string
+}
+
 func (lock *JobLock) IsLocked() bool {
 	return lock != nil && lock.Date != time.Time{}
 }
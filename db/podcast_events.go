@@ -0,0 +1,58 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+func CreatePodcastEvent(event *PodcastEvent) error {
+	return DB.Create(event).Error
+}
+
+func GetPodcastEvents(podcastID string, limit int) (*[]PodcastEvent, error) {
+	var events []PodcastEvent
+	query := DB.Where("podcast_id=?", podcastID).Order("created_at desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&events)
+	return &events, result.Error
+}
+
+func GetLatestPodcastEvent(podcastID string, event *PodcastEvent) error {
+	return DB.Where("podcast_id=?", podcastID).Order("created_at desc").First(event).Error
+}
+
+// GetPodcastByPreviousURL finds the podcast that moved away from url.
+func GetPodcastByPreviousURL(url string, kind string, podcast *Podcast) error {
+	var event PodcastEvent
+	if err := DB.Where("old_url=? and kind=?", url, kind).Order("created_at desc").First(&event).Error; err != nil {
+		return err
+	}
+	return DB.Where("id=?", event.PodcastID).First(podcast).Error
+}
+
+// MovePodcastURL points a podcast at its new feed URL and logs the move in
+// one transaction. The cache validators belong to the old URL and are
+// cleared.
+func MovePodcastURL(podcastID string, event *PodcastEvent) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Podcast{}).Where("id=?", podcastID).Updates(map[string]interface{}{
+			"url":                event.NewURL,
+			"feed_e_tag":         "",
+			"feed_last_modified": "",
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		event.PodcastID = podcastID
+		return tx.Create(event).Error
+	})
+}
+
+// DeletePodcastEvents removes the event log of a podcast.
+func DeletePodcastEvents(podcastID string) error {
+	return DB.Where("podcast_id=?", podcastID).Delete(&PodcastEvent{}).Error
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -39,12 +41,31 @@ func applyDownloadStatusFilter(query *gorm.DB, downloadedOnly *bool) *gorm.DB {
 	return query.Where("download_status!=?", Downloaded)
 }
 
-func applyPlayedStatusFilter(query *gorm.DB, playedOnly *bool) *gorm.DB {
+// applyPlayedStatusFilter filters on the user's played state, or on the
+// library-wide flag when no user is given.
+func applyPlayedStatusFilter(query *gorm.DB, playedOnly *bool, userID string) *gorm.DB {
 	if playedOnly == nil {
 		return query
 	}
+	if userID != "" {
+		played := "select podcast_item_id from user_episode_states where user_id=? and is_played=?"
+		if *playedOnly {
+			return query.Where("podcast_items.id in ("+played+")", userID, true)
+		}
+		return query.Where("podcast_items.id not in ("+played+")", userID, true)
+	}
 	if *playedOnly {
 		return query.Where("is_played=?", true)
 	}
 	return query.Where("is_played=?", false)
 }
+
+// applyPodcastingTextFilter matches a Podcasting 2.0 JSON column of the
+// episode, or of its podcast when the episode has none of its own.
+func applyPodcastingTextFilter(query *gorm.DB, column string, term string) *gorm.DB {
+	pattern := "%" + strings.ToUpper(term) + "%"
+	return query.Where(
+		"(UPPER(podcast_items."+column+") like ? or (coalesce(podcast_items."+column+", '') = '' and podcast_id in (select id from podcasts where UPPER("+column+") like ?)))",
+		pattern, pattern,
+	)
+}
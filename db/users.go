@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetAllUsers() (*[]User, error) {
+	var users []User
+	result := DB.Order("created_at").Find(&users)
+	return &users, result.Error
+}
+
+func GetUserById(id string, user *User) error {
+	return DB.First(user, "id=?", id).Error
+}
+
+func GetUserByUsername(username string, user *User) error {
+	return DB.Where("username=?", username).First(user).Error
+}
+
+func CreateUser(user *User) error {
+	return DB.Create(user).Error
+}
+
+func UpdateUserPasswordHash(id string, passwordHash string) error {
+	return DB.Model(&User{}).Where("id=?", id).Update("password_hash", passwordHash).Error
+}
+
+// DeleteUserById removes the user along with their sessions, API tokens,
+// listening state and gPodder episode actions.
+func DeleteUserById(id string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&UserSession{}, &APIToken{}, &UserEpisodeState{}, &EpisodeAction{}} {
+			if err := tx.Where("user_id=?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id=?", id).Delete(&User{}).Error
+	})
+}
+
+func CreateUserSession(session *UserSession) error {
+	return DB.Create(session).Error
+}
+
+func GetUserSessionByTokenHash(tokenHash string, now time.Time, session *UserSession) error {
+	return DB.Where("token_hash=? and expires_at>?", tokenHash, now).First(session).Error
+}
+
+func DeleteUserSessionByTokenHash(tokenHash string) error {
+	return DB.Where("token_hash=?", tokenHash).Delete(&UserSession{}).Error
+}
+
+func DeleteUserSessionsByUserId(userID string) error {
+	return DB.Where("user_id=?", userID).Delete(&UserSession{}).Error
+}
+
+func DeleteExpiredUserSessions(now time.Time) error {
+	return DB.Where("expires_at<=?", now).Delete(&UserSession{}).Error
+}
+
+func GetAPITokensByUserId(userID string) (*[]APIToken, error) {
+	var tokens []APIToken
+	result := DB.Where("user_id=?", userID).Order("created_at").Find(&tokens)
+	return &tokens, result.Error
+}
+
+func GetAPITokenByHash(tokenHash string, token *APIToken) error {
+	return DB.Where("token_hash=?", tokenHash).First(token).Error
+}
+
+func CreateAPIToken(token *APIToken) error {
+	return DB.Create(token).Error
+}
+
+func DeleteAPITokenById(userID string, id string) (int64, error) {
+	result := DB.Where("id=? and user_id=?", id, userID).Delete(&APIToken{})
+	return result.RowsAffected, result.Error
+}
+
+func UpdateAPITokenLastUsed(id string, lastUsedAt time.Time) error {
+	return DB.Model(&APIToken{}).Where("id=?", id).UpdateColumn("last_used_at", lastUsedAt).Error
+}
+
+// GetUserEpisodeState returns the user's state for an episode, or an unsaved
+// zero state when the user has not interacted with it yet.
+func GetUserEpisodeState(userID string, podcastItemID string) (UserEpisodeState, error) {
+	var state UserEpisodeState
+	err := DB.Where("user_id=? and podcast_item_id=?", userID, podcastItemID).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UserEpisodeState{UserID: userID, PodcastItemID: podcastItemID}, nil
+	}
+	return state, err
+}
+
+func GetUserEpisodeStates(userID string, podcastItemIDs []string) (*[]UserEpisodeState, error) {
+	var states []UserEpisodeState
+	if len(podcastItemIDs) == 0 {
+		return &states, nil
+	}
+	result := DB.Where("user_id=? and podcast_item_id in ?", userID, podcastItemIDs).Find(&states)
+	return &states, result.Error
+}
+
+func GetAllUserEpisodeStates() (*[]UserEpisodeState, error) {
+	var states []UserEpisodeState
+	result := DB.Order("created_at").Find(&states)
+	return &states, result.Error
+}
+
+func SaveUserEpisodeState(state *UserEpisodeState) error {
+	if state.ID == "" {
+		return DB.Create(state).Error
+	}
+	return DB.Save(state).Error
+}
+
+// RefreshPodcastItemsPlayed recomputes the library-wide IsPlayed flag, which is
+// only set once every user has played the episode. With no IDs every episode
+// is refreshed.
+func RefreshPodcastItemsPlayed(podcastItemIDs ...string) error {
+	query := DB.Model(&PodcastItem{})
+	if len(podcastItemIDs) > 0 {
+		query = query.Where("id in ?", podcastItemIDs)
+	} else {
+		query = query.Session(&gorm.Session{AllowGlobalUpdate: true})
+	}
+	played := gorm.Expr(
+		"(select count(*) from users) > 0 and (select count(*) from user_episode_states s where s.podcast_item_id = podcast_items.id and s.is_played = ?) >= (select count(*) from users)",
+		true,
+	)
+	return query.UpdateColumn("is_played", played).Error
+}
+
+type legacyEpisodeState struct {
+	ID                 string
+	IsPlayed           bool
+	BookmarkDate       time.Time
+	PlaybackPosition   int
+	PlaybackCompletion float64
+	PlaybackUpdatedAt  time.Time
+}
+
+// ImportLegacyEpisodeState copies the played, bookmark and progress columns
+// that used to live on podcast_items into state rows for the given user, and
+// hands them the gPodder episode actions recorded before users existed.
+func ImportLegacyEpisodeState(userID string) error {
+	if err := DB.Model(&EpisodeAction{}).Where("user_id=? or user_id is null", "").Update("user_id", userID).Error; err != nil {
+		return err
+	}
+	columns := []string{"id", "is_played"}
+	for _, column := range []string{"bookmark_date", "playback_position", "playback_completion", "playback_updated_at"} {
+		if DB.Migrator().HasColumn(&PodcastItem{}, column) {
+			columns = append(columns, column)
+		}
+	}
+	var legacy []legacyEpisodeState
+	if err := DB.Table("podcast_items").Select(columns).Find(&legacy).Error; err != nil {
+		return err
+	}
+
+	var states []UserEpisodeState
+	for _, item := range legacy {
+		if !item.IsPlayed && item.BookmarkDate.IsZero() && item.PlaybackUpdatedAt.IsZero() && item.PlaybackPosition == 0 {
+			continue
+		}
+		states = append(states, UserEpisodeState{
+			UserID:             userID,
+			PodcastItemID:      item.ID,
+			IsPlayed:           item.IsPlayed,
+			BookmarkDate:       item.BookmarkDate,
+			PlaybackPosition:   item.PlaybackPosition,
+			PlaybackCompletion: item.PlaybackCompletion,
+			PlaybackUpdatedAt:  item.PlaybackUpdatedAt,
+		})
+	}
+	if len(states) == 0 {
+		return nil
+	}
+	return DB.CreateInBatches(&states, 100).Error
+}
@@ -1,4 +1,11 @@
-import type { ChaptersResponse, EpisodeSorting, EpisodesResponse, PodcastItem, TranscriptResponse } from "../../types/api";
+import type {
+  ChaptersResponse,
+  EpisodeSorting,
+  EpisodesResponse,
+  PlaybackProgress,
+  PodcastItem,
+  TranscriptResponse,
+} from "../../types/api";
 import { httpClient } from "./http";
 
 export interface EpisodeListQuery {
@@ -50,4 +57,14 @@ export const episodesApi = {
   getTranscript(id: string): Promise<TranscriptResponse> {
     return httpClient.get<TranscriptResponse>(`/podcastitems/${id}/transcript`);
   },
+  getProgress(id: string): Promise<PlaybackProgress> {
+    return httpClient.get<PlaybackProgress>(`/podcastitems/${id}/progress`);
+  },
+  saveProgress(id: string, position: number, duration?: number): Promise<PlaybackProgress> {
+    return httpClient.post<PlaybackProgress>(`/podcastitems/${id}/progress`, {
+      position: Math.max(0, Math.floor(position)),
+      duration: duration ? Math.floor(duration) : 0,
+      updatedAt: new Date().toISOString(),
+    });
+  },
 };
@@ -9,7 +9,34 @@ export type EpisodeSorting =
   | "release_desc"
   | "release_asc"
   | "duration_desc"
-  | "duration_asc";
+  | "duration_asc"
+  | "episode_desc"
+  | "episode_asc";
+
+export interface PodcastPerson {
+  name: string;
+  role?: string;
+  group?: string;
+  img?: string;
+  href?: string;
+}
+
+export interface PodcastSoundbite {
+  startTime: number;
+  duration: number;
+  title?: string;
+}
+
+export interface PodcastFunding {
+  url: string;
+  title?: string;
+}
+
+export interface PodcastLocation {
+  name: string;
+  geo?: string;
+  osm?: string;
+}
 
 export interface Podcast {
   ID: string;
@@ -28,7 +55,41 @@ export interface Podcast {
   AllEpisodesSize: number;
   IsPaused: boolean;
   RetentionKeepAll: boolean;
+  RetentionKeepLatest: number | null;
+  RetentionDeleteAfterDays: number | null;
+  RetentionDeleteOnlyPlayed: boolean | null;
+  RetentionMaxDiskGB: number | null;
   AutoSkipSponsorChapters: boolean;
+  DownloadPriority?: number;
+  ProcessLoudnorm?: boolean;
+  ProcessStripSilence?: boolean;
+  ProcessCodec?: string;
+  ProcessBitrateKbps?: number;
+  PodcastGUID?: string;
+  Persons?: PodcastPerson[] | null;
+  Funding?: PodcastFunding[] | null;
+  Location?: PodcastLocation | null;
+  LastRefreshAt?: string | null;
+  NextRefreshAt?: string | null;
+  RefreshIntervalMinutes?: number;
+  RefreshFailures?: number;
+  LastRefreshError?: string;
+  WebSubHub?: string;
+  WebSubTopic?: string;
+  WebSubRequestedAt?: string | null;
+  WebSubExpiresAt?: string | null;
+}
+
+export type PodcastEventKind = "url_changed" | "url_rejected" | "websub_subscribed" | "websub_failed";
+
+export interface PodcastEvent {
+  ID: string;
+  CreatedAt: string;
+  PodcastID: string;
+  Kind: PodcastEventKind;
+  Message: string;
+  OldURL: string;
+  NewURL: string;
 }
 
 export interface PodcastItemPodcast {
@@ -46,17 +107,45 @@ export interface PodcastItem {
   Duration: number;
   PubDate: string;
   FileURL: string;
+  EnclosureType?: string;
+  EnclosureLength?: number;
+  Season?: number;
+  EpisodeNumber?: number;
+  Persons?: PodcastPerson[] | null;
+  Soundbites?: PodcastSoundbite[] | null;
+  Location?: PodcastLocation | null;
   Image: string;
   LocalImage: string;
   DownloadPath: string;
   DownloadStatus: number;
   DownloadedBytes: number;
   DownloadTotalBytes: number;
+  DownloadAttempts?: number;
+  LastDownloadError?: string;
+  LastDownloadAttempt?: string;
+  NextDownloadAttempt?: string;
+  FileSHA256?: string;
+  IntegrityCheckedAt?: string;
   TranscriptStatus: string;
+  ProcessingStatus?: "" | "pending" | "processing" | "processed" | "failed";
+  ProcessingError?: string;
+  OriginalPath?: string;
   HasChapters: boolean;
   HasTranscript: boolean;
   IsPlayed: boolean;
   BookmarkDate: string;
+  PlaybackPosition?: number;
+  PlaybackCompletion?: number;
+  PlaybackUpdatedAt?: string;
+}
+
+export interface PlaybackProgress {
+  podcastItemId: string;
+  position: number;
+  duration: number;
+  completion: number;
+  updatedAt: string;
+  isPlayed: boolean;
 }
 
 export interface DownloadCounts {
@@ -64,12 +153,50 @@ export interface DownloadCounts {
   downloading: number;
   downloaded: number;
   paused: number;
+  failed?: number;
 }
 
 export interface DownloadQueueResponse {
   paused: boolean;
+  windowOpen?: boolean;
   counts: DownloadCounts;
   items: PodcastItem[];
+  queue?: DownloadQueueEntry[];
+}
+
+export interface DownloadQueueEntry {
+  ID: string;
+  PodcastItemID: string;
+  Position: number;
+  Priority: number;
+}
+
+export type HookKind = "exec" | "webhook";
+
+export interface Hook {
+  ID: string;
+  CreatedAt: string;
+  Name: string;
+  Kind: HookKind;
+  Target: string;
+  Events: string;
+  TimeoutSeconds: number;
+  MaxAttempts: number;
+  Enabled: boolean;
+}
+
+export interface HookDelivery {
+  ID: string;
+  CreatedAt: string;
+  HookID: string;
+  Event: string;
+  PodcastItemID: string;
+  Attempt: number;
+  Success: boolean;
+  StatusCode: number;
+  Output: string;
+  Error: string;
+  DurationMs: number;
 }
 
 export interface Chapter {
@@ -100,6 +227,11 @@ export interface EpisodesFilter {
   sorting?: EpisodeSorting;
   q?: string;
   podcastIds?: string[];
+  season?: number | null;
+  person?: string;
+  location?: string;
+  hasSoundbites?: EpisodeTriState | null;
+  hasFunding?: EpisodeTriState | null;
 }
 
 export interface EpisodesResponse {
@@ -130,9 +262,100 @@ export interface LocalSearchResult {
   startSeconds?: number;
 }
 
+export interface PodcastProcessing {
+  loudnorm: boolean;
+  stripSilence: boolean;
+  codec: "" | "mp3" | "aac" | "opus";
+  bitrateKbps: number;
+}
+
 export interface RetentionSettings {
   keepAllEpisodes: boolean;
   keepLatestEpisodes: number;
   deleteAfterDays: number;
   deleteOnlyPlayed: boolean;
+  maxDiskGB?: number;
+  playedThresholdPercent?: number;
+  feedParserBackend?: "native" | "python";
+  backupIncludeArtwork?: boolean;
+  downloadMaxAttempts?: number;
+  downloadRetryBackoffMinutes?: number;
+  downloadWindowStart?: string;
+  downloadWindowEnd?: string;
+  downloadMaxBytesPerSecond?: number;
+  downloadSegments?: number;
+  segmentedDownloadMinMB?: number;
+  writeID3Tags?: boolean;
+  processingKeepOriginal?: boolean;
+  loudnessTargetLUFS?: number;
+  episodePathTemplate?: string;
+}
+
+export interface LibraryMove {
+  podcastItemId: string;
+  title: string;
+  from: string;
+  to: string;
+  error?: string;
+}
+
+export interface ReorganizeResult {
+  dryRun: boolean;
+  moved: number;
+  failed: number;
+  moves: LibraryMove[];
+}
+
+export interface LibraryScanCandidate {
+  podcastItemId: string;
+  title: string;
+  podcast: string;
+  score: number;
+  reasons: string[];
+}
+
+export interface LibraryScanMatch extends LibraryScanCandidate {
+  path: string;
+}
+
+export interface LibraryScanAmbiguous {
+  path: string;
+  candidates: LibraryScanCandidate[];
+}
+
+export interface LibraryScanReport {
+  running: boolean;
+  dryRun: boolean;
+  startedAt: string;
+  finishedAt: string;
+  error?: string;
+  scanned: number;
+  imported: number;
+  matched: LibraryScanMatch[];
+  ambiguous: LibraryScanAmbiguous[];
+  unmatched: string[];
+}
+
+export interface User {
+  ID: string;
+  CreatedAt: string;
+  UpdatedAt: string;
+  Username: string;
+  IsAdmin: boolean;
+}
+
+export type ApiTokenScope = "feeds" | "read" | "full";
+
+export interface ApiToken {
+  ID: string;
+  CreatedAt: string;
+  Name: string;
+  Prefix: string;
+  Scope: ApiTokenScope;
+  LastUsedAt: string;
+}
+
+export interface CreateApiTokenResponse {
+  token: string;
+  apiToken: ApiToken;
 }
@@ -0,0 +1,118 @@
+package feedmeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ctaylor1/briefcast/internal/feedparse"
+)
+
+// requireFeedparserEnv turns a missing python feedparser into a test failure
+// instead of a skip, so CI cannot pass without running the python backend.
+const requireFeedparserEnv = "BRIEFCAST_REQUIRE_FEEDPARSER"
+
+// skipPythonBackend skips the python leg of a backend test, or fails it when
+// BRIEFCAST_REQUIRE_FEEDPARSER is set.
+func skipPythonBackend(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+	if strings.TrimSpace(os.Getenv(requireFeedparserEnv)) != "" {
+		t.Fatalf(format, args...)
+	}
+	t.Skipf(format, args...)
+}
+
+// backendRSS wraps channel elements and items in an RSS document that
+// declares the namespaces feedmeta reads.
+func backendRSS(channel string, items ...string) string {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel><title>Backend Show</title>` + channel
+	for _, item := range items {
+		body += "<item>" + item + "</item>"
+	}
+	return body + "</channel></rss>"
+}
+
+// forEachBackend parses doc with the native parser and with the python
+// feedparser helper, and runs check against each result. The python leg is
+// skipped when python or its feedparser module is missing.
+func forEachBackend(t *testing.T, doc string, check func(t *testing.T, parsed feedparse.Result)) {
+	t.Helper()
+	t.Run("native", func(t *testing.T) {
+		parsed, err := feedparse.Parse([]byte(doc))
+		if err != nil {
+			t.Fatalf("native parse failed: %v", err)
+		}
+		check(t, parsed)
+	})
+	t.Run("python", func(t *testing.T) {
+		parsed := parseWithPythonFeedparser(t, doc)
+		check(t, parsed)
+	})
+}
+
+var (
+	pythonFeedparserOnce    sync.Once
+	pythonFeedparserPath    string
+	pythonFeedparserMissing string
+)
+
+// findPythonFeedparser looks for a python with the feedparser module once per
+// test binary and reports why it is unusable when it is.
+func findPythonFeedparser() (string, string) {
+	pythonFeedparserOnce.Do(func() {
+		pythonPath := strings.TrimSpace(os.Getenv("FEEDPARSER_PYTHON"))
+		if pythonPath == "" {
+			for _, candidate := range []string{"python3", "python"} {
+				if path, err := exec.LookPath(candidate); err == nil {
+					pythonPath = path
+					break
+				}
+			}
+		}
+		if pythonPath == "" {
+			pythonFeedparserMissing = "python not available"
+			return
+		}
+		if output, err := exec.Command(pythonPath, "-c", "import feedparser").CombinedOutput(); err != nil {
+			lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+			pythonFeedparserMissing = "python feedparser module not installed: " + err.Error() + " (" + lines[len(lines)-1] + ")"
+			return
+		}
+		pythonFeedparserPath = pythonPath
+	})
+	return pythonFeedparserPath, pythonFeedparserMissing
+}
+
+func parseWithPythonFeedparser(t *testing.T, doc string) feedparse.Result {
+	t.Helper()
+	pythonPath, missing := findPythonFeedparser()
+	if missing != "" {
+		skipPythonBackend(t, "%s", missing)
+	}
+
+	scriptPath, err := filepath.Abs(filepath.Join("..", "..", "scripts", "feedparser_parse.py"))
+	if err != nil {
+		t.Fatalf("failed to resolve feedparser script: %v", err)
+	}
+	cmd := exec.Command(pythonPath, scriptPath)
+	cmd.Env = append(os.Environ(), "LOG_OUTPUT=stderr")
+	cmd.Stdin = strings.NewReader(doc)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("feedparser failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var parsed feedparse.Result
+	if err := json.Unmarshal(stdout.Bytes(), &parsed); err != nil {
+		t.Fatalf("feedparser output decode failed: %v", err)
+	}
+	return parsed
+}
@@ -152,16 +152,26 @@ func ExtractEntryImage(entry map[string]interface{}, fallback string) string {
 	return fallback
 }
 
+// Enclosure is the media file of a feed entry with the type and length the
+// feed declares for it.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
 func ExtractEnclosureURL(entry map[string]interface{}) string {
+	return ExtractEnclosure(entry).URL
+}
+
+func ExtractEnclosure(entry map[string]interface{}) Enclosure {
 	if raw, ok := entry["enclosures"]; ok {
 		if list, ok := raw.([]interface{}); ok {
 			for _, item := range list {
 				if itemMap, ok := item.(map[string]interface{}); ok {
-					if href := stringValue(itemMap["href"]); href != "" {
-						return href
-					}
-					if href := stringValue(itemMap["url"]); href != "" {
-						return href
+					href := PickFirstNonEmpty(stringValue(itemMap["href"]), stringValue(itemMap["url"]))
+					if href != "" {
+						return enclosureFromMap(href, itemMap)
 					}
 				}
 			}
@@ -176,13 +186,29 @@ func ExtractEnclosureURL(entry map[string]interface{}) string {
 				}
 				if strings.EqualFold(stringValue(itemMap["rel"]), "enclosure") {
 					if href := stringValue(itemMap["href"]); href != "" {
-						return href
+						return enclosureFromMap(href, itemMap)
 					}
 				}
 			}
 		}
 	}
-	return GetString(entry, "link")
+	return Enclosure{URL: GetString(entry, "link")}
+}
+
+func enclosureFromMap(href string, itemMap map[string]interface{}) Enclosure {
+	enclosure := Enclosure{URL: href, Type: strings.TrimSpace(stringValue(itemMap["type"]))}
+	switch typed := itemMap["length"].(type) {
+	case float64:
+		enclosure.Length = int64(typed)
+	case string:
+		if length, err := strconv.ParseInt(strings.TrimSpace(typed), 10, 64); err == nil {
+			enclosure.Length = length
+		}
+	}
+	if enclosure.Length < 0 {
+		enclosure.Length = 0
+	}
+	return enclosure
 }
 
 func ExtractEntryGUID(entry map[string]interface{}) string {
@@ -193,6 +219,33 @@ func ExtractEntryGUID(entry map[string]interface{}) string {
 	)
 }
 
+// ExtractNewFeedURL returns the itunes:new-feed-url a publisher sets when
+// the feed moves.
+func ExtractNewFeedURL(feed map[string]interface{}) string {
+	return PickFirstNonEmpty(GetString(feed, "itunes_new-feed-url"), GetString(feed, "itunes_new_feed_url"))
+}
+
+// ExtractWebSubLinks returns the WebSub hub a feed advertises with an
+// atom:link rel="hub", and its rel="self" URL, the topic to subscribe to.
+func ExtractWebSubLinks(feed map[string]interface{}) (string, string) {
+	links, _ := feed["links"].([]interface{})
+	var hub, self string
+	for _, link := range links {
+		linkMap, ok := link.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		href := strings.TrimSpace(GetString(linkMap, "href"))
+		switch strings.ToLower(GetString(linkMap, "rel")) {
+		case "hub":
+			hub = PickFirstNonEmpty(hub, href)
+		case "self":
+			self = PickFirstNonEmpty(self, href)
+		}
+	}
+	return hub, self
+}
+
 func ParseEntryDate(entry map[string]interface{}) time.Time {
 	candidates := []string{
 		GetString(entry, "published"),
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ctaylor1/briefcast/internal/feedparse"
 )
 
 func TestPickFirstNonEmpty(t *testing.T) {
@@ -35,6 +37,13 @@ func TestExtractEntryShowNotesHTML(t *testing.T) {
 	if !strings.Contains(got, "longest of all show notes") {
 		t.Fatalf("expected longest content, got %q", got)
 	}
+
+	doc := backendRSS("", `<title>Notes</title><description>short</description><content:encoded><![CDATA[<p>rich content is the longest of all show notes</p>]]></content:encoded>`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractEntryShowNotesHTML(parsed.Entries[0]); !strings.Contains(got, "longest of all show notes") {
+			t.Fatalf("expected longest content, got %q", got)
+		}
+	})
 }
 
 func TestExtractFeedShowNotesHTML(t *testing.T) {
@@ -46,6 +55,13 @@ func TestExtractFeedShowNotesHTML(t *testing.T) {
 	if got != "<p>itunes summary longer</p>" {
 		t.Fatalf("expected itunes summary, got %q", got)
 	}
+
+	doc := backendRSS(`<description>short</description><itunes:summary>itunes summary longer</itunes:summary>`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractFeedShowNotesHTML(parsed.Feed); !strings.Contains(got, "itunes summary longer") {
+			t.Fatalf("expected itunes summary, got %q", got)
+		}
+	})
 }
 
 func TestExtractPodcastChapters(t *testing.T) {
@@ -59,6 +75,14 @@ func TestExtractPodcastChapters(t *testing.T) {
 	if url != "https://example.com/chapters.json" || typ != "application/json" {
 		t.Fatalf("unexpected chapters: %q %q", url, typ)
 	}
+
+	doc := backendRSS("", `<title>Chapters</title><podcast:chapters url="https://example.com/chapters.json" type="application/json" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		url, typ := ExtractPodcastChapters(parsed.Entries[0])
+		if url != "https://example.com/chapters.json" || typ != "application/json" {
+			t.Fatalf("unexpected chapters: %q %q", url, typ)
+		}
+	})
 }
 
 func TestExtractPodcastChaptersFallbacks(t *testing.T) {
@@ -102,6 +126,14 @@ func TestExtractTranscripts(t *testing.T) {
 	if assets[0].URL == "" || assets[1].URL == "" {
 		t.Fatalf("expected transcript URLs, got %+v", assets)
 	}
+
+	doc := backendRSS("", `<title>Transcript</title><podcast:transcript url="https://example.com/transcript.vtt" type="text/vtt" language="en" rel="captions" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		assets := ExtractTranscripts(parsed.Entries[0])
+		if len(assets) != 1 || assets[0].URL != "https://example.com/transcript.vtt" || assets[0].Type != "text/vtt" {
+			t.Fatalf("unexpected transcripts %+v", assets)
+		}
+	})
 }
 
 func TestExtractTranscriptsDedup(t *testing.T) {
@@ -161,6 +193,51 @@ func TestExtractEnclosureURL(t *testing.T) {
 	if got := ExtractEnclosureURL(entry); got != "https://cdn.example.com/fallback.mp3" {
 		t.Fatalf("unexpected fallback url %q", got)
 	}
+
+	doc := backendRSS("", `<title>Audio</title><enclosure url="https://cdn.example.com/audio.mp3" type="audio/mpeg" length="1" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractEnclosureURL(parsed.Entries[0]); got != "https://cdn.example.com/audio.mp3" {
+			t.Fatalf("unexpected enclosure url %q", got)
+		}
+	})
+}
+
+func TestExtractEnclosure(t *testing.T) {
+	entry := map[string]interface{}{
+		"enclosures": []interface{}{
+			map[string]interface{}{"href": "https://cdn.example.com/video.mp4", "type": " video/mp4 ", "length": "52428800"},
+		},
+	}
+	got := ExtractEnclosure(entry)
+	if got.URL != "https://cdn.example.com/video.mp4" || got.Type != "video/mp4" || got.Length != 52428800 {
+		t.Fatalf("unexpected enclosure %+v", got)
+	}
+
+	entry = map[string]interface{}{
+		"links": []interface{}{
+			map[string]interface{}{"rel": "enclosure", "href": "https://cdn.example.com/a.m4a", "type": "audio/x-m4a", "length": float64(1024)},
+		},
+	}
+	if got := ExtractEnclosure(entry); got.Type != "audio/x-m4a" || got.Length != 1024 {
+		t.Fatalf("unexpected link enclosure %+v", got)
+	}
+
+	entry = map[string]interface{}{
+		"enclosures": []interface{}{
+			map[string]interface{}{"url": "https://cdn.example.com/a.mp3", "length": "unknown"},
+		},
+	}
+	if got := ExtractEnclosure(entry); got.URL != "https://cdn.example.com/a.mp3" || got.Type != "" || got.Length != 0 {
+		t.Fatalf("expected no type or length, got %+v", got)
+	}
+
+	doc := backendRSS("", `<title>Video</title><enclosure url="https://cdn.example.com/video.mp4" type="video/mp4" length="52428800" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		got := ExtractEnclosure(parsed.Entries[0])
+		if got.URL != "https://cdn.example.com/video.mp4" || got.Type != "video/mp4" || got.Length != 52428800 {
+			t.Fatalf("unexpected enclosure %+v", got)
+		}
+	})
 }
 
 func TestParseDurationSeconds(t *testing.T) {
@@ -179,6 +256,23 @@ func TestParseDurationSeconds(t *testing.T) {
 	if got := ParseDurationSeconds("1:2:3:4"); got != 0 {
 		t.Fatalf("expected 0 on oversized input, got %d", got)
 	}
+
+	doc := backendRSS("",
+		`<title>Seconds</title><itunes:duration>90</itunes:duration>`,
+		`<title>Minutes</title><itunes:duration>01:02</itunes:duration>`,
+		`<title>Hours</title><itunes:duration>01:02:03</itunes:duration>`,
+	)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		expected := []int{90, 62, 3723}
+		if len(parsed.Entries) != len(expected) {
+			t.Fatalf("expected %d entries, got %d", len(expected), len(parsed.Entries))
+		}
+		for i, entry := range parsed.Entries {
+			if got := ParseDurationSeconds(GetString(entry, "itunes_duration")); got != expected[i] {
+				t.Fatalf("entry %d: expected %d seconds, got %d", i, expected[i], got)
+			}
+		}
+	})
 }
 
 func TestParseFeedTime(t *testing.T) {
@@ -191,6 +285,20 @@ func TestParseFeedTime(t *testing.T) {
 	if parsed := ParseFeedTime("not-a-date"); !parsed.IsZero() {
 		t.Fatalf("expected zero time for invalid input")
 	}
+
+	doc := backendRSS("",
+		`<title>RFC 1123</title><pubDate>Tue, 03 Mar 2026 10:00:00 +0000</pubDate>`,
+		`<title>GMT</title><pubDate>Tue, 3 Mar 2026 10:00:00 GMT</pubDate>`,
+		`<title>RFC 3339</title><pubDate>2026-03-03T11:00:00+01:00</pubDate>`,
+	)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		expected := time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+		for _, entry := range parsed.Entries {
+			if got := ParseEntryDate(entry); !got.Equal(expected) {
+				t.Fatalf("%s: expected %v, got %v", GetString(entry, "title"), expected, got)
+			}
+		}
+	})
 }
 
 func TestExtractEntryGUID(t *testing.T) {
@@ -206,6 +314,39 @@ func TestExtractEntryGUID(t *testing.T) {
 	if got := ExtractEntryGUID(entry); got != "entry-guid" {
 		t.Fatalf("unexpected guid %q", got)
 	}
+
+	doc := backendRSS("", `<title>Guid</title><guid isPermaLink="false">entry-guid</guid>`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractEntryGUID(parsed.Entries[0]); got != "entry-guid" {
+			t.Fatalf("unexpected guid %q", got)
+		}
+	})
+}
+
+func TestExtractWebSubLinks(t *testing.T) {
+	feed := map[string]interface{}{
+		"links": []interface{}{
+			map[string]interface{}{"rel": "alternate", "href": "https://example.com"},
+			map[string]interface{}{"rel": "self", "href": "https://example.com/feed.xml"},
+			map[string]interface{}{"rel": "hub", "href": "https://pubsubhubbub.appspot.com/"},
+			map[string]interface{}{"rel": "hub", "href": "https://second.example.com/hub"},
+		},
+	}
+	hub, self := ExtractWebSubLinks(feed)
+	if hub != "https://pubsubhubbub.appspot.com/" || self != "https://example.com/feed.xml" {
+		t.Fatalf("unexpected hub %q and self %q", hub, self)
+	}
+	if hub, self := ExtractWebSubLinks(map[string]interface{}{}); hub != "" || self != "" {
+		t.Fatalf("expected no links, got %q %q", hub, self)
+	}
+
+	doc := backendRSS(`<atom:link rel="self" href="https://example.com/feed.xml" /><atom:link rel="hub" href="https://pubsubhubbub.appspot.com/" /><atom:link rel="hub" href="https://second.example.com/hub" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		hub, self := ExtractWebSubLinks(parsed.Feed)
+		if hub != "https://pubsubhubbub.appspot.com/" || self != "https://example.com/feed.xml" {
+			t.Fatalf("unexpected hub %q and self %q", hub, self)
+		}
+	})
 }
 
 func TestExtractEntryImage(t *testing.T) {
@@ -220,6 +361,16 @@ func TestExtractEntryImage(t *testing.T) {
 	if got := ExtractEntryImage(map[string]interface{}{}, "fallback"); got != "fallback" {
 		t.Fatalf("expected fallback, got %q", got)
 	}
+
+	doc := backendRSS("", `<title>Image</title><itunes:image href="https://example.com/img.jpg" />`, `<title>Plain</title>`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractEntryImage(parsed.Entries[0], "fallback"); got != "https://example.com/img.jpg" {
+			t.Fatalf("unexpected image %q", got)
+		}
+		if got := ExtractEntryImage(parsed.Entries[1], "fallback"); got != "fallback" {
+			t.Fatalf("expected fallback, got %q", got)
+		}
+	})
 }
 
 func TestGetStringAndNested(t *testing.T) {
@@ -247,6 +398,13 @@ func TestExtractImageURL(t *testing.T) {
 	if got := ExtractImageURL(entry); got != "https://example.com/itunes.png" {
 		t.Fatalf("unexpected image url %q", got)
 	}
+
+	doc := backendRSS(`<itunes:image href="https://example.com/itunes.png" />`)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		if got := ExtractImageURL(parsed.Feed); got != "https://example.com/itunes.png" {
+			t.Fatalf("unexpected image url %q", got)
+		}
+	})
 }
 
 func TestMarshalMetadata(t *testing.T) {
@@ -255,3 +413,74 @@ func TestMarshalMetadata(t *testing.T) {
 		t.Fatalf("expected json output, got %q", got)
 	}
 }
+
+func TestExtractPodcastingFields(t *testing.T) {
+	entry := map[string]interface{}{
+		"itunes_season":   "2",
+		"podcast_episode": map[string]interface{}{"value": "7.5", "display": "Seven and a half"},
+		"podcast_person": []interface{}{
+			map[string]interface{}{"role": "Host", "img": "https://example.com/host.jpg", "value": " Jane Host "},
+			map[string]interface{}{"role": "guest"},
+			"Plain Name",
+		},
+		"podcast_soundbite": []interface{}{
+			map[string]interface{}{"starttime": "73.0", "duration": "60", "value": "The best bit"},
+			map[string]interface{}{"starttime": "10", "duration": "0"},
+		},
+		"podcast_location": map[string]interface{}{"geo": "geo:30.2672,-97.7431", "osm": "R113314", "value": "Austin, TX"},
+	}
+	if got := ExtractSeason(entry); got != 2 {
+		t.Fatalf("unexpected season %d", got)
+	}
+	if got := ExtractEpisodeNumber(entry); got != 7 {
+		t.Fatalf("unexpected episode number %d", got)
+	}
+	persons := ExtractPersons(entry)
+	if len(persons) != 2 || persons[0].Name != "Jane Host" || persons[0].Role != "host" || persons[1].Name != "Plain Name" {
+		t.Fatalf("unexpected persons %+v", persons)
+	}
+	soundbites := ExtractSoundbites(entry)
+	if len(soundbites) != 1 || soundbites[0].StartTime != 73 || soundbites[0].Duration != 60 || soundbites[0].Title != "The best bit" {
+		t.Fatalf("unexpected soundbites %+v", soundbites)
+	}
+	location := ExtractLocation(entry)
+	if location == nil || location.Name != "Austin, TX" || location.OSM != "R113314" {
+		t.Fatalf("unexpected location %+v", location)
+	}
+
+	feed := map[string]interface{}{
+		"podcast_guid":    "917393e3-1b1e-5cef-ace4-edaa54e1f810",
+		"podcast_funding": []interface{}{map[string]interface{}{"url": "https://example.com/donate", "value": "Support us"}},
+	}
+	if got := ExtractPodcastGUID(feed); got != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("unexpected podcast guid %q", got)
+	}
+	if funding := ExtractFunding(feed); len(funding) != 1 || funding[0].URL != "https://example.com/donate" || funding[0].Title != "Support us" {
+		t.Fatalf("unexpected funding %+v", funding)
+	}
+	if ExtractSeason(feed) != 0 || ExtractLocation(feed) != nil || len(ExtractPersons(feed)) != 0 {
+		t.Fatalf("expected empty values for missing fields")
+	}
+
+	// Podcasting 2.0 elements that carry both text and attributes are left to
+	// the feedparse tests, because python feedparser keeps only the attributes
+	// of namespaces it does not know.
+	doc := backendRSS(`<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid><podcast:funding url="https://example.com/donate" />`,
+		`<title>Podcasting</title><itunes:season>2</itunes:season><itunes:episode>7</itunes:episode>`,
+	)
+	forEachBackend(t, doc, func(t *testing.T, parsed feedparse.Result) {
+		entry := parsed.Entries[0]
+		if got := ExtractSeason(entry); got != 2 {
+			t.Fatalf("unexpected season %d", got)
+		}
+		if got := ExtractEpisodeNumber(entry); got != 7 {
+			t.Fatalf("unexpected episode number %d", got)
+		}
+		if got := ExtractPodcastGUID(parsed.Feed); got != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+			t.Fatalf("unexpected podcast guid %q", got)
+		}
+		if funding := ExtractFunding(parsed.Feed); len(funding) != 1 || funding[0].URL != "https://example.com/donate" {
+			t.Fatalf("unexpected funding %+v", funding)
+		}
+	})
+}
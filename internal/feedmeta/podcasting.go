@@ -0,0 +1,152 @@
+package feedmeta
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Person is a podcast:person credit on a podcast or episode.
+type Person struct {
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"`
+	Group string `json:"group,omitempty"`
+	Image string `json:"img,omitempty"`
+	Href  string `json:"href,omitempty"`
+}
+
+// Soundbite is a podcast:soundbite clip of an episode, in seconds.
+type Soundbite struct {
+	StartTime float64 `json:"startTime"`
+	Duration  float64 `json:"duration"`
+	Title     string  `json:"title,omitempty"`
+}
+
+// Funding is a podcast:funding link for supporting a podcast.
+type Funding struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Location is the podcast:location a podcast or episode is about.
+type Location struct {
+	Name string `json:"name"`
+	Geo  string `json:"geo,omitempty"`
+	OSM  string `json:"osm,omitempty"`
+}
+
+// ExtractSeason returns the itunes:season or podcast:season number of an
+// entry, or 0 when there is none.
+func ExtractSeason(entry map[string]interface{}) int {
+	return pickFirstNumber(GetString(entry, "itunes_season"), podcastText(entry["podcast_season"]))
+}
+
+// ExtractEpisodeNumber returns the itunes:episode or podcast:episode number
+// of an entry, or 0 when there is none. Fractional podcast:episode numbers
+// are rounded down.
+func ExtractEpisodeNumber(entry map[string]interface{}) int {
+	return pickFirstNumber(GetString(entry, "itunes_episode"), podcastText(entry["podcast_episode"]))
+}
+
+// pickFirstNumber returns the first value that parses as a positive number.
+func pickFirstNumber(values ...string) int {
+	for _, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil && number >= 1 {
+			return int(number)
+		}
+	}
+	return 0
+}
+
+func ExtractPodcastGUID(feed map[string]interface{}) string {
+	return strings.TrimSpace(podcastText(feed["podcast_guid"]))
+}
+
+func ExtractPersons(data map[string]interface{}) []Person {
+	persons := make([]Person, 0)
+	for _, element := range podcastElements(data["podcast_person"]) {
+		person := Person{
+			Name:  GetString(element, "value"),
+			Role:  strings.ToLower(GetString(element, "role")),
+			Group: strings.ToLower(GetString(element, "group")),
+			Image: GetString(element, "img"),
+			Href:  GetString(element, "href"),
+		}
+		if person.Name != "" {
+			persons = append(persons, person)
+		}
+	}
+	return persons
+}
+
+func ExtractSoundbites(entry map[string]interface{}) []Soundbite {
+	soundbites := make([]Soundbite, 0)
+	for _, element := range podcastElements(entry["podcast_soundbite"]) {
+		start, startErr := strconv.ParseFloat(GetString(element, "starttime"), 64)
+		duration, durationErr := strconv.ParseFloat(GetString(element, "duration"), 64)
+		if startErr != nil || durationErr != nil || start < 0 || duration <= 0 {
+			continue
+		}
+		soundbites = append(soundbites, Soundbite{StartTime: start, Duration: duration, Title: GetString(element, "value")})
+	}
+	return soundbites
+}
+
+func ExtractFunding(data map[string]interface{}) []Funding {
+	funding := make([]Funding, 0)
+	for _, element := range podcastElements(data["podcast_funding"]) {
+		if url := GetString(element, "url"); url != "" {
+			funding = append(funding, Funding{URL: url, Title: GetString(element, "value")})
+		}
+	}
+	return funding
+}
+
+// ExtractLocation returns the first podcast:location, or nil when there is
+// none.
+func ExtractLocation(data map[string]interface{}) *Location {
+	for _, element := range podcastElements(data["podcast_location"]) {
+		if name := GetString(element, "value"); name != "" {
+			return &Location{Name: name, Geo: GetString(element, "geo"), OSM: GetString(element, "osm")}
+		}
+	}
+	return nil
+}
+
+// podcastElements normalizes a parsed podcast: element to a list of
+// attribute maps. Parsers that keep only the text give a string, which
+// becomes the "value" key.
+func podcastElements(value interface{}) []map[string]interface{} {
+	switch typed := value.(type) {
+	case []interface{}:
+		elements := make([]map[string]interface{}, 0, len(typed))
+		for _, item := range typed {
+			elements = append(elements, podcastElements(item)...)
+		}
+		return elements
+	case map[string]interface{}:
+		trimmed := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			if text, ok := item.(string); ok {
+				trimmed[key] = strings.TrimSpace(text)
+			} else {
+				trimmed[key] = item
+			}
+		}
+		return []map[string]interface{}{trimmed}
+	case string:
+		if strings.TrimSpace(typed) != "" {
+			return []map[string]interface{}{{"value": strings.TrimSpace(typed)}}
+		}
+	}
+	return nil
+}
+
+func podcastText(value interface{}) string {
+	for _, element := range podcastElements(value) {
+		if text := GetString(element, "value"); text != "" {
+			return text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,899 @@
+// Package feedparse is a pure Go feed parser. It reads RSS 0.9x/1.0/2.0 and
+// Atom documents, including the iTunes and Podcasting 2.0 namespaces, and
+// returns the same map structure as scripts/feedparser_parse.py so callers
+// can use internal/feedmeta against either backend.
+package feedparse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Result mirrors the JSON payload printed by scripts/feedparser_parse.py.
+type Result struct {
+	Feed          map[string]interface{}   `json:"feed"`
+	Entries       []map[string]interface{} `json:"entries"`
+	Bozo          bool                     `json:"bozo"`
+	Version       string                   `json:"version"`
+	BozoException string                   `json:"bozo_exception"`
+}
+
+// ErrNotFeed is returned when the document has no RSS, RDF or Atom root.
+var ErrNotFeed = errors.New("document is not an RSS or Atom feed")
+
+var knownNamespaces = map[string]string{
+	"http://www.itunes.com/dtds/podcast-1.0.dtd":                                  "itunes",
+	"https://podcastindex.org/namespace/1.0":                                      "podcast",
+	"http://podcastindex.org/namespace/1.0":                                       "podcast",
+	"https://github.com/podcastindex-org/podcast-namespace/blob/main/docs/1.0.md": "podcast",
+	"http://purl.org/rss/1.0/modules/content":                                     "content",
+	"http://purl.org/dc/elements/1.1":                                             "dc",
+	"http://purl.org/dc/terms":                                                    "dcterms",
+	"http://search.yahoo.com/mrss":                                                "media",
+	"http://podlove.org/simple-chapters":                                          "psc",
+	"http://www.w3.org/2005/atom":                                                 "atom",
+	"http://purl.org/atom/ns#":                                                    "atom",
+	"http://purl.org/rss/1.0":                                                     "",
+	"http://my.netscape.com/rdf/simple/0.9":                                       "",
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#":                                 "rdf",
+	"http://www.google.com/schemas/play-podcasts/1.0":                             "googleplay",
+	"http://www.w3.org/1999/xhtml":                                                "xhtml",
+}
+
+// Podcasting 2.0 elements that may repeat and are therefore returned as lists.
+var podcastListElements = map[string]bool{
+	"alternateenclosure": true,
+	"funding":            true,
+	"person":             true,
+	"podroll":            true,
+	"socialinteract":     true,
+	"soundbite":          true,
+	"source":             true,
+	"trailer":            true,
+	"transcript":         true,
+	"txt":                true,
+	"value":              true,
+	"valuerecipient":     true,
+	"remoteitem":         true,
+	"integrity":          true,
+}
+
+// Podcasting 2.0 elements that only carry text and are returned as strings.
+var podcastTextElements = map[string]bool{
+	"guid":   true,
+	"medium": true,
+}
+
+type element struct {
+	space      string
+	name       string
+	attrs      []xml.Attr
+	text       strings.Builder
+	inner      string
+	innerStart int64
+	children   []*element
+	parent     *element
+}
+
+func (e *element) attr(name string) string {
+	for _, a := range e.attrs {
+		if strings.EqualFold(a.Name.Local, name) && a.Name.Space != "xmlns" {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+func (e *element) hasElementChildren() bool {
+	return len(e.children) > 0
+}
+
+// value returns the element text, or its raw inner markup when the element
+// contains unescaped child elements such as inline HTML.
+func (e *element) value() string {
+	if e.hasElementChildren() {
+		return strings.TrimSpace(e.inner)
+	}
+	return strings.TrimSpace(e.text.String())
+}
+
+type parser struct {
+	prefixes map[string]string
+	isAtom   bool
+}
+
+// Parse converts a feed document into the feedparser map structure. Documents
+// that are only partly well-formed are parsed as far as possible and flagged
+// as bozo, like feedparser does.
+func Parse(body []byte) (Result, error) {
+	body = toUTF8(body)
+	doc, prefixes, bozoErr := buildTree(body)
+	root := firstChild(doc)
+	if root == nil {
+		if bozoErr != nil {
+			return Result{}, bozoErr
+		}
+		return Result{}, ErrNotFeed
+	}
+
+	p := &parser{prefixes: prefixes}
+	var result Result
+	switch strings.ToLower(root.name) {
+	case "rss":
+		result = p.parseRSS(root)
+	case "rdf":
+		result = p.parseRSS(root)
+		result.Version = "rss10"
+		if strings.EqualFold(strings.TrimSuffix(root.childNamespace("channel"), "/"), "http://my.netscape.com/rdf/simple/0.9") {
+			result.Version = "rss090"
+		}
+	case "feed":
+		p.isAtom = true
+		result = p.parseAtom(root)
+	default:
+		return Result{}, ErrNotFeed
+	}
+	if bozoErr != nil {
+		result.Bozo = true
+		result.BozoException = bozoErr.Error()
+	}
+	return result, nil
+}
+
+func (e *element) childNamespace(name string) string {
+	for _, child := range e.children {
+		if strings.EqualFold(child.name, name) {
+			return child.space
+		}
+	}
+	return ""
+}
+
+var xmlEncodingRe = regexp.MustCompile(`(?i)^\s*<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+// toUTF8 converts documents declared in another encoding up front so that the
+// byte offsets used to capture inner markup refer to the decoded text.
+func toUTF8(body []byte) []byte {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	matches := xmlEncodingRe.FindSubmatch(body)
+	if len(matches) < 2 {
+		return body
+	}
+	label := strings.ToLower(string(matches[1]))
+	if label == "utf-8" || label == "utf8" {
+		return body
+	}
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	converted, err := io.ReadAll(reader)
+	if err != nil {
+		return body
+	}
+	return converted
+}
+
+func buildTree(body []byte) (*element, map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	prefixes := make(map[string]string)
+	doc := &element{}
+	current := doc
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return doc, prefixes, err
+		}
+		switch typed := token.(type) {
+		case xml.StartElement:
+			for _, a := range typed.Attr {
+				if a.Name.Space == "xmlns" {
+					prefixes[normalizeNamespace(a.Value)] = strings.ToLower(a.Name.Local)
+				}
+			}
+			child := &element{
+				space:      typed.Name.Space,
+				name:       typed.Name.Local,
+				attrs:      typed.Attr,
+				parent:     current,
+				innerStart: decoder.InputOffset(),
+			}
+			current.children = append(current.children, child)
+			current = child
+		case xml.EndElement:
+			if current.parent == nil {
+				continue
+			}
+			if offset >= current.innerStart && offset <= int64(len(body)) {
+				current.inner = string(body[current.innerStart:offset])
+			}
+			current = current.parent
+		case xml.CharData:
+			current.text.Write(typed)
+		}
+	}
+	return doc, prefixes, nil
+}
+
+func firstChild(e *element) *element {
+	if e == nil || len(e.children) == 0 {
+		return nil
+	}
+	return e.children[0]
+}
+
+func normalizeNamespace(space string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(space)), "/")
+}
+
+func (p *parser) prefix(e *element) string {
+	if e.space == "" {
+		return ""
+	}
+	normalized := normalizeNamespace(e.space)
+	if prefix, ok := knownNamespaces[normalized]; ok {
+		if prefix == "atom" && p.isAtom {
+			return ""
+		}
+		return prefix
+	}
+	if prefix, ok := p.prefixes[normalized]; ok {
+		return prefix
+	}
+	return normalized
+}
+
+type context struct {
+	data           map[string]interface{}
+	entry          bool
+	description    string
+	itunesSummary  string
+	itunesSubtitle string
+	itunesImage    string
+}
+
+func newContext(entry bool) *context {
+	return &context{data: make(map[string]interface{}), entry: entry}
+}
+
+func (c *context) setDefault(key string, value interface{}) {
+	if _, exists := c.data[key]; !exists {
+		c.data[key] = value
+	}
+}
+
+func (c *context) appendList(key string, value interface{}) {
+	list, _ := c.data[key].([]interface{})
+	c.data[key] = append(list, value)
+}
+
+func (c *context) setText(key string, value string, contentType string) {
+	c.data[key] = value
+	c.data[key+"_detail"] = map[string]interface{}{"type": contentType, "value": value}
+}
+
+func (c *context) setDate(key string, value string) {
+	c.data[key] = value
+	if parsed, ok := parseDate(value); ok {
+		c.data[key+"_parsed"] = formatParsedDate(parsed)
+	}
+}
+
+// finish resolves fields whose source depends on which elements were present.
+func (c *context) finish() map[string]interface{} {
+	summaryKey := "subtitle"
+	if c.entry {
+		summaryKey = "summary"
+	}
+	if c.description != "" {
+		c.setText(summaryKey, c.description, "text/html")
+	}
+	if c.itunesSubtitle != "" {
+		if _, exists := c.data["subtitle"]; exists {
+			c.data["itunes_subtitle"] = c.itunesSubtitle
+		} else {
+			c.setText("subtitle", c.itunesSubtitle, "text/plain")
+		}
+	}
+	if c.itunesSummary != "" {
+		if _, exists := c.data["summary"]; exists {
+			c.data["itunes_summary"] = c.itunesSummary
+		} else {
+			c.setText("summary", c.itunesSummary, "text/html")
+		}
+	}
+	if c.itunesImage != "" {
+		c.data["image"] = map[string]interface{}{"href": c.itunesImage}
+	}
+	return c.data
+}
+
+func (p *parser) parseRSS(root *element) Result {
+	result := Result{Feed: map[string]interface{}{}, Entries: []map[string]interface{}{}}
+	switch strings.TrimSpace(root.attr("version")) {
+	case "2.0", "2":
+		result.Version = "rss20"
+	case "0.91":
+		result.Version = "rss091u"
+	case "0.92":
+		result.Version = "rss092"
+	case "0.93":
+		result.Version = "rss093"
+	case "0.94":
+		result.Version = "rss094"
+	default:
+		result.Version = "rss"
+	}
+
+	feed := newContext(false)
+	var items []*element
+	for _, child := range root.children {
+		if p.prefix(child) != "" {
+			continue
+		}
+		switch strings.ToLower(child.name) {
+		case "channel":
+			for _, channelChild := range child.children {
+				if p.prefix(channelChild) == "" && strings.EqualFold(channelChild.name, "item") {
+					items = append(items, channelChild)
+					continue
+				}
+				p.handleRSSElement(feed, channelChild)
+			}
+		case "item":
+			items = append(items, child)
+		case "image":
+			p.handleRSSElement(feed, child)
+		}
+	}
+	result.Feed = feed.finish()
+
+	for _, item := range items {
+		entry := newContext(true)
+		for _, child := range item.children {
+			p.handleRSSElement(entry, child)
+		}
+		result.Entries = append(result.Entries, entry.finish())
+	}
+	return result
+}
+
+func (p *parser) handleRSSElement(ctx *context, e *element) {
+	if p.prefix(e) != "" {
+		p.handleNamespacedElement(ctx, e)
+		return
+	}
+	name := strings.ToLower(e.name)
+	switch name {
+	case "title":
+		ctx.setText("title", e.value(), "text/plain")
+	case "link":
+		link := e.value()
+		if link == "" {
+			return
+		}
+		ctx.data["link"] = link
+		ctx.appendList("links", map[string]interface{}{"rel": "alternate", "type": "text/html", "href": link})
+	case "description":
+		ctx.description = e.value()
+	case "guid":
+		guid := e.value()
+		ctx.data["id"] = guid
+		isPermaLink := !strings.EqualFold(e.attr("ispermalink"), "false")
+		ctx.data["guidislink"] = false
+		if isPermaLink && guid != "" {
+			if _, hasLink := ctx.data["link"]; !hasLink {
+				ctx.data["link"] = guid
+				ctx.data["guidislink"] = true
+			}
+		}
+	case "pubdate":
+		ctx.setDate("published", e.value())
+	case "lastbuilddate":
+		ctx.setDate("updated", e.value())
+	case "author", "managingeditor":
+		setAuthor(ctx, e.value())
+	case "webmaster":
+		ctx.data["publisher"] = e.value()
+	case "copyright":
+		ctx.setText("rights", e.value(), "text/plain")
+	case "category":
+		ctx.appendList("tags", map[string]interface{}{"term": e.value(), "scheme": nullable(e.attr("domain")), "label": nil})
+	case "enclosure":
+		enclosure := map[string]interface{}{
+			"href":   e.attr("url"),
+			"length": e.attr("length"),
+			"type":   e.attr("type"),
+		}
+		ctx.appendList("enclosures", enclosure)
+		ctx.appendList("links", map[string]interface{}{
+			"rel":    "enclosure",
+			"href":   e.attr("url"),
+			"length": e.attr("length"),
+			"type":   e.attr("type"),
+		})
+	case "image":
+		image := map[string]interface{}{}
+		for _, child := range e.children {
+			switch strings.ToLower(child.name) {
+			case "url":
+				image["href"] = child.value()
+			case "title":
+				image["title"] = child.value()
+			case "link":
+				image["link"] = child.value()
+			case "width", "height", "description":
+				image[strings.ToLower(child.name)] = child.value()
+			}
+		}
+		if _, ok := ctx.data["image"]; !ok || image["href"] != nil {
+			ctx.data["image"] = image
+		}
+	case "source":
+		ctx.data["source"] = map[string]interface{}{"href": e.attr("url"), "title": e.value()}
+	default:
+		if !e.hasElementChildren() {
+			ctx.data[name] = e.value()
+		}
+	}
+}
+
+func (p *parser) parseAtom(root *element) Result {
+	result := Result{Feed: map[string]interface{}{}, Entries: []map[string]interface{}{}, Version: "atom10"}
+	if normalizeNamespace(root.space) == "http://purl.org/atom/ns#" {
+		result.Version = "atom03"
+	}
+
+	feed := newContext(false)
+	for _, child := range root.children {
+		if p.prefix(child) == "" && strings.EqualFold(child.name, "entry") {
+			entry := newContext(true)
+			for _, entryChild := range child.children {
+				p.handleAtomElement(entry, entryChild)
+			}
+			result.Entries = append(result.Entries, entry.finish())
+			continue
+		}
+		p.handleAtomElement(feed, child)
+	}
+	result.Feed = feed.finish()
+	return result
+}
+
+func (p *parser) handleAtomElement(ctx *context, e *element) {
+	if p.prefix(e) != "" {
+		p.handleNamespacedElement(ctx, e)
+		return
+	}
+	name := strings.ToLower(e.name)
+	switch name {
+	case "title", "subtitle", "tagline", "rights", "copyright", "summary":
+		key := name
+		switch name {
+		case "tagline":
+			key = "subtitle"
+		case "copyright":
+			key = "rights"
+		}
+		value, contentType := atomText(e)
+		ctx.setText(key, value, contentType)
+	case "content":
+		value, contentType := atomText(e)
+		ctx.appendList("content", map[string]interface{}{"type": contentType, "value": value})
+	case "link":
+		p.handleAtomLink(ctx, e)
+	case "id":
+		ctx.data["id"] = e.value()
+	case "updated", "modified":
+		ctx.setDate("updated", e.value())
+	case "published", "issued":
+		ctx.setDate("published", e.value())
+	case "created":
+		ctx.setDate("created", e.value())
+	case "author", "contributor":
+		detail := map[string]interface{}{}
+		for _, child := range e.children {
+			switch strings.ToLower(child.name) {
+			case "name":
+				detail["name"] = child.value()
+			case "email":
+				detail["email"] = child.value()
+			case "uri", "url":
+				detail["href"] = child.value()
+			}
+		}
+		if name == "contributor" {
+			ctx.appendList("contributors", detail)
+			return
+		}
+		ctx.appendList("authors", detail)
+		if _, exists := ctx.data["author"]; !exists {
+			if authorName, ok := detail["name"].(string); ok {
+				ctx.data["author"] = authorName
+			}
+			ctx.data["author_detail"] = detail
+		}
+	case "category":
+		ctx.appendList("tags", map[string]interface{}{
+			"term":   e.attr("term"),
+			"scheme": nullable(e.attr("scheme")),
+			"label":  nullable(e.attr("label")),
+		})
+	case "logo":
+		ctx.data["image"] = map[string]interface{}{"href": e.value()}
+	case "icon":
+		ctx.data["icon"] = e.value()
+	case "generator":
+		ctx.data["generator"] = e.value()
+		ctx.data["generator_detail"] = map[string]interface{}{
+			"name":    e.value(),
+			"href":    e.attr("uri"),
+			"version": e.attr("version"),
+		}
+	default:
+		if !e.hasElementChildren() {
+			ctx.data[name] = e.value()
+		}
+	}
+}
+
+func (p *parser) handleAtomLink(ctx *context, e *element) {
+	rel := e.attr("rel")
+	if rel == "" {
+		rel = "alternate"
+	}
+	link := map[string]interface{}{"rel": rel, "href": e.attr("href")}
+	for _, attr := range []string{"type", "title", "length", "hreflang"} {
+		if value := e.attr(attr); value != "" {
+			link[attr] = value
+		}
+	}
+	ctx.appendList("links", link)
+	switch rel {
+	case "alternate":
+		ctx.setDefault("link", e.attr("href"))
+	case "enclosure":
+		ctx.appendList("enclosures", map[string]interface{}{
+			"href":   e.attr("href"),
+			"length": e.attr("length"),
+			"type":   e.attr("type"),
+		})
+	}
+}
+
+func atomText(e *element) (string, string) {
+	switch strings.ToLower(e.attr("type")) {
+	case "html", "text/html":
+		return strings.TrimSpace(e.text.String()), "text/html"
+	case "xhtml", "application/xhtml+xml":
+		if len(e.children) == 1 && strings.EqualFold(e.children[0].name, "div") {
+			return strings.TrimSpace(e.children[0].inner), "application/xhtml+xml"
+		}
+		return strings.TrimSpace(e.inner), "application/xhtml+xml"
+	case "", "text", "text/plain":
+		return e.value(), "text/plain"
+	default:
+		return e.value(), strings.ToLower(e.attr("type"))
+	}
+}
+
+// handleNamespacedElement covers the extension namespaces shared by RSS and
+// Atom feeds.
+func (p *parser) handleNamespacedElement(ctx *context, e *element) {
+	prefix := p.prefix(e)
+	name := strings.ToLower(e.name)
+	key := prefix + "_" + name
+	switch prefix {
+	case "itunes":
+		p.handleItunesElement(ctx, e, name, key)
+	case "podcast":
+		p.handlePodcastElement(ctx, e, name, key)
+	case "content":
+		if name == "encoded" {
+			ctx.appendList("content", map[string]interface{}{"type": "text/html", "value": e.value()})
+			return
+		}
+		ctx.data[key] = e.value()
+	case "dc", "dcterms":
+		switch name {
+		case "creator", "publisher":
+			if name == "creator" {
+				setAuthor(ctx, e.value())
+			} else {
+				ctx.data["publisher"] = e.value()
+			}
+		case "date", "modified":
+			ctx.setDate("updated", e.value())
+		case "issued", "created":
+			ctx.setDate("published", e.value())
+		case "rights":
+			ctx.setText("rights", e.value(), "text/plain")
+		case "subject":
+			ctx.appendList("tags", map[string]interface{}{"term": e.value(), "scheme": nil, "label": nil})
+		case "language":
+			ctx.data["language"] = e.value()
+		default:
+			ctx.data[key] = e.value()
+		}
+	case "media":
+		p.handleMediaElement(ctx, e, name, key)
+	case "psc":
+		if name != "chapters" {
+			return
+		}
+		chapters := make([]interface{}, 0, len(e.children))
+		for _, child := range e.children {
+			chapter := map[string]interface{}{}
+			for _, attr := range []string{"start", "title", "href", "image"} {
+				if value := child.attr(attr); value != "" {
+					chapter[attr] = value
+				}
+			}
+			chapters = append(chapters, chapter)
+		}
+		ctx.data["psc_chapters"] = map[string]interface{}{"version": e.attr("version"), "chapters": chapters}
+	case "atom":
+		if name == "link" {
+			p.handleAtomLink(ctx, e)
+		}
+	case "xhtml", "rdf":
+	default:
+		if !e.hasElementChildren() {
+			ctx.data[key] = e.value()
+		}
+	}
+}
+
+func (p *parser) handleItunesElement(ctx *context, e *element, name string, key string) {
+	switch name {
+	case "author":
+		setAuthor(ctx, e.value())
+	case "summary":
+		ctx.itunesSummary = e.value()
+	case "subtitle":
+		ctx.itunesSubtitle = e.value()
+	case "image":
+		href := e.attr("href")
+		if href == "" {
+			href = e.value()
+		}
+		ctx.itunesImage = href
+	case "owner":
+		detail := map[string]interface{}{}
+		for _, child := range e.children {
+			switch strings.ToLower(child.name) {
+			case "name":
+				detail["name"] = child.value()
+			case "email":
+				detail["email"] = child.value()
+			}
+		}
+		ctx.data["publisher_detail"] = detail
+	case "category":
+		addItunesCategories(ctx, e)
+	case "keywords":
+		for _, keyword := range strings.Split(e.value(), ",") {
+			keyword = strings.TrimSpace(keyword)
+			if keyword != "" {
+				ctx.appendList("tags", map[string]interface{}{"term": keyword, "scheme": "http://www.itunes.com/", "label": nil})
+			}
+		}
+	case "explicit":
+		switch strings.ToLower(e.value()) {
+		case "yes", "true", "explicit":
+			ctx.data[key] = true
+		case "no", "false", "clean":
+			ctx.data[key] = false
+		default:
+			ctx.data[key] = nil
+		}
+	case "block", "complete":
+		if strings.EqualFold(e.value(), "yes") {
+			ctx.data[key] = 1
+		} else {
+			ctx.data[key] = 0
+		}
+	default:
+		ctx.data[key] = e.value()
+	}
+}
+
+func addItunesCategories(ctx *context, e *element) {
+	if text := e.attr("text"); text != "" {
+		ctx.appendList("tags", map[string]interface{}{"term": text, "scheme": "http://www.itunes.com/", "label": nil})
+	}
+	for _, child := range e.children {
+		if strings.EqualFold(child.name, "category") {
+			addItunesCategories(ctx, child)
+		}
+	}
+}
+
+func (p *parser) handlePodcastElement(ctx *context, e *element, name string, key string) {
+	if podcastTextElements[name] {
+		ctx.data[key] = e.value()
+		return
+	}
+	value := p.podcastValue(e)
+	if podcastListElements[name] {
+		ctx.appendList(key, value)
+		return
+	}
+	ctx.data[key] = value
+}
+
+// podcastValue maps a Podcasting 2.0 element to its attributes plus a "value"
+// key holding the text; nested podcast elements are added as lists.
+func (p *parser) podcastValue(e *element) map[string]interface{} {
+	value := map[string]interface{}{}
+	for _, attr := range e.attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		value[strings.ToLower(attr.Name.Local)] = strings.TrimSpace(attr.Value)
+	}
+	text := strings.TrimSpace(e.text.String())
+	if text != "" {
+		value["value"] = text
+	}
+	for _, child := range e.children {
+		if p.prefix(child) != "podcast" {
+			continue
+		}
+		childName := strings.ToLower(child.name)
+		list, _ := value[childName].([]interface{})
+		value[childName] = append(list, p.podcastValue(child))
+	}
+	return value
+}
+
+func (p *parser) handleMediaElement(ctx *context, e *element, name string, key string) {
+	switch name {
+	case "content", "thumbnail":
+		item := map[string]interface{}{}
+		for _, attr := range e.attrs {
+			if attr.Name.Space != "xmlns" {
+				item[strings.ToLower(attr.Name.Local)] = strings.TrimSpace(attr.Value)
+			}
+		}
+		ctx.appendList(key, item)
+		for _, child := range e.children {
+			if p.prefix(child) == "media" {
+				p.handleMediaElement(ctx, child, strings.ToLower(child.name), "media_"+strings.ToLower(child.name))
+			}
+		}
+	case "group":
+		for _, child := range e.children {
+			if p.prefix(child) == "media" {
+				p.handleMediaElement(ctx, child, strings.ToLower(child.name), "media_"+strings.ToLower(child.name))
+			}
+		}
+	case "title", "description", "keywords":
+		ctx.data[key] = e.value()
+	default:
+		if !e.hasElementChildren() {
+			ctx.data[key] = e.value()
+		}
+	}
+}
+
+func setAuthor(ctx *context, value string) {
+	if value == "" {
+		return
+	}
+	if _, exists := ctx.data["author"]; exists {
+		return
+	}
+	ctx.data["author"] = value
+	detail := map[string]interface{}{"name": value}
+	if name, email, ok := splitEmailAuthor(value); ok {
+		detail = map[string]interface{}{"name": name, "email": email}
+	}
+	ctx.data["author_detail"] = detail
+	ctx.appendList("authors", detail)
+}
+
+var emailAuthorRe = regexp.MustCompile(`^\s*([^\s()]+@[^\s()]+)\s*\(([^)]*)\)\s*$`)
+
+// splitEmailAuthor handles the RSS "email (Name)" author convention.
+func splitEmailAuthor(value string) (string, string, bool) {
+	matches := emailAuthorRe.FindStringSubmatch(value)
+	if len(matches) < 3 {
+		return "", "", false
+	}
+	return strings.TrimSpace(matches[2]), matches[1], true
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"Mon, 2 Jan 2006 15:04:05",
+	"Mon, 02 Jan 06 15:04:05 -0700",
+	"Mon, 02 Jan 06 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"Mon, 2 January 2006 15:04:05 -0700",
+	"Monday, 2 Jan 2006 15:04:05 -0700",
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var timezoneOffsets = map[string]string{
+	"UT":  "+0000",
+	"UTC": "+0000",
+	"GMT": "+0000",
+	"Z":   "+0000",
+	"EST": "-0500",
+	"EDT": "-0400",
+	"CST": "-0600",
+	"CDT": "-0500",
+	"MST": "-0700",
+	"MDT": "-0600",
+	"PST": "-0800",
+	"PDT": "-0700",
+}
+
+func parseDate(value string) (time.Time, bool) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}, false
+	}
+	// Named US zones parse as zero offsets in Go unless the local zone
+	// matches, so rewrite them to numeric offsets first.
+	if idx := strings.LastIndex(value, " "); idx > 0 {
+		if offset, ok := timezoneOffsets[strings.ToUpper(value[idx+1:])]; ok {
+			value = value[:idx+1] + offset
+		}
+	}
+	for _, layout := range dateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	// Some feeds use an incorrect weekday or omit the comma; retry without it.
+	if idx := strings.Index(value, ","); idx > 0 && idx < 10 {
+		return parseDate(strings.TrimSpace(value[idx+1:]))
+	}
+	return time.Time{}, false
+}
+
+// formatParsedDate matches the ISO format produced by the Python helper for
+// feedparser's *_parsed struct_time values.
+func formatParsedDate(value time.Time) string {
+	return value.UTC().Format("2006-01-02T15:04:05+00:00")
+}
@@ -0,0 +1,283 @@
+package feedparse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
+)
+
+const podcastRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:atom="http://www.w3.org/2005/Atom"
+	xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel>
+	<title>Example Show</title>
+	<link>https://example.com/</link>
+	<atom:link rel="hub" href="https://hub.example.com/" />
+	<description>Short</description>
+	<itunes:summary><![CDATA[<p>A much longer show description</p>]]></itunes:summary>
+	<itunes:author>Jane Host</itunes:author>
+	<itunes:image href="https://example.com/cover.jpg" />
+	<itunes:category text="Technology"><itunes:category text="Podcasting" /></itunes:category>
+	<itunes:new-feed-url>https://example.com/new.xml</itunes:new-feed-url>
+	<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+	<podcast:funding url="https://example.com/donate">Support us</podcast:funding>
+	<item>
+		<title>Episode 1 &amp; more</title>
+		<guid isPermaLink="false">ep-1</guid>
+		<pubDate>Tue, 3 Mar 2026 10:00:00 GMT</pubDate>
+		<description>Plain summary</description>
+		<content:encoded><![CDATA[<p>Full <b>show notes</b> for episode one</p>]]></content:encoded>
+		<enclosure url="https://cdn.example.com/ep1.mp3" length="1234" type="audio/mpeg" />
+		<itunes:duration>01:02:03</itunes:duration>
+		<itunes:episodeType>full</itunes:episodeType>
+		<itunes:season>2</itunes:season>
+		<itunes:episode>7</itunes:episode>
+		<itunes:image href="https://example.com/ep1.jpg" />
+		<podcast:chapters url="https://example.com/ep1-chapters.json" type="application/json+chapters" />
+		<podcast:transcript url="https://example.com/ep1.vtt" type="text/vtt" language="en" rel="captions" />
+		<podcast:transcript url="https://example.com/ep1.json" type="application/json" />
+		<podcast:person role="host" href="https://example.com/jane">Jane Host</podcast:person>
+		<podcast:soundbite startTime="73.0" duration="60.0">Best bit</podcast:soundbite>
+		<podcast:season name="Second">2</podcast:season>
+		<podcast:episode display="Ep. 7">7</podcast:episode>
+		<podcast:location geo="geo:30.2672,97.7431" osm="R113314">Austin, TX</podcast:location>
+	</item>
+	<item>
+		<title>Permalink episode</title>
+		<guid>https://example.com/episodes/2</guid>
+		<pubDate>Wed, 04 Mar 2026 08:30:00 -0500</pubDate>
+		<enclosure url="https://cdn.example.com/ep2.mp3" length="10" type="audio/mpeg" />
+	</item>
+</channel>
+</rss>`
+
+const podcastAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+	<title>Atom Show</title>
+	<subtitle type="html">&lt;p&gt;Atom show notes&lt;/p&gt;</subtitle>
+	<link href="https://example.org/" />
+	<link rel="self" href="https://example.org/feed.atom" />
+	<logo>https://example.org/logo.png</logo>
+	<id>urn:uuid:atom-show</id>
+	<updated>2026-03-01T12:00:00Z</updated>
+	<author><name>Atom Author</name><email>atom@example.org</email></author>
+	<entry>
+		<title>Atom Episode</title>
+		<id>urn:uuid:atom-episode-1</id>
+		<published>2026-03-02T09:15:00+01:00</published>
+		<updated>2026-03-02T10:00:00Z</updated>
+		<link rel="alternate" href="https://example.org/ep1" />
+		<link rel="enclosure" type="audio/mpeg" length="42" href="https://example.org/ep1.mp3" />
+		<summary>Atom summary</summary>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Rich <em>atom</em> content</p></div></content>
+		<itunes:duration>3600</itunes:duration>
+	</entry>
+</feed>`
+
+func TestParseRSSPodcastFeed(t *testing.T) {
+	result, err := Parse([]byte(podcastRSS))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result.Version != "rss20" || result.Bozo {
+		t.Fatalf("unexpected version/bozo: %q %v %q", result.Version, result.Bozo, result.BozoException)
+	}
+
+	feed := result.Feed
+	if got := feedmeta.GetString(feed, "title"); got != "Example Show" {
+		t.Fatalf("unexpected feed title %q", got)
+	}
+	if got := feedmeta.ExtractImageURL(feed); got != "https://example.com/cover.jpg" {
+		t.Fatalf("unexpected feed image %q", got)
+	}
+	if got := feedmeta.ExtractFeedShowNotesHTML(feed); got != "<p>A much longer show description</p>" {
+		t.Fatalf("unexpected feed show notes %q", got)
+	}
+	if got := feedmeta.GetString(feed, "author"); got != "Jane Host" {
+		t.Fatalf("unexpected feed author %q", got)
+	}
+	if got := feedmeta.GetString(feed, "itunes_new-feed-url"); got != "https://example.com/new.xml" {
+		t.Fatalf("unexpected new feed url %q", got)
+	}
+	if got := feedmeta.GetString(feed, "podcast_guid"); got != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("unexpected podcast guid %q", got)
+	}
+	tags, _ := feed["tags"].([]interface{})
+	if len(tags) != 2 {
+		t.Fatalf("expected nested itunes categories as tags, got %+v", feed["tags"])
+	}
+	links, _ := feed["links"].([]interface{})
+	foundHub := false
+	for _, link := range links {
+		if linkMap, ok := link.(map[string]interface{}); ok && linkMap["rel"] == "hub" {
+			foundHub = true
+		}
+	}
+	if !foundHub {
+		t.Fatalf("expected atom hub link in feed links, got %+v", links)
+	}
+
+	if len(result.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(result.Entries))
+	}
+	entry := result.Entries[0]
+	if got := feedmeta.GetString(entry, "title"); got != "Episode 1 & more" {
+		t.Fatalf("unexpected entry title %q", got)
+	}
+	if got := feedmeta.ExtractEntryGUID(entry); got != "ep-1" {
+		t.Fatalf("unexpected guid %q", got)
+	}
+	if got := feedmeta.ExtractEnclosureURL(entry); got != "https://cdn.example.com/ep1.mp3" {
+		t.Fatalf("unexpected enclosure %q", got)
+	}
+	if got := feedmeta.ParseDurationSeconds(feedmeta.GetString(entry, "itunes_duration")); got != 3723 {
+		t.Fatalf("unexpected duration %d", got)
+	}
+	if got := feedmeta.ParseEntryDate(entry); got.IsZero() || got.UTC().Hour() != 10 || got.Day() != 3 {
+		t.Fatalf("unexpected entry date %v", got)
+	}
+	if got := feedmeta.ExtractEntryShowNotesHTML(entry); !strings.Contains(got, "<b>show notes</b>") {
+		t.Fatalf("expected content:encoded show notes, got %q", got)
+	}
+	if got := feedmeta.ExtractEntryImage(entry, ""); got != "https://example.com/ep1.jpg" {
+		t.Fatalf("unexpected entry image %q", got)
+	}
+	if got := feedmeta.GetString(entry, "itunes_episodetype"); got != "full" {
+		t.Fatalf("unexpected episode type %q", got)
+	}
+	if url, typ := feedmeta.ExtractPodcastChapters(entry); url != "https://example.com/ep1-chapters.json" || typ != "application/json+chapters" {
+		t.Fatalf("unexpected chapters %q %q", url, typ)
+	}
+	transcripts := feedmeta.ExtractTranscripts(entry)
+	if len(transcripts) != 2 || transcripts[0].Language != "en" || transcripts[0].Rel != "captions" {
+		t.Fatalf("unexpected transcripts %+v", transcripts)
+	}
+	if got := feedmeta.GetNestedString(entry, "podcast_episode", "display"); got != "Ep. 7" {
+		t.Fatalf("unexpected podcast episode %q", got)
+	}
+	if got := feedmeta.GetNestedString(entry, "podcast_location", "value"); got != "Austin, TX" {
+		t.Fatalf("unexpected podcast location %q", got)
+	}
+	people, _ := entry["podcast_person"].([]interface{})
+	if len(people) != 1 {
+		t.Fatalf("expected one podcast person, got %+v", entry["podcast_person"])
+	}
+	if person := people[0].(map[string]interface{}); person["role"] != "host" || person["value"] != "Jane Host" {
+		t.Fatalf("unexpected podcast person %+v", person)
+	}
+
+	second := result.Entries[1]
+	if got := feedmeta.GetString(second, "link"); got != "https://example.com/episodes/2" {
+		t.Fatalf("expected permalink guid to become the link, got %q", got)
+	}
+	if got := feedmeta.ParseEntryDate(second); got.UTC().Hour() != 13 {
+		t.Fatalf("unexpected second entry date %v", got)
+	}
+}
+
+func TestParseAtomFeed(t *testing.T) {
+	result, err := Parse([]byte(podcastAtom))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result.Version != "atom10" {
+		t.Fatalf("unexpected version %q", result.Version)
+	}
+	feed := result.Feed
+	if got := feedmeta.GetString(feed, "title"); got != "Atom Show" {
+		t.Fatalf("unexpected title %q", got)
+	}
+	if got := feedmeta.GetString(feed, "link"); got != "https://example.org/" {
+		t.Fatalf("unexpected link %q", got)
+	}
+	if got := feedmeta.ExtractImageURL(feed); got != "https://example.org/logo.png" {
+		t.Fatalf("unexpected logo %q", got)
+	}
+	if got := feedmeta.ExtractFeedShowNotesHTML(feed); got != "<p>Atom show notes</p>" {
+		t.Fatalf("unexpected subtitle %q", got)
+	}
+	if got := feedmeta.GetString(feed, "author"); got != "Atom Author" {
+		t.Fatalf("unexpected author %q", got)
+	}
+
+	if len(result.Entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(result.Entries))
+	}
+	entry := result.Entries[0]
+	if got := feedmeta.ExtractEntryGUID(entry); got != "urn:uuid:atom-episode-1" {
+		t.Fatalf("unexpected id %q", got)
+	}
+	if got := feedmeta.ExtractEnclosureURL(entry); got != "https://example.org/ep1.mp3" {
+		t.Fatalf("unexpected enclosure %q", got)
+	}
+	if got := feedmeta.ParseEntryDate(entry); got.UTC().Hour() != 8 {
+		t.Fatalf("expected published date to win, got %v", got)
+	}
+	if got := feedmeta.ExtractEntryShowNotesHTML(entry); got != "<p>Rich <em>atom</em> content</p>" {
+		t.Fatalf("unexpected xhtml content %q", got)
+	}
+	if got := feedmeta.GetString(entry, "itunes_duration"); got != "3600" {
+		t.Fatalf("unexpected duration %q", got)
+	}
+}
+
+func TestParseNonUTF8Feed(t *testing.T) {
+	body := append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><rss version="2.0"><channel><title>Caf`), 0xe9)
+	body = append(body, []byte(`</title></channel></rss>`)...)
+	result, err := Parse(body)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := feedmeta.GetString(result.Feed, "title"); got != "Café" {
+		t.Fatalf("unexpected decoded title %q", got)
+	}
+}
+
+func TestParseMalformedFeedIsBozo(t *testing.T) {
+	result, err := Parse([]byte(`<rss version="2.0"><channel><title>Broken</title><item><title>One</title></item><item><title>Two`))
+	if err != nil {
+		t.Fatalf("expected partial parse, got %v", err)
+	}
+	if !result.Bozo || result.BozoException == "" {
+		t.Fatalf("expected bozo result, got %+v", result)
+	}
+	if feedmeta.GetString(result.Feed, "title") != "Broken" || len(result.Entries) != 2 {
+		t.Fatalf("expected partial data, got feed=%+v entries=%d", result.Feed, len(result.Entries))
+	}
+}
+
+func TestParseRejectsNonFeedDocuments(t *testing.T) {
+	if _, err := Parse([]byte(`<html><body>not a feed</body></html>`)); err != ErrNotFeed {
+		t.Fatalf("expected ErrNotFeed, got %v", err)
+	}
+	if _, err := Parse([]byte(``)); err == nil {
+		t.Fatalf("expected error for empty document")
+	}
+}
+
+func TestParseDateFormats(t *testing.T) {
+	cases := map[string]string{
+		"Tue, 03 Mar 2026 10:00:00 +0000": "2026-03-03T10:00:00+00:00",
+		"Tue, 3 Mar 2026 10:00:00 GMT":    "2026-03-03T10:00:00+00:00",
+		"3 Mar 2026 05:00:00 EST":         "2026-03-03T10:00:00+00:00",
+		"Mon, 03 Mar 2026 10:00:00 +0000": "2026-03-03T10:00:00+00:00",
+		"2026-03-03T11:00:00+01:00":       "2026-03-03T10:00:00+00:00",
+		"2026-03-03":                      "2026-03-03T00:00:00+00:00",
+	}
+	for input, expected := range cases {
+		parsed, ok := parseDate(input)
+		if !ok {
+			t.Fatalf("failed to parse %q", input)
+		}
+		if got := formatParsedDate(parsed); got != expected {
+			t.Fatalf("parseDate(%q) = %s, expected %s", input, got, expected)
+		}
+	}
+	if _, ok := parseDate("not a date"); ok {
+		t.Fatalf("expected invalid date to fail")
+	}
+}
@@ -30,6 +30,18 @@ func main() {
 		return
 	}
 	db.Migrate()
+
+	pass := os.Getenv("PASSWORD")
+	if _, err := service.EnsureDefaultUser(pass); err != nil {
+		appLogger.Fatalw("default user initialization failed", "error", err)
+		return
+	}
+	service.RestoreDownloadsPaused()
+	go func() {
+		if err := service.BackfillPodcastingFields(); err != nil {
+			appLogger.Warnw("podcasting fields backfill failed", "error", err)
+		}
+	}()
 	r := gin.New()
 
 	r.Use(logging.RequestLoggerMiddleware())
@@ -39,22 +51,21 @@ func main() {
 
 	// Legacy HTML templates removed; modern Vue app is the only UI.
 
-	pass := os.Getenv("PASSWORD")
-	var router *gin.RouterGroup
-	if pass != "" {
-		router = r.Group("/", gin.BasicAuth(gin.Accounts{
-			"briefcast": pass,
-		}))
-	} else {
-		router = &r.RouterGroup
-	}
+	// Every request runs as a user: the one behind an API token, the session
+	// cookie or basic auth credentials, or the default user while no password
+	// is configured.
+	r.POST("/auth/login", controllers.Login)
+	// WebSub hubs verify subscriptions and push feed updates without
+	// credentials; pushes are checked against the subscription secret.
+	r.GET("/websub/:id", controllers.VerifyWebSubSubscription)
+	r.POST("/websub/:id", controllers.ReceiveWebSubPush)
+	router := r.Group("/", controllers.RequireUser(pass != ""))
 
 	dataPath := os.Getenv("DATA")
 	backupPath := path.Join(os.Getenv("CONFIG"), "backups")
 
 	router.Static("/webassets", "./webassets")
 	router.Static("/assets", dataPath)
-	router.Static(backupPath, backupPath)
 	router.Static("/app/assets", "./frontend/dist/assets")
 	router.StaticFile("/app/favicon.ico", "./frontend/dist/favicon.ico")
 	router.GET("/", func(c *gin.Context) {
@@ -73,15 +84,17 @@ func main() {
 	router.GET("/podcasts", controllers.GetAllPodcasts)
 	router.GET("/podcasts/:id", controllers.GetPodcastById)
 	router.GET("/podcasts/:id/image", controllers.GetPodcastImageById)
-	router.DELETE("/podcasts/:id", controllers.DeletePodcastById)
 	router.GET("/podcasts/:id/items", controllers.GetPodcastItemsByPodcastId)
 	router.GET("/podcasts/:id/download", controllers.DownloadAllEpisodesByPodcastId)
-	router.DELETE("/podcasts/:id/items", controllers.DeletePodcastEpisodesById)
-	router.DELETE("/podcasts/:id/podcast", controllers.DeleteOnlyPodcastById)
 	router.GET("/podcasts/:id/pause", controllers.PausePodcastById)
 	router.GET("/podcasts/:id/unpause", controllers.UnpausePodcastById)
-	router.PATCH("/podcasts/:id/retention", controllers.PatchPodcastRetention)
+	router.GET("/podcasts/:id/retention", controllers.GetPodcastRetention)
+	router.GET("/podcasts/:id/processing", controllers.GetPodcastProcessing)
+	router.GET("/podcasts/:id/events", controllers.GetPodcastEvents)
+	router.POST("/podcasts/:id/refresh", controllers.RefreshPodcastById)
+	router.PATCH("/podcasts/:id/refresh-interval", controllers.PatchPodcastRefreshInterval)
 	router.PATCH("/podcasts/:id/sponsor-skip", controllers.PatchPodcastSponsorSkip)
+	router.PATCH("/podcasts/:id/download-priority", controllers.PatchPodcastDownloadPriority)
 	router.GET("/podcasts/:id/rss", controllers.GetRssForPodcastById)
 
 	router.GET("/podcastitems", controllers.GetAllPodcastItems)
@@ -92,18 +105,26 @@ func main() {
 	router.GET("/podcastitems/:id/markPlayed", controllers.MarkPodcastItemAsPlayed)
 	router.GET("/podcastitems/:id/bookmark", controllers.BookmarkPodcastItem)
 	router.GET("/podcastitems/:id/unbookmark", controllers.UnbookmarkPodcastItem)
+	router.GET("/podcastitems/:id/progress", controllers.GetPodcastItemProgress)
+	router.POST("/podcastitems/:id/progress", controllers.UpdatePodcastItemProgress)
 	router.PATCH("/podcastitems/:id", controllers.PatchPodcastItemById)
 	router.GET("/podcastitems/:id/download", controllers.DownloadPodcastItem)
 	router.GET("/podcastitems/:id/chapters", controllers.GetPodcastItemChapters)
 	router.GET("/podcastitems/:id/transcript", controllers.GetPodcastItemTranscript)
 	router.POST("/podcastitems/:id/cancel", controllers.CancelPodcastItemDownload)
 	router.POST("/podcastitems/:id/resume", controllers.ResumePodcastItemDownload)
-	router.GET("/podcastitems/:id/delete", controllers.DeletePodcastItem)
+	router.POST("/podcastitems/:id/retry", controllers.RetryPodcastItemDownload)
 
 	router.GET("/downloads/queue", controllers.GetDownloadQueue)
+	router.POST("/downloads/queue/reorder", controllers.ReorderDownloadQueue)
+	router.POST("/downloads/queue/:id/top", controllers.MoveDownloadToTop)
+	router.POST("/downloads/queue/:id/bottom", controllers.MoveDownloadToBottom)
+	router.PATCH("/downloads/queue/:id", controllers.PatchDownloadQueueEntry)
 	router.POST("/downloads/pause", controllers.PauseDownloads)
 	router.POST("/downloads/resume", controllers.ResumeDownloads)
 	router.POST("/downloads/cancel", controllers.CancelAllDownloads)
+	router.GET("/downloads/failed", controllers.GetFailedDownloads)
+	router.POST("/downloads/failed/retry", controllers.RetryFailedDownloads)
 
 	router.GET("/tags", controllers.GetAllTags)
 	router.GET("/tags/:id", controllers.GetTagById)
@@ -114,15 +135,62 @@ func main() {
 	router.DELETE("/podcasts/:id/tags/:tagId", controllers.RemoveTagFromPodcast)
 
 	router.GET("/search", controllers.Search)
+	router.GET("/retention/preview", controllers.GetRetentionPreview)
 	router.GET("/search/local", controllers.SearchLocalRecords)
 	router.GET("/settings", controllers.GetSettings)
-	router.PATCH("/settings", controllers.PatchSettings)
-	router.POST("/settings", controllers.UpdateSetting)
 	router.POST("/opml", controllers.UploadOpml)
 	router.GET("/opml", controllers.GetOmpl)
 	router.GET("/rss", controllers.GetRss)
+	router.GET("/ws", controllers.Wshandler)
+
+	router.POST("/auth/logout", controllers.Logout)
+	router.GET("/auth/me", controllers.GetCurrentUser)
+	router.GET("/tokens", controllers.GetAPITokens)
+	router.POST("/tokens", controllers.AddAPIToken)
+	router.DELETE("/tokens/:id", controllers.DeleteAPITokenById)
+	admin := router.Group("/users", controllers.RequireAdmin())
+	admin.GET("", controllers.GetAllUsers)
+	admin.POST("", controllers.AddUser)
+	admin.DELETE("/:id", controllers.DeleteUserById)
+	hooks := router.Group("/hooks", controllers.RequireAdmin())
+	hooks.GET("", controllers.GetAllHooks)
+	hooks.POST("", controllers.AddHook)
+	hooks.PUT("/:id", controllers.UpdateHook)
+	hooks.DELETE("/:id", controllers.DeleteHookById)
+	hooks.GET("/:id/deliveries", controllers.GetHookDeliveries)
+	// Settings, backups and anything that deletes or rewrites downloaded files
+	// affect every user. Restoring a backup replaces the users table, and
+	// backup files hold it.
+	adminRoutes := router.Group("/", controllers.RequireAdmin())
+	adminRoutes.Static(backupPath, backupPath)
+	adminRoutes.PATCH("/settings", controllers.PatchSettings)
+	adminRoutes.POST("/settings", controllers.UpdateSetting)
+	adminRoutes.POST("/backups/restore", controllers.RestoreBackup)
+	adminRoutes.DELETE("/podcasts/:id", controllers.DeletePodcastById)
+	adminRoutes.DELETE("/podcasts/:id/items", controllers.DeletePodcastEpisodesById)
+	adminRoutes.DELETE("/podcasts/:id/podcast", controllers.DeleteOnlyPodcastById)
+	adminRoutes.PATCH("/podcasts/:id/retention", controllers.PatchPodcastRetention)
+	adminRoutes.PATCH("/podcasts/:id/processing", controllers.PatchPodcastProcessing)
+	adminRoutes.POST("/podcastitems/:id/tags", controllers.WritePodcastItemTags)
+	adminRoutes.POST("/podcastitems/:id/process", controllers.ProcessPodcastItem)
+	adminRoutes.GET("/podcastitems/:id/delete", controllers.DeletePodcastItem)
+	library := router.Group("/library", controllers.RequireAdmin())
+	library.GET("/reorganize", controllers.GetLibraryReorganizePreview)
+	library.POST("/reorganize", controllers.ReorganizeLibrary)
+	library.GET("/scan", controllers.GetLibraryScanReport)
+	library.POST("/scan", controllers.StartLibraryScan)
+	library.POST("/import", controllers.ImportLibraryFile)
+
+	gpodder := r.Group("/api/2", controllers.GpodderAuth(pass != ""))
+	gpodder.POST("/auth/:username/login.json", controllers.GpodderLogin)
+	gpodder.POST("/auth/:username/logout.json", controllers.GpodderLogout)
+	gpodder.GET("/devices/:username", controllers.GpodderListDevices)
+	gpodder.POST("/devices/:username/:deviceid", controllers.GpodderUpdateDevice)
+	gpodder.GET("/subscriptions/:username/:deviceid", controllers.GpodderGetSubscriptions)
+	gpodder.POST("/subscriptions/:username/:deviceid", controllers.GpodderUploadSubscriptions)
+	gpodder.GET("/episodes/:username", controllers.GpodderGetEpisodeActions)
+	gpodder.POST("/episodes/:username", controllers.GpodderUploadEpisodeActions)
 
-	r.GET("/ws", controllers.Wshandler)
 	go controllers.HandleWebsocketMessages()
 
 	go assetEnv()
@@ -177,6 +245,7 @@ func intiCron() {
 	minutes := fmt.Sprintf("@every %dm", checkFrequency)
 	add(minutes, "RefreshEpisodes", service.RefreshEpisodes)
 	add(minutes, "CheckMissingFiles", service.CheckMissingFiles)
+	add("@every 24h", "VerifyDownloadedFiles", service.VerifyDownloadedFiles)
 	add("@every 24h", "RetentionCleanup", service.ApplyRetentionPolicies)
 	add(fmt.Sprintf("@every %dm", checkFrequency*2), "UnlockMissedJobs", func() error {
 		service.UnlockMissedJobs()
@@ -187,6 +256,7 @@ func intiCron() {
 		return nil
 	})
 	add(minutes, "DownloadMissingImages", service.DownloadMissingImages)
+	add(minutes, "ProcessDownloadedAudio", service.ProcessDownloadedAudio)
 	whisperxFrequency := checkFrequency
 	if raw := strings.TrimSpace(os.Getenv("WHISPERX_CHECK_FREQUENCY")); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
@@ -19,3 +19,43 @@ type GPodcastTag struct {
 	Title string `json:"title"`
 	Usage int    `json:"usage"`
 }
+
+type GpodderDevice struct {
+	ID            string `json:"id"`
+	Caption       string `json:"caption"`
+	Type          string `json:"type"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+type GpodderDeviceUpdate struct {
+	Caption string `json:"caption"`
+	Type    string `json:"type"`
+}
+
+type GpodderSubscriptionChanges struct {
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
+	Timestamp int64    `json:"timestamp,omitempty"`
+}
+
+type GpodderEpisodeAction struct {
+	Podcast   string `json:"podcast"`
+	Episode   string `json:"episode"`
+	GUID      string `json:"guid,omitempty"`
+	Device    string `json:"device,omitempty"`
+	Action    string `json:"action"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Started   *int   `json:"started,omitempty"`
+	Position  *int   `json:"position,omitempty"`
+	Total     *int   `json:"total,omitempty"`
+}
+
+type GpodderEpisodeActions struct {
+	Actions   []GpodderEpisodeAction `json:"actions"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+type GpodderUploadResponse struct {
+	Timestamp  int64       `json:"timestamp"`
+	UpdateURLs [][2]string `json:"update_urls"`
+}
@@ -18,6 +18,9 @@ const (
 	RELEASE_DESC  EpisodeSort = "release_desc"
 	DURATION_ASC  EpisodeSort = "duration_asc"
 	DURATION_DESC EpisodeSort = "duration_desc"
+	// EPISODE_ASC and EPISODE_DESC order by season, then episode number.
+	EPISODE_ASC  EpisodeSort = "episode_asc"
+	EPISODE_DESC EpisodeSort = "episode_desc"
 )
 
 type EpisodesFilter struct {
@@ -28,6 +31,14 @@ type EpisodesFilter struct {
 	Q            string      `uri:"q" query:"q" json:"q" form:"q"`
 	TagIds       []string    `uri:"tagIds" query:"tagIds[]" json:"tagIds" form:"tagIds[]"`
 	PodcastIds   []string    `uri:"podcastIds" query:"podcastIds[]" json:"podcastIds" form:"podcastIds[]"`
+	// Podcasting 2.0 filters. Person and Location match the episode's own
+	// podcast:person and podcast:location, or the podcast's.
+	Season        *int    `uri:"season" query:"season" json:"season" form:"season"`
+	Person        string  `uri:"person" query:"person" json:"person" form:"person"`
+	Location      string  `uri:"location" query:"location" json:"location" form:"location"`
+	HasSoundbites *string `uri:"hasSoundbites" query:"hasSoundbites" json:"hasSoundbites" form:"hasSoundbites"`
+	HasFunding    *string `uri:"hasFunding" query:"hasFunding" json:"hasFunding" form:"hasFunding"`
+	UserID        string  `form:"-" json:"-"`
 }
 
 func (filter *EpisodesFilter) VerifyPaginationValues() {
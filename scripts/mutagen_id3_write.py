@@ -0,0 +1,136 @@
+#!/usr/bin/env python3
+import json
+import logging
+import mimetypes
+import sys
+from pathlib import Path
+
+from mutagen.id3 import (
+    APIC,
+    CHAP,
+    COMM,
+    CTOC,
+    ID3,
+    TALB,
+    TCON,
+    TDRC,
+    TIT2,
+    TPE1,
+    TPE2,
+    TPOS,
+    TRCK,
+    CTOCFlags,
+    ID3NoHeaderError,
+)
+
+ROOT_DIR = Path(__file__).resolve().parents[1]
+SRC_DIR = ROOT_DIR / "src"
+if str(SRC_DIR) not in sys.path:
+    sys.path.insert(0, str(SRC_DIR))
+
+from briefcast_tools import log_extra, setup_logging
+
+logger = logging.getLogger(__name__)
+
+# Offsets are unused when chapters are addressed by time.
+NO_OFFSET = 0xFFFFFFFF
+
+
+def set_text(id3, frame_type, value):
+    id3.delall(frame_type.__name__)
+    if value:
+        id3.add(frame_type(encoding=3, text=[value]))
+
+
+def artwork_mime(path, data):
+    if data.startswith(b"\x89PNG"):
+        return "image/png"
+    if data.startswith(b"\xff\xd8"):
+        return "image/jpeg"
+    guessed, _ = mimetypes.guess_type(path)
+    return guessed or "image/jpeg"
+
+
+def apply_artwork(id3, path):
+    if not path:
+        return
+    try:
+        data = Path(path).read_bytes()
+    except OSError:
+        logger.warning("artwork not readable", extra=log_extra({"path": path}))
+        return
+    id3.delall("APIC")
+    id3.add(APIC(encoding=3, mime=artwork_mime(path, data), type=3, desc="Cover", data=data))
+
+
+def apply_chapters(id3, chapters):
+    if not chapters:
+        return
+    id3.delall("CHAP")
+    id3.delall("CTOC")
+    element_ids = []
+    for chapter in chapters:
+        element_id = chapter["id"]
+        element_ids.append(element_id)
+        id3.add(
+            CHAP(
+                element_id=element_id,
+                start_time=int(chapter["start_ms"]),
+                end_time=int(chapter["end_ms"]),
+                start_offset=NO_OFFSET,
+                end_offset=NO_OFFSET,
+                sub_frames=[TIT2(encoding=3, text=[chapter.get("title", "")])],
+            )
+        )
+    id3.add(
+        CTOC(
+            element_id="toc",
+            flags=CTOCFlags.TOP_LEVEL | CTOCFlags.ORDERED,
+            child_element_ids=element_ids,
+            sub_frames=[TIT2(encoding=3, text=["Chapters"])],
+        )
+    )
+
+
+def main():
+    setup_logging(service_name="briefcast-mutagen")
+
+    try:
+        request = json.load(sys.stdin)
+    except ValueError:
+        logger.warning("invalid tag request")
+        return 2
+
+    path = request.get("path", "")
+    try:
+        try:
+            id3 = ID3(path)
+        except ID3NoHeaderError:
+            id3 = ID3()
+
+        set_text(id3, TIT2, request.get("title"))
+        set_text(id3, TALB, request.get("album"))
+        set_text(id3, TPE1, request.get("artist"))
+        set_text(id3, TPE2, request.get("album_artist"))
+        set_text(id3, TDRC, request.get("date"))
+        set_text(id3, TRCK, request.get("track"))
+        set_text(id3, TPOS, request.get("disc"))
+        set_text(id3, TCON, request.get("genre"))
+        id3.delall("COMM")
+        if request.get("description"):
+            id3.add(COMM(encoding=3, lang="eng", desc="", text=[request["description"]]))
+        apply_artwork(id3, request.get("artwork"))
+        apply_chapters(id3, request.get("chapters") or [])
+
+        # ID3v2.3 is the version car stereos and older players read reliably.
+        id3.save(path, v2_version=3)
+    except Exception:
+        logger.exception("id3 tag write failed", extra=log_extra({"path": path}))
+        return 1
+
+    json.dump({"frames": len(id3.keys())}, sys.stdout)
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
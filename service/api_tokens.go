@@ -0,0 +1,125 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// API token scopes. Feeds tokens can only read RSS feeds and the media and
+// artwork they link to, read tokens can call the read-only endpoints and full
+// tokens act as the user.
+const (
+	APITokenScopeFeeds = "feeds"
+	APITokenScopeRead  = "read"
+	APITokenScopeFull  = "full"
+)
+
+const (
+	apiTokenPrefix        = "bc_"
+	apiTokenLastUsedDelay = time.Minute
+)
+
+var (
+	ErrInvalidAPIToken      = errors.New("invalid API token")
+	ErrInvalidAPITokenScope = errors.New("scope must be feeds, read or full")
+	ErrAPITokenNameRequired = errors.New("token name is required")
+)
+
+func GetAPITokens(userID string) (*[]db.APIToken, error) {
+	return db.GetAPITokensByUserId(userID)
+}
+
+// CreateAPIToken issues a token for the user and returns it in clear text
+// along with the stored record. The clear text is not kept anywhere. An empty
+// scope grants full access.
+func CreateAPIToken(userID string, name string, scope string) (string, db.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", db.APIToken{}, ErrAPITokenNameRequired
+	}
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if scope == "" {
+		scope = APITokenScopeFull
+	}
+	switch scope {
+	case APITokenScopeFeeds, APITokenScopeRead, APITokenScopeFull:
+	default:
+		return "", db.APIToken{}, ErrInvalidAPITokenScope
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", db.APIToken{}, err
+	}
+	secret := apiTokenPrefix + hex.EncodeToString(buf)
+	token := db.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(apiTokenPrefix)+8],
+		TokenHash: hashToken(secret),
+		Scope:     scope,
+	}
+	if err := db.CreateAPIToken(&token); err != nil {
+		return "", db.APIToken{}, err
+	}
+	return secret, token, nil
+}
+
+// DeleteAPIToken revokes one of the user's tokens.
+func DeleteAPIToken(userID string, id string) error {
+	deleted, err := db.DeleteAPITokenById(userID, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrInvalidAPIToken
+	}
+	return nil
+}
+
+// AuthenticateAPIToken returns the token record and its owner.
+func AuthenticateAPIToken(secret string) (db.User, db.APIToken, error) {
+	var token db.APIToken
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return db.User{}, token, ErrInvalidAPIToken
+	}
+	if err := db.GetAPITokenByHash(hashToken(secret), &token); err != nil {
+		return db.User{}, token, ErrInvalidAPIToken
+	}
+	user, err := GetUserById(token.UserID)
+	if err != nil {
+		return db.User{}, token, ErrInvalidAPIToken
+	}
+
+	now := usersNow()
+	if now.Sub(token.LastUsedAt) > apiTokenLastUsedDelay {
+		if err := db.UpdateAPITokenLastUsed(token.ID, now); err != nil {
+			Logger.Warnw("failed to update API token last use", "token_id", token.ID, "error", err)
+		}
+		token.LastUsedAt = now
+	}
+	return user, token, nil
+}
+
+// APITokenAllows reports whether a token's scope permits a request.
+// readRoute marks the endpoints that change nothing and feedRoute the RSS,
+// media and artwork ones. Some GET endpoints change state, so the method
+// alone never makes a request read-only.
+func APITokenAllows(token db.APIToken, method string, readRoute bool, feedRoute bool) bool {
+	safeMethod := method == http.MethodGet || method == http.MethodHead
+	switch token.Scope {
+	case APITokenScopeFull:
+		return true
+	case APITokenScopeRead:
+		return safeMethod && (readRoute || feedRoute)
+	case APITokenScopeFeeds:
+		return safeMethod && feedRoute
+	}
+	return false
+}
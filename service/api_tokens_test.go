@@ -0,0 +1,66 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestAPITokenLifecycle(t *testing.T) {
+	setupRetentionTestDB(t)
+	userID := defaultUserID(t)
+
+	if _, _, err := CreateAPIToken(userID, "phone", "admin"); err != ErrInvalidAPITokenScope {
+		t.Fatalf("expected ErrInvalidAPITokenScope, got %v", err)
+	}
+	secret, token, err := CreateAPIToken(userID, "phone", "")
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	if token.Scope != APITokenScopeFull || token.TokenHash == secret {
+		t.Fatalf("expected a hashed full token, got %+v", token)
+	}
+
+	user, authenticated, err := AuthenticateAPIToken(secret)
+	if err != nil || user.ID != userID || authenticated.ID != token.ID {
+		t.Fatalf("expected token to authenticate the default user, got %+v, %v", user, err)
+	}
+	if authenticated.LastUsedAt.IsZero() {
+		t.Fatalf("expected last use to be recorded")
+	}
+
+	if err := DeleteAPIToken("someone-else", token.ID); err != ErrInvalidAPIToken {
+		t.Fatalf("expected another user's delete to fail, got %v", err)
+	}
+	if err := DeleteAPIToken(userID, token.ID); err != nil {
+		t.Fatalf("DeleteAPIToken failed: %v", err)
+	}
+	if _, _, err := AuthenticateAPIToken(secret); err != ErrInvalidAPIToken {
+		t.Fatalf("expected revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestAPITokenAllows(t *testing.T) {
+	cases := []struct {
+		scope     string
+		method    string
+		readRoute bool
+		feedRoute bool
+		allowed   bool
+	}{
+		{APITokenScopeFeeds, http.MethodGet, false, true, true},
+		{APITokenScopeFeeds, http.MethodGet, true, false, false},
+		{APITokenScopeRead, http.MethodGet, true, false, true},
+		{APITokenScopeRead, http.MethodGet, false, true, true},
+		{APITokenScopeRead, http.MethodGet, false, false, false},
+		{APITokenScopeRead, http.MethodPost, true, false, false},
+		{APITokenScopeFull, http.MethodDelete, false, false, true},
+		{"", http.MethodGet, true, true, false},
+	}
+	for _, tc := range cases {
+		if got := APITokenAllows(db.APIToken{Scope: tc.scope}, tc.method, tc.readRoute, tc.feedRoute); got != tc.allowed {
+			t.Fatalf("scope %q %s read=%v feed=%v: expected %v, got %v", tc.scope, tc.method, tc.readRoute, tc.feedRoute, tc.allowed, got)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/logging"
+)
+
+// Audio processing statuses stored in PodcastItem.ProcessingStatus. Episodes of
+// podcasts without processing keep an empty status.
+const (
+	ProcessingPending    = "pending"
+	ProcessingInProgress = "processing"
+	ProcessingDone       = "processed"
+	ProcessingFailed     = "failed"
+)
+
+const (
+	ffmpegPathEnv               = "FFMPEG_PATH"
+	ffmpegTimeoutEnv            = "FFMPEG_TIMEOUT_SECONDS"
+	defaultFFmpegTimeoutSeconds = 1800
+	processingBatchSize         = 20
+	maxProcessingBitrateKbps    = 512
+	minLoudnessTargetLUFS       = -70.0
+	maxLoudnessTargetLUFS       = -5.0
+)
+
+var (
+	ErrInvalidProcessingCodec   = errors.New("codec must be empty, mp3, aac or opus")
+	ErrInvalidProcessingBitrate = fmt.Errorf("bitrateKbps must be between 0 and %d", maxProcessingBitrateKbps)
+	ErrProcessingDisabled       = errors.New("podcast has no audio processing configured")
+	ErrInvalidLoudnessTarget    = fmt.Errorf("loudnessTargetLUFS must be between %g and %g", minLoudnessTargetLUFS, maxLoudnessTargetLUFS)
+)
+
+// processingEncoder is an ffmpeg audio encoder and the extension of the file
+// it produces. Lossy encoders take a bitrate; artwork is copied into
+// containers that hold cover art as a picture stream.
+type processingEncoder struct {
+	name       string
+	ext        string
+	lossy      bool
+	artwork    bool
+	sampleRate string
+}
+
+// processingCodecs are the transcode targets a podcast can choose.
+var processingCodecs = map[string]processingEncoder{
+	"mp3":  {name: "libmp3lame", ext: ".mp3", lossy: true, artwork: true, sampleRate: "44100"},
+	"aac":  {name: "aac", ext: ".m4a", lossy: true, artwork: true, sampleRate: "44100"},
+	"opus": {name: "libopus", ext: ".opus", lossy: true, sampleRate: "48000"},
+}
+
+// sourceEncoders re-encode a file in its own format when filters run without a
+// transcode. Unknown formats are converted to MP3.
+var sourceEncoders = map[string]processingEncoder{
+	".mp3":  processingCodecs["mp3"],
+	".m4a":  processingCodecs["aac"],
+	".m4b":  {name: "aac", ext: ".m4b", lossy: true, artwork: true, sampleRate: "44100"},
+	".mp4":  processingCodecs["aac"],
+	".aac":  processingCodecs["aac"],
+	".ogg":  {name: "libvorbis", ext: ".ogg", lossy: true, sampleRate: "44100"},
+	".oga":  {name: "libvorbis", ext: ".ogg", lossy: true, sampleRate: "44100"},
+	".opus": processingCodecs["opus"],
+	".flac": {name: "flac", ext: ".flac", sampleRate: "44100"},
+	".wav":  {name: "pcm_s16le", ext: ".wav", sampleRate: "44100"},
+}
+
+// ProcessingSettings are a podcast's audio processing options.
+type ProcessingSettings struct {
+	Loudnorm     bool   `json:"loudnorm"`
+	StripSilence bool   `json:"stripSilence"`
+	Codec        string `json:"codec"`
+	BitrateKbps  int    `json:"bitrateKbps"`
+}
+
+func (settings ProcessingSettings) Enabled() bool {
+	return settings.Loudnorm || settings.StripSilence || settings.Codec != ""
+}
+
+func podcastProcessingSettings(podcast db.Podcast) ProcessingSettings {
+	return ProcessingSettings{
+		Loudnorm:     podcast.ProcessLoudnorm,
+		StripSilence: podcast.ProcessStripSilence,
+		Codec:        podcast.ProcessCodec,
+		BitrateKbps:  podcast.ProcessBitrateKbps,
+	}
+}
+
+func GetPodcastProcessing(podcastId string) (ProcessingSettings, error) {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(podcastId, &podcast); err != nil {
+		return ProcessingSettings{}, err
+	}
+	return podcastProcessingSettings(podcast), nil
+}
+
+// SetPodcastProcessing validates and stores a podcast's processing options.
+// They apply to episodes downloaded from then on; earlier ones can be queued
+// with QueueEpisodeProcessing.
+func SetPodcastProcessing(podcastId string, settings ProcessingSettings) (ProcessingSettings, error) {
+	settings.Codec = strings.ToLower(strings.TrimSpace(settings.Codec))
+	if _, ok := processingCodecs[settings.Codec]; settings.Codec != "" && !ok {
+		return ProcessingSettings{}, ErrInvalidProcessingCodec
+	}
+	if settings.BitrateKbps < 0 || settings.BitrateKbps > maxProcessingBitrateKbps {
+		return ProcessingSettings{}, ErrInvalidProcessingBitrate
+	}
+	if err := db.UpdatePodcastProcessing(podcastId, settings.Loudnorm, settings.StripSilence, settings.Codec, settings.BitrateKbps); err != nil {
+		return ProcessingSettings{}, err
+	}
+	return settings, nil
+}
+
+func ValidateLoudnessTarget(lufs float64) error {
+	if lufs < minLoudnessTargetLUFS || lufs > maxLoudnessTargetLUFS {
+		return ErrInvalidLoudnessTarget
+	}
+	return nil
+}
+
+// QueueEpisodeProcessing marks a downloaded episode for processing with its
+// podcast's current options, reprocessing from the kept original if there is
+// one.
+func QueueEpisodeProcessing(id string) error {
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		return err
+	}
+	if item.DownloadStatus != db.Downloaded || item.DownloadPath == "" {
+		return ErrNotDownloaded
+	}
+	if !podcastProcessingSettings(item.Podcast).Enabled() {
+		return ErrProcessingDisabled
+	}
+	item.ProcessingStatus = ProcessingPending
+	item.ProcessingError = ""
+	return db.UpdatePodcastItem(&item)
+}
+
+// processingPlan is the ffmpeg invocation for one file.
+type processingPlan struct {
+	filters     []string
+	loudnorm    bool
+	encoder     processingEncoder
+	bitrateKbps int
+}
+
+func buildProcessingPlan(settings ProcessingSettings, loudnessTarget float64, sourcePath string) processingPlan {
+	plan := processingPlan{loudnorm: settings.Loudnorm, bitrateKbps: settings.BitrateKbps}
+	if settings.StripSilence {
+		// Trims everything before the first sound above -50 dB, keeping half a
+		// second of lead-in.
+		plan.filters = append(plan.filters, "silenceremove=start_periods=1:start_threshold=-50dB:start_silence=0.5")
+	}
+	if settings.Loudnorm {
+		plan.filters = append(plan.filters, "loudnorm=I="+strconv.FormatFloat(loudnessTarget, 'f', -1, 64)+":TP=-1.5:LRA=11")
+	}
+	if encoder, ok := processingCodecs[settings.Codec]; ok {
+		plan.encoder = encoder
+	} else if encoder, ok := sourceEncoders[strings.ToLower(filepath.Ext(sourcePath))]; ok {
+		plan.encoder = encoder
+	} else {
+		plan.encoder = processingCodecs["mp3"]
+	}
+	return plan
+}
+
+func (plan processingPlan) args(input string, output string) []string {
+	args := []string{"-hide_banner", "-nostdin", "-y", "-i", input, "-map", "0:a:0"}
+	if plan.encoder.artwork {
+		args = append(args, "-map", "0:v?", "-c:v", "copy")
+	}
+	args = append(args, "-map_metadata", "0", "-map_chapters", "0")
+	if len(plan.filters) > 0 {
+		args = append(args, "-af", strings.Join(plan.filters, ","))
+	}
+	args = append(args, "-c:a", plan.encoder.name)
+	if plan.encoder.lossy && plan.bitrateKbps > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", plan.bitrateKbps))
+	}
+	// loudnorm resamples to 192 kHz internally, so the output rate is set
+	// explicitly.
+	if plan.loudnorm {
+		args = append(args, "-ar", plan.encoder.sampleRate)
+	}
+	if plan.encoder.ext == ".mp3" {
+		args = append(args, "-id3v2_version", "3")
+	}
+	return append(args, output)
+}
+
+func resolveFFmpeg() (string, error) {
+	name := strings.TrimSpace(os.Getenv(ffmpegPathEnv))
+	if name == "" {
+		name = "ffmpeg"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg not found; install it or set %s: %w", ffmpegPathEnv, err)
+	}
+	return path, nil
+}
+
+func runFFmpeg(ffmpegPath string, args []string) error {
+	timeoutSeconds := getEnvInt(ffmpegTimeoutEnv, defaultFFmpegTimeoutSeconds)
+	ctx := context.Background()
+	cancel := func() {}
+	if timeoutSeconds > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	}
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		tail := stderr.Bytes()
+		if len(tail) > 2048 {
+			tail = tail[len(tail)-2048:]
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("ffmpeg timed out after %d seconds", timeoutSeconds)
+		}
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(tail)))
+	}
+	return nil
+}
+
+// processEpisodeAudio runs an episode through its podcast's pipeline. The
+// result replaces the download, which is either removed or kept as
+// OriginalPath. Reprocessing starts from the kept original.
+func processEpisodeAudio(ffmpegPath string, item *db.PodcastItem, setting *db.Setting) error {
+	input := item.DownloadPath
+	if item.OriginalPath != "" && FileExists(item.OriginalPath) {
+		input = item.OriginalPath
+	}
+	plan := buildProcessingPlan(podcastProcessingSettings(item.Podcast), setting.LoudnessTargetLUFS, input)
+
+	base := strings.TrimSuffix(item.DownloadPath, filepath.Ext(item.DownloadPath))
+	output := base + ".processing" + plan.encoder.ext
+	if err := runFFmpeg(ffmpegPath, plan.args(input, output)); err != nil {
+		_ = os.Remove(output)
+		return err
+	}
+	if err := checkDownloadedFile(output, 0); err != nil {
+		_ = os.Remove(output)
+		return err
+	}
+
+	finalPath := base + plan.encoder.ext
+	if input == item.DownloadPath {
+		if setting.ProcessingKeepOriginal {
+			original := base + ".original" + filepath.Ext(input)
+			if err := os.Rename(input, original); err != nil {
+				_ = os.Remove(output)
+				return err
+			}
+			item.OriginalPath = original
+		} else if finalPath != input {
+			_ = os.Remove(input)
+		}
+	} else {
+		if item.DownloadPath != finalPath {
+			_ = os.Remove(item.DownloadPath)
+		}
+		if !setting.ProcessingKeepOriginal {
+			_ = os.Remove(input)
+			item.OriginalPath = ""
+		}
+	}
+	if err := os.Rename(output, finalPath); err != nil {
+		return err
+	}
+	item.DownloadPath = finalPath
+	if extensionForMediaType(item.EnclosureType) != plan.encoder.ext {
+		item.EnclosureType = mediaTypeForExtension(plan.encoder.ext)
+	}
+
+	if setting.WriteID3Tags {
+		if err := WriteID3Tags(*item); err != nil && !errors.Is(err, ErrNotID3Taggable) {
+			Logger.Warnw("id3 tag write failed", "podcast_item_id", item.ID, "error", err)
+		}
+	}
+	refreshDownloadedFileStats(item, downloadsNow())
+	return nil
+}
+
+// ProcessDownloadedAudio runs the audio processing pipeline on downloaded
+// episodes waiting for it. Episodes left processing by an interrupted run are
+// picked up again.
+func ProcessDownloadedAudio() error {
+	const JOB_NAME = "ProcessDownloadedAudio"
+	jobLogger, _ := logging.NewJobSugar(JOB_NAME)
+
+	lock := db.GetLock(JOB_NAME)
+	if lock.IsLocked() {
+		jobLogger.Infow("job_skipped_lock_exists")
+		return nil
+	}
+	db.Lock(JOB_NAME, 120)
+	defer db.Unlock(JOB_NAME)
+
+	items, err := db.GetPodcastItemsForProcessing([]string{ProcessingPending, ProcessingInProgress}, processingBatchSize)
+	if err != nil {
+		return err
+	}
+	if len(*items) == 0 {
+		return nil
+	}
+	ffmpegPath, err := resolveFFmpeg()
+	if err != nil {
+		jobLogger.Errorw("ffmpeg resolution failed", "error", err)
+		return err
+	}
+
+	setting := db.GetOrCreateSetting()
+	processed, failed := 0, 0
+	for _, item := range *items {
+		item := item
+		if !podcastProcessingSettings(item.Podcast).Enabled() || !FileExists(item.DownloadPath) {
+			item.ProcessingStatus = ""
+			if err := db.UpdatePodcastItem(&item); err != nil {
+				jobLogger.Warnw("failed to clear processing status", "podcast_item_id", item.ID, "error", err)
+			}
+			continue
+		}
+
+		item.ProcessingStatus = ProcessingInProgress
+		if err := db.UpdatePodcastItem(&item); err != nil {
+			jobLogger.Warnw("failed to mark processing", "podcast_item_id", item.ID, "error", err)
+			continue
+		}
+		if err := processEpisodeAudio(ffmpegPath, &item, setting); err != nil {
+			failed++
+			jobLogger.Warnw("audio processing failed", "podcast_item_id", item.ID, "path", item.DownloadPath, "error", err)
+			item.ProcessingStatus = ProcessingFailed
+			item.ProcessingError = err.Error()
+		} else {
+			processed++
+			item.ProcessingStatus = ProcessingDone
+			item.ProcessingError = ""
+		}
+		if err := db.UpdatePodcastItem(&item); err != nil {
+			jobLogger.Errorw("failed to save processing result", "podcast_item_id", item.ID, "error", err)
+		}
+	}
+	jobLogger.Infow("processed downloaded audio", "processed", processed, "failed", failed)
+	return nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestProcessingPlanArgs(t *testing.T) {
+	plan := buildProcessingPlan(ProcessingSettings{Loudnorm: true, StripSilence: true}, -16, "/data/show/episode.MP3")
+	args := strings.Join(plan.args("in.mp3", "out.mp3"), " ")
+	for _, expected := range []string{
+		"-af silenceremove=start_periods=1:start_threshold=-50dB:start_silence=0.5,loudnorm=I=-16:TP=-1.5:LRA=11",
+		"-c:a libmp3lame",
+		"-ar 44100",
+		"-map 0:v? -c:v copy",
+		"-id3v2_version 3",
+	} {
+		if !strings.Contains(args, expected) {
+			t.Fatalf("expected %q in %q", expected, args)
+		}
+	}
+	if strings.Contains(args, "-b:a") {
+		t.Fatalf("expected the encoder default bitrate, got %q", args)
+	}
+
+	plan = buildProcessingPlan(ProcessingSettings{Codec: "opus", BitrateKbps: 48}, -16, "/data/show/episode.wav")
+	if plan.encoder.ext != ".opus" {
+		t.Fatalf("expected opus output, got %q", plan.encoder.ext)
+	}
+	args = strings.Join(plan.args("in.wav", "out.opus"), " ")
+	if strings.Contains(args, "-af") || strings.Contains(args, "0:v?") || strings.Contains(args, "-ar") {
+		t.Fatalf("expected a plain transcode without filters or artwork, got %q", args)
+	}
+	if !strings.Contains(args, "-c:a libopus -b:a 48k") {
+		t.Fatalf("expected opus at 48k, got %q", args)
+	}
+
+	plan = buildProcessingPlan(ProcessingSettings{Loudnorm: true}, -16, "/data/show/episode.xyz")
+	if plan.encoder.ext != ".mp3" {
+		t.Fatalf("expected unknown formats to become mp3, got %q", plan.encoder.ext)
+	}
+}
+
+// stubFFmpeg installs a fake ffmpeg that logs its input and writes an MP3
+// header to the output, or fails when fail is set.
+func stubFFmpeg(t *testing.T, dir string, fail bool) string {
+	t.Helper()
+	logPath := filepath.Join(dir, "ffmpeg.log")
+	script := "#!/bin/sh\nprev=''\nfor arg; do\n  if [ \"$prev\" = '-i' ]; then echo \"$arg\" >> '" + logPath + "'; fi\n  prev=\"$arg\"\n  out=\"$arg\"\ndone\n"
+	if fail {
+		script += "echo 'Invalid data found when processing input' >&2\nexit 1\n"
+	} else {
+		script += "printf 'ID3processed' > \"$out\"\n"
+	}
+	path := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("write ffmpeg stub failed: %v", err)
+	}
+	t.Setenv(ffmpegPathEnv, path)
+	return logPath
+}
+
+func createProcessingEpisode(t *testing.T, dir string, settings ProcessingSettings) db.PodcastItem {
+	t.Helper()
+	podcast := createPodcast(t, "Processed", false)
+	if _, err := SetPodcastProcessing(podcast.ID, settings); err != nil {
+		t.Fatalf("set processing failed: %v", err)
+	}
+	item := createDownloadedItem(t, podcast, "Episode", time.Now().UTC(), false, dir)
+	item.ProcessingStatus = ProcessingPending
+	if err := db.UpdatePodcastItem(&item); err != nil {
+		t.Fatalf("update item failed: %v", err)
+	}
+	return item
+}
+
+func reloadPodcastItem(t *testing.T, id string) db.PodcastItem {
+	t.Helper()
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	return item
+}
+
+func TestProcessDownloadedAudioReplacesOriginal(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	stubFFmpeg(t, dir, false)
+	item := createProcessingEpisode(t, dir, ProcessingSettings{Loudnorm: true, Codec: "opus"})
+
+	if err := ProcessDownloadedAudio(); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+
+	processed := reloadPodcastItem(t, item.ID)
+	if processed.ProcessingStatus != ProcessingDone {
+		t.Fatalf("expected processed status, got %q (%s)", processed.ProcessingStatus, processed.ProcessingError)
+	}
+	expectedPath := filepath.Join(dir, "Episode.opus")
+	if processed.DownloadPath != expectedPath {
+		t.Fatalf("expected download path %q, got %q", expectedPath, processed.DownloadPath)
+	}
+	if processed.EnclosureType != "audio/ogg" {
+		t.Fatalf("expected the enclosure type to follow the transcode, got %q", processed.EnclosureType)
+	}
+	if FileExists(item.DownloadPath) || processed.OriginalPath != "" {
+		t.Fatalf("expected the original to be replaced")
+	}
+	sum, _ := fileSHA256(expectedPath)
+	if processed.FileSize != int64(len("ID3processed")) || processed.FileSHA256 != sum {
+		t.Fatalf("expected size and checksum of the processed file, got %d %q", processed.FileSize, processed.FileSHA256)
+	}
+	if FileExists(filepath.Join(dir, "Episode.processing.opus")) {
+		t.Fatalf("expected no temporary output left behind")
+	}
+}
+
+func TestProcessDownloadedAudioKeepsOriginalAndReprocessesFromIt(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	logPath := stubFFmpeg(t, dir, false)
+	setting := db.GetOrCreateSetting()
+	setting.ProcessingKeepOriginal = true
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+	item := createProcessingEpisode(t, dir, ProcessingSettings{StripSilence: true})
+
+	if err := ProcessDownloadedAudio(); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	processed := reloadPodcastItem(t, item.ID)
+	original := filepath.Join(dir, "Episode.original.mp3")
+	if processed.OriginalPath != original || !FileExists(original) {
+		t.Fatalf("expected original kept at %q, got %q", original, processed.OriginalPath)
+	}
+	if processed.DownloadPath != item.DownloadPath {
+		t.Fatalf("expected processed file at %q, got %q", item.DownloadPath, processed.DownloadPath)
+	}
+
+	if err := QueueEpisodeProcessing(item.ID); err != nil {
+		t.Fatalf("queue processing failed: %v", err)
+	}
+	if err := ProcessDownloadedAudio(); err != nil {
+		t.Fatalf("reprocess failed: %v", err)
+	}
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read ffmpeg log failed: %v", err)
+	}
+	inputs := strings.Fields(string(log))
+	if len(inputs) != 2 || inputs[0] != item.DownloadPath || inputs[1] != original {
+		t.Fatalf("expected the second run to read the original, got %v", inputs)
+	}
+
+	if err := DeleteEpisodeFile(item.ID); err != nil {
+		t.Fatalf("delete episode failed: %v", err)
+	}
+	if FileExists(original) {
+		t.Fatalf("expected the original to be deleted with the episode")
+	}
+}
+
+func TestProcessDownloadedAudioRecordsFailure(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	stubFFmpeg(t, dir, true)
+	item := createProcessingEpisode(t, dir, ProcessingSettings{Loudnorm: true})
+
+	if err := ProcessDownloadedAudio(); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	failed := reloadPodcastItem(t, item.ID)
+	if failed.ProcessingStatus != ProcessingFailed || !strings.Contains(failed.ProcessingError, "Invalid data") {
+		t.Fatalf("expected failed status with ffmpeg output, got %q %q", failed.ProcessingStatus, failed.ProcessingError)
+	}
+	content, err := os.ReadFile(item.DownloadPath)
+	if err != nil || string(content) != "audio" {
+		t.Fatalf("expected the download to be untouched, got %q (%v)", content, err)
+	}
+}
+
+func TestDownloadMarksProcessingPending(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	podcast := createPodcast(t, "Pending", false)
+	plain := createDownloadedItem(t, podcast, "Plain", time.Now().UTC(), false, dir)
+	if err := QueueEpisodeProcessing(plain.ID); !errors.Is(err, ErrProcessingDisabled) {
+		t.Fatalf("expected ErrProcessingDisabled, got %v", err)
+	}
+
+	if _, err := SetPodcastProcessing(podcast.ID, ProcessingSettings{Codec: "flac"}); !errors.Is(err, ErrInvalidProcessingCodec) {
+		t.Fatalf("expected ErrInvalidProcessingCodec, got %v", err)
+	}
+	if _, err := SetPodcastProcessing(podcast.ID, ProcessingSettings{Codec: "AAC", BitrateKbps: 96}); err != nil {
+		t.Fatalf("set processing failed: %v", err)
+	}
+	if err := SetPodcastItemAsDownloaded(plain.ID, plain.DownloadPath); err != nil {
+		t.Fatalf("set downloaded failed: %v", err)
+	}
+	if status := reloadPodcastItem(t, plain.ID).ProcessingStatus; status != ProcessingPending {
+		t.Fatalf("expected pending processing, got %q", status)
+	}
+}
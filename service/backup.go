@@ -0,0 +1,497 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+const (
+	backupFormatVersion = 1
+	backupManifestName  = "backup.json"
+	backupArtworkPrefix = "artwork/"
+	// Artwork is read into memory before anything is restored, so uploaded
+	// archives are capped per file and in total.
+	backupArtworkMaxBytes      = 32 << 20
+	backupArtworkTotalMaxBytes = 512 << 20
+)
+
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// BackupManifest is the logical, database independent backup format. It is
+// stored as backup.json inside the backup archive, next to optional artwork
+// files under artwork/ that are relative to DataPath.
+type BackupManifest struct {
+	Version      int                 `json:"version"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	Driver       string              `json:"driver"`
+	DataPath     string              `json:"dataPath"`
+	Podcasts     []BackupPodcast     `json:"podcasts"`
+	PodcastItems []BackupPodcastItem `json:"podcastItems"`
+	Tags         []BackupTag         `json:"tags"`
+	PodcastTags  []db.PodcastTag     `json:"podcastTags"`
+	Setting      *db.Setting         `json:"settings"`
+	Artwork      []string            `json:"artwork"`
+	// Users and their episode state are missing from backups made before
+	// accounts existed; restoring those keeps the current users.
+	Users             []BackupUser          `json:"users,omitempty"`
+	UserEpisodeStates []db.UserEpisodeState `json:"userEpisodeStates,omitempty"`
+}
+
+// BackupPodcast exposes the podcast columns hidden from the API and drops the
+// associations, which are stored separately.
+type BackupPodcast struct {
+	db.Podcast
+	FeedMetadata string `json:"FeedMetadata"`
+	PersonsJSON  string `json:"PersonsJSON"`
+	FundingJSON  string `json:"FundingJSON"`
+	LocationJSON string `json:"LocationJSON"`
+
+	PodcastItems []db.PodcastItem `json:"-"`
+	Tags         []*db.Tag        `json:"-"`
+}
+
+type BackupPodcastItem struct {
+	db.PodcastItem
+	ChaptersJSON    string `json:"ChaptersJSON"`
+	ID3TagsJSON     string `json:"ID3TagsJSON"`
+	ID3ChaptersJSON string `json:"ID3ChaptersJSON"`
+	ItemMetadata    string `json:"ItemMetadata"`
+	TranscriptJSON  string `json:"TranscriptJSON"`
+	PersonsJSON     string `json:"PersonsJSON"`
+	SoundbitesJSON  string `json:"SoundbitesJSON"`
+	LocationJSON    string `json:"LocationJSON"`
+
+	Podcast db.Podcast `json:"-"`
+}
+
+type BackupTag struct {
+	db.Tag
+	Podcasts []*db.Podcast `json:"-"`
+}
+
+// BackupUser keeps the password hash so accounts can still log in after a
+// restore.
+type BackupUser struct {
+	db.User
+	PasswordHash string `json:"PasswordHash"`
+}
+
+type BackupRestoreResult struct {
+	Podcasts     int `json:"podcasts"`
+	PodcastItems int `json:"podcastItems"`
+	Tags         int `json:"tags"`
+	Users        int `json:"users"`
+	Artwork      int `json:"artwork"`
+}
+
+// CreateBackup writes a logical backup of the library to the backups folder.
+// Artwork is included when the BackupIncludeArtwork setting is on.
+func CreateBackup() (string, error) {
+	backupFileName := "briefcast_backup_" + backupNow().Format("2006.01.02_150405") + ".tar.gz"
+	folder := createConfigFolderIfNotExists("backups")
+	tarballFilePath := path.Join(folder, backupFileName)
+
+	snapshot, err := db.LoadLibrarySnapshot()
+	if err != nil {
+		return "", fmt.Errorf("could not read library for backup: %w", err)
+	}
+	manifest := newBackupManifest(snapshot)
+	if snapshot.Setting.BackupIncludeArtwork {
+		manifest.Artwork = collectBackupArtwork(snapshot)
+	}
+
+	file, err := os.Create(tarballFilePath)
+	if err != nil {
+		return "", fmt.Errorf("could not create tarball file '%s', got error '%s'", tarballFilePath, err.Error())
+	}
+	defer file.Close()
+
+	if err := writeBackupArchive(file, manifest); err != nil {
+		return "", err
+	}
+	deleteOldBackup()
+	return backupFileName, nil
+}
+
+func newBackupManifest(snapshot db.LibrarySnapshot) BackupManifest {
+	manifest := BackupManifest{
+		Version:      backupFormatVersion,
+		CreatedAt:    backupNow(),
+		Driver:       db.DriverName(),
+		DataPath:     os.Getenv("DATA"),
+		Podcasts:     make([]BackupPodcast, 0, len(snapshot.Podcasts)),
+		PodcastItems: make([]BackupPodcastItem, 0, len(snapshot.PodcastItems)),
+		Tags:         make([]BackupTag, 0, len(snapshot.Tags)),
+		PodcastTags:  snapshot.PodcastTags,
+		Setting:      snapshot.Setting,
+		Artwork:      []string{},
+	}
+	if manifest.PodcastTags == nil {
+		manifest.PodcastTags = []db.PodcastTag{}
+	}
+	for _, podcast := range snapshot.Podcasts {
+		manifest.Podcasts = append(manifest.Podcasts, BackupPodcast{
+			Podcast:      podcast,
+			FeedMetadata: podcast.FeedMetadata,
+			PersonsJSON:  podcast.PersonsJSON,
+			FundingJSON:  podcast.FundingJSON,
+			LocationJSON: podcast.LocationJSON,
+		})
+	}
+	for _, item := range snapshot.PodcastItems {
+		manifest.PodcastItems = append(manifest.PodcastItems, BackupPodcastItem{
+			PodcastItem:     item,
+			ChaptersJSON:    item.ChaptersJSON,
+			ID3TagsJSON:     item.ID3TagsJSON,
+			ID3ChaptersJSON: item.ID3ChaptersJSON,
+			ItemMetadata:    item.ItemMetadata,
+			TranscriptJSON:  item.TranscriptJSON,
+			PersonsJSON:     item.PersonsJSON,
+			SoundbitesJSON:  item.SoundbitesJSON,
+			LocationJSON:    item.LocationJSON,
+		})
+	}
+	for _, tag := range snapshot.Tags {
+		manifest.Tags = append(manifest.Tags, BackupTag{Tag: tag})
+	}
+	manifest.Users = make([]BackupUser, 0, len(snapshot.Users))
+	for _, user := range snapshot.Users {
+		manifest.Users = append(manifest.Users, BackupUser{User: user, PasswordHash: user.PasswordHash})
+	}
+	manifest.UserEpisodeStates = snapshot.UserEpisodeStates
+	return manifest
+}
+
+// collectBackupArtwork returns the podcast and episode images stored under the
+// data folder, relative to it.
+func collectBackupArtwork(snapshot db.LibrarySnapshot) []string {
+	dataPath := os.Getenv("DATA")
+	seen := map[string]bool{}
+	artwork := []string{}
+	add := func(filePath string) {
+		rel, ok := relativeDataPath(dataPath, filePath)
+		if !ok || seen[rel] || !FileExists(filePath) {
+			return
+		}
+		seen[rel] = true
+		artwork = append(artwork, rel)
+	}
+	for _, podcast := range snapshot.Podcasts {
+		add(path.Join(dataPath, cleanFileName(podcast.Title), getFileName(podcast.Image, "folder", ".jpg")))
+	}
+	for _, item := range snapshot.PodcastItems {
+		if item.LocalImage != "" {
+			add(item.LocalImage)
+		}
+	}
+	return artwork
+}
+
+func relativeDataPath(dataPath string, filePath string) (string, bool) {
+	if dataPath == "" || filePath == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(dataPath, filePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func writeBackupArchive(w io.Writer, manifest BackupManifest) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    backupManifestName,
+		Size:    int64(len(body)),
+		Mode:    0o644,
+		ModTime: manifest.CreatedAt,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("could not write header for '%s', got error '%s'", backupManifestName, err.Error())
+	}
+	if _, err := tarWriter.Write(body); err != nil {
+		return fmt.Errorf("could not write '%s' to the tarball, got error '%s'", backupManifestName, err.Error())
+	}
+
+	for _, rel := range manifest.Artwork {
+		if err := addFileToTarWriter(path.Join(manifest.DataPath, rel), backupArtworkPrefix+rel, tarWriter); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func addFileToTarWriter(filePath string, name string, tarWriter *tar.Writer) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("could not open file '%s', got error '%s'", filePath, err.Error())
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("could not get stat for file '%s', got error '%s'", filePath, err.Error())
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Size:    stat.Size(),
+		Mode:    int64(stat.Mode()),
+		ModTime: stat.ModTime(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("could not write header for file '%s', got error '%s'", filePath, err.Error())
+	}
+	if _, err := io.Copy(tarWriter, file); err != nil {
+		return fmt.Errorf("could not copy the file '%s' data to the tarball, got error '%s'", filePath, err.Error())
+	}
+	return nil
+}
+
+// RestoreBackupFile restores a backup archive from the backups folder.
+func RestoreBackupFile(name string) (BackupRestoreResult, error) {
+	if name == "" || name != filepath.Base(name) {
+		return BackupRestoreResult{}, fmt.Errorf("%w: unknown backup file", ErrInvalidBackup)
+	}
+	file, err := os.Open(path.Join(createConfigFolderIfNotExists("backups"), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BackupRestoreResult{}, fmt.Errorf("%w: unknown backup file", ErrInvalidBackup)
+		}
+		return BackupRestoreResult{}, err
+	}
+	defer file.Close()
+	return RestoreBackup(file)
+}
+
+// RestoreBackup validates a backup archive and replaces the library with its
+// contents. Nothing is changed when validation fails. Download and artwork
+// paths under the backup's data folder are moved to the current data folder,
+// so a backup can be restored on a different machine.
+func RestoreBackup(r io.Reader) (BackupRestoreResult, error) {
+	manifest, artwork, err := readBackupArchive(r)
+	if err != nil {
+		return BackupRestoreResult{}, err
+	}
+	if err := validateBackupManifest(manifest, artwork); err != nil {
+		return BackupRestoreResult{}, err
+	}
+
+	dataPath := os.Getenv("DATA")
+	snapshot := db.LibrarySnapshot{
+		Podcasts:     make([]db.Podcast, 0, len(manifest.Podcasts)),
+		PodcastItems: make([]db.PodcastItem, 0, len(manifest.PodcastItems)),
+		Tags:         make([]db.Tag, 0, len(manifest.Tags)),
+		PodcastTags:  manifest.PodcastTags,
+		Setting:      manifest.Setting,
+	}
+	for _, entry := range manifest.Podcasts {
+		podcast := entry.Podcast
+		podcast.FeedMetadata = entry.FeedMetadata
+		podcast.PersonsJSON = entry.PersonsJSON
+		podcast.FundingJSON = entry.FundingJSON
+		podcast.LocationJSON = entry.LocationJSON
+		snapshot.Podcasts = append(snapshot.Podcasts, podcast)
+	}
+	for _, entry := range manifest.PodcastItems {
+		item := entry.PodcastItem
+		item.ChaptersJSON = entry.ChaptersJSON
+		item.ID3TagsJSON = entry.ID3TagsJSON
+		item.ID3ChaptersJSON = entry.ID3ChaptersJSON
+		item.ItemMetadata = entry.ItemMetadata
+		item.TranscriptJSON = entry.TranscriptJSON
+		item.PersonsJSON = entry.PersonsJSON
+		item.SoundbitesJSON = entry.SoundbitesJSON
+		item.LocationJSON = entry.LocationJSON
+		item.DownloadPath = rebaseDataPath(manifest.DataPath, dataPath, item.DownloadPath)
+		item.LocalImage = rebaseDataPath(manifest.DataPath, dataPath, item.LocalImage)
+		snapshot.PodcastItems = append(snapshot.PodcastItems, item)
+	}
+	for _, entry := range manifest.Tags {
+		snapshot.Tags = append(snapshot.Tags, entry.Tag)
+	}
+	if len(manifest.Users) > 0 {
+		snapshot.Users = make([]db.User, 0, len(manifest.Users))
+		for _, entry := range manifest.Users {
+			user := entry.User
+			user.PasswordHash = entry.PasswordHash
+			snapshot.Users = append(snapshot.Users, user)
+		}
+		snapshot.UserEpisodeStates = manifest.UserEpisodeStates
+	}
+
+	if err := db.ReplaceLibrary(snapshot); err != nil {
+		return BackupRestoreResult{}, err
+	}
+	if snapshot.Users != nil {
+		clearCredentialCache()
+		if err := db.RefreshPodcastItemsPlayed(); err != nil {
+			Logger.Warnw("failed to refresh played episodes after restore", "error", err)
+		}
+	}
+
+	restoredArtwork := 0
+	for rel, content := range artwork {
+		target := path.Join(dataPath, rel)
+		if err := os.MkdirAll(path.Dir(target), 0o777); err != nil {
+			Logger.Warnw("failed to create artwork folder", "path", target, "error", err)
+			continue
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			Logger.Warnw("failed to restore artwork", "path", target, "error", err)
+			continue
+		}
+		changeOwnership(target)
+		restoredArtwork++
+	}
+
+	return BackupRestoreResult{
+		Podcasts:     len(snapshot.Podcasts),
+		PodcastItems: len(snapshot.PodcastItems),
+		Tags:         len(snapshot.Tags),
+		Users:        len(snapshot.Users),
+		Artwork:      restoredArtwork,
+	}, nil
+}
+
+func readBackupArchive(r io.Reader) (BackupManifest, map[string][]byte, error) {
+	var manifest BackupManifest
+	artwork := map[string][]byte{}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w: not a gzip archive", ErrInvalidBackup)
+	}
+	defer gzipReader.Close()
+
+	found := false
+	artworkBytes := 0
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch {
+		case header.Name == backupManifestName:
+			if err := json.NewDecoder(tarReader).Decode(&manifest); err != nil {
+				return manifest, nil, fmt.Errorf("%w: %s is not valid JSON", ErrInvalidBackup, backupManifestName)
+			}
+			found = true
+		case strings.HasPrefix(header.Name, backupArtworkPrefix):
+			if header.Size > backupArtworkMaxBytes {
+				return manifest, nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidBackup, header.Name, backupArtworkMaxBytes)
+			}
+			content, err := io.ReadAll(io.LimitReader(tarReader, backupArtworkMaxBytes+1))
+			if err != nil {
+				return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+			}
+			if len(content) > backupArtworkMaxBytes {
+				return manifest, nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidBackup, header.Name, backupArtworkMaxBytes)
+			}
+			artworkBytes += len(content)
+			if artworkBytes > backupArtworkTotalMaxBytes {
+				return manifest, nil, fmt.Errorf("%w: artwork is larger than %d bytes", ErrInvalidBackup, backupArtworkTotalMaxBytes)
+			}
+			artwork[strings.TrimPrefix(header.Name, backupArtworkPrefix)] = content
+		}
+	}
+	if !found {
+		return manifest, nil, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, backupManifestName)
+	}
+	return manifest, artwork, nil
+}
+
+func validateBackupManifest(manifest BackupManifest, artwork map[string][]byte) error {
+	if manifest.Version != backupFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, manifest.Version)
+	}
+	podcastIDs := make(map[string]bool, len(manifest.Podcasts))
+	for _, podcast := range manifest.Podcasts {
+		if podcast.ID == "" || podcastIDs[podcast.ID] {
+			return fmt.Errorf("%w: missing or duplicate podcast id", ErrInvalidBackup)
+		}
+		podcastIDs[podcast.ID] = true
+	}
+	itemIDs := make(map[string]bool, len(manifest.PodcastItems))
+	for _, item := range manifest.PodcastItems {
+		if item.ID == "" || itemIDs[item.ID] {
+			return fmt.Errorf("%w: missing or duplicate episode id", ErrInvalidBackup)
+		}
+		if !podcastIDs[item.PodcastID] {
+			return fmt.Errorf("%w: episode %s references unknown podcast %s", ErrInvalidBackup, item.ID, item.PodcastID)
+		}
+		itemIDs[item.ID] = true
+	}
+	tagIDs := make(map[string]bool, len(manifest.Tags))
+	for _, tag := range manifest.Tags {
+		if tag.ID == "" || tagIDs[tag.ID] {
+			return fmt.Errorf("%w: missing or duplicate tag id", ErrInvalidBackup)
+		}
+		tagIDs[tag.ID] = true
+	}
+	for _, link := range manifest.PodcastTags {
+		if !podcastIDs[link.PodcastID] || !tagIDs[link.TagID] {
+			return fmt.Errorf("%w: tag link references unknown podcast or tag", ErrInvalidBackup)
+		}
+	}
+	userIDs := make(map[string]bool, len(manifest.Users))
+	usernames := make(map[string]bool, len(manifest.Users))
+	for _, user := range manifest.Users {
+		if user.ID == "" || user.Username == "" || userIDs[user.ID] || usernames[user.Username] {
+			return fmt.Errorf("%w: missing or duplicate user", ErrInvalidBackup)
+		}
+		userIDs[user.ID] = true
+		usernames[user.Username] = true
+	}
+	for _, state := range manifest.UserEpisodeStates {
+		if !userIDs[state.UserID] || !itemIDs[state.PodcastItemID] {
+			return fmt.Errorf("%w: episode state references unknown user or episode", ErrInvalidBackup)
+		}
+	}
+	for rel := range artwork {
+		cleaned := path.Clean(rel)
+		if cleaned != rel || path.IsAbs(rel) || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+			return fmt.Errorf("%w: unsafe artwork path %q", ErrInvalidBackup, rel)
+		}
+	}
+	return nil
+}
+
+func rebaseDataPath(fromDataPath string, toDataPath string, filePath string) string {
+	if fromDataPath == "" || toDataPath == "" || fromDataPath == toDataPath {
+		return filePath
+	}
+	rel, ok := relativeDataPath(fromDataPath, filePath)
+	if !ok {
+		return filePath
+	}
+	return path.Join(toDataPath, rel)
+}
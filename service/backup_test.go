@@ -0,0 +1,188 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestCreateBackupAndRestoreRoundTrip(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	podcast := createPodcast(t, "backup", false)
+	if err := db.DB.Model(&db.Podcast{}).Where("id = ?", podcast.ID).Update("feed_metadata", `{"title":"backup"}`).Error; err != nil {
+		t.Fatalf("update feed metadata failed: %v", err)
+	}
+	item := createDownloadedItem(t, podcast, "backup-episode", time.Now().Add(-time.Hour), true, dataDir)
+	imagePath := filepath.Join(dataDir, "backup", "episode.jpg")
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+		t.Fatalf("failed to create image folder: %v", err)
+	}
+	if err := os.WriteFile(imagePath, []byte("jpeg"), 0o644); err != nil {
+		t.Fatalf("failed to write artwork: %v", err)
+	}
+	if err := db.DB.Model(&db.PodcastItem{}).Where("id = ?", item.ID).Update("local_image", imagePath).Error; err != nil {
+		t.Fatalf("update local image failed: %v", err)
+	}
+	tag := db.Tag{Label: "news"}
+	if err := db.CreateTag(&tag); err != nil {
+		t.Fatalf("create tag failed: %v", err)
+	}
+	if err := db.AddTagToPodcast(podcast.ID, tag.ID); err != nil {
+		t.Fatalf("add tag failed: %v", err)
+	}
+	setting := db.GetOrCreateSetting()
+	setting.DownloadOnAdd = false
+	setting.BackupIncludeArtwork = true
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	name, err := CreateBackup()
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+
+	if err := DeletePodcast(podcast.ID, false); err != nil {
+		t.Fatalf("DeletePodcast failed: %v", err)
+	}
+	_ = os.Remove(imagePath)
+	setting.DownloadOnAdd = true
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+	// Rows hanging off a podcast the backup does not have go with it.
+	stray := createPodcast(t, "stray", false)
+	strayItem := createDownloadedItem(t, stray, "stray-episode", time.Now(), false, dataDir)
+	if err := db.DB.Create(&db.DownloadQueueEntry{PodcastItemID: strayItem.ID}).Error; err != nil {
+		t.Fatalf("create queue entry failed: %v", err)
+	}
+	if err := db.CreatePodcastEvent(&db.PodcastEvent{PodcastID: stray.ID, Kind: PodcastEventURLChanged}); err != nil {
+		t.Fatalf("create podcast event failed: %v", err)
+	}
+
+	result, err := RestoreBackupFile(name)
+	if err != nil {
+		t.Fatalf("RestoreBackupFile failed: %v", err)
+	}
+	if result.Podcasts != 1 || result.PodcastItems != 1 || result.Tags != 1 || result.Artwork != 1 {
+		t.Fatalf("unexpected restore result: %+v", result)
+	}
+
+	var restored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &restored); err != nil {
+		t.Fatalf("expected podcast to be restored: %v", err)
+	}
+	if restored.FeedMetadata != `{"title":"backup"}` {
+		t.Fatalf("expected feed metadata to survive the backup, got %q", restored.FeedMetadata)
+	}
+	snapshot, err := db.LoadLibrarySnapshot()
+	if err != nil {
+		t.Fatalf("LoadLibrarySnapshot failed: %v", err)
+	}
+	if len(snapshot.PodcastTags) != 1 || snapshot.PodcastTags[0] != (db.PodcastTag{PodcastID: podcast.ID, TagID: tag.ID}) {
+		t.Fatalf("expected tag link to be restored, got %+v", snapshot.PodcastTags)
+	}
+	var restoredItem db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &restoredItem); err != nil {
+		t.Fatalf("expected episode to be restored: %v", err)
+	}
+	if !restoredItem.IsPlayed || restoredItem.DownloadStatus != db.Downloaded {
+		t.Fatalf("expected episode state to be restored, got %+v", restoredItem)
+	}
+	if !FileExists(imagePath) {
+		t.Fatalf("expected artwork to be restored")
+	}
+	if db.GetOrCreateSetting().DownloadOnAdd {
+		t.Fatalf("expected disabled setting to be restored")
+	}
+	var queued, events int64
+	db.DB.Model(&db.DownloadQueueEntry{}).Where("podcast_item_id = ?", strayItem.ID).Count(&queued)
+	db.DB.Model(&db.PodcastEvent{}).Where("podcast_id = ?", stray.ID).Count(&events)
+	if queued != 0 || events != 0 {
+		t.Fatalf("expected rows of removed podcasts to be dropped, got %d queue entries and %d events", queued, events)
+	}
+}
+
+func TestRestoreBackupRejectsOversizedArtwork(t *testing.T) {
+	setupRetentionTestDB(t)
+	existing := createPodcast(t, "existing", false)
+
+	body, err := json.Marshal(BackupManifest{Version: backupFormatVersion})
+	if err != nil {
+		t.Fatalf("marshal manifest failed: %v", err)
+	}
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range []struct {
+		name string
+		body []byte
+	}{
+		{backupManifestName, body},
+		{backupArtworkPrefix + "huge/cover.jpg", make([]byte, backupArtworkMaxBytes+1)},
+	} {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: entry.name, Size: int64(len(entry.body)), Mode: 0o644}); err != nil {
+			t.Fatalf("write header failed: %v", err)
+		}
+		if _, err := tarWriter.Write(entry.body); err != nil {
+			t.Fatalf("write entry failed: %v", err)
+		}
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	if _, err := RestoreBackup(&archive); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected ErrInvalidBackup, got %v", err)
+	}
+	var podcast db.Podcast
+	if err := db.GetPodcastById(existing.ID, &podcast); err != nil {
+		t.Fatalf("expected library to be untouched after a rejected restore: %v", err)
+	}
+}
+
+func TestRestoreBackupRejectsInvalidArchive(t *testing.T) {
+	setupRetentionTestDB(t)
+	existing := createPodcast(t, "existing", false)
+
+	manifest := BackupManifest{
+		Version:      backupFormatVersion,
+		PodcastItems: []BackupPodcastItem{{PodcastItem: db.PodcastItem{Base: db.Base{ID: "item"}, PodcastID: "missing"}}},
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshal manifest failed: %v", err)
+	}
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	if err := tarWriter.WriteHeader(&tar.Header{Name: backupManifestName, Size: int64(len(body)), Mode: 0o644}); err != nil {
+		t.Fatalf("write header failed: %v", err)
+	}
+	if _, err := tarWriter.Write(body); err != nil {
+		t.Fatalf("write manifest failed: %v", err)
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	if _, err := RestoreBackup(&archive); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected ErrInvalidBackup, got %v", err)
+	}
+	if _, err := RestoreBackup(bytes.NewBufferString("not an archive")); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected ErrInvalidBackup for garbage input, got %v", err)
+	}
+
+	var podcast db.Podcast
+	if err := db.GetPodcastById(existing.ID, &podcast); err != nil {
+		t.Fatalf("expected library to be untouched after a rejected restore: %v", err)
+	}
+}
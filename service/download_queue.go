@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ctaylor1/briefcast/db"
+	"gorm.io/gorm"
+)
+
+var ErrNotInDownloadQueue = errors.New("episode is not in the download queue")
+
+// GetDownloadQueue returns the queued episodes in download order together
+// with their queue entries.
+func GetDownloadQueue(limit int) ([]db.PodcastItem, []db.DownloadQueueEntry, error) {
+	if err := db.SyncDownloadQueue(); err != nil {
+		return nil, nil, err
+	}
+	items, err := db.GetDownloadQueueItems(limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := db.GetDownloadQueueEntries()
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, entries, nil
+}
+
+// MoveDownloadToTop puts an episode first in the queue, raising its priority
+// to the highest one queued.
+func MoveDownloadToTop(podcastItemId string) error {
+	return moveDownload(podcastItemId, true)
+}
+
+// MoveDownloadToBottom puts an episode last in the queue, lowering its
+// priority to the lowest one queued.
+func MoveDownloadToBottom(podcastItemId string) error {
+	return moveDownload(podcastItemId, false)
+}
+
+func moveDownload(podcastItemId string, toTop bool) error {
+	entries, err := loadDownloadQueue()
+	if err != nil {
+		return err
+	}
+	index := downloadQueueIndex(entries, podcastItemId)
+	if index < 0 {
+		return ErrNotInDownloadQueue
+	}
+	// Entries are sorted by priority, then position, so the first and last
+	// entries mark the ends of the queue.
+	entry := entries[index]
+	if toTop {
+		entry.Priority = entries[0].Priority
+		entry.Position = entries[0].Position - 1
+	} else {
+		last := entries[len(entries)-1]
+		entry.Priority = last.Priority
+		entry.Position = last.Position + 1
+	}
+	return db.UpdateDownloadQueueEntries([]db.DownloadQueueEntry{entry})
+}
+
+// ReorderDownloadQueue reorders the given episodes among the places they hold
+// in the queue, so a client can send the whole queue or just a slice of it.
+// Each episode takes over the position and priority of the place it moves to.
+func ReorderDownloadQueue(podcastItemIds []string) error {
+	entries, err := loadDownloadQueue()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(podcastItemIds))
+	indexes := make([]int, 0, len(podcastItemIds))
+	for _, id := range podcastItemIds {
+		index := downloadQueueIndex(entries, id)
+		if index < 0 || seen[id] {
+			return ErrNotInDownloadQueue
+		}
+		seen[id] = true
+		indexes = append(indexes, index)
+	}
+	slots := append([]int(nil), indexes...)
+	sort.Ints(slots)
+
+	updated := make([]db.DownloadQueueEntry, 0, len(indexes))
+	for i, index := range indexes {
+		entry := entries[index]
+		slot := entries[slots[i]]
+		entry.Position = slot.Position
+		entry.Priority = slot.Priority
+		updated = append(updated, entry)
+	}
+	return db.UpdateDownloadQueueEntries(updated)
+}
+
+// SetDownloadPriority changes the priority of one queued episode.
+func SetDownloadPriority(podcastItemId string, priority int) error {
+	if err := db.SyncDownloadQueue(); err != nil {
+		return err
+	}
+	var entry db.DownloadQueueEntry
+	if err := db.GetDownloadQueueEntryByPodcastItemId(podcastItemId, &entry); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotInDownloadQueue
+		}
+		return err
+	}
+	entry.Priority = priority
+	return db.UpdateDownloadQueueEntries([]db.DownloadQueueEntry{entry})
+}
+
+func SetPodcastDownloadPriority(podcastId string, priority int) error {
+	return db.UpdatePodcastDownloadPriority(podcastId, priority)
+}
+
+// RestoreDownloadsPaused applies the global pause saved before a restart.
+func RestoreDownloadsPaused() {
+	if db.GetOrCreateSetting().DownloadsPaused {
+		PauseDownloads()
+	}
+}
+
+func setDownloadsPaused(paused bool) error {
+	if paused {
+		PauseDownloads()
+	} else {
+		ResumeDownloads()
+	}
+	return db.SetDownloadsPaused(paused)
+}
+
+func loadDownloadQueue() ([]db.DownloadQueueEntry, error) {
+	if err := db.SyncDownloadQueue(); err != nil {
+		return nil, err
+	}
+	return db.GetDownloadQueueEntries()
+}
+
+func downloadQueueIndex(entries []db.DownloadQueueEntry, podcastItemId string) int {
+	for i, entry := range entries {
+		if entry.PodcastItemID == podcastItemId {
+			return i
+		}
+	}
+	return -1
+}
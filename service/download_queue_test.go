@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func createQueuedItems(t *testing.T, podcast db.Podcast, titles ...string) []db.PodcastItem {
+	t.Helper()
+	items := make([]db.PodcastItem, 0, len(titles))
+	pubDate := time.Now().UTC()
+	for _, title := range titles {
+		// Earlier titles are newer so the queue starts in the given order.
+		pubDate = pubDate.Add(-time.Hour)
+		item := db.PodcastItem{PodcastID: podcast.ID, Title: title, PubDate: pubDate, DownloadStatus: db.NotDownloaded}
+		if err := db.CreatePodcastItem(&item); err != nil {
+			t.Fatalf("create podcast item failed: %v", err)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func queuedTitles(t *testing.T) []string {
+	t.Helper()
+	items, _, err := GetDownloadQueue(0)
+	if err != nil {
+		t.Fatalf("GetDownloadQueue failed: %v", err)
+	}
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func assertQueueOrder(t *testing.T, want ...string) {
+	t.Helper()
+	got := queuedTitles(t)
+	if len(got) != len(want) {
+		t.Fatalf("expected queue %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected queue %v, got %v", want, got)
+		}
+	}
+}
+
+func TestDownloadQueueOrdering(t *testing.T) {
+	setupRetentionTestDB(t)
+	podcast := createPodcast(t, "queue", false)
+	items := createQueuedItems(t, podcast, "a", "b", "c", "d")
+	assertQueueOrder(t, "a", "b", "c", "d")
+
+	if err := MoveDownloadToTop(items[2].ID); err != nil {
+		t.Fatalf("MoveDownloadToTop failed: %v", err)
+	}
+	assertQueueOrder(t, "c", "a", "b", "d")
+
+	if err := MoveDownloadToBottom(items[0].ID); err != nil {
+		t.Fatalf("MoveDownloadToBottom failed: %v", err)
+	}
+	assertQueueOrder(t, "c", "b", "d", "a")
+
+	if err := ReorderDownloadQueue([]string{items[3].ID, items[1].ID}); err != nil {
+		t.Fatalf("ReorderDownloadQueue failed: %v", err)
+	}
+	assertQueueOrder(t, "c", "d", "b", "a")
+
+	if err := SetDownloadPriority(items[0].ID, 5); err != nil {
+		t.Fatalf("SetDownloadPriority failed: %v", err)
+	}
+	assertQueueOrder(t, "a", "c", "d", "b")
+
+	toDownload, err := db.GetAllPodcastItemsToBeDownloaded()
+	if err != nil {
+		t.Fatalf("GetAllPodcastItemsToBeDownloaded failed: %v", err)
+	}
+	if len(*toDownload) != 4 || (*toDownload)[0].Title != "a" {
+		t.Fatalf("expected downloads to follow the queue, got %+v", *toDownload)
+	}
+
+	if err := SetPodcastItemAsNotDownloaded(items[2].ID, db.Deleted); err != nil {
+		t.Fatalf("SetPodcastItemAsNotDownloaded failed: %v", err)
+	}
+	assertQueueOrder(t, "a", "d", "b")
+	if err := MoveDownloadToTop(items[2].ID); err != ErrNotInDownloadQueue {
+		t.Fatalf("expected ErrNotInDownloadQueue, got %v", err)
+	}
+}
+
+func TestPodcastDownloadPriority(t *testing.T) {
+	setupRetentionTestDB(t)
+	normal := createPodcast(t, "normal", false)
+	urgent := createPodcast(t, "urgent", false)
+	createQueuedItems(t, normal, "normal-1")
+	assertQueueOrder(t, "normal-1")
+
+	if err := SetPodcastDownloadPriority(urgent.ID, 10); err != nil {
+		t.Fatalf("SetPodcastDownloadPriority failed: %v", err)
+	}
+	createQueuedItems(t, urgent, "urgent-1")
+	assertQueueOrder(t, "urgent-1", "normal-1")
+
+	if err := SetPodcastDownloadPriority(urgent.ID, -1); err != nil {
+		t.Fatalf("SetPodcastDownloadPriority failed: %v", err)
+	}
+	assertQueueOrder(t, "normal-1", "urgent-1")
+}
+
+func TestDownloadsPauseIsPersisted(t *testing.T) {
+	setupRetentionTestDB(t)
+	t.Cleanup(ResumeDownloads)
+
+	if err := PauseAllDownloads(); err != nil {
+		t.Fatalf("PauseAllDownloads failed: %v", err)
+	}
+	ResumeDownloads()
+	RestoreDownloadsPaused()
+	if !DownloadsPaused() {
+		t.Fatalf("expected saved pause to be restored")
+	}
+
+	if err := ResumeAllDownloads(); err != nil {
+		t.Fatalf("ResumeAllDownloads failed: %v", err)
+	}
+	if db.GetOrCreateSetting().DownloadsPaused {
+		t.Fatalf("expected resume to be saved")
+	}
+}
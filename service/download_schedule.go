@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+var ErrInvalidDownloadWindow = errors.New("download window times must use HH:MM")
+
+var downloadWindowNow = func() time.Time {
+	return time.Now()
+}
+
+// downloadBandwidth paces every running download against the shared
+// DownloadMaxBytesPerSecond budget.
+var downloadBandwidth = &bandwidthLimiter{}
+
+type bandwidthLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	nextAllowed    time.Time
+}
+
+// ParseDownloadWindowTime validates an "HH:MM" window bound and returns the
+// minutes since midnight. An empty value is allowed and returns -1.
+func ParseDownloadWindowTime(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return -1, nil
+	}
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, ErrInvalidDownloadWindow
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// DownloadWindowOpen reports whether scheduled downloads may start at now.
+// Windows whose end is before their start wrap around midnight, and an unset
+// or empty window is always open.
+func DownloadWindowOpen(setting *db.Setting, now time.Time) bool {
+	start, startErr := ParseDownloadWindowTime(setting.DownloadWindowStart)
+	end, endErr := ParseDownloadWindowTime(setting.DownloadWindowEnd)
+	if startErr != nil || endErr != nil || start < 0 || end < 0 || start == end {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// SetDownloadBandwidthLimit changes the shared download speed cap. Running
+// downloads pick up the new limit with their next read.
+func SetDownloadBandwidthLimit(bytesPerSecond int64) {
+	downloadBandwidth.setRate(bytesPerSecond)
+}
+
+func (limiter *bandwidthLimiter) setRate(bytesPerSecond int64) {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.bytesPerSecond != bytesPerSecond {
+		limiter.bytesPerSecond = bytesPerSecond
+		limiter.nextAllowed = time.Time{}
+	}
+}
+
+// chunkSize limits reads so a single read never takes much more than a second
+// of the budget.
+func (limiter *bandwidthLimiter) chunkSize(bufferSize int) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.bytesPerSecond > 0 && limiter.bytesPerSecond < int64(bufferSize) {
+		return int(limiter.bytesPerSecond)
+	}
+	return bufferSize
+}
+
+func (limiter *bandwidthLimiter) wait(n int) {
+	if delay := limiter.reserve(n, time.Now()); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// reserve books n bytes against the budget and returns how long the caller
+// has to wait before using them.
+func (limiter *bandwidthLimiter) reserve(n int, now time.Time) time.Duration {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.bytesPerSecond <= 0 || n <= 0 {
+		return 0
+	}
+	if limiter.nextAllowed.Before(now) {
+		limiter.nextAllowed = now
+	}
+	wait := limiter.nextAllowed.Sub(now)
+	limiter.nextAllowed = limiter.nextAllowed.Add(time.Duration(int64(n) * int64(time.Second) / limiter.bytesPerSecond))
+	return wait
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestDownloadWindowOpen(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 16, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name  string
+		start string
+		end   string
+		now   time.Time
+		open  bool
+	}{
+		{"unset", "", "", at(12, 0), true},
+		{"inside", "01:00", "06:00", at(3, 30), true},
+		{"before", "01:00", "06:00", at(0, 59), false},
+		{"end is exclusive", "01:00", "06:00", at(6, 0), false},
+		{"wraps midnight late", "22:00", "06:00", at(23, 15), true},
+		{"wraps midnight early", "22:00", "06:00", at(5, 0), true},
+		{"wraps midnight outside", "22:00", "06:00", at(12, 0), false},
+	}
+	for _, tc := range cases {
+		setting := &db.Setting{DownloadWindowStart: tc.start, DownloadWindowEnd: tc.end}
+		if got := DownloadWindowOpen(setting, tc.now); got != tc.open {
+			t.Fatalf("%s: expected open=%v, got %v", tc.name, tc.open, got)
+		}
+	}
+	if _, err := ParseDownloadWindowTime("25:00"); err != ErrInvalidDownloadWindow {
+		t.Fatalf("expected ErrInvalidDownloadWindow, got %v", err)
+	}
+}
+
+func TestDownloadMissingEpisodesWaitsForWindow(t *testing.T) {
+	setupRetentionTestDB(t)
+	originalNow := downloadWindowNow
+	downloadWindowNow = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { downloadWindowNow = originalNow })
+
+	setting := db.GetOrCreateSetting()
+	setting.DownloadWindowStart = "01:00"
+	setting.DownloadWindowEnd = "06:00"
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+	podcast := createPodcast(t, "window", false)
+	item := db.PodcastItem{PodcastID: podcast.ID, Title: "episode", FileURL: "://bad-url", DownloadStatus: db.NotDownloaded}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+
+	if err := DownloadMissingEpisodes(); err != nil {
+		t.Fatalf("DownloadMissingEpisodes failed: %v", err)
+	}
+	var stored db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &stored); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if stored.DownloadStatus != db.NotDownloaded || stored.DownloadAttempts != 0 {
+		t.Fatalf("expected episode to stay queued outside the window, got %+v", stored)
+	}
+}
+
+func TestBandwidthLimiterPacesReservations(t *testing.T) {
+	limiter := &bandwidthLimiter{}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	if wait := limiter.reserve(1000, now); wait != 0 {
+		t.Fatalf("expected unlimited limiter not to wait, got %v", wait)
+	}
+
+	limiter.setRate(1000)
+	if size := limiter.chunkSize(32 * 1024); size != 1000 {
+		t.Fatalf("expected reads capped to one second of budget, got %d", size)
+	}
+	if wait := limiter.reserve(500, now); wait != 0 {
+		t.Fatalf("expected first reservation to run immediately, got %v", wait)
+	}
+	if wait := limiter.reserve(500, now); wait != 500*time.Millisecond {
+		t.Fatalf("expected second reservation to wait 500ms, got %v", wait)
+	}
+	if wait := limiter.reserve(1000, now.Add(200*time.Millisecond)); wait != 800*time.Millisecond {
+		t.Fatalf("expected shared budget to delay third reservation by 800ms, got %v", wait)
+	}
+}
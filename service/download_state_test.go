@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -69,3 +70,90 @@ func TestDownloadStateTransitions(t *testing.T) {
 		t.Fatalf("expected progress cleared, got %d/%d", cleared.DownloadedBytes, cleared.DownloadTotalBytes)
 	}
 }
+
+func TestRecordDownloadFailureBacksOffAndFails(t *testing.T) {
+	setupRetentionTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	originalNow := downloadsNow
+	downloadsNow = func() time.Time { return now }
+	t.Cleanup(func() { downloadsNow = originalNow })
+
+	setting := db.GetOrCreateSetting()
+	setting.DownloadMaxAttempts = 3
+	setting.DownloadRetryBackoffMinutes = 10
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	podcast := createPodcast(t, "download-retry", false)
+	item := db.PodcastItem{PodcastID: podcast.ID, Title: "episode", DownloadStatus: db.NotDownloaded}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+
+	for attempt, wantDelay := range []time.Duration{10 * time.Minute, 20 * time.Minute} {
+		if err := recordDownloadFailure(item.ID, errors.New("connection reset")); err != nil {
+			t.Fatalf("recordDownloadFailure failed: %v", err)
+		}
+		var stored db.PodcastItem
+		if err := db.GetPodcastItemById(item.ID, &stored); err != nil {
+			t.Fatalf("reload item failed: %v", err)
+		}
+		if stored.DownloadStatus != db.NotDownloaded || stored.DownloadAttempts != attempt+1 {
+			t.Fatalf("expected queued item with %d attempts, got status %v attempts %d", attempt+1, stored.DownloadStatus, stored.DownloadAttempts)
+		}
+		if !stored.NextDownloadAttempt.Equal(now.Add(wantDelay)) {
+			t.Fatalf("expected next attempt at %v, got %v", now.Add(wantDelay), stored.NextDownloadAttempt)
+		}
+		if stored.LastDownloadError != "connection reset" {
+			t.Fatalf("expected last error to be recorded, got %q", stored.LastDownloadError)
+		}
+	}
+
+	queued, err := db.GetAllPodcastItemsToBeDownloaded()
+	if err != nil {
+		t.Fatalf("GetAllPodcastItemsToBeDownloaded failed: %v", err)
+	}
+	for _, queuedItem := range *queued {
+		if queuedItem.ID == item.ID {
+			t.Fatalf("expected item waiting for backoff to be skipped")
+		}
+	}
+
+	if err := recordDownloadFailure(item.ID, errors.New("connection reset")); err != nil {
+		t.Fatalf("recordDownloadFailure failed: %v", err)
+	}
+	failed, err := GetFailedDownloads(0)
+	if err != nil {
+		t.Fatalf("GetFailedDownloads failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].DownloadStatus != db.Failed || failed[0].DownloadAttempts != 3 {
+		t.Fatalf("expected one failed item after max attempts, got %+v", failed)
+	}
+
+	if count, err := RetryFailedDownloads(); err != nil || count != 1 {
+		t.Fatalf("expected one retried item, got %d, %v", count, err)
+	}
+	var retried db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &retried); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if retried.DownloadStatus != db.NotDownloaded || retried.DownloadAttempts != 0 || !retried.NextDownloadAttempt.IsZero() {
+		t.Fatalf("expected retry to reset attempts, got %+v", retried)
+	}
+	if err := RetryFailedDownload(item.ID); err != ErrNoFailedDownload {
+		t.Fatalf("expected ErrNoFailedDownload, got %v", err)
+	}
+}
+
+func TestDownloadRetryDelayIsCapped(t *testing.T) {
+	if got := downloadRetryDelay(15, 1); got != 15*time.Minute {
+		t.Fatalf("expected base delay, got %v", got)
+	}
+	if got := downloadRetryDelay(15, 4); got != 2*time.Hour {
+		t.Fatalf("expected doubled delay, got %v", got)
+	}
+	if got := downloadRetryDelay(15, 30); got != maxDownloadRetryDelay {
+		t.Fatalf("expected capped delay, got %v", got)
+	}
+}
@@ -1,6 +1,20 @@
 package service
 
-import "github.com/ctaylor1/briefcast/db"
+import (
+	"errors"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// maxDownloadRetryDelay caps the exponential backoff between failed attempts.
+const maxDownloadRetryDelay = 24 * time.Hour
+
+var downloadsNow = func() time.Time {
+	return time.Now().UTC()
+}
+
+var ErrNoFailedDownload = errors.New("episode has no failed download")
 
 func CancelEpisodeDownload(id string) error {
 	var item db.PodcastItem
@@ -27,7 +41,9 @@ func ResumeEpisodeDownload(id string) (bool, error) {
 		return false, err
 	}
 
-	ResumeDownloads()
+	if err := setDownloadsPaused(false); err != nil {
+		return false, err
+	}
 	ClearDownloadPause(item.ID)
 
 	switch item.DownloadStatus {
@@ -50,7 +66,9 @@ func CancelAllDownloads() error {
 }
 
 func PauseAllDownloads() error {
-	PauseDownloads()
+	if err := setDownloadsPaused(true); err != nil {
+		return err
+	}
 	queued, err := db.GetPodcastItemsByDownloadStatuses([]db.DownloadStatus{db.NotDownloaded}, 0)
 	if err != nil {
 		return err
@@ -70,7 +88,9 @@ func PauseAllDownloads() error {
 }
 
 func ResumeAllDownloads() error {
-	ResumeDownloads()
+	if err := setDownloadsPaused(false); err != nil {
+		return err
+	}
 	paused, err := db.GetPodcastItemsByDownloadStatuses([]db.DownloadStatus{db.Paused}, 0)
 	if err != nil {
 		return err
@@ -81,3 +101,92 @@ func ResumeAllDownloads() error {
 	}
 	return nil
 }
+
+// recordDownloadFailure counts a failed download attempt. The episode goes
+// back to the queue with its next attempt pushed out, or becomes Failed once
+// the configured number of attempts is used up.
+func recordDownloadFailure(id string, downloadErr error) error {
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		return err
+	}
+	setting := db.GetOrCreateSetting()
+	now := downloadsNow()
+
+	item.DownloadDate = time.Time{}
+	item.DownloadPath = ""
+	item.DownloadedBytes = 0
+	item.DownloadTotalBytes = 0
+	item.DownloadAttempts++
+	item.LastDownloadError = downloadErr.Error()
+	item.LastDownloadAttempt = now
+	if setting.DownloadMaxAttempts > 0 && item.DownloadAttempts >= setting.DownloadMaxAttempts {
+		item.DownloadStatus = db.Failed
+		item.NextDownloadAttempt = time.Time{}
+	} else {
+		item.DownloadStatus = db.NotDownloaded
+		item.NextDownloadAttempt = now.Add(downloadRetryDelay(setting.DownloadRetryBackoffMinutes, item.DownloadAttempts))
+	}
+	if err := db.UpdatePodcastItem(&item); err != nil {
+		return err
+	}
+	FireHookEvent(HookEventDownloadFailed, item, downloadErr)
+	return nil
+}
+
+// downloadRetryDelay doubles the base delay after every failed attempt.
+func downloadRetryDelay(backoffMinutes int, attempts int) time.Duration {
+	if backoffMinutes <= 0 || attempts <= 0 {
+		return 0
+	}
+	delay := time.Duration(backoffMinutes) * time.Minute
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDownloadRetryDelay {
+			return maxDownloadRetryDelay
+		}
+	}
+	if delay > maxDownloadRetryDelay {
+		return maxDownloadRetryDelay
+	}
+	return delay
+}
+
+func resetDownloadAttempts(item *db.PodcastItem) {
+	item.DownloadAttempts = 0
+	item.NextDownloadAttempt = time.Time{}
+}
+
+func GetFailedDownloads(limit int) ([]db.PodcastItem, error) {
+	return db.GetFailedPodcastItems(limit)
+}
+
+// RetryFailedDownload queues a failed episode again with a fresh attempt
+// count. The last error is kept until the next attempt finishes.
+func RetryFailedDownload(id string) error {
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		return err
+	}
+	if item.DownloadStatus != db.Failed && !(item.DownloadStatus == db.NotDownloaded && item.DownloadAttempts > 0) {
+		return ErrNoFailedDownload
+	}
+	item.DownloadStatus = db.NotDownloaded
+	resetDownloadAttempts(&item)
+	return db.UpdatePodcastItem(&item)
+}
+
+// RetryFailedDownloads queues every failed episode again and returns how many
+// were queued.
+func RetryFailedDownloads() (int, error) {
+	items, err := db.GetFailedPodcastItems(0)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		if err := RetryFailedDownload(item.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// ApplyUserEpisodeState replaces the listener fields on the items with the
+// user's own played, bookmark and progress state.
+func ApplyUserEpisodeState(userID string, items []db.PodcastItem) error {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	states, err := db.GetUserEpisodeStates(userID, ids)
+	if err != nil {
+		return err
+	}
+	byItem := make(map[string]db.UserEpisodeState, len(*states))
+	for _, state := range *states {
+		byItem[state.PodcastItemID] = state
+	}
+	for i := range items {
+		applyEpisodeState(&items[i], byItem[items[i].ID])
+	}
+	return nil
+}
+
+func applyEpisodeState(item *db.PodcastItem, state db.UserEpisodeState) {
+	item.IsPlayed = state.IsPlayed
+	item.BookmarkDate = state.BookmarkDate
+	item.PlaybackPosition = state.PlaybackPosition
+	item.PlaybackCompletion = state.PlaybackCompletion
+	item.PlaybackUpdatedAt = state.PlaybackUpdatedAt
+}
+
+// getUserPodcastItem loads an episode together with the user's state for it.
+func getUserPodcastItem(userID string, id string) (db.PodcastItem, db.UserEpisodeState, error) {
+	var podcastItem db.PodcastItem
+	if err := db.GetPodcastItemById(id, &podcastItem); err != nil {
+		return podcastItem, db.UserEpisodeState{}, err
+	}
+	state, err := db.GetUserEpisodeState(userID, podcastItem.ID)
+	if err != nil {
+		return podcastItem, state, err
+	}
+	applyEpisodeState(&podcastItem, state)
+	return podcastItem, state, nil
+}
+
+// saveUserEpisodeState stores the user's state and refreshes the library-wide
+// played flag of the episode.
+func saveUserEpisodeState(state *db.UserEpisodeState) error {
+	if err := db.SaveUserEpisodeState(state); err != nil {
+		return err
+	}
+	return db.RefreshPodcastItemsPlayed(state.PodcastItemID)
+}
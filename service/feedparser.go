@@ -3,14 +3,21 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/feedparse"
 )
 
 type FeedParserResult struct {
@@ -28,8 +35,130 @@ const (
 	feedparserScriptEnv             = "FEEDPARSER_SCRIPT"
 	feedparserTimeoutEnv            = "FEEDPARSER_TIMEOUT_SECONDS"
 	logOutputEnv                    = "LOG_OUTPUT"
+
+	FeedParserBackendNative = "native"
+	FeedParserBackendPython = "python"
 )
 
+// FeedValidators holds the cache validators of a previously fetched feed.
+type FeedValidators struct {
+	ETag         string
+	LastModified string
+	BodyHash     string
+}
+
+// ErrFeedNotModified is returned by FetchFeedBodyConditional when the server
+// answers 304 or the body hashes to the previously stored value.
+var ErrFeedNotModified = errors.New("feed not modified")
+
+// FetchFeedBodyConditional downloads a feed using If-None-Match and
+// If-Modified-Since from the previous fetch. The returned validators describe
+// the new body and should be stored once it has been processed. movedTo is
+// the last URL reached through permanent redirects (301 or 308) from the
+// start; the first temporary redirect ends the chain, so a 301 followed by a
+// 302 reports the 301's target. It is only a candidate: callers should move
+// the feed once the body there parses.
+func FetchFeedBodyConditional(url string, previous FeedValidators) (body []byte, validators FeedValidators, movedTo string, err error) {
+	req, err := getRequest(url)
+	if err != nil {
+		return nil, previous, "", err
+	}
+	if previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+	if previous.LastModified != "" {
+		req.Header.Set("If-Modified-Since", previous.LastModified)
+	}
+
+	client := httpClient()
+	permanent := true
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if permanent && r.Response != nil && (r.Response.StatusCode == http.StatusMovedPermanently || r.Response.StatusCode == http.StatusPermanentRedirect) {
+			movedTo = r.URL.String()
+		} else {
+			permanent = false
+		}
+		return nil
+	}
+	resp, err := doRequestWithHostLimit(client, req)
+	if err != nil {
+		return nil, previous, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, previous, movedTo, ErrFeedNotModified
+	}
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, previous, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, previous, "", nil
+	}
+
+	sum := sha256.Sum256(body)
+	current := FeedValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		BodyHash:     hex.EncodeToString(sum[:]),
+	}
+	if previous.BodyHash != "" && current.BodyHash == previous.BodyHash {
+		return body, current, movedTo, ErrFeedNotModified
+	}
+	return body, current, movedTo, nil
+}
+
+// FetchFeed downloads a feed and parses it with the configured backend.
+func FetchFeed(url string) (FeedParserResult, []byte, error) {
+	body, err := makeQuery(url)
+	if err != nil {
+		return FeedParserResult{}, nil, err
+	}
+	parsed, err := ParseFeed(body)
+	if err != nil {
+		return FeedParserResult{}, body, err
+	}
+	return parsed, body, nil
+}
+
+// ParseFeed parses a feed body with the backend selected in settings. The
+// native parser falls back to the Python feedparser helper when it cannot
+// read the document.
+func ParseFeed(body []byte) (FeedParserResult, error) {
+	setting := db.GetOrCreateSetting()
+	if NormalizeFeedParserBackend(setting.FeedParserBackend) == FeedParserBackendPython {
+		return ParseFeedWithFeedparser(body)
+	}
+
+	parsed, err := ParseFeedNative(body)
+	if err == nil {
+		return parsed, nil
+	}
+	Logger.Warnw("native feed parser failed; falling back to python feedparser", "error", err)
+	fallback, fallbackErr := ParseFeedWithFeedparser(body)
+	if fallbackErr != nil {
+		return FeedParserResult{}, fmt.Errorf("native parser: %w; python fallback: %v", err, fallbackErr)
+	}
+	return fallback, nil
+}
+
+func ParseFeedNative(body []byte) (FeedParserResult, error) {
+	parsed, err := feedparse.Parse(body)
+	if err != nil {
+		return FeedParserResult{}, err
+	}
+	return FeedParserResult(parsed), nil
+}
+
+// NormalizeFeedParserBackend maps unknown or empty values to the native parser.
+func NormalizeFeedParserBackend(backend string) string {
+	if strings.EqualFold(strings.TrimSpace(backend), FeedParserBackendPython) {
+		return FeedParserBackendPython
+	}
+	return FeedParserBackendNative
+}
+
 func FetchFeedWithFeedparser(url string) (FeedParserResult, []byte, error) {
 	body, err := makeQuery(url)
 	if err != nil {
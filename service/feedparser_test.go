@@ -7,6 +7,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
 )
 
 func TestResolvePythonExplicitPath(t *testing.T) {
@@ -112,6 +116,85 @@ func TestFetchFeedWithFeedparser(t *testing.T) {
 	}
 }
 
+func TestFetchFeedBodyConditional(t *testing.T) {
+	setupRetentionTestDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 14 Oct 2026 10:00:00 GMT")
+		_, _ = w.Write([]byte("<rss>feed-body</rss>"))
+	}))
+	defer server.Close()
+
+	body, validators, _, err := FetchFeedBodyConditional(server.URL, FeedValidators{})
+	if err != nil {
+		t.Fatalf("FetchFeedBodyConditional failed: %v", err)
+	}
+	if string(body) != "<rss>feed-body</rss>" || validators.ETag != `"v1"` || validators.BodyHash == "" {
+		t.Fatalf("unexpected fetch result body=%q validators=%+v", string(body), validators)
+	}
+
+	if _, _, _, err := FetchFeedBodyConditional(server.URL, validators); err != ErrFeedNotModified {
+		t.Fatalf("expected ErrFeedNotModified for 304, got %v", err)
+	}
+
+	hashOnly := FeedValidators{BodyHash: validators.BodyHash}
+	if _, current, _, err := FetchFeedBodyConditional(server.URL, hashOnly); err != ErrFeedNotModified {
+		t.Fatalf("expected ErrFeedNotModified for identical body, got %v", err)
+	} else if current.ETag != `"v1"` {
+		t.Fatalf("expected refreshed validators on identical body, got %+v", current)
+	}
+}
+
+func TestAddPodcastItemsSkipsParseForUnchangedFeed(t *testing.T) {
+	setupRetentionTestDB(t)
+	pythonPath := requireWorkingPython(t)
+
+	tempDir := t.TempDir()
+	scriptPath := filepath.Join(tempDir, "feedparser_once.py")
+	body := "#!/usr/bin/env python3\nimport json\nprint(json.dumps({'feed': {'title':'Cached Feed'}, 'entries': []}))\n"
+	if err := os.WriteFile(scriptPath, []byte(body), 0o755); err != nil {
+		t.Fatalf("failed to write stub script: %v", err)
+	}
+	t.Setenv(feedparserPythonEnv, pythonPath)
+	t.Setenv(feedparserScriptEnv, scriptPath)
+	setting := db.GetOrCreateSetting()
+	setting.FeedParserBackend = FeedParserBackendPython
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<rss>unchanged</rss>"))
+	}))
+	defer server.Close()
+
+	podcast := db.Podcast{Title: "cached", URL: server.URL}
+	if err := db.CreatePodcast(&podcast); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&podcast, false); err != nil {
+		t.Fatalf("first AddPodcastItems failed: %v", err)
+	}
+
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err != nil {
+		t.Fatalf("reload podcast failed: %v", err)
+	}
+	if stored.FeedBodyHash == "" {
+		t.Fatalf("expected feed body hash to be stored")
+	}
+
+	t.Setenv(feedparserScriptEnv, filepath.Join(tempDir, "missing.py"))
+	if err := AddPodcastItems(&stored, false); err != nil {
+		t.Fatalf("expected unchanged feed to skip feedparser, got %v", err)
+	}
+}
+
 func TestSanitizeHelperLogOutput(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -155,3 +238,193 @@ func TestParseFeedWithFeedparserSuppressesStdoutLoggingNoise(t *testing.T) {
 		t.Fatalf("expected parsed feed title, got %+v", parsed.Feed)
 	}
 }
+
+const backendParityRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>Parity Show</title>
+	<link>https://example.com/</link>
+	<description>Parity show description</description>
+	<itunes:author>Parity Host</itunes:author>
+	<itunes:image href="https://example.com/parity.jpg" />
+	<item>
+		<title>Parity Episode</title>
+		<guid isPermaLink="false">parity-1</guid>
+		<pubDate>Tue, 03 Mar 2026 10:00:00 +0000</pubDate>
+		<description>Parity summary</description>
+		<content:encoded><![CDATA[<p>Parity show notes for the episode</p>]]></content:encoded>
+		<enclosure url="https://cdn.example.com/parity.mp3" length="1234" type="audio/mpeg" />
+		<itunes:duration>10:00</itunes:duration>
+		<itunes:episodeType>full</itunes:episodeType>
+	</item>
+</channel>
+</rss>`
+
+const backendParityAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Parity Atom</title>
+	<link href="https://example.org/" />
+	<id>urn:uuid:parity-atom</id>
+	<updated>2026-03-01T12:00:00Z</updated>
+	<author><name>Atom Host</name></author>
+	<entry>
+		<title>Parity Atom Episode</title>
+		<id>urn:uuid:parity-atom-1</id>
+		<updated>2026-03-02T09:00:00Z</updated>
+		<link rel="enclosure" type="audio/mpeg" length="42" href="https://example.org/parity.mp3" />
+		<summary>Parity atom summary</summary>
+	</entry>
+</feed>`
+
+type feedBackendSummary struct {
+	Title       string
+	Author      string
+	Image       string
+	ShowNotes   string
+	EntryTitle  string
+	GUID        string
+	Enclosure   string
+	PubDate     time.Time
+	Duration    int
+	EpisodeType string
+	EntryNotes  string
+}
+
+func summarizeParsedFeed(t *testing.T, parsed FeedParserResult) feedBackendSummary {
+	t.Helper()
+	if len(parsed.Entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(parsed.Entries))
+	}
+	feed := parsed.Feed
+	entry := parsed.Entries[0]
+	return feedBackendSummary{
+		Title:       feedmeta.GetString(feed, "title"),
+		Author:      feedmeta.PickFirstNonEmpty(feedmeta.GetString(feed, "itunes_author"), feedmeta.GetString(feed, "author")),
+		Image:       feedmeta.ExtractImageURL(feed),
+		ShowNotes:   feedmeta.ExtractFeedShowNotesHTML(feed),
+		EntryTitle:  feedmeta.GetString(entry, "title"),
+		GUID:        feedmeta.ExtractEntryGUID(entry),
+		Enclosure:   feedmeta.ExtractEnclosureURL(entry),
+		PubDate:     feedmeta.ParseEntryDate(entry).UTC(),
+		Duration:    feedmeta.ParseDurationSeconds(feedmeta.GetString(entry, "itunes_duration")),
+		EpisodeType: feedmeta.GetString(entry, "itunes_episodetype"),
+		EntryNotes:  feedmeta.ExtractEntryShowNotesHTML(entry),
+	}
+}
+
+// forEachFeedBackend runs fn against the native parser and, when python and
+// the feedparser module are installed, the real feedparser helper script.
+func forEachFeedBackend(t *testing.T, fn func(t *testing.T, parse func([]byte) (FeedParserResult, error))) {
+	t.Run(FeedParserBackendNative, func(t *testing.T) {
+		fn(t, ParseFeedNative)
+	})
+	t.Run(FeedParserBackendPython, func(t *testing.T) {
+		pythonPath := requireFeedparserModule(t)
+		scriptPath, err := filepath.Abs(filepath.Join("..", defaultFeedparserScript))
+		if err != nil {
+			t.Fatalf("failed to resolve feedparser script: %v", err)
+		}
+		t.Setenv(feedparserPythonEnv, pythonPath)
+		t.Setenv(feedparserScriptEnv, scriptPath)
+		fn(t, ParseFeedWithFeedparser)
+	})
+}
+
+func TestFeedParserBackendsAgreeOnRSS(t *testing.T) {
+	forEachFeedBackend(t, func(t *testing.T, parse func([]byte) (FeedParserResult, error)) {
+		parsed, err := parse([]byte(backendParityRSS))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if parsed.Version != "rss20" {
+			t.Fatalf("unexpected version %q", parsed.Version)
+		}
+		got := summarizeParsedFeed(t, parsed)
+		if got.Title != "Parity Show" || got.Author != "Parity Host" || got.Image != "https://example.com/parity.jpg" {
+			t.Fatalf("unexpected feed fields %+v", got)
+		}
+		if got.ShowNotes != "Parity show description" {
+			t.Fatalf("unexpected feed show notes %q", got.ShowNotes)
+		}
+		if got.EntryTitle != "Parity Episode" || got.GUID != "parity-1" || got.Enclosure != "https://cdn.example.com/parity.mp3" {
+			t.Fatalf("unexpected entry fields %+v", got)
+		}
+		if !got.PubDate.Equal(time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected entry date %v", got.PubDate)
+		}
+		if got.Duration != 600 || got.EpisodeType != "full" {
+			t.Fatalf("unexpected itunes fields %+v", got)
+		}
+		if !strings.Contains(got.EntryNotes, "Parity show notes for the episode") {
+			t.Fatalf("unexpected entry show notes %q", got.EntryNotes)
+		}
+	})
+}
+
+func TestFeedParserBackendsAgreeOnAtom(t *testing.T) {
+	forEachFeedBackend(t, func(t *testing.T, parse func([]byte) (FeedParserResult, error)) {
+		parsed, err := parse([]byte(backendParityAtom))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if parsed.Version != "atom10" {
+			t.Fatalf("unexpected version %q", parsed.Version)
+		}
+		got := summarizeParsedFeed(t, parsed)
+		if got.Title != "Parity Atom" || got.Author != "Atom Host" {
+			t.Fatalf("unexpected feed fields %+v", got)
+		}
+		if got.GUID != "urn:uuid:parity-atom-1" || got.Enclosure != "https://example.org/parity.mp3" {
+			t.Fatalf("unexpected entry fields %+v", got)
+		}
+		if !got.PubDate.Equal(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected entry date %v", got.PubDate)
+		}
+		if got.EntryNotes != "Parity atom summary" {
+			t.Fatalf("unexpected entry show notes %q", got.EntryNotes)
+		}
+	})
+}
+
+func TestParseFeedFallsBackToPython(t *testing.T) {
+	setupRetentionTestDB(t)
+	pythonPath := requireWorkingPython(t)
+
+	tempDir := t.TempDir()
+	scriptPath := filepath.Join(tempDir, "feedparser_fallback.py")
+	body := "#!/usr/bin/env python3\nimport json\nprint(json.dumps({'feed': {'title':'Fallback Feed'}, 'entries': []}))\n"
+	if err := os.WriteFile(scriptPath, []byte(body), 0o755); err != nil {
+		t.Fatalf("failed to write fallback script: %v", err)
+	}
+	t.Setenv(feedparserPythonEnv, pythonPath)
+	t.Setenv(feedparserScriptEnv, scriptPath)
+
+	parsed, err := ParseFeed([]byte("<rss version=\"2.0\"><channel><title>Native Feed</title></channel></rss>"))
+	if err != nil {
+		t.Fatalf("ParseFeed failed: %v", err)
+	}
+	if parsed.Feed["title"] != "Native Feed" {
+		t.Fatalf("expected native parser by default, got %+v", parsed.Feed)
+	}
+
+	parsed, err = ParseFeed([]byte("<html><body>not a feed</body></html>"))
+	if err != nil {
+		t.Fatalf("ParseFeed fallback failed: %v", err)
+	}
+	if parsed.Feed["title"] != "Fallback Feed" {
+		t.Fatalf("expected python fallback result, got %+v", parsed.Feed)
+	}
+
+	setting := db.GetOrCreateSetting()
+	setting.FeedParserBackend = FeedParserBackendPython
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+	parsed, err = ParseFeed([]byte("<rss version=\"2.0\"><channel><title>Native Feed</title></channel></rss>"))
+	if err != nil {
+		t.Fatalf("ParseFeed with python backend failed: %v", err)
+	}
+	if parsed.Feed["title"] != "Fallback Feed" {
+		t.Fatalf("expected python backend to be used, got %+v", parsed.Feed)
+	}
+}
@@ -1,8 +1,6 @@
 package service
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -34,7 +32,11 @@ const (
 	httpTimeoutEnv            = "HTTP_TIMEOUT_SECONDS"
 )
 
-func Download(downloadID string, link string, episodeTitle string, podcastName string, prefix string) (string, error) {
+// Download fetches an episode to target, a path without extension as built by
+// EpisodeDownloadTarget. The file extension comes from mediaType or the URL;
+// when neither names a media format the finished file is sniffed and renamed
+// to match its content.
+func Download(downloadID string, link string, target string, mediaType string) (string, error) {
 	if link == "" {
 		return "", errors.New("Download path empty")
 	}
@@ -45,17 +47,35 @@ func Download(downloadID string, link string, episodeTitle string, podcastName s
 		logError("error creating request", err, "url", link)
 		return "", err
 	}
-	fileName := getFileName(link, episodeTitle, ".mp3")
-	if prefix != "" {
-		fileName = fmt.Sprintf("%s-%s", prefix, fileName)
+	ext := episodeFileExtension(link, mediaType)
+	sniffExtension := ext == ""
+	if ext == "" {
+		ext = filepath.Ext(getFileName(link, "", ".mp3"))
 	}
-	folder := createDataFolderIfNotExists(podcastName)
-	finalPath := path.Join(folder, fileName)
+	if err := ensureFolder(filepath.Dir(target)); err != nil {
+		logError("error creating folder", err, "path", target, "url", link)
+		return "", err
+	}
+	finalPath := target + ext
 
 	var resumeOffset int64
 	if info, statErr := os.Stat(finalPath); statErr == nil {
 		resumeOffset = info.Size()
 	}
+
+	if segments, totalBytes := segmentedDownloadFor(client, link, finalPath, resumeOffset); segments > 0 {
+		if err := downloadSegmented(client, downloadID, link, finalPath, totalBytes, segments); err != nil {
+			if err != ErrDownloadPaused && err != ErrDownloadCancelled {
+				logError("error downloading segments", err, "path", finalPath, "url", link)
+			}
+			return "", err
+		}
+		if err := verifyDownload(finalPath, link, totalBytes, false); err != nil {
+			return "", err
+		}
+		return finishDownload(finalPath, sniffExtension), nil
+	}
+
 	if resumeOffset > 0 {
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeOffset))
 	}
@@ -111,8 +131,9 @@ func Download(downloadID string, link string, episodeTitle string, podcastName s
 			ClearDownloadCancellation(downloadID)
 			return "", ErrDownloadCancelled
 		}
-		n, readErr := resp.Body.Read(buffer)
+		n, readErr := resp.Body.Read(buffer[:downloadBandwidth.chunkSize(len(buffer))])
 		if n > 0 {
+			downloadBandwidth.wait(n)
 			downloadedBytes += int64(n)
 			if _, writeErr := file.Write(buffer[:n]); writeErr != nil {
 				logError("error saving file", writeErr, "path", finalPath, "url", link)
@@ -137,12 +158,34 @@ func Download(downloadID string, link string, episodeTitle string, podcastName s
 	if downloadID != "" {
 		_ = db.UpdatePodcastItemDownloadProgress(downloadID, downloadedBytes, totalBytes)
 	}
-	defer file.Close()
-	changeOwnership(finalPath)
-	return finalPath, nil
+	if err := file.Close(); err != nil {
+		logError("error saving file", err, "path", finalPath, "url", link)
+		return "", err
+	}
+	if err := verifyDownload(finalPath, link, totalBytes, downloadedBytes < totalBytes); err != nil {
+		return "", err
+	}
+	return finishDownload(finalPath, sniffExtension), nil
 
 }
 
+// finishDownload hands a verified file to the configured owner and, when its
+// extension was a guess, renames it after the sniffed container.
+func finishDownload(finalPath string, sniffExtension bool) string {
+	if sniffExtension {
+		if ext := sniffFileExtension(finalPath); ext != "" && !strings.EqualFold(ext, filepath.Ext(finalPath)) {
+			renamed := strings.TrimSuffix(finalPath, filepath.Ext(finalPath)) + ext
+			if err := os.Rename(finalPath, renamed); err != nil {
+				logError("error renaming download to its sniffed type", err, "path", finalPath)
+			} else {
+				finalPath = renamed
+			}
+		}
+	}
+	changeOwnership(finalPath)
+	return finalPath
+}
+
 func resolveTotalBytes(resp *http.Response, resumeOffset int64) int64 {
 	if resp == nil {
 		return 0
@@ -386,66 +429,6 @@ func GetFileSizeFromUrl(url string) (int64, error) {
 	return int64(size), nil
 }
 
-func CreateBackup() (string, error) {
-	backupFileName := "briefcast_backup_" + backupNow().Format("2006.01.02_150405") + ".tar.gz"
-	folder := createConfigFolderIfNotExists("backups")
-	configPath := os.Getenv("CONFIG")
-	tarballFilePath := path.Join(folder, backupFileName)
-	file, err := os.Create(tarballFilePath)
-	if err != nil {
-		return "", errors.New(fmt.Sprintf("Could not create tarball file '%s', got error '%s'", tarballFilePath, err.Error()))
-	}
-	defer file.Close()
-
-	dbPath := path.Join(configPath, "briefcast.db")
-	_, err = os.Stat(dbPath)
-	if err != nil {
-		return "", errors.New(fmt.Sprintf("Could not find db file '%s', got error '%s'", dbPath, err.Error()))
-	}
-	gzipWriter := gzip.NewWriter(file)
-	defer gzipWriter.Close()
-
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer tarWriter.Close()
-
-	err = addFileToTarWriter(dbPath, tarWriter)
-	if err == nil {
-		deleteOldBackup()
-	}
-	return backupFileName, err
-}
-
-func addFileToTarWriter(filePath string, tarWriter *tar.Writer) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Could not open file '%s', got error '%s'", filePath, err.Error()))
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return errors.New(fmt.Sprintf("Could not get stat for file '%s', got error '%s'", filePath, err.Error()))
-	}
-
-	header := &tar.Header{
-		Name:    filePath,
-		Size:    stat.Size(),
-		Mode:    int64(stat.Mode()),
-		ModTime: stat.ModTime(),
-	}
-
-	err = tarWriter.WriteHeader(header)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Could not write header for file '%s', got error '%s'", filePath, err.Error()))
-	}
-
-	_, err = io.Copy(tarWriter, file)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Could not copy the file '%s' data to the tarball, got error '%s'", filePath, err.Error()))
-	}
-
-	return nil
-}
 func httpClient() *http.Client {
 	timeoutSeconds := getEnvInt(httpTimeoutEnv, defaultHTTPTimeoutSeconds)
 	client := http.Client{
@@ -490,6 +473,19 @@ func createFolder(folder string, parent string) string {
 	return folderPath
 }
 
+// ensureFolder creates a folder and its parents, handing new ones to the
+// configured owner.
+func ensureFolder(folderPath string) error {
+	if _, err := os.Stat(folderPath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(folderPath, 0777); err != nil {
+		return err
+	}
+	changeOwnership(folderPath)
+	return nil
+}
+
 func createDataFolderIfNotExists(folder string) string {
 	dataPath := os.Getenv("DATA")
 	return createFolder(folder, dataPath)
@@ -64,7 +64,7 @@ func TestFileHelpers(t *testing.T) {
 }
 
 func TestDownloadReturnsErrorForInvalidURL(t *testing.T) {
-	if _, err := Download("", "://bad-url", "Episode", "Podcast", ""); err == nil {
+	if _, err := Download("", "://bad-url", filepath.Join(os.Getenv("DATA"), "Podcast", "Episode"), ""); err == nil {
 		t.Fatalf("expected download to fail for invalid URL")
 	}
 }
@@ -105,7 +105,7 @@ func TestAddFileToTarWriter(t *testing.T) {
 
 	var buffer bytes.Buffer
 	tw := tar.NewWriter(&buffer)
-	if err := addFileToTarWriter(filePath, tw); err != nil {
+	if err := addFileToTarWriter(filePath, "artwork/archive-me.txt", tw); err != nil {
 		t.Fatalf("addFileToTarWriter failed: %v", err)
 	}
 	if err := tw.Close(); err != nil {
@@ -114,4 +114,11 @@ func TestAddFileToTarWriter(t *testing.T) {
 	if buffer.Len() == 0 {
 		t.Fatalf("expected tar output")
 	}
+	header, err := tar.NewReader(&buffer).Next()
+	if err != nil {
+		t.Fatalf("failed to read tar output: %v", err)
+	}
+	if header.Name != "artwork/archive-me.txt" {
+		t.Fatalf("expected entry name to be kept, got %q", header.Name)
+	}
 }
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/model"
+	"gorm.io/gorm"
+)
+
+const (
+	subscriptionAdded   = "add"
+	subscriptionRemoved = "remove"
+
+	gpodderLocalDevice     = "briefcast"
+	gpodderTimestampLayout = "2006-01-02T15:04:05"
+)
+
+var gpodderNow = func() time.Time {
+	return time.Now().UTC()
+}
+
+var (
+	ErrGpodderConflictingChanges = errors.New("the same podcast cannot be added and removed in one request")
+	ErrGpodderInvalidAction      = errors.New("unsupported episode action")
+	ErrGpodderInvalidTimestamp   = errors.New("invalid episode action timestamp")
+	ErrGpodderRemoveForbidden    = errors.New("only admins can remove podcasts from the library")
+)
+
+func recordSubscriptionChange(url string, action string) {
+	if err := db.CreateSubscriptionChange(url, action); err != nil {
+		Logger.Warnw("failed to record subscription change", "url", url, "action", action, "error", err)
+	}
+}
+
+func recordPlayEpisodeAction(userID string, podcastItem db.PodcastItem, progress PlaybackProgress) {
+	position := progress.Position
+	action := db.EpisodeAction{
+		UserID:        userID,
+		PodcastItemID: podcastItem.ID,
+		PodcastURL:    podcastItem.Podcast.URL,
+		EpisodeURL:    podcastItem.FileURL,
+		GUID:          podcastItem.GUID,
+		Device:        gpodderLocalDevice,
+		Action:        "play",
+		Timestamp:     progress.UpdatedAt,
+		Position:      &position,
+	}
+	if progress.Duration > 0 {
+		total := progress.Duration
+		action.Total = &total
+	}
+	if err := db.CreateEpisodeAction(&action); err != nil {
+		Logger.Warnw("failed to record episode action", "podcast_item_id", podcastItem.ID, "error", err)
+	}
+}
+
+func UpsertGpodderDevice(deviceID string, update model.GpodderDeviceUpdate) error {
+	_, err := db.SaveGpodderDevice(deviceID, update.Caption, update.Type)
+	return err
+}
+
+// ListGpodderDevices returns the registered devices. Briefcast keeps a single
+// subscription list, so every device reports the same subscription count.
+func ListGpodderDevices() ([]model.GpodderDevice, error) {
+	devices, err := db.GetAllGpodderDevices()
+	if err != nil {
+		return nil, err
+	}
+	var podcasts []db.Podcast
+	if err := db.GetAllPodcasts(&podcasts, ""); err != nil {
+		return nil, err
+	}
+
+	result := make([]model.GpodderDevice, 0, len(*devices))
+	for _, device := range *devices {
+		result = append(result, model.GpodderDevice{
+			ID:            device.DeviceID,
+			Caption:       device.Caption,
+			Type:          device.Type,
+			Subscriptions: len(podcasts),
+		})
+	}
+	return result, nil
+}
+
+// GetSubscriptionChanges returns the subscription changes since the given unix
+// timestamp. A zero timestamp returns the full subscription list as additions.
+func GetSubscriptionChanges(since int64) (model.GpodderSubscriptionChanges, error) {
+	now := gpodderNow()
+	var podcasts []db.Podcast
+	if err := db.GetAllPodcasts(&podcasts, ""); err != nil {
+		return model.GpodderSubscriptionChanges{}, err
+	}
+	subscribed := make(map[string]bool, len(podcasts))
+	for _, podcast := range podcasts {
+		subscribed[podcast.URL] = true
+	}
+
+	response := model.GpodderSubscriptionChanges{Add: []string{}, Remove: []string{}, Timestamp: now.Unix()}
+	if since <= 0 {
+		for _, podcast := range podcasts {
+			response.Add = append(response.Add, podcast.URL)
+		}
+		sort.Strings(response.Add)
+		return response, nil
+	}
+
+	changes, err := db.GetSubscriptionChangesSince(time.Unix(since, 0).UTC())
+	if err != nil {
+		return model.GpodderSubscriptionChanges{}, err
+	}
+	latest := make(map[string]string)
+	for _, change := range *changes {
+		latest[change.URL] = change.Action
+	}
+	for url, action := range latest {
+		switch {
+		case action == subscriptionAdded && subscribed[url]:
+			response.Add = append(response.Add, url)
+		case action == subscriptionRemoved && !subscribed[url]:
+			response.Remove = append(response.Remove, url)
+		}
+	}
+	sort.Strings(response.Add)
+	sort.Strings(response.Remove)
+	return response, nil
+}
+
+// ApplySubscriptionChanges subscribes to and removes podcasts uploaded by a
+// gPodder client. URLs that had to be cleaned up are reported in update_urls.
+// Subscriptions are shared by all users, so removals need canRemove, which
+// callers grant to admins only.
+func ApplySubscriptionChanges(changes model.GpodderSubscriptionChanges, canRemove bool) (model.GpodderUploadResponse, error) {
+	response := model.GpodderUploadResponse{UpdateURLs: [][2]string{}}
+	add, addRewrites := sanitizeGpodderURLs(changes.Add)
+	remove, removeRewrites := sanitizeGpodderURLs(changes.Remove)
+	for _, url := range add {
+		for _, removed := range remove {
+			if url == removed {
+				return response, ErrGpodderConflictingChanges
+			}
+		}
+	}
+	if len(remove) > 0 && !canRemove {
+		return response, ErrGpodderRemoveForbidden
+	}
+	response.UpdateURLs = append(response.UpdateURLs, addRewrites...)
+	response.UpdateURLs = append(response.UpdateURLs, removeRewrites...)
+
+	if len(add) > 0 {
+		setting := db.GetOrCreateSetting()
+		workers := boundedWorkerCount(setting.MaxDownloadConcurrency, 4, len(add))
+		runWorkerPool(add, workers, func(url string) {
+			_, addErr := AddPodcast(url)
+			if addErr == nil {
+				return
+			}
+			if _, alreadyExists := addErr.(*model.PodcastAlreadyExistsError); alreadyExists {
+				return
+			}
+			Logger.Warnw("Failed to add podcast from gPodder sync", "url", url, "error", addErr)
+		})
+		go RefreshEpisodes()
+	}
+
+	for _, url := range remove {
+		var podcast db.Podcast
+		if err := db.GetPodcastByURL(url, &podcast); err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				Logger.Warnw("Failed to look up podcast from gPodder sync", "url", url, "error", err)
+			}
+			continue
+		}
+		// Downloaded files stay on disk; a client unsubscribing should not
+		// destroy the library.
+		if err := DeletePodcast(podcast.ID, false); err != nil {
+			Logger.Warnw("Failed to remove podcast from gPodder sync", "url", url, "error", err)
+		}
+	}
+
+	response.Timestamp = gpodderNow().Unix()
+	return response, nil
+}
+
+func sanitizeGpodderURLs(urls []string) ([]string, [][2]string) {
+	cleaned := make([]string, 0, len(urls))
+	rewrites := [][2]string{}
+	seen := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		trimmed := strings.TrimSpace(url)
+		if trimmed != url {
+			rewrites = append(rewrites, [2]string{url, trimmed})
+		}
+		if trimmed == "" || seen[trimmed] {
+			continue
+		}
+		seen[trimmed] = true
+		cleaned = append(cleaned, trimmed)
+	}
+	return cleaned, rewrites
+}
+
+// ListEpisodeActions returns the user's episode actions recorded since the given
+// unix timestamp, optionally filtered by podcast and device. With aggregated set only
+// the latest action per episode is returned.
+func ListEpisodeActions(userID string, since int64, podcastURL string, device string, aggregated bool) (model.GpodderEpisodeActions, error) {
+	now := gpodderNow()
+	actions, err := db.GetEpisodeActionsSince(userID, time.Unix(since, 0).UTC(), podcastURL, device)
+	if err != nil {
+		return model.GpodderEpisodeActions{}, err
+	}
+
+	stored := *actions
+	if aggregated {
+		latest := make(map[string]int)
+		var order []string
+		for i, action := range stored {
+			key := action.PodcastURL + "\x00" + action.EpisodeURL
+			if _, ok := latest[key]; !ok {
+				order = append(order, key)
+			}
+			latest[key] = i
+		}
+		aggregatedActions := make([]db.EpisodeAction, 0, len(order))
+		for _, key := range order {
+			aggregatedActions = append(aggregatedActions, stored[latest[key]])
+		}
+		stored = aggregatedActions
+	}
+
+	response := model.GpodderEpisodeActions{Actions: make([]model.GpodderEpisodeAction, 0, len(stored)), Timestamp: now.Unix()}
+	for _, action := range stored {
+		response.Actions = append(response.Actions, model.GpodderEpisodeAction{
+			Podcast:   action.PodcastURL,
+			Episode:   action.EpisodeURL,
+			GUID:      action.GUID,
+			Device:    action.Device,
+			Action:    action.Action,
+			Timestamp: action.Timestamp.UTC().Format(gpodderTimestampLayout),
+			Started:   action.Started,
+			Position:  action.Position,
+			Total:     action.Total,
+		})
+	}
+	return response, nil
+}
+
+// ApplyEpisodeActions stores episode actions uploaded by the user and applies
+// the ones that match a known episode: play updates the playback position, download queues
+// the episode, delete removes the local file and new resets it to unplayed.
+func ApplyEpisodeActions(userID string, actions []model.GpodderEpisodeAction) (model.GpodderUploadResponse, error) {
+	response := model.GpodderUploadResponse{UpdateURLs: [][2]string{}}
+	parsed := make([]db.EpisodeAction, 0, len(actions))
+	for _, action := range actions {
+		name := strings.ToLower(strings.TrimSpace(action.Action))
+		switch name {
+		case "play", "download", "delete", "new":
+		default:
+			return response, ErrGpodderInvalidAction
+		}
+		timestamp, err := parseGpodderTimestamp(action.Timestamp)
+		if err != nil {
+			return response, err
+		}
+		parsed = append(parsed, db.EpisodeAction{
+			UserID:     userID,
+			PodcastURL: strings.TrimSpace(action.Podcast),
+			EpisodeURL: strings.TrimSpace(action.Episode),
+			GUID:       action.GUID,
+			Device:     action.Device,
+			Action:     name,
+			Timestamp:  timestamp,
+			Started:    action.Started,
+			Position:   action.Position,
+			Total:      action.Total,
+		})
+	}
+
+	for i := range parsed {
+		action := &parsed[i]
+		podcastItem, found := findEpisodeForAction(*action)
+		if found {
+			action.PodcastItemID = podcastItem.ID
+			if err := applyEpisodeAction(userID, podcastItem, *action); err != nil {
+				Logger.Warnw("failed to apply episode action", "podcast_item_id", podcastItem.ID, "action", action.Action, "error", err)
+			}
+		}
+		if err := db.CreateEpisodeAction(action); err != nil {
+			return response, err
+		}
+	}
+
+	response.Timestamp = gpodderNow().Unix()
+	return response, nil
+}
+
+func parseGpodderTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return gpodderNow(), nil
+	}
+	if parsed, err := time.Parse(gpodderTimestampLayout, value); err == nil {
+		return parsed.UTC(), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.UTC(), nil
+	}
+	return time.Time{}, ErrGpodderInvalidTimestamp
+}
+
+func findEpisodeForAction(action db.EpisodeAction) (db.PodcastItem, bool) {
+	var podcast db.Podcast
+	if err := findPodcastByURL(action.PodcastURL, &podcast); err != nil {
+		return db.PodcastItem{}, false
+	}
+	var podcastItem db.PodcastItem
+	if action.EpisodeURL != "" {
+		if err := db.GetPodcastItemByPodcastIdAndFileURL(podcast.ID, action.EpisodeURL, &podcastItem); err == nil {
+			return podcastItem, true
+		}
+	}
+	if action.GUID != "" {
+		if err := db.GetPodcastItemByPodcastIdAndGUID(podcast.ID, action.GUID, &podcastItem); err == nil {
+			return podcastItem, true
+		}
+	}
+	return db.PodcastItem{}, false
+}
+
+func applyEpisodeAction(userID string, podcastItem db.PodcastItem, action db.EpisodeAction) error {
+	switch action.Action {
+	case "play":
+		if action.Position == nil {
+			return nil
+		}
+		update := PlaybackProgressUpdate{Position: *action.Position, UpdatedAt: action.Timestamp}
+		if action.Total != nil {
+			update.Duration = *action.Total
+		}
+		_, _, _, err := applyPodcastItemProgress(userID, podcastItem.ID, update)
+		return err
+	case "download":
+		if podcastItem.DownloadStatus == db.Deleted {
+			return SetPodcastItemAsQueuedForDownload(podcastItem.ID)
+		}
+	case "delete":
+		if podcastItem.DownloadStatus == db.Downloaded {
+			return DeleteEpisodeFile(podcastItem.ID)
+		}
+	case "new":
+		state, err := db.GetUserEpisodeState(userID, podcastItem.ID)
+		if err != nil {
+			return err
+		}
+		state.IsPlayed = false
+		state.PlaybackPosition = 0
+		state.PlaybackCompletion = 0
+		state.PlaybackUpdatedAt = action.Timestamp
+		return saveUserEpisodeState(&state)
+	}
+	return nil
+}
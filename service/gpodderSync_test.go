@@ -0,0 +1,153 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/model"
+)
+
+func createGpodderItem(t *testing.T) (db.Podcast, db.PodcastItem) {
+	t.Helper()
+	podcast := createPodcast(t, "gpodder", false)
+	item := db.PodcastItem{
+		PodcastID: podcast.ID,
+		Title:     "gpodder-episode",
+		GUID:      "gpodder-guid",
+		FileURL:   "https://example.com/gpodder.mp3",
+		Duration:  1000,
+		PubDate:   time.Now().UTC(),
+	}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	return podcast, item
+}
+
+func TestGetSubscriptionChangesTracksRemovals(t *testing.T) {
+	setupRetentionTestDB(t)
+	kept := createPodcast(t, "kept", false)
+	removed := createPodcast(t, "removed", false)
+
+	initial, err := GetSubscriptionChanges(0)
+	if err != nil {
+		t.Fatalf("GetSubscriptionChanges failed: %v", err)
+	}
+	if len(initial.Add) != 2 || len(initial.Remove) != 0 {
+		t.Fatalf("expected full subscription list on first sync, got %+v", initial)
+	}
+
+	since := time.Now().UTC().Add(-time.Minute).Unix()
+	if err := DeletePodcast(removed.ID, false); err != nil {
+		t.Fatalf("DeletePodcast failed: %v", err)
+	}
+	changes, err := GetSubscriptionChanges(since)
+	if err != nil {
+		t.Fatalf("GetSubscriptionChanges failed: %v", err)
+	}
+	if len(changes.Remove) != 1 || changes.Remove[0] != removed.URL {
+		t.Fatalf("expected removal of %s, got %+v", removed.URL, changes)
+	}
+	for _, url := range changes.Add {
+		if url == kept.URL {
+			t.Fatalf("expected unchanged podcast to be left out of incremental sync")
+		}
+	}
+}
+
+func TestApplySubscriptionChangesRejectsConflicts(t *testing.T) {
+	setupRetentionTestDB(t)
+	url := "https://example.com/feed.xml"
+	_, err := ApplySubscriptionChanges(model.GpodderSubscriptionChanges{Add: []string{url}, Remove: []string{" " + url}}, true)
+	if err != ErrGpodderConflictingChanges {
+		t.Fatalf("expected ErrGpodderConflictingChanges, got %v", err)
+	}
+}
+
+func TestApplySubscriptionChangesKeepsFilesOfRemovedPodcasts(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	podcast := createPodcast(t, "unsubscribed", false)
+	item := createDownloadedItem(t, podcast, "unsubscribed-episode", time.Now(), false, filepath.Join(tempDir, "assets"))
+
+	if _, err := ApplySubscriptionChanges(model.GpodderSubscriptionChanges{Remove: []string{podcast.URL}}, false); err != ErrGpodderRemoveForbidden {
+		t.Fatalf("expected ErrGpodderRemoveForbidden without canRemove, got %v", err)
+	}
+	if err := db.GetPodcastById(podcast.ID, &db.Podcast{}); err != nil {
+		t.Fatalf("expected the podcast to survive a refused removal: %v", err)
+	}
+	if _, err := ApplySubscriptionChanges(model.GpodderSubscriptionChanges{Remove: []string{podcast.URL}}, true); err != nil {
+		t.Fatalf("ApplySubscriptionChanges failed: %v", err)
+	}
+	var removed db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &removed); err == nil {
+		t.Fatalf("expected podcast to be removed")
+	}
+	if !FileExists(item.DownloadPath) {
+		t.Fatalf("expected downloaded files to be kept")
+	}
+}
+
+func TestApplyEpisodeActionsUpdatesPlayback(t *testing.T) {
+	setupRetentionTestDB(t)
+	podcast, item := createGpodderItem(t)
+
+	position, total := 300, 1000
+	timestamp := time.Now().UTC().Add(-time.Minute).Format(gpodderTimestampLayout)
+	_, err := ApplyEpisodeActions(defaultUserID(t), []model.GpodderEpisodeAction{{
+		Podcast:   podcast.URL,
+		Episode:   item.FileURL,
+		Device:    "phone",
+		Action:    "play",
+		Timestamp: timestamp,
+		Position:  &position,
+		Total:     &total,
+	}})
+	if err != nil {
+		t.Fatalf("ApplyEpisodeActions failed: %v", err)
+	}
+
+	progress, err := GetPodcastItemProgress(defaultUserID(t), item.ID)
+	if err != nil {
+		t.Fatalf("GetPodcastItemProgress failed: %v", err)
+	}
+	if progress.Position != 300 {
+		t.Fatalf("expected uploaded position to be applied, got %+v", progress)
+	}
+
+	actions, err := ListEpisodeActions(defaultUserID(t), 0, podcast.URL, "", false)
+	if err != nil {
+		t.Fatalf("ListEpisodeActions failed: %v", err)
+	}
+	if len(actions.Actions) != 1 || actions.Actions[0].Device != "phone" || actions.Actions[0].Timestamp != timestamp {
+		t.Fatalf("expected uploaded action to be listed once, got %+v", actions.Actions)
+	}
+
+	if _, err := ApplyEpisodeActions(defaultUserID(t), []model.GpodderEpisodeAction{{Podcast: podcast.URL, Episode: item.FileURL, Action: "skip"}}); err != ErrGpodderInvalidAction {
+		t.Fatalf("expected ErrGpodderInvalidAction, got %v", err)
+	}
+}
+
+func TestSetPodcastItemProgressRecordsEpisodeAction(t *testing.T) {
+	setupRetentionTestDB(t)
+	podcast, item := createGpodderItem(t)
+
+	if _, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 100}); err != nil {
+		t.Fatalf("SetPodcastItemProgress failed: %v", err)
+	}
+	if _, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 200}); err != nil {
+		t.Fatalf("SetPodcastItemProgress failed: %v", err)
+	}
+
+	actions, err := ListEpisodeActions(defaultUserID(t), 0, podcast.URL, gpodderLocalDevice, true)
+	if err != nil {
+		t.Fatalf("ListEpisodeActions failed: %v", err)
+	}
+	if len(actions.Actions) != 1 {
+		t.Fatalf("expected aggregated actions to collapse to one, got %+v", actions.Actions)
+	}
+	if actions.Actions[0].Position == nil || *actions.Actions[0].Position != 200 {
+		t.Fatalf("expected latest position 200, got %+v", actions.Actions[0])
+	}
+}
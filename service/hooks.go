@@ -0,0 +1,334 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// Hook kinds and the lifecycle events hooks can subscribe to.
+const (
+	HookKindExec    = "exec"
+	HookKindWebhook = "webhook"
+
+	HookEventEpisodeDiscovered   = "episode.discovered"
+	HookEventDownloadCompleted   = "download.completed"
+	HookEventDownloadFailed      = "download.failed"
+	HookEventTranscriptAvailable = "transcript.available"
+)
+
+var HookEvents = []string{HookEventEpisodeDiscovered, HookEventDownloadCompleted, HookEventDownloadFailed, HookEventTranscriptAvailable}
+
+const (
+	hookSignatureHeader = "X-Briefcast-Signature"
+	hookEventHeader     = "X-Briefcast-Event"
+	hookDeliveryLogSize = 100
+	hookOutputLimit     = 4096
+)
+
+var (
+	ErrInvalidHookKind   = errors.New("kind must be exec or webhook")
+	ErrInvalidHookEvent  = errors.New("events must be one or more of " + strings.Join(HookEvents, ", "))
+	ErrInvalidHookTarget = errors.New("target must be a command path for exec hooks or an http(s) URL for webhooks")
+)
+
+// hookDispatch runs deliveries in the background so downloads and refreshes
+// never wait on a hook. Tests replace it to deliver synchronously.
+var hookDispatch = func(deliver func()) {
+	go deliver()
+}
+
+// hookRetryDelay is the wait before the given retry, doubling each time.
+var hookRetryDelay = func(attempt int) time.Duration {
+	return time.Duration(1<<uint(attempt-1)) * time.Second
+}
+
+// HookPayload is the JSON document sent to hooks on stdin or as the webhook
+// body.
+type HookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Episode   HookEpisode `json:"episode"`
+	Error     string      `json:"error,omitempty"`
+	Attempts  int         `json:"attempts,omitempty"`
+	// Final is set on download.failed once no more retries are scheduled.
+	Final bool `json:"final,omitempty"`
+}
+
+type HookEpisode struct {
+	ID               string    `json:"id"`
+	GUID             string    `json:"guid"`
+	Title            string    `json:"title"`
+	PodcastID        string    `json:"podcastId"`
+	PodcastTitle     string    `json:"podcastTitle"`
+	PubDate          time.Time `json:"pubDate"`
+	FileURL          string    `json:"fileUrl"`
+	DownloadPath     string    `json:"downloadPath,omitempty"`
+	FileSize         int64     `json:"fileSize,omitempty"`
+	TranscriptStatus string    `json:"transcriptStatus,omitempty"`
+}
+
+func GetAllHooks() (*[]db.Hook, error) {
+	return db.GetAllHooks()
+}
+
+// CreateHook validates and stores a hook. Events are stored as a
+// comma-separated list in the order of HookEvents.
+func CreateHook(hook db.Hook) (db.Hook, error) {
+	if err := normalizeHook(&hook); err != nil {
+		return db.Hook{}, err
+	}
+	if err := db.CreateHook(&hook); err != nil {
+		return db.Hook{}, err
+	}
+	return hook, nil
+}
+
+// UpdateHook replaces the settings of an existing hook. An empty secret keeps
+// the stored one.
+func UpdateHook(id string, changes db.Hook) (db.Hook, error) {
+	var hook db.Hook
+	if err := db.GetHookById(id, &hook); err != nil {
+		return db.Hook{}, err
+	}
+	changes.Base = hook.Base
+	if changes.Secret == "" {
+		changes.Secret = hook.Secret
+	}
+	if err := normalizeHook(&changes); err != nil {
+		return db.Hook{}, err
+	}
+	if err := db.UpdateHook(&changes); err != nil {
+		return db.Hook{}, err
+	}
+	return changes, nil
+}
+
+func DeleteHook(id string) error {
+	return db.DeleteHookById(id)
+}
+
+func GetHookDeliveries(id string, limit int) (*[]db.HookDelivery, error) {
+	return db.GetHookDeliveries(id, limit)
+}
+
+func normalizeHook(hook *db.Hook) error {
+	hook.Name = strings.TrimSpace(hook.Name)
+	hook.Kind = strings.ToLower(strings.TrimSpace(hook.Kind))
+	hook.Target = strings.TrimSpace(hook.Target)
+	if hook.Name == "" {
+		hook.Name = hook.Target
+	}
+	switch hook.Kind {
+	case HookKindExec:
+		if hook.Target == "" {
+			return ErrInvalidHookTarget
+		}
+	case HookKindWebhook:
+		parsed, err := url.Parse(hook.Target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return ErrInvalidHookTarget
+		}
+	default:
+		return ErrInvalidHookKind
+	}
+
+	for _, requested := range strings.Split(hook.Events, ",") {
+		if requested = strings.TrimSpace(requested); requested != "" && !hookListensTo(strings.Join(HookEvents, ","), requested) {
+			return ErrInvalidHookEvent
+		}
+	}
+	var events []string
+	for _, event := range HookEvents {
+		if hookListensTo(hook.Events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return ErrInvalidHookEvent
+	}
+	hook.Events = strings.Join(events, ",")
+
+	if hook.TimeoutSeconds <= 0 {
+		hook.TimeoutSeconds = 30
+	}
+	if hook.MaxAttempts <= 0 {
+		hook.MaxAttempts = 3
+	}
+	return nil
+}
+
+func hookListensTo(events string, event string) bool {
+	for _, candidate := range strings.Split(events, ",") {
+		if strings.TrimSpace(candidate) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// FireHookEvent delivers an event about an episode to every enabled hook
+// listening for it.
+func FireHookEvent(event string, item db.PodcastItem, eventErr error) {
+	hooks, err := db.GetEnabledHooks()
+	if err != nil {
+		Logger.Warnw("failed to load hooks", "event", event, "error", err)
+		return
+	}
+	var targets []db.Hook
+	for _, hook := range *hooks {
+		if hookListensTo(hook.Events, event) {
+			targets = append(targets, hook)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	if item.Podcast.ID == "" && item.PodcastID != "" {
+		_ = db.GetPodcastById(item.PodcastID, &item.Podcast)
+	}
+	payload := HookPayload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Episode: HookEpisode{
+			ID:               item.ID,
+			GUID:             item.GUID,
+			Title:            item.Title,
+			PodcastID:        item.PodcastID,
+			PodcastTitle:     item.Podcast.Title,
+			PubDate:          item.PubDate,
+			FileURL:          item.FileURL,
+			DownloadPath:     item.DownloadPath,
+			FileSize:         item.FileSize,
+			TranscriptStatus: item.TranscriptStatus,
+		},
+	}
+	if eventErr != nil {
+		payload.Error = eventErr.Error()
+		payload.Attempts = item.DownloadAttempts
+		payload.Final = item.DownloadStatus == db.Failed
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		Logger.Warnw("failed to encode hook payload", "event", event, "error", err)
+		return
+	}
+
+	for _, hook := range targets {
+		hook := hook
+		hookDispatch(func() {
+			deliverHook(hook, event, item.ID, body)
+		})
+	}
+}
+
+// deliverHook runs a hook until it succeeds or runs out of attempts, logging
+// every attempt.
+func deliverHook(hook db.Hook, event string, podcastItemID string, body []byte) {
+	for attempt := 1; attempt <= hook.MaxAttempts; attempt++ {
+		start := time.Now()
+		statusCode, output, err := runHook(hook, event, body)
+		delivery := db.HookDelivery{
+			HookID:        hook.ID,
+			Event:         event,
+			PodcastItemID: podcastItemID,
+			Attempt:       attempt,
+			Success:       err == nil,
+			StatusCode:    statusCode,
+			Output:        truncateHookOutput(output),
+			DurationMs:    time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+			Logger.Warnw("hook delivery failed", "hook_id", hook.ID, "event", event, "attempt", attempt, "error", err)
+		}
+		if logErr := db.CreateHookDelivery(&delivery); logErr != nil {
+			Logger.Warnw("failed to log hook delivery", "hook_id", hook.ID, "error", logErr)
+		}
+		if err == nil || attempt == hook.MaxAttempts {
+			break
+		}
+		time.Sleep(hookRetryDelay(attempt))
+	}
+	if err := db.PruneHookDeliveries(hook.ID, hookDeliveryLogSize); err != nil {
+		Logger.Warnw("failed to prune hook deliveries", "hook_id", hook.ID, "error", err)
+	}
+}
+
+func runHook(hook db.Hook, event string, body []byte) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(hook.TimeoutSeconds)*time.Second)
+	defer cancel()
+	if hook.Kind == HookKindExec {
+		return runExecHook(ctx, hook, event, body)
+	}
+	return runWebhook(ctx, hook, event, body)
+}
+
+// runExecHook starts the command with the payload on stdin. The target is
+// split on whitespace and run without a shell.
+func runExecHook(ctx context.Context, hook db.Hook, event string, body []byte) (int, string, error) {
+	args := strings.Fields(hook.Target)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(cmd.Environ(), "BRIEFCAST_EVENT="+event)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %ds", hook.TimeoutSeconds)
+	}
+	exitCode := 0
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	return exitCode, string(output), err
+}
+
+// runWebhook posts the payload, signed with HMAC-SHA256 of the body when the
+// hook has a secret.
+func runWebhook(ctx context.Context, hook db.Hook, event string, body []byte) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Target, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(hookEventHeader, event)
+	if hook.Secret != "" {
+		req.Header.Set(hookSignatureHeader, "sha256="+signHookPayload(hook.Secret, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	output, _ := io.ReadAll(io.LimitReader(resp.Body, hookOutputLimit))
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, string(output), fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, string(output), nil
+}
+
+func signHookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func truncateHookOutput(output string) string {
+	if len(output) > hookOutputLimit {
+		return output[:hookOutputLimit]
+	}
+	return output
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func deliverHooksSynchronously(t *testing.T) {
+	t.Helper()
+	previousDispatch := hookDispatch
+	previousDelay := hookRetryDelay
+	hookDispatch = func(deliver func()) { deliver() }
+	hookRetryDelay = func(int) time.Duration { return 0 }
+	t.Cleanup(func() {
+		hookDispatch = previousDispatch
+		hookRetryDelay = previousDelay
+	})
+}
+
+func TestExecHookReceivesPayloadOnStdin(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	deliverHooksSynchronously(t)
+
+	outPath := filepath.Join(dir, "payload.json")
+	script := filepath.Join(dir, "hook.sh")
+	body := "#!/bin/sh\necho \"$BRIEFCAST_EVENT\" > " + outPath + ".event\ncat > " + outPath + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	if _, err := CreateHook(db.Hook{Kind: HookKindExec, Target: script, Events: HookEventDownloadCompleted, Enabled: true}); err != nil {
+		t.Fatalf("create hook failed: %v", err)
+	}
+
+	podcast := createPodcast(t, "Hooked", false)
+	item := createDownloadedItem(t, podcast, "Episode", time.Now().UTC(), false, dir)
+	if err := SetPodcastItemAsDownloaded(item.ID, item.DownloadPath); err != nil {
+		t.Fatalf("set downloaded failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	var payload HookPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("decode payload failed: %v", err)
+	}
+	if payload.Event != HookEventDownloadCompleted || payload.Episode.ID != item.ID {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if payload.Episode.PodcastTitle != "Hooked" || payload.Episode.DownloadPath != item.DownloadPath {
+		t.Fatalf("expected podcast title and download path in payload, got %+v", payload.Episode)
+	}
+	event, _ := os.ReadFile(outPath + ".event")
+	if strings.TrimSpace(string(event)) != HookEventDownloadCompleted {
+		t.Fatalf("expected BRIEFCAST_EVENT %q, got %q", HookEventDownloadCompleted, event)
+	}
+}
+
+func TestWebhookIsSignedRetriedAndLogged(t *testing.T) {
+	setupRetentionTestDB(t)
+	deliverHooksSynchronously(t)
+
+	var calls atomic.Int32
+	var signatureValid atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		signatureValid.Store(hmac.Equal([]byte(r.Header.Get(hookSignatureHeader)), []byte(expected)))
+		if r.Header.Get(hookEventHeader) != HookEventDownloadFailed {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hook, err := CreateHook(db.Hook{Kind: HookKindWebhook, Target: server.URL, Events: HookEventDownloadFailed, Secret: "s3cret", Enabled: true})
+	if err != nil {
+		t.Fatalf("create hook failed: %v", err)
+	}
+
+	podcast := createPodcast(t, "Failing", false)
+	item := db.PodcastItem{PodcastID: podcast.ID, Title: "episode", DownloadStatus: db.Downloading}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	if err := recordDownloadFailure(item.ID, errors.New("connection reset")); err != nil {
+		t.Fatalf("record failure failed: %v", err)
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected one retry after a 503, got %d calls", calls.Load())
+	}
+	if !signatureValid.Load() {
+		t.Fatalf("expected a valid HMAC signature")
+	}
+	deliveries, err := GetHookDeliveries(hook.ID, 0)
+	if err != nil {
+		t.Fatalf("load deliveries failed: %v", err)
+	}
+	if len(*deliveries) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(*deliveries))
+	}
+	var failed, succeeded int
+	for _, delivery := range *deliveries {
+		if delivery.Success {
+			succeeded++
+			if delivery.StatusCode != http.StatusOK || delivery.Attempt != 2 {
+				t.Fatalf("unexpected successful delivery %+v", delivery)
+			}
+		} else {
+			failed++
+			if delivery.StatusCode != http.StatusServiceUnavailable || delivery.Error == "" {
+				t.Fatalf("unexpected failed delivery %+v", delivery)
+			}
+		}
+	}
+	if failed != 1 || succeeded != 1 {
+		t.Fatalf("expected one failed and one successful delivery, got %d/%d", failed, succeeded)
+	}
+}
+
+func TestExecHookTimesOut(t *testing.T) {
+	setupRetentionTestDB(t)
+
+	hook := db.Hook{Kind: HookKindExec, Target: "sleep 5", TimeoutSeconds: 1, MaxAttempts: 1}
+	start := time.Now()
+	_, _, err := runHook(hook, HookEventEpisodeDiscovered, []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Fatalf("expected the hook to be killed at its timeout")
+	}
+}
+
+func TestCreateHookValidation(t *testing.T) {
+	setupRetentionTestDB(t)
+
+	cases := []struct {
+		hook db.Hook
+		err  error
+	}{
+		{db.Hook{Kind: "email", Target: "x", Events: HookEventDownloadCompleted}, ErrInvalidHookKind},
+		{db.Hook{Kind: HookKindWebhook, Target: "ftp://example.com", Events: HookEventDownloadCompleted}, ErrInvalidHookTarget},
+		{db.Hook{Kind: HookKindExec, Target: "/bin/true", Events: "download.started"}, ErrInvalidHookEvent},
+		{db.Hook{Kind: HookKindExec, Target: "/bin/true"}, ErrInvalidHookEvent},
+	}
+	for _, tc := range cases {
+		if _, err := CreateHook(tc.hook); !errors.Is(err, tc.err) {
+			t.Fatalf("expected %v for %+v, got %v", tc.err, tc.hook, err)
+		}
+	}
+
+	hook, err := CreateHook(db.Hook{Kind: HookKindExec, Target: "/bin/true", Events: "transcript.available, episode.discovered"})
+	if err != nil {
+		t.Fatalf("create hook failed: %v", err)
+	}
+	if hook.Events != "episode.discovered,transcript.available" {
+		t.Fatalf("expected normalized events, got %q", hook.Events)
+	}
+	if hook.TimeoutSeconds != 30 || hook.MaxAttempts != 3 {
+		t.Fatalf("expected default timeout and attempts, got %d/%d", hook.TimeoutSeconds, hook.MaxAttempts)
+	}
+}
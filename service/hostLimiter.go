@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -71,6 +72,19 @@ func doRequestWithHostLimit(client *http.Client, req *http.Request) (*http.Respo
 	return getOutboundRequestLimiter().Do(req.Context(), client, req)
 }
 
+// doStreamingRequestWithHostLimit is doRequestWithHostLimit for responses
+// whose body is read at length. The host slot is held until the body is
+// closed instead of being released once the headers arrive.
+func doStreamingRequestWithHostLimit(client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if req == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	return getOutboundRequestLimiter().DoStreaming(req.Context(), client, req)
+}
+
 func getOutboundRequestLimiter() *hostRequestLimiter {
 	outboundRequestLimiterOnce.Do(func() {
 		outboundRequestLimiter = newHostRequestLimiterFromEnv()
@@ -93,6 +107,34 @@ func (limiter *hostRequestLimiter) Do(ctx context.Context, client *http.Client,
 	return client.Do(req)
 }
 
+func (limiter *hostRequestLimiter) DoStreaming(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	release, err := limiter.acquire(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &hostLimitedBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// hostLimitedBody releases its host slot when the response body is closed.
+type hostLimitedBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (body *hostLimitedBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.release)
+	return err
+}
+
 func (limiter *hostRequestLimiter) acquire(ctx context.Context, requestURL *url.URL) (func(), error) {
 	if ctx == nil {
 		ctx = context.Background()
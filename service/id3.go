@@ -21,14 +21,21 @@ const (
 )
 
 func ExtractID3Metadata(path string) ([]byte, error) {
+	return runMutagenScript(mutagenScriptEnv, defaultMutagenScript, "extraction", nil, path)
+}
+
+// runMutagenScript runs a mutagen helper script, chosen by scriptEnv, with the
+// given stdin and arguments and returns its stdout. action names the step in
+// errors.
+func runMutagenScript(scriptEnv string, defaultScript string, action string, stdin []byte, args ...string) ([]byte, error) {
 	pythonPath, err := resolveMutagenPython()
 	if err != nil {
 		return nil, err
 	}
 
-	scriptPath := strings.TrimSpace(os.Getenv(mutagenScriptEnv))
+	scriptPath := strings.TrimSpace(os.Getenv(scriptEnv))
 	if scriptPath == "" {
-		scriptPath = defaultMutagenScript
+		scriptPath = defaultScript
 	}
 	if abs, absErr := filepath.Abs(scriptPath); absErr == nil {
 		scriptPath = abs
@@ -42,7 +49,10 @@ func ExtractID3Metadata(path string) ([]byte, error) {
 	}
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, pythonPath, scriptPath, path)
+	cmd := exec.CommandContext(cmdCtx, pythonPath, append([]string{scriptPath}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -52,12 +62,13 @@ func ExtractID3Metadata(path string) ([]byte, error) {
 		stderrText := strings.TrimSpace(stderr.String())
 		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
 			return nil, fmt.Errorf(
-				"mutagen extraction timed out after %d seconds: %s",
+				"mutagen %s timed out after %d seconds: %s",
+				action,
 				timeoutSeconds,
 				stderrText,
 			)
 		}
-		return nil, fmt.Errorf("mutagen extraction failed: %w: %s", err, stderrText)
+		return nil, fmt.Errorf("mutagen %s failed: %w: %s", action, err, stderrText)
 	}
 
 	return stdout.Bytes(), nil
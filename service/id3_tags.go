@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+const (
+	defaultMutagenWriteScript = "scripts/mutagen_id3_write.py"
+	mutagenWriteScriptEnv     = "MUTAGEN_WRITE_SCRIPT"
+)
+
+var (
+	ErrNotID3Taggable = errors.New("file is not an MP3 and cannot carry ID3 tags")
+	ErrNotDownloaded  = errors.New("episode is not downloaded")
+)
+
+// ID3TagRequest is the document sent to the mutagen writer on stdin. Empty
+// fields remove the matching frame; chapters replace any already embedded.
+type ID3TagRequest struct {
+	Path        string       `json:"path"`
+	Title       string       `json:"title"`
+	Album       string       `json:"album"`
+	Artist      string       `json:"artist"`
+	AlbumArtist string       `json:"album_artist"`
+	Date        string       `json:"date"`
+	Track       string       `json:"track"`
+	Disc        string       `json:"disc"`
+	Genre       string       `json:"genre"`
+	Description string       `json:"description"`
+	Artwork     string       `json:"artwork,omitempty"`
+	Chapters    []ID3Chapter `json:"chapters"`
+}
+
+type ID3Chapter struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+}
+
+// buildID3TagRequest maps an episode onto normalized tags: the podcast is the
+// album, the episode and season numbers are the track and disc, and the feed
+// chapters become CHAP frames.
+func buildID3TagRequest(item db.PodcastItem) ID3TagRequest {
+	artist := strings.TrimSpace(item.Podcast.Author)
+	if artist == "" {
+		artist = item.Podcast.Title
+	}
+	request := ID3TagRequest{
+		Path:        item.DownloadPath,
+		Title:       item.Title,
+		Album:       item.Podcast.Title,
+		Artist:      artist,
+		AlbumArtist: artist,
+		Genre:       "Podcast",
+		Description: strings.TrimSpace(item.Summary),
+		Chapters:    []ID3Chapter{},
+	}
+	if !item.PubDate.IsZero() {
+		request.Date = item.PubDate.Format("2006-01-02")
+	}
+	if item.EpisodeNumber > 0 {
+		request.Track = strconv.Itoa(item.EpisodeNumber)
+	}
+	if item.Season > 0 {
+		request.Disc = strconv.Itoa(item.Season)
+	}
+	if item.LocalImage != "" && FileExists(item.LocalImage) {
+		request.Artwork = item.LocalImage
+	}
+
+	chapters := parseChapters(item.ChaptersJSON)
+	for i, chapter := range chapters {
+		endSeconds := chapter.EndSeconds
+		if endSeconds <= chapter.StartSeconds {
+			if i+1 < len(chapters) {
+				endSeconds = chapters[i+1].StartSeconds
+			} else if item.Duration > 0 {
+				endSeconds = float64(item.Duration)
+			}
+		}
+		if endSeconds < chapter.StartSeconds {
+			endSeconds = chapter.StartSeconds
+		}
+		request.Chapters = append(request.Chapters, ID3Chapter{
+			ID:      fmt.Sprintf("chp%d", i),
+			Title:   chapter.Title,
+			StartMs: int64(chapter.StartSeconds * 1000),
+			EndMs:   int64(endSeconds * 1000),
+		})
+	}
+	return request
+}
+
+// isID3Taggable reports whether a file is an MP3, the only format written
+// tags are safe for. ADTS AAC shares the frame sync but has layer bits 00.
+func isID3Taggable(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	if string(header) == "ID3" {
+		return true
+	}
+	return header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0
+}
+
+// WriteID3Tags writes normalized ID3v2 tags and chapters into a downloaded
+// episode.
+func WriteID3Tags(item db.PodcastItem) error {
+	if !isID3Taggable(item.DownloadPath) {
+		return ErrNotID3Taggable
+	}
+	request, err := json.Marshal(buildID3TagRequest(item))
+	if err != nil {
+		return err
+	}
+	_, err = runMutagenScript(mutagenWriteScriptEnv, defaultMutagenWriteScript, "tag write", request)
+	return err
+}
+
+// TagPodcastItem rewrites the tags of a downloaded episode on demand and
+// stores the new size and checksum of the file.
+func TagPodcastItem(id string) error {
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		return err
+	}
+	if item.DownloadStatus != db.Downloaded || item.DownloadPath == "" {
+		return ErrNotDownloaded
+	}
+	if err := WriteID3Tags(item); err != nil {
+		return err
+	}
+	refreshDownloadedFileStats(&item, downloadsNow())
+	return db.UpdatePodcastItem(&item)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestBuildID3TagRequest(t *testing.T) {
+	dir := t.TempDir()
+	artwork := filepath.Join(dir, "cover.jpg")
+	if err := os.WriteFile(artwork, []byte{0xFF, 0xD8, 0xFF}, 0o644); err != nil {
+		t.Fatalf("write artwork failed: %v", err)
+	}
+	item := db.PodcastItem{
+		Podcast:       db.Podcast{Title: "The Show", Author: "Host"},
+		Title:         "Episode 12",
+		Summary:       " Notes ",
+		PubDate:       time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+		Duration:      600,
+		DownloadPath:  filepath.Join(dir, "episode.mp3"),
+		LocalImage:    artwork,
+		Season:        2,
+		EpisodeNumber: 12,
+		ItemMetadata:  `{"itunes_episode": "99"}`,
+		ChaptersJSON:  `{"chapters":[{"title":"Intro","startTime":0},{"title":"Main","startTime":60.5,"endTime":500},{"title":"Outro","startTime":500}]}`,
+	}
+
+	request := buildID3TagRequest(item)
+	if request.Album != "The Show" || request.Artist != "Host" || request.Title != "Episode 12" {
+		t.Fatalf("unexpected text frames %+v", request)
+	}
+	if request.Date != "2026-03-04" || request.Track != "12" || request.Disc != "2" || request.Description != "Notes" {
+		t.Fatalf("unexpected date, track, disc or description %+v", request)
+	}
+	if request.Artwork != artwork {
+		t.Fatalf("expected artwork %q, got %q", artwork, request.Artwork)
+	}
+	expected := []ID3Chapter{
+		{ID: "chp0", Title: "Intro", StartMs: 0, EndMs: 60500},
+		{ID: "chp1", Title: "Main", StartMs: 60500, EndMs: 500000},
+		{ID: "chp2", Title: "Outro", StartMs: 500000, EndMs: 600000},
+	}
+	if len(request.Chapters) != len(expected) {
+		t.Fatalf("expected %d chapters, got %+v", len(expected), request.Chapters)
+	}
+	for i := range expected {
+		if request.Chapters[i] != expected[i] {
+			t.Fatalf("chapter %d: expected %+v, got %+v", i, expected[i], request.Chapters[i])
+		}
+	}
+
+	item.LocalImage = filepath.Join(dir, "missing.jpg")
+	item.Podcast.Author = ""
+	request = buildID3TagRequest(item)
+	if request.Artwork != "" || request.Artist != "The Show" {
+		t.Fatalf("expected no artwork and the podcast title as artist, got %+v", request)
+	}
+}
+
+func TestIsID3Taggable(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]byte{
+		"tagged.mp3": []byte("ID3\x04\x00"),
+		"frame.mp3":  {0xFF, 0xFB, 0x90, 0x00},
+		"adts.aac":   {0xFF, 0xF1, 0x50, 0x80},
+		"audio.m4a":  []byte("\x00\x00\x00\x20ftypM4A "),
+	}
+	expected := map[string]bool{"tagged.mp3": true, "frame.mp3": true, "adts.aac": false, "audio.m4a": false}
+	for name, content := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+		if got := isID3Taggable(path); got != expected[name] {
+			t.Fatalf("%s: expected %v, got %v", name, expected[name], got)
+		}
+	}
+}
+
+func TestSetPodcastItemAsDownloadedWritesTagsAndRehashes(t *testing.T) {
+	pythonPath := requireWorkingPython(t)
+	dir := setupRetentionTestDB(t)
+
+	extractScript := filepath.Join(dir, "extract_stub.py")
+	if err := os.WriteFile(extractScript, []byte("print('{\"tags\":{},\"chapters\":[]}')\n"), 0o755); err != nil {
+		t.Fatalf("write extract stub failed: %v", err)
+	}
+	// The writer stub keeps the request and appends to the file the way a
+	// real tag write changes its size and checksum.
+	requestPath := filepath.Join(dir, "request.json")
+	writeScript := filepath.Join(dir, "write_stub.py")
+	body := "import json, sys\nrequest = json.load(sys.stdin)\n" +
+		"open(" + pyQuote(requestPath) + ", 'w').write(json.dumps(request))\n" +
+		"open(request['path'], 'ab').write(b'TAGS')\n"
+	if err := os.WriteFile(writeScript, []byte(body), 0o755); err != nil {
+		t.Fatalf("write tag stub failed: %v", err)
+	}
+	t.Setenv(mutagenPythonEnv, pythonPath)
+	t.Setenv(mutagenScriptEnv, extractScript)
+	t.Setenv(mutagenWriteScriptEnv, writeScript)
+
+	setting := db.GetOrCreateSetting()
+	setting.WriteID3Tags = true
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	podcast := createPodcast(t, "Tagged", false)
+	filePath := filepath.Join(dir, "episode.mp3")
+	original := []byte("ID3\x03\x00\x00\x00\x00\x00\x00audio")
+	if err := os.WriteFile(filePath, original, 0o644); err != nil {
+		t.Fatalf("write audio failed: %v", err)
+	}
+	item := db.PodcastItem{PodcastID: podcast.ID, Title: "Episode", DownloadStatus: db.Downloading, ChaptersJSON: `[{"title":"Intro","startTime":0,"endTime":5}]`}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+
+	if err := SetPodcastItemAsDownloaded(item.ID, filePath); err != nil {
+		t.Fatalf("set downloaded failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(requestPath)
+	if err != nil {
+		t.Fatalf("tag writer did not run: %v", err)
+	}
+	var request ID3TagRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		t.Fatalf("decode tag request failed: %v", err)
+	}
+	if request.Album != "Tagged" || request.Title != "Episode" || len(request.Chapters) != 1 {
+		t.Fatalf("unexpected tag request %+v", request)
+	}
+
+	var downloaded db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &downloaded); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	expectedSize := int64(len(original) + len("TAGS"))
+	if downloaded.FileSize != expectedSize || downloaded.DownloadTotalBytes != expectedSize {
+		t.Fatalf("expected size %d after tagging, got %d/%d", expectedSize, downloaded.FileSize, downloaded.DownloadTotalBytes)
+	}
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	if downloaded.FileSHA256 != sum {
+		t.Fatalf("expected checksum of the tagged file")
+	}
+	if err := checkDownloadedFile(filePath, downloaded.DownloadTotalBytes); err != nil {
+		t.Fatalf("expected tagged file to pass verification, got %v", err)
+	}
+}
+
+func TestTagPodcastItemRequiresDownloadedMP3(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	podcast := createPodcast(t, "Untaggable", false)
+
+	queued := db.PodcastItem{PodcastID: podcast.ID, Title: "Queued", DownloadStatus: db.NotDownloaded}
+	if err := db.CreatePodcastItem(&queued); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	if err := TagPodcastItem(queued.ID); !errors.Is(err, ErrNotDownloaded) {
+		t.Fatalf("expected ErrNotDownloaded, got %v", err)
+	}
+
+	// createDownloadedItem writes plain text, which is not an MP3.
+	downloaded := createDownloadedItem(t, podcast, "Text", time.Now().UTC(), false, dir)
+	if err := TagPodcastItem(downloaded.ID); !errors.Is(err, ErrNotID3Taggable) {
+		t.Fatalf("expected ErrNotID3Taggable, got %v", err)
+	}
+}
+
+func pyQuote(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -105,6 +105,15 @@ func TestIntegrationFeedDownloadWhisperX(t *testing.T) {
 	if item.DownloadStatus != db.Downloaded {
 		t.Fatalf("expected downloaded status, got %v", item.DownloadStatus)
 	}
+	if item.EnclosureType != "audio/mpeg" || item.EnclosureLength != 123 {
+		t.Fatalf("expected the enclosure type and length from the feed, got %q %d", item.EnclosureType, item.EnclosureLength)
+	}
+	if item.Season != 2 || item.EpisodeNumber != 5 || len(item.Persons) != 1 || item.Persons[0].Name != "Jane Host" {
+		t.Fatalf("expected season, episode and persons from the feed, got %d %d %+v", item.Season, item.EpisodeNumber, item.Persons)
+	}
+	if item.Podcast.PodcastGUID == "" || len(item.Podcast.Funding) != 1 {
+		t.Fatalf("expected the podcast guid and funding from the feed, got %+v", item.Podcast)
+	}
 	if item.TranscriptStatus != "available" {
 		t.Fatalf("expected transcript available, got %q", item.TranscriptStatus)
 	}
@@ -124,7 +133,7 @@ func testFeedServer() http.Handler {
 	})
 	mux.HandleFunc("/audio.mp3", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "audio/mpeg")
-		_, _ = w.Write([]byte("fake audio data"))
+		_, _ = w.Write([]byte("ID3fake audio data"))
 	})
 	mux.HandleFunc("/cover.jpg", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "image/jpeg")
@@ -143,10 +152,12 @@ func writeTempScript(t *testing.T, dir, name, body string) string {
 }
 
 const feedXMLTemplate = `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:podcast="https://podcastindex.org/namespace/1.0">
   <channel>
     <title>Integration Podcast</title>
     <description>Feed summary</description>
+    <podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+    <podcast:funding url="https://example.com/donate">Support the show</podcast:funding>
     <itunes:image href="http://%s/cover.jpg" />
     <item>
       <title>Episode 1</title>
@@ -154,6 +165,9 @@ const feedXMLTemplate = `<?xml version="1.0" encoding="UTF-8"?>
       <pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate>
       <enclosure url="http://%s/audio.mp3" type="audio/mpeg" length="123" />
       <description><![CDATA[<p>Episode summary</p>]]></description>
+      <itunes:season>2</itunes:season>
+      <itunes:episode>5</itunes:episode>
+      <podcast:person role="host">Jane Host</podcast:person>
     </item>
   </channel>
 </rss>
@@ -179,7 +193,7 @@ payload = {
             "published": "Mon, 01 Jan 2024 00:00:00 GMT",
             "itunes_duration": "123",
             "summary": "<p>Episode summary</p>",
-            "enclosures": [{"href": base + "/audio.mp3"}],
+            "enclosures": [{"href": base + "/audio.mp3", "type": "audio/mpeg", "length": "123"}],
         }
     ],
 }
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/logging"
+)
+
+var (
+	ErrIncompleteDownload = errors.New("download incomplete")
+	ErrUnexpectedContent  = errors.New("downloaded file is not audio or video")
+)
+
+const mediaSniffBytes = 512
+
+// mediaSignatures are the leading bytes of the audio and video containers
+// podcasts are published in. Offsets matter for MP4, where the box type
+// follows the box size.
+var mediaSignatures = []struct {
+	offset int
+	magic  []byte
+}{
+	{0, []byte("ID3")},                  // MP3 with ID3v2 tag
+	{0, []byte("OggS")},                 // Ogg Vorbis and Opus
+	{0, []byte("fLaC")},                 // FLAC
+	{0, []byte("RIFF")},                 // WAV
+	{0, []byte("FORM")},                 // AIFF
+	{0, []byte("#!AMR")},                // AMR
+	{0, []byte("FLV")},                  // Flash video
+	{0, []byte{0x1A, 0x45, 0xDF, 0xA3}}, // Matroska and WebM
+	{0, []byte{0x30, 0x26, 0xB2, 0x75}}, // ASF (WMA and WMV)
+	{4, []byte("ftyp")},                 // MP4, M4A and M4B
+	{0, []byte{0x00, 0x00, 0x01, 0xBA}}, // MPEG program stream
+}
+
+// sniffMediaContent checks the first bytes of a download for a known audio or
+// video container. Anything the standard sniffer reads as text, such as an
+// HTML error page served with 200, is rejected.
+func sniffMediaContent(header []byte) error {
+	if len(header) == 0 {
+		return ErrUnexpectedContent
+	}
+	for _, signature := range mediaSignatures {
+		end := signature.offset + len(signature.magic)
+		if len(header) >= end && bytes.Equal(header[signature.offset:end], signature.magic) {
+			return nil
+		}
+	}
+	// MPEG audio and ADTS AAC frames start with an 11 or 12 bit sync word.
+	if len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 {
+		return nil
+	}
+
+	contentType := http.DetectContentType(header)
+	if strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "video/") || contentType == "application/ogg" {
+		return nil
+	}
+	if strings.HasPrefix(contentType, "text/") {
+		return fmt.Errorf("%w: looks like %s", ErrUnexpectedContent, contentType)
+	}
+	return nil
+}
+
+// checkDownloadedFile compares a finished file against the expected size, when
+// known, and sniffs its content.
+func checkDownloadedFile(path string, expectedBytes int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if expectedBytes > 0 && info.Size() != expectedBytes {
+		return fmt.Errorf("%w: got %d of %d bytes", ErrIncompleteDownload, info.Size(), expectedBytes)
+	}
+
+	header := make([]byte, mediaSniffBytes)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	return sniffMediaContent(header[:n])
+}
+
+// verifyDownload checks a finished download and removes it when it fails. A
+// short file is kept when keepShort is set so the next attempt can resume it.
+func verifyDownload(finalPath string, link string, totalBytes int64, keepShort bool) error {
+	err := checkDownloadedFile(finalPath, totalBytes)
+	if err == nil {
+		return nil
+	}
+	logError("downloaded file failed integrity check", err, "path", finalPath, "url", link)
+	if !keepShort || !errors.Is(err, ErrIncompleteDownload) {
+		_ = os.Remove(finalPath)
+	}
+	return err
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// refreshDownloadedFileStats stores the size and checksum of an episode's file
+// after it was written, so later verification compares against what is on
+// disk.
+func refreshDownloadedFileStats(item *db.PodcastItem, now time.Time) {
+	if size, err := GetFileSize(item.DownloadPath); err == nil && size > 0 {
+		item.FileSize = size
+		item.DownloadedBytes = size
+		item.DownloadTotalBytes = size
+	}
+	if sum, err := fileSHA256(item.DownloadPath); err == nil {
+		item.FileSHA256 = sum
+		item.IntegrityCheckedAt = now
+	} else {
+		Logger.Warnw("failed to hash downloaded file", "podcast_item_id", item.ID, "path", item.DownloadPath, "error", err)
+	}
+}
+
+// VerifyDownloadedFiles re-checks every downloaded file against its stored
+// size and checksum. Files without a checksum get one; corrupt files are
+// deleted and their episodes queued for download again. Missing files are left
+// to CheckMissingFiles.
+func VerifyDownloadedFiles() error {
+	const JOB_NAME = "VerifyDownloadedFiles"
+	jobLogger, _ := logging.NewJobSugar(JOB_NAME)
+
+	lock := db.GetLock(JOB_NAME)
+	if lock.IsLocked() {
+		jobLogger.Infow("job_skipped_lock_exists")
+		return nil
+	}
+	db.Lock(JOB_NAME, 120)
+	defer db.Unlock(JOB_NAME)
+
+	items, err := db.GetAllPodcastItemsAlreadyDownloaded()
+	if err != nil {
+		return err
+	}
+	corrupt := 0
+	for _, item := range *items {
+		if item.DownloadPath == "" || !FileExists(item.DownloadPath) {
+			continue
+		}
+		verifyErr := checkDownloadedFile(item.DownloadPath, item.DownloadTotalBytes)
+		var sum string
+		if verifyErr == nil {
+			sum, err = fileSHA256(item.DownloadPath)
+			if err != nil {
+				jobLogger.Warnw("failed to hash downloaded file", "podcast_item_id", item.ID, "path", item.DownloadPath, "error", err)
+				continue
+			}
+			if item.FileSHA256 != "" && item.FileSHA256 != sum {
+				verifyErr = fmt.Errorf("checksum mismatch: expected %s, got %s", item.FileSHA256, sum)
+			}
+		}
+		if verifyErr != nil {
+			corrupt++
+			jobLogger.Warnw("downloaded file failed integrity check", "podcast_item_id", item.ID, "path", item.DownloadPath, "error", verifyErr)
+			if err := requeueCorruptDownload(item.ID, verifyErr); err != nil {
+				jobLogger.Errorw("failed to requeue corrupt download", "podcast_item_id", item.ID, "error", err)
+			}
+			continue
+		}
+		if err := db.UpdatePodcastItemChecksum(item.ID, sum, downloadsNow()); err != nil {
+			jobLogger.Warnw("failed to store file checksum", "podcast_item_id", item.ID, "error", err)
+		}
+	}
+	jobLogger.Infow("verified downloaded files", "count", len(*items), "corrupt", corrupt)
+	return nil
+}
+
+// requeueCorruptDownload deletes a corrupt file and queues its episode again,
+// recording why in the download error.
+func requeueCorruptDownload(id string, reason error) error {
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(id, &item); err != nil {
+		return err
+	}
+	if err := DeleteFile(item.DownloadPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if item.OriginalPath != "" {
+		_ = DeleteFile(item.OriginalPath)
+	}
+	item.DownloadDate = time.Time{}
+	item.DownloadPath = ""
+	item.DownloadStatus = db.NotDownloaded
+	item.DownloadedBytes = 0
+	item.DownloadTotalBytes = 0
+	item.FileSHA256 = ""
+	item.OriginalPath = ""
+	item.ProcessingStatus = ""
+	item.LastDownloadError = "integrity check failed: " + reason.Error()
+	resetDownloadAttempts(&item)
+	return db.UpdatePodcastItem(&item)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestSniffMediaContent(t *testing.T) {
+	valid := [][]byte{
+		[]byte("ID3\x04\x00rest of the tag"),
+		{0xFF, 0xFB, 0x90, 0x64},
+		[]byte("\x00\x00\x00\x20ftypM4A "),
+		[]byte("OggS\x00\x02"),
+	}
+	for _, header := range valid {
+		if err := sniffMediaContent(header); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", header, err)
+		}
+	}
+	invalid := [][]byte{
+		[]byte("<!DOCTYPE html><html><body>Not Found</body></html>"),
+		[]byte("<?xml version=\"1.0\"?><error/>"),
+		nil,
+	}
+	for _, header := range invalid {
+		if err := sniffMediaContent(header); !errors.Is(err, ErrUnexpectedContent) {
+			t.Fatalf("expected %q to be rejected, got %v", header, err)
+		}
+	}
+}
+
+func TestDownloadRejectsHTMLErrorPage(t *testing.T) {
+	setupRetentionTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>Service unavailable</body></html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := Download("", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Integrity", "Episode"), ""); !errors.Is(err, ErrUnexpectedContent) {
+		t.Fatalf("expected ErrUnexpectedContent, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("DATA"), "Integrity", "Episode.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected rejected file to be removed, got %v", err)
+	}
+}
+
+func TestCheckDownloadedFileDetectsTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := os.WriteFile(path, []byte("ID3 short"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := checkDownloadedFile(path, 1024); !errors.Is(err, ErrIncompleteDownload) {
+		t.Fatalf("expected ErrIncompleteDownload, got %v", err)
+	}
+	if err := checkDownloadedFile(path, 9); err != nil {
+		t.Fatalf("expected complete file to pass, got %v", err)
+	}
+}
+
+func TestVerifyDownloadedFilesRequeuesCorruptFiles(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	podcast := createPodcast(t, "verify", false)
+
+	create := func(title string, content string) db.PodcastItem {
+		path := filepath.Join(tempDir, title+".mp3")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		item := db.PodcastItem{
+			PodcastID:          podcast.ID,
+			Title:              title,
+			DownloadDate:       time.Now().UTC(),
+			DownloadPath:       path,
+			DownloadStatus:     db.Downloaded,
+			DownloadTotalBytes: int64(len(content)),
+		}
+		if err := db.CreatePodcastItem(&item); err != nil {
+			t.Fatalf("create podcast item failed: %v", err)
+		}
+		return item
+	}
+	good := create("good", "ID3 audio frames")
+	html := create("html", "<html><body>Gone</body></html>")
+
+	if err := VerifyDownloadedFiles(); err != nil {
+		t.Fatalf("VerifyDownloadedFiles failed: %v", err)
+	}
+	var stored db.PodcastItem
+	if err := db.GetPodcastItemById(good.ID, &stored); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if stored.DownloadStatus != db.Downloaded || stored.FileSHA256 == "" {
+		t.Fatalf("expected good file to keep its status and get a checksum, got %+v", stored)
+	}
+	var requeued db.PodcastItem
+	if err := db.GetPodcastItemById(html.ID, &requeued); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if requeued.DownloadStatus != db.NotDownloaded || requeued.LastDownloadError == "" || FileExists(html.DownloadPath) {
+		t.Fatalf("expected html file to be deleted and requeued, got %+v", requeued)
+	}
+
+	if err := os.WriteFile(good.DownloadPath, []byte("ID3 audio frameZ"), 0o644); err != nil {
+		t.Fatalf("failed to corrupt file: %v", err)
+	}
+	if err := VerifyDownloadedFiles(); err != nil {
+		t.Fatalf("VerifyDownloadedFiles failed: %v", err)
+	}
+	var tampered db.PodcastItem
+	if err := db.GetPodcastItemById(good.ID, &tampered); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if tampered.DownloadStatus != db.NotDownloaded || tampered.FileSHA256 != "" {
+		t.Fatalf("expected checksum mismatch to requeue the episode, got %+v", tampered)
+	}
+}
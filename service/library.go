@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/logging"
+)
+
+var ErrLibraryBusy = errors.New("library reorganization already running")
+
+// LibraryMove is one downloaded episode whose file does not match the
+// current naming. Error is set when the move was skipped or failed.
+type LibraryMove struct {
+	PodcastItemID string `json:"podcastItemId"`
+	Title         string `json:"title"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Error         string `json:"error,omitempty"`
+}
+
+type ReorganizeResult struct {
+	DryRun bool          `json:"dryRun"`
+	Moved  int           `json:"moved"`
+	Failed int           `json:"failed"`
+	Moves  []LibraryMove `json:"moves"`
+}
+
+// ReorganizeLibrary moves downloaded episodes to the paths the current naming
+// settings give them. A dry run only lists the moves. The new paths are
+// stored in one transaction; if that fails the files are moved back.
+func ReorganizeLibrary(dryRun bool) (ReorganizeResult, error) {
+	const JOB_NAME = "ReorganizeLibrary"
+	result := ReorganizeResult{DryRun: dryRun, Moves: []LibraryMove{}}
+
+	if !dryRun {
+		lock := db.GetLock(JOB_NAME)
+		if lock.IsLocked() {
+			return result, ErrLibraryBusy
+		}
+		db.Lock(JOB_NAME, 120)
+		defer db.Unlock(JOB_NAME)
+	}
+	jobLogger, _ := logging.NewJobSugar(JOB_NAME)
+
+	items, err := db.GetAllPodcastItemsAlreadyDownloaded()
+	if err != nil {
+		return result, err
+	}
+	setting := db.GetOrCreateSetting()
+
+	type plannedMove struct {
+		index    int
+		from, to db.PodcastItem
+	}
+	claimed := make(map[string]bool)
+	var planned []plannedMove
+	for _, item := range *items {
+		if item.DownloadPath == "" || item.ProcessingStatus == ProcessingInProgress || !FileExists(item.DownloadPath) {
+			continue
+		}
+		target := EpisodeDownloadTarget(&item, setting)
+		to := filepath.Clean(target + filepath.Ext(item.DownloadPath))
+		if to == filepath.Clean(item.DownloadPath) {
+			continue
+		}
+		move := LibraryMove{PodcastItemID: item.ID, Title: item.Title, From: item.DownloadPath, To: to}
+		if claimed[to] || FileExists(to) {
+			move.Error = "target already exists"
+			result.Failed++
+			result.Moves = append(result.Moves, move)
+			continue
+		}
+		claimed[to] = true
+		result.Moves = append(result.Moves, move)
+
+		moved := item
+		moved.DownloadPath = to
+		moved.OriginalPath = ""
+		if item.OriginalPath != "" && FileExists(item.OriginalPath) {
+			moved.OriginalPath = target + ".original" + filepath.Ext(item.OriginalPath)
+		}
+		planned = append(planned, plannedMove{index: len(result.Moves) - 1, from: item, to: moved})
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	var done []plannedMove
+	for _, move := range planned {
+		if err := moveEpisodeFiles(move.from, move.to); err != nil {
+			jobLogger.Warnw("failed to move episode", "podcast_item_id", move.from.ID, "error", err)
+			result.Moves[move.index].Error = err.Error()
+			result.Failed++
+			continue
+		}
+		done = append(done, move)
+	}
+
+	updates := make([]db.PodcastItem, 0, len(done))
+	for _, move := range done {
+		updates = append(updates, move.to)
+	}
+	if err := db.UpdatePodcastItemPaths(updates); err != nil {
+		jobLogger.Errorw("failed to store moved paths, moving files back", "error", err)
+		for _, move := range done {
+			if undoErr := moveEpisodeFiles(move.to, move.from); undoErr != nil {
+				jobLogger.Errorw("failed to move episode back", "podcast_item_id", move.from.ID, "error", undoErr)
+			}
+		}
+		return result, err
+	}
+	dataPath := os.Getenv("DATA")
+	for _, move := range done {
+		removeEmptyFolders(filepath.Dir(move.from.DownloadPath), dataPath)
+	}
+	result.Moved = len(done)
+	jobLogger.Infow("library reorganized", "moved", result.Moved, "failed", result.Failed)
+	return result, nil
+}
+
+// moveEpisodeFiles renames an episode's download and kept original from the
+// paths of one item to those of the other.
+func moveEpisodeFiles(from db.PodcastItem, to db.PodcastItem) error {
+	if err := ensureFolder(filepath.Dir(to.DownloadPath)); err != nil {
+		return err
+	}
+	if err := os.Rename(from.DownloadPath, to.DownloadPath); err != nil {
+		return err
+	}
+	if from.OriginalPath != "" && to.OriginalPath != "" && from.OriginalPath != to.OriginalPath {
+		if err := os.Rename(from.OriginalPath, to.OriginalPath); err != nil {
+			_ = os.Rename(to.DownloadPath, from.DownloadPath)
+			return err
+		}
+	}
+	changeOwnership(to.DownloadPath)
+	return nil
+}
+
+// removeEmptyFolders deletes folder and its parents while they are empty,
+// stopping at root.
+func removeEmptyFolders(folder string, root string) {
+	root = filepath.Clean(root)
+	for folder = filepath.Clean(folder); folder != root && strings.HasPrefix(folder, root+string(filepath.Separator)); folder = filepath.Dir(folder) {
+		entries, err := os.ReadDir(folder)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(folder); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/id3meta"
+	"github.com/ctaylor1/briefcast/internal/logging"
+)
+
+var (
+	ErrLibraryScanRunning = errors.New("library scan already running")
+	ErrInvalidImportPath  = errors.New("file must be an audio or video file inside the data folder")
+	ErrAlreadyDownloaded  = errors.New("episode is already downloaded")
+	ErrImportPathOwned    = errors.New("file already belongs to another episode")
+)
+
+// A file is matched to the best scoring episode when it reaches
+// libraryMatchScore and leads the runner-up by libraryMatchMargin. Weaker or
+// tied evidence from libraryCandidateScore up is reported as ambiguous.
+const (
+	libraryMatchScore     = 4
+	libraryMatchMargin    = 2
+	libraryCandidateScore = 3
+	libraryMaxCandidates  = 5
+)
+
+// importableStatuses are the episodes a file on disk can be matched to. Paused
+// and downloading episodes own the partial file at their target path.
+var importableStatuses = []db.DownloadStatus{db.NotDownloaded, db.Deleted, db.Failed}
+
+var fileNameDate = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+type LibraryScanCandidate struct {
+	PodcastItemID string   `json:"podcastItemId"`
+	Title         string   `json:"title"`
+	Podcast       string   `json:"podcast"`
+	Score         int      `json:"score"`
+	Reasons       []string `json:"reasons"`
+}
+
+type LibraryScanMatch struct {
+	Path string `json:"path"`
+	LibraryScanCandidate
+}
+
+type LibraryScanAmbiguous struct {
+	Path       string                 `json:"path"`
+	Candidates []LibraryScanCandidate `json:"candidates"`
+}
+
+// LibraryScanReport is the outcome of the last library scan, kept in memory
+// until the next one starts.
+type LibraryScanReport struct {
+	Running    bool                   `json:"running"`
+	DryRun     bool                   `json:"dryRun"`
+	StartedAt  time.Time              `json:"startedAt"`
+	FinishedAt time.Time              `json:"finishedAt"`
+	Error      string                 `json:"error,omitempty"`
+	Scanned    int                    `json:"scanned"`
+	Imported   int                    `json:"imported"`
+	Matched    []LibraryScanMatch     `json:"matched"`
+	Ambiguous  []LibraryScanAmbiguous `json:"ambiguous"`
+	Unmatched  []string               `json:"unmatched"`
+}
+
+var (
+	libraryScanMutex  sync.Mutex
+	libraryScanReport *LibraryScanReport
+)
+
+// GetLibraryScanReport returns a copy of the last scan report, or nil when no
+// scan has run since startup.
+func GetLibraryScanReport() *LibraryScanReport {
+	libraryScanMutex.Lock()
+	defer libraryScanMutex.Unlock()
+	if libraryScanReport == nil {
+		return nil
+	}
+	report := *libraryScanReport
+	return &report
+}
+
+func setLibraryScanReport(report LibraryScanReport) {
+	libraryScanMutex.Lock()
+	defer libraryScanMutex.Unlock()
+	libraryScanReport = &report
+}
+
+// StartLibraryScan starts ScanLibrary in the background. Its report is read
+// with GetLibraryScanReport.
+func StartLibraryScan(dryRun bool) error {
+	const JOB_NAME = "ScanLibrary"
+	lock := db.GetLock(JOB_NAME)
+	if lock.IsLocked() {
+		return ErrLibraryScanRunning
+	}
+	db.Lock(JOB_NAME, 120)
+	setLibraryScanReport(LibraryScanReport{Running: true, DryRun: dryRun, StartedAt: time.Now().UTC()})
+	go func() {
+		defer db.Unlock(JOB_NAME)
+		_, _ = ScanLibrary(dryRun)
+	}()
+	return nil
+}
+
+// libraryFile is a media file found under DATA that no episode points to.
+type libraryFile struct {
+	path    string
+	size    int64
+	podcast string
+	tags    map[string][]string
+	raw     []byte
+}
+
+// ScanLibrary matches media files under DATA that no episode points to with
+// episodes that are not downloaded, and marks the matches as downloaded
+// unless dryRun is set. Files are matched on their path and name, then on
+// their size and embedded ID3 title, date and length.
+func ScanLibrary(dryRun bool) (LibraryScanReport, error) {
+	jobLogger, _ := logging.NewJobSugar("ScanLibrary")
+	report := LibraryScanReport{DryRun: dryRun, StartedAt: time.Now().UTC(), Matched: []LibraryScanMatch{}, Ambiguous: []LibraryScanAmbiguous{}, Unmatched: []string{}}
+	finish := func(err error) (LibraryScanReport, error) {
+		report.FinishedAt = time.Now().UTC()
+		if err != nil {
+			report.Error = err.Error()
+		}
+		setLibraryScanReport(report)
+		return report, err
+	}
+
+	dataPath := os.Getenv("DATA")
+	if dataPath == "" {
+		return finish(errors.New("DATA is not set"))
+	}
+	owned, err := ownedLibraryPaths()
+	if err != nil {
+		return finish(err)
+	}
+	candidates, err := db.GetPodcastItemsByDownloadStatuses(importableStatuses, 0)
+	if err != nil {
+		return finish(err)
+	}
+	var podcasts []db.Podcast
+	if err := db.GetAllPodcasts(&podcasts, ""); err != nil {
+		return finish(err)
+	}
+	podcastFolders := make(map[string]string, len(podcasts))
+	for _, podcast := range podcasts {
+		podcastFolders[normalizeForMatch(cleanFileName(podcast.Title))] = podcast.ID
+	}
+
+	var files []libraryFile
+	err = filepath.WalkDir(dataPath, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if name == "images" || strings.HasSuffix(name, segmentPartsSuffix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(name)
+		if _, taken := owned[filepath.Clean(path)]; taken || mediaTypeForExtension(ext) == "" {
+			return nil
+		}
+		stem := strings.TrimSuffix(name, ext)
+		if strings.HasSuffix(stem, ".original") || strings.HasSuffix(stem, ".processing") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || checkDownloadedFile(path, 0) != nil {
+			return nil
+		}
+		file := libraryFile{path: path, size: info.Size()}
+		if relative, err := filepath.Rel(dataPath, filepath.Dir(path)); err == nil {
+			for _, segment := range strings.Split(relative, string(filepath.Separator)) {
+				if podcastID, ok := podcastFolders[normalizeForMatch(segment)]; ok {
+					file.podcast = podcastID
+					break
+				}
+			}
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return finish(err)
+	}
+	report.Scanned = len(files)
+
+	// Where each episode would have been downloaded to, worked out once.
+	setting := db.GetOrCreateSetting()
+	targets := make(map[string]string, len(candidates))
+	for i := range candidates {
+		targets[candidates[i].ID] = filepath.Clean(EpisodeDownloadTarget(&candidates[i], setting))
+	}
+	matches := make(map[string][]int)
+	found := make([]LibraryScanCandidate, len(files))
+	for i := range files {
+		file := &files[i]
+		ranked := rankLibraryCandidates(file, candidates, targets)
+		if !isLibraryMatch(ranked) && isID3Taggable(file.path) {
+			if raw, err := ExtractID3Metadata(file.path); err == nil {
+				var parsed id3meta.Parsed
+				if splitErr := json.Unmarshal(raw, &parsed); splitErr == nil {
+					file.raw = raw
+					file.tags = parsed.Tags
+					ranked = rankLibraryCandidates(file, candidates, targets)
+				}
+			}
+		}
+		switch {
+		case isLibraryMatch(ranked):
+			found[i] = ranked[0]
+			matches[ranked[0].PodcastItemID] = append(matches[ranked[0].PodcastItemID], i)
+		case len(ranked) > 0 && ranked[0].Score >= libraryCandidateScore:
+			ambiguous := LibraryScanAmbiguous{Path: file.path}
+			for _, candidate := range ranked {
+				if candidate.Score < libraryCandidateScore || len(ambiguous.Candidates) == libraryMaxCandidates {
+					break
+				}
+				ambiguous.Candidates = append(ambiguous.Candidates, candidate)
+			}
+			report.Ambiguous = append(report.Ambiguous, ambiguous)
+		default:
+			report.Unmatched = append(report.Unmatched, file.path)
+		}
+	}
+
+	byID := make(map[string]db.PodcastItem, len(candidates))
+	for _, item := range candidates {
+		byID[item.ID] = item
+	}
+	for i := range files {
+		candidate := found[i]
+		if candidate.PodcastItemID == "" {
+			continue
+		}
+		// Two files that both look like the same episode need a person to
+		// pick one.
+		if len(matches[candidate.PodcastItemID]) > 1 {
+			report.Ambiguous = append(report.Ambiguous, LibraryScanAmbiguous{Path: files[i].path, Candidates: []LibraryScanCandidate{candidate}})
+			continue
+		}
+		if !dryRun {
+			item := byID[candidate.PodcastItemID]
+			if err := importEpisodeFile(&item, files[i].path, files[i].raw); err != nil {
+				jobLogger.Warnw("failed to import file", "path", files[i].path, "podcast_item_id", item.ID, "error", err)
+				report.Unmatched = append(report.Unmatched, files[i].path)
+				continue
+			}
+			report.Imported++
+		}
+		report.Matched = append(report.Matched, LibraryScanMatch{Path: files[i].path, LibraryScanCandidate: candidate})
+	}
+	sort.Strings(report.Unmatched)
+	jobLogger.Infow("library scanned", "scanned", report.Scanned, "matched", len(report.Matched), "ambiguous", len(report.Ambiguous), "unmatched", len(report.Unmatched), "dry_run", dryRun)
+	return finish(nil)
+}
+
+func isLibraryMatch(ranked []LibraryScanCandidate) bool {
+	if len(ranked) == 0 || ranked[0].Score < libraryMatchScore {
+		return false
+	}
+	return len(ranked) == 1 || ranked[0].Score-ranked[1].Score >= libraryMatchMargin
+}
+
+// rankLibraryCandidates scores every episode against a file, best first.
+// Episodes of another podcast are skipped when the file sits in a podcast
+// folder.
+func rankLibraryCandidates(file *libraryFile, candidates []db.PodcastItem, targets map[string]string) []LibraryScanCandidate {
+	ext := filepath.Ext(file.path)
+	target := filepath.Clean(strings.TrimSuffix(file.path, ext))
+	stem := strings.TrimSuffix(filepath.Base(file.path), ext)
+	// A date in the name, as other downloaders like to add, is compared on
+	// its own and left out of the title comparison.
+	stemDate := ""
+	if match := fileNameDate.FindStringSubmatch(stem); match != nil {
+		stemDate = match[1] + "-" + match[2] + "-" + match[3]
+	}
+	normalizedStem := normalizeForMatch(fileNameDate.ReplaceAllString(stem, ""))
+	id3Title := normalizeForMatch(firstTag(file.tags, "TIT2"))
+	id3Date := firstTag(file.tags, "TDRC", "TYER")
+	id3Length, _ := strconv.Atoi(firstTag(file.tags, "TLEN"))
+
+	var ranked []LibraryScanCandidate
+	for i := range candidates {
+		item := &candidates[i]
+		if file.podcast != "" && item.PodcastID != file.podcast {
+			continue
+		}
+		// A file much smaller than the declared enclosure is an interrupted
+		// download.
+		if item.EnclosureLength > 0 && file.size < item.EnclosureLength/2 {
+			continue
+		}
+		candidate := LibraryScanCandidate{PodcastItemID: item.ID, Title: item.Title, Podcast: item.Podcast.Title}
+		add := func(score int, reason string) {
+			candidate.Score += score
+			candidate.Reasons = append(candidate.Reasons, reason)
+		}
+		title := normalizeForMatch(item.Title)
+		pubDate := ""
+		if !item.PubDate.IsZero() {
+			pubDate = item.PubDate.Format("2006-01-02")
+		}
+
+		switch {
+		case targets[item.ID] == target:
+			add(6, "expected path")
+		case len(item.GUID) >= 8 && strings.Contains(normalizedStem, normalizeForMatch(item.GUID)):
+			add(6, "guid in file name")
+		case title != "" && normalizedStem == title:
+			add(4, "file name")
+		case len(title) >= 6 && strings.Contains(normalizedStem, title):
+			add(2, "title in file name")
+		}
+		if stemDate != "" && stemDate == pubDate {
+			add(1, "date in file name")
+		}
+		if item.EnclosureLength > 0 && file.size == item.EnclosureLength {
+			add(2, "size")
+		}
+		if id3Title != "" && id3Title == title {
+			add(4, "id3 title")
+		}
+		if pubDate != "" && strings.HasPrefix(id3Date, pubDate) {
+			add(1, "id3 date")
+		}
+		if id3Length > 0 && item.Duration > 0 && math.Abs(float64(id3Length)/1000-float64(item.Duration)) <= 2 {
+			add(1, "duration")
+		}
+		if candidate.Score > 0 {
+			ranked = append(ranked, candidate)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	return ranked
+}
+
+func firstTag(tags map[string][]string, keys ...string) string {
+	for _, key := range keys {
+		for _, value := range tags[key] {
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeForMatch keeps only the lower-cased letters and digits of a name,
+// so "Episode 12: The End" and "episode-12-the-end" compare equal.
+func normalizeForMatch(value string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// ownedLibraryPaths maps the download and original paths of episodes that
+// are downloaded, downloading or paused to the episode that holds them.
+func ownedLibraryPaths() (map[string]string, error) {
+	items, err := db.GetPodcastItemsByDownloadStatuses([]db.DownloadStatus{db.Downloaded, db.Downloading, db.Paused}, 0)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string]string)
+	for _, item := range items {
+		for _, path := range []string{item.DownloadPath, item.OriginalPath} {
+			if path != "" {
+				owned[filepath.Clean(path)] = item.ID
+			}
+		}
+	}
+	return owned, nil
+}
+
+// importEpisodeFile marks an episode as downloaded to an existing file.
+// Unlike a download it does not write tags, queue processing or fire hooks.
+func importEpisodeFile(item *db.PodcastItem, path string, raw []byte) error {
+	item.DownloadPath = path
+	item.DownloadStatus = db.Downloaded
+	item.DownloadDate = time.Now().UTC()
+	resetDownloadAttempts(item)
+	item.LastDownloadError = ""
+	if item.TranscriptStatus == "" && item.TranscriptJSON == "" {
+		item.TranscriptStatus = "pending_whisperx"
+	}
+	if id3meta.ShouldExtract(item.ChaptersJSON, item.ID3TagsJSON, item.ID3ChaptersJSON) {
+		if raw == nil && isID3Taggable(path) {
+			raw, _ = ExtractID3Metadata(path)
+		}
+		if raw != nil {
+			applyID3Metadata(item, raw)
+		}
+	}
+	refreshDownloadedFileStats(item, item.DownloadDate)
+	return db.UpdatePodcastItem(item)
+}
+
+// ImportLibraryFile resolves a scan result by hand, marking an episode as
+// downloaded to a file under DATA.
+func ImportLibraryFile(podcastItemId string, path string) error {
+	dataPath := filepath.Clean(os.Getenv("DATA"))
+	path = filepath.Clean(path)
+	if relative, err := filepath.Rel(dataPath, path); err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+		return ErrInvalidImportPath
+	}
+	if mediaTypeForExtension(filepath.Ext(path)) == "" || checkDownloadedFile(path, 0) != nil {
+		return ErrInvalidImportPath
+	}
+	var item db.PodcastItem
+	if err := db.GetPodcastItemById(podcastItemId, &item); err != nil {
+		return err
+	}
+	if item.DownloadStatus == db.Downloaded || item.DownloadStatus == db.Downloading {
+		return ErrAlreadyDownloaded
+	}
+	owned, err := ownedLibraryPaths()
+	if err != nil {
+		return err
+	}
+	if owner, taken := owned[path]; taken && owner != item.ID {
+		return ErrImportPathOwned
+	}
+	return importEpisodeFile(&item, path, nil)
+}
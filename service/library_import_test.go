@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func createLibraryItem(t *testing.T, podcast db.Podcast, title string, pubDate time.Time, status db.DownloadStatus) db.PodcastItem {
+	t.Helper()
+	item := db.PodcastItem{PodcastID: podcast.ID, Title: title, GUID: "guid-" + title, PubDate: pubDate, DownloadStatus: status}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	return item
+}
+
+func writeLibraryFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("ID3\x03\x00\x00\x00\x00\x00\x00audio"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+}
+
+func TestScanLibraryMatchesFiles(t *testing.T) {
+	pythonPath := requireWorkingPython(t)
+	dir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(dir, "assets")
+	showDir := filepath.Join(dataDir, "Show")
+
+	// The ID3 stub only knows the title of track07.mp3.
+	script := filepath.Join(dir, "extract_stub.py")
+	body := "import json, sys\n" +
+		"tags = {'TIT2': ['Interview']} if sys.argv[1].endswith('track07.mp3') else {}\n" +
+		"print(json.dumps({'tags': tags, 'chapters': []}))\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write extract stub failed: %v", err)
+	}
+	t.Setenv(mutagenPythonEnv, pythonPath)
+	t.Setenv(mutagenScriptEnv, script)
+
+	if err := os.MkdirAll(showDir, 0o755); err != nil {
+		t.Fatalf("create folder failed: %v", err)
+	}
+	podcast := createPodcast(t, "Show", false)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := createLibraryItem(t, podcast, "Episode 1", day, db.NotDownloaded)
+	createLibraryItem(t, podcast, "Episode 12", day.AddDate(0, 1, 0), db.Deleted)
+	interview := createLibraryItem(t, podcast, "Interview", day.AddDate(0, 2, 0), db.Deleted)
+	bonus := createLibraryItem(t, podcast, "Bonus", day, db.NotDownloaded)
+	createLibraryItem(t, podcast, "Bonus", day.AddDate(0, 0, 7), db.NotDownloaded)
+	downloaded := createDownloadedItem(t, podcast, "Downloaded", day, false, showDir)
+
+	writeLibraryFile(t, filepath.Join(showDir, "episode-1.mp3"))
+	writeLibraryFile(t, filepath.Join(showDir, "track07.mp3"))
+	writeLibraryFile(t, filepath.Join(showDir, "2024-01-01 Bonus.mp3"))
+	writeLibraryFile(t, filepath.Join(showDir, "random.mp3"))
+	writeLibraryFile(t, filepath.Join(showDir, "episode-1.original.mp3"))
+	if err := os.WriteFile(filepath.Join(showDir, "notes.mp3"), []byte("<html>not audio</html>"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+
+	report, err := ScanLibrary(true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if report.Scanned != 4 || len(report.Matched) != 2 || len(report.Ambiguous) != 1 || len(report.Unmatched) != 1 || report.Imported != 0 {
+		t.Fatalf("unexpected dry run report %+v", report)
+	}
+	if report.Ambiguous[0].Path != filepath.Join(showDir, "2024-01-01 Bonus.mp3") || len(report.Ambiguous[0].Candidates) != 2 {
+		t.Fatalf("expected both Bonus episodes as candidates, got %+v", report.Ambiguous[0])
+	}
+	if report.Unmatched[0] != filepath.Join(showDir, "random.mp3") {
+		t.Fatalf("unexpected unmatched files %v", report.Unmatched)
+	}
+	if reloadPodcastItem(t, first.ID).DownloadStatus != db.NotDownloaded {
+		t.Fatalf("expected the dry run to leave episodes alone")
+	}
+
+	report, err = ScanLibrary(false)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if report.Imported != 2 {
+		t.Fatalf("expected two imports, got %+v", report)
+	}
+	imported := reloadPodcastItem(t, first.ID)
+	if imported.DownloadStatus != db.Downloaded || imported.DownloadPath != filepath.Join(showDir, "episode-1.mp3") || imported.FileSHA256 == "" {
+		t.Fatalf("expected Episode 1 to be imported, got %+v", imported)
+	}
+	if got := reloadPodcastItem(t, interview.ID); got.DownloadPath != filepath.Join(showDir, "track07.mp3") || got.ID3TagsJSON == "" {
+		t.Fatalf("expected the ID3 title to match Interview, got %q", got.DownloadPath)
+	}
+	if GetLibraryScanReport().Imported != 2 {
+		t.Fatalf("expected the last report to be kept")
+	}
+
+	ambiguous := filepath.Join(showDir, "2024-01-01 Bonus.mp3")
+	if err := ImportLibraryFile(bonus.ID, filepath.Join(dir, "briefcast.db")); !errors.Is(err, ErrInvalidImportPath) {
+		t.Fatalf("expected files outside DATA to be rejected, got %v", err)
+	}
+	if err := ImportLibraryFile(downloaded.ID, ambiguous); !errors.Is(err, ErrAlreadyDownloaded) {
+		t.Fatalf("expected ErrAlreadyDownloaded, got %v", err)
+	}
+	if err := ImportLibraryFile(bonus.ID, imported.DownloadPath); !errors.Is(err, ErrImportPathOwned) {
+		t.Fatalf("expected a file owned by another episode to be rejected, got %v", err)
+	}
+	downloaded.OriginalPath = filepath.Join(showDir, "episode-1.original.mp3")
+	if err := db.UpdatePodcastItem(&downloaded); err != nil {
+		t.Fatalf("update podcast item failed: %v", err)
+	}
+	if err := ImportLibraryFile(bonus.ID, downloaded.OriginalPath); !errors.Is(err, ErrImportPathOwned) {
+		t.Fatalf("expected a kept original to be rejected, got %v", err)
+	}
+	if err := ImportLibraryFile(bonus.ID, ambiguous); err != nil {
+		t.Fatalf("manual import failed: %v", err)
+	}
+	if got := reloadPodcastItem(t, bonus.ID); got.DownloadStatus != db.Downloaded || got.DownloadPath != ambiguous {
+		t.Fatalf("expected the manual import to be stored, got %+v", got)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// mediaTypes maps file extensions to the MIME types podcasts publish. The first
+// entry for an extension is the type served for it, and the first entry for a
+// type is the extension a download of that type is saved with.
+var mediaTypes = []struct {
+	ext       string
+	mediaType string
+}{
+	{".mp3", "audio/mpeg"},
+	{".mp3", "audio/mp3"},
+	{".mp3", "audio/x-mpeg"},
+	{".mp3", "audio/mpeg3"},
+	{".m4a", "audio/mp4"},
+	{".m4a", "audio/x-m4a"},
+	{".m4a", "audio/m4a"},
+	{".m4b", "audio/x-m4b"},
+	{".aac", "audio/aac"},
+	{".aac", "audio/aacp"},
+	{".aac", "audio/x-aac"},
+	{".ogg", "audio/ogg"},
+	{".opus", "audio/ogg"},
+	{".opus", "audio/opus"},
+	{".oga", "audio/ogg"},
+	{".flac", "audio/flac"},
+	{".flac", "audio/x-flac"},
+	{".wav", "audio/wav"},
+	{".wav", "audio/x-wav"},
+	{".mp4", "video/mp4"},
+	{".m4v", "video/x-m4v"},
+	{".mov", "video/quicktime"},
+	{".webm", "video/webm"},
+	{".webm", "audio/webm"},
+	{".mkv", "video/x-matroska"},
+}
+
+// normalizeMediaType lower-cases a MIME type and drops its parameters, so
+// "Audio/MPEG; charset=binary" becomes "audio/mpeg".
+func normalizeMediaType(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if parsed, _, err := mime.ParseMediaType(value); err == nil {
+		return parsed
+	}
+	return strings.ToLower(value)
+}
+
+func extensionForMediaType(mediaType string) string {
+	mediaType = normalizeMediaType(mediaType)
+	for _, known := range mediaTypes {
+		if known.mediaType == mediaType {
+			return known.ext
+		}
+	}
+	return ""
+}
+
+func mediaTypeForExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	for _, known := range mediaTypes {
+		if known.ext == ext {
+			return known.mediaType
+		}
+	}
+	return ""
+}
+
+func urlExtension(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(filepath.Ext(parsed.Path))
+}
+
+// episodeFileExtension picks the extension for a download from the declared
+// MIME type, then a media extension in the URL. It returns "" when neither is
+// known and the content has to be sniffed.
+func episodeFileExtension(link string, mediaType string) string {
+	if ext := extensionForMediaType(mediaType); ext != "" {
+		return ext
+	}
+	if ext := urlExtension(link); mediaTypeForExtension(ext) != "" {
+		return ext
+	}
+	return ""
+}
+
+// sniffMediaExtension names the container in the first bytes of a file, or
+// returns "" when it is not one a podcast is likely to use.
+func sniffMediaExtension(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return ".mp3"
+	case bytes.HasPrefix(header, []byte("OggS")):
+		if bytes.Contains(header, []byte("OpusHead")) {
+			return ".opus"
+		}
+		return ".ogg"
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return ".flac"
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && string(header[8:12]) == "WAVE":
+		return ".wav"
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(header, []byte("webm")) {
+			return ".webm"
+		}
+		return ".mkv"
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		switch string(header[8:12]) {
+		case "M4A ", "M4B ":
+			return ".m4a"
+		case "qt  ":
+			return ".mov"
+		}
+		return ".mp4"
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// MPEG audio has non-zero layer bits, ADTS AAC has 00.
+		if header[1]&0x06 == 0 {
+			return ".aac"
+		}
+		return ".mp3"
+	}
+	return ""
+}
+
+func sniffFileExtension(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	header := make([]byte, mediaSniffBytes)
+	n, _ := file.Read(header)
+	return sniffMediaExtension(header[:n])
+}
+
+// EpisodeMediaType is the MIME type an episode is served with: the stored
+// enclosure type, or the one implied by the local file or enclosure URL.
+// It returns "" when none of them is known.
+func EpisodeMediaType(item db.PodcastItem) string {
+	if mediaType := normalizeMediaType(item.EnclosureType); mediaType != "" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if item.DownloadPath != "" {
+		if mediaType := mediaTypeForExtension(filepath.Ext(item.DownloadPath)); mediaType != "" {
+			return mediaType
+		}
+	}
+	return mediaTypeForExtension(urlExtension(item.FileURL))
+}
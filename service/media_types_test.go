@@ -0,0 +1,92 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestEpisodeFileExtension(t *testing.T) {
+	cases := []struct {
+		link      string
+		mediaType string
+		expected  string
+	}{
+		{"https://cdn.example.com/episode.mp3", "", ".mp3"},
+		{"https://cdn.example.com/episode.mp3", "video/mp4", ".mp4"},
+		{"https://cdn.example.com/stream?id=1", "Audio/X-M4A; charset=binary", ".m4a"},
+		{"https://cdn.example.com/play.php?id=1", "", ""},
+		{"https://cdn.example.com/episode", "application/octet-stream", ""},
+	}
+	for _, tc := range cases {
+		if got := episodeFileExtension(tc.link, tc.mediaType); got != tc.expected {
+			t.Fatalf("%s (%s): expected %q, got %q", tc.link, tc.mediaType, tc.expected, got)
+		}
+	}
+}
+
+func TestSniffMediaExtension(t *testing.T) {
+	cases := map[string][]byte{
+		".mp3":  []byte("ID3\x04\x00"),
+		".aac":  {0xFF, 0xF1, 0x50, 0x80},
+		".m4a":  []byte("\x00\x00\x00\x20ftypM4A "),
+		".mp4":  []byte("\x00\x00\x00\x20ftypisom"),
+		".opus": []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x13OpusHead"),
+		".webm": append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x82, 0x84}, []byte("webm")...),
+		"":      []byte("<html>"),
+	}
+	for expected, header := range cases {
+		if got := sniffMediaExtension(header); got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, header, got)
+		}
+	}
+}
+
+func TestEpisodeMediaType(t *testing.T) {
+	item := db.PodcastItem{EnclosureType: "video/mp4", DownloadPath: "/data/show/episode.mp3"}
+	if got := EpisodeMediaType(item); got != "video/mp4" {
+		t.Fatalf("expected the stored type, got %q", got)
+	}
+	item.EnclosureType = "application/octet-stream"
+	if got := EpisodeMediaType(item); got != "audio/mpeg" {
+		t.Fatalf("expected the type of the local file, got %q", got)
+	}
+	item = db.PodcastItem{FileURL: "https://cdn.example.com/episode.m4a?token=1"}
+	if got := EpisodeMediaType(item); got != "audio/mp4" {
+		t.Fatalf("expected the type of the enclosure URL, got %q", got)
+	}
+	if got := EpisodeMediaType(db.PodcastItem{FileURL: "https://cdn.example.com/play"}); got != "" {
+		t.Fatalf("expected no type, got %q", got)
+	}
+}
+
+func TestDownloadNamesFileFromTypeOrContent(t *testing.T) {
+	setupRetentionTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00audio"))
+	}))
+	t.Cleanup(server.Close)
+
+	finalPath, err := Download("", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Types", "Video"), "video/mp4")
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if filepath.Base(finalPath) != "Video.mp4" {
+		t.Fatalf("expected the declared type to pick the extension, got %q", finalPath)
+	}
+
+	finalPath, err = Download("", server.URL+"/play?id=1", filepath.Join(os.Getenv("DATA"), "Types", "Sniffed"), "")
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if filepath.Base(finalPath) != "Sniffed.m4a" {
+		t.Fatalf("expected the sniffed extension, got %q", finalPath)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(finalPath), "Sniffed.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected the guessed name to be renamed, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+var ErrInvalidNamingTemplate = errors.New("naming template must use known fields and include {title}, {guid} or {id} in the file name")
+
+// namingPlaceholder matches template fields such as {title} or {episode:3},
+// where the number zero-pads numeric values to that width.
+var namingPlaceholder = regexp.MustCompile(`\{([a-z]+)(?::([1-9]))?\}`)
+
+var namingFields = map[string]bool{
+	"podcast": true,
+	"author":  true,
+	"title":   true,
+	"date":    true,
+	"year":    true,
+	"month":   true,
+	"day":     true,
+	"season":  true,
+	"episode": true,
+	"guid":    true,
+	"id":      true,
+}
+
+// ValidateNamingTemplate checks an episode path template. An empty template
+// keeps the default layout.
+func ValidateNamingTemplate(template string) error {
+	template = strings.Trim(strings.TrimSpace(template), "/")
+	if template == "" {
+		return nil
+	}
+	for _, match := range namingPlaceholder.FindAllStringSubmatch(template, -1) {
+		if !namingFields[match[1]] {
+			return ErrInvalidNamingTemplate
+		}
+	}
+	if strings.ContainsAny(namingPlaceholder.ReplaceAllString(template, ""), "{}") {
+		return ErrInvalidNamingTemplate
+	}
+	segments := strings.Split(template, "/")
+	fileName := segments[len(segments)-1]
+	for _, field := range []string{"title", "guid", "id"} {
+		if strings.Contains(fileName, "{"+field+"}") {
+			return nil
+		}
+	}
+	return ErrInvalidNamingTemplate
+}
+
+func episodeNamingValues(item *db.PodcastItem) map[string]string {
+	values := map[string]string{
+		"podcast": item.Podcast.Title,
+		"author":  item.Podcast.Author,
+		"title":   item.Title,
+		"guid":    item.GUID,
+		"id":      item.ID,
+	}
+	if !item.PubDate.IsZero() {
+		values["date"] = item.PubDate.Format("2006-01-02")
+		values["year"] = item.PubDate.Format("2006")
+		values["month"] = item.PubDate.Format("01")
+		values["day"] = item.PubDate.Format("02")
+	}
+	if item.Season > 0 {
+		values["season"] = strconv.Itoa(item.Season)
+	}
+	if item.EpisodeNumber > 0 {
+		values["episode"] = strconv.Itoa(item.EpisodeNumber)
+	} else if item.ID != "" {
+		if seq, err := db.GetEpisodeNumber(item.ID, item.PodcastID); err == nil {
+			values["episode"] = strconv.Itoa(seq)
+		}
+	}
+	return values
+}
+
+// renderNamingTemplate fills in a template and sanitizes each path segment.
+// Segments that end up empty are dropped.
+func renderNamingTemplate(template string, values map[string]string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimSpace(template), "/") {
+		rendered := namingPlaceholder.ReplaceAllStringFunc(segment, func(field string) string {
+			match := namingPlaceholder.FindStringSubmatch(field)
+			value := strings.TrimSpace(values[match[1]])
+			if width, err := strconv.Atoi(match[2]); err == nil {
+				if number, err := strconv.Atoi(value); err == nil {
+					value = strconv.Itoa(number)
+					for len(value) < width {
+						value = "0" + value
+					}
+				}
+			}
+			return value
+		})
+		rendered = strings.Trim(cleanFileName(rendered), " -_.")
+		if rendered != "" {
+			segments = append(segments, rendered)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// EpisodeDownloadTarget is where an episode is saved, without its extension.
+// The episode path template decides it when set; otherwise episodes go into
+// the podcast folder, named after the title with the optional number and
+// date prefix.
+func EpisodeDownloadTarget(item *db.PodcastItem, setting *db.Setting) string {
+	dataPath := os.Getenv("DATA")
+	if setting.EpisodePathTemplate != "" {
+		if rendered := renderNamingTemplate(setting.EpisodePathTemplate, episodeNamingValues(item)); rendered != "" {
+			return path.Join(dataPath, rendered)
+		}
+	}
+	fileName := getFileName("", item.Title, "")
+	if prefix := GetPodcastPrefix(item, setting); prefix != "" {
+		fileName = prefix + "-" + fileName
+	}
+	return path.Join(dataPath, cleanFileName(item.Podcast.Title), fileName)
+}
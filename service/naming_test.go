@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestValidateNamingTemplate(t *testing.T) {
+	valid := []string{"", "{podcast}/{date} {title}", "/{podcast}/Season {season:2}/{episode:3} - {guid}/", "Archive/{id}"}
+	for _, template := range valid {
+		if err := ValidateNamingTemplate(template); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", template, err)
+		}
+	}
+	invalid := []string{"{podcast}/{date}", "{title}/{date}", "{podcast}/{name}", "{podcast}/{title", "{podcast}/{title:0}"}
+	for _, template := range invalid {
+		if err := ValidateNamingTemplate(template); !errors.Is(err, ErrInvalidNamingTemplate) {
+			t.Fatalf("expected %q to be rejected, got %v", template, err)
+		}
+	}
+}
+
+func TestRenderNamingTemplate(t *testing.T) {
+	values := map[string]string{
+		"podcast": "The Show",
+		"title":   "Part 1/2: Intro?",
+		"date":    "2026-03-04",
+		"season":  "1",
+		"episode": "7",
+	}
+	got := renderNamingTemplate("{podcast}/Season {season:2}/S{season:2}E{episode:3} {title}", values)
+	if got != "The Show/Season 01/S01E007 Part 1-2- Intro" {
+		t.Fatalf("unexpected rendered path %q", got)
+	}
+	if got := renderNamingTemplate("{podcast}/{year}/{title}", values); got != "The Show/Part 1-2- Intro" {
+		t.Fatalf("expected empty segments to be dropped, got %q", got)
+	}
+}
+
+func TestEpisodeDownloadTarget(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(dir, "assets")
+	item := db.PodcastItem{
+		Podcast:       db.Podcast{Title: "The Show"},
+		Title:         "First Episode",
+		GUID:          "guid-1",
+		PubDate:       time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+		Season:        2,
+		EpisodeNumber: 5,
+	}
+	setting := &db.Setting{AppendDateToFileName: true}
+	expected := filepath.Join(dataDir, "The Show", "2026-03-04-"+getFileName("", "First Episode", ""))
+	if got := EpisodeDownloadTarget(&item, setting); got != expected {
+		t.Fatalf("expected the default layout %q, got %q", expected, got)
+	}
+
+	setting.EpisodePathTemplate = "Podcasts/{podcast}/{year}/S{season:2}E{episode:2} {title}"
+	expected = filepath.Join(dataDir, "Podcasts", "The Show", "2026", "S02E05 First Episode")
+	if got := EpisodeDownloadTarget(&item, setting); got != expected {
+		t.Fatalf("expected templated path %q, got %q", expected, got)
+	}
+}
+
+func TestReorganizeLibrary(t *testing.T) {
+	dir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(dir, "assets")
+	oldFolder := filepath.Join(dataDir, "Show")
+	if err := os.MkdirAll(oldFolder, 0o755); err != nil {
+		t.Fatalf("create folder failed: %v", err)
+	}
+	podcast := createPodcast(t, "Show", false)
+	pubDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	first := createDownloadedItem(t, podcast, "First", pubDate, false, oldFolder)
+	second := createDownloadedItem(t, podcast, "Second", pubDate, false, oldFolder)
+	// A file already at the target of the second episode blocks its move.
+	blocked := filepath.Join(dataDir, "Show", "2025", "Second.mp3")
+	if err := os.MkdirAll(filepath.Dir(blocked), 0o755); err != nil {
+		t.Fatalf("create folder failed: %v", err)
+	}
+	if err := os.WriteFile(blocked, []byte("other"), 0o644); err != nil {
+		t.Fatalf("write blocking file failed: %v", err)
+	}
+
+	setting := db.GetOrCreateSetting()
+	setting.EpisodePathTemplate = "{podcast}/{year}/{title}"
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	preview, err := ReorganizeLibrary(true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !preview.DryRun || len(preview.Moves) != 2 || preview.Moved != 0 || preview.Failed != 1 {
+		t.Fatalf("unexpected dry run %+v", preview)
+	}
+	if !FileExists(first.DownloadPath) {
+		t.Fatalf("expected the dry run to leave files in place")
+	}
+
+	result, err := ReorganizeLibrary(false)
+	if err != nil {
+		t.Fatalf("reorganize failed: %v", err)
+	}
+	if result.Moved != 1 || result.Failed != 1 {
+		t.Fatalf("expected one move and one conflict, got %+v", result)
+	}
+	moved := reloadPodcastItem(t, first.ID)
+	expected := filepath.Join(dataDir, "Show", "2025", "First.mp3")
+	if moved.DownloadPath != expected || !FileExists(expected) || FileExists(first.DownloadPath) {
+		t.Fatalf("expected the first episode at %q, got %q", expected, moved.DownloadPath)
+	}
+	if kept := reloadPodcastItem(t, second.ID); kept.DownloadPath != second.DownloadPath || !FileExists(second.DownloadPath) {
+		t.Fatalf("expected the conflicting episode to stay at %q, got %q", second.DownloadPath, kept.DownloadPath)
+	}
+
+	if err := os.Remove(blocked); err != nil {
+		t.Fatalf("remove blocking file failed: %v", err)
+	}
+	if _, err := ReorganizeLibrary(false); err != nil {
+		t.Fatalf("second reorganize failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(oldFolder, "First.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected the old file to be gone, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "Show", "2025")); err != nil {
+		t.Fatalf("expected the new folder to remain, got %v", err)
+	}
+}
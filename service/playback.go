@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+var playbackNow = func() time.Time {
+	return time.Now().UTC()
+}
+
+type PlaybackProgress struct {
+	PodcastItemID string    `json:"podcastItemId"`
+	Position      int       `json:"position"`
+	Duration      int       `json:"duration"`
+	Completion    float64   `json:"completion"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	IsPlayed      bool      `json:"isPlayed"`
+}
+
+type PlaybackProgressUpdate struct {
+	Position  int       `json:"position"`
+	Duration  int       `json:"duration"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+var ErrInvalidPlaybackPosition = errors.New("position must be 0 or greater")
+
+func GetPodcastItemProgress(userID string, id string) (PlaybackProgress, error) {
+	podcastItem, _, err := getUserPodcastItem(userID, id)
+	if err != nil {
+		return PlaybackProgress{}, err
+	}
+	return playbackProgressFromItem(podcastItem), nil
+}
+
+// SetPodcastItemProgress stores the user's position for an episode. Updates
+// carrying an UpdatedAt older than the stored one are ignored so a device that
+// reconnects late cannot rewind progress made elsewhere. Crossing the configured
+// played threshold marks the episode as played.
+func SetPodcastItemProgress(userID string, id string, update PlaybackProgressUpdate) (PlaybackProgress, error) {
+	progress, podcastItem, applied, err := applyPodcastItemProgress(userID, id, update)
+	if err != nil || !applied {
+		return progress, err
+	}
+	recordPlayEpisodeAction(userID, podcastItem, progress)
+	return progress, nil
+}
+
+func applyPodcastItemProgress(userID string, id string, update PlaybackProgressUpdate) (PlaybackProgress, db.PodcastItem, bool, error) {
+	if update.Position < 0 {
+		return PlaybackProgress{}, db.PodcastItem{}, false, ErrInvalidPlaybackPosition
+	}
+
+	podcastItem, state, err := getUserPodcastItem(userID, id)
+	if err != nil {
+		return PlaybackProgress{}, db.PodcastItem{}, false, err
+	}
+
+	now := playbackNow()
+	updatedAt := update.UpdatedAt.UTC()
+	if updatedAt.IsZero() || updatedAt.After(now) {
+		updatedAt = now
+	}
+	if !state.PlaybackUpdatedAt.IsZero() && updatedAt.Before(state.PlaybackUpdatedAt) {
+		return playbackProgressFromItem(podcastItem), podcastItem, false, nil
+	}
+
+	duration := podcastItem.Duration
+	if duration <= 0 {
+		duration = update.Duration
+	}
+	completion := playbackCompletion(update.Position, duration)
+
+	state.PlaybackPosition = update.Position
+	state.PlaybackCompletion = completion
+	state.PlaybackUpdatedAt = updatedAt
+	setting := db.GetOrCreateSetting()
+	if !state.IsPlayed && reachedPlayedThreshold(completion, setting.PlayedThresholdPercent) {
+		state.IsPlayed = true
+	}
+	if err := saveUserEpisodeState(&state); err != nil {
+		return PlaybackProgress{}, podcastItem, false, err
+	}
+	applyEpisodeState(&podcastItem, state)
+
+	progress := playbackProgressFromItem(podcastItem)
+	progress.Duration = duration
+	return progress, podcastItem, true, nil
+}
+
+func playbackCompletion(position int, duration int) float64 {
+	if duration <= 0 || position <= 0 {
+		return 0
+	}
+	percent := float64(position) / float64(duration) * 100
+	if percent > 100 {
+		percent = 100
+	}
+	return math.Round(percent*100) / 100
+}
+
+func reachedPlayedThreshold(completion float64, thresholdPercent int) bool {
+	if thresholdPercent <= 0 || thresholdPercent > 100 {
+		return false
+	}
+	return completion >= float64(thresholdPercent)
+}
+
+func playbackProgressFromItem(podcastItem db.PodcastItem) PlaybackProgress {
+	return PlaybackProgress{
+		PodcastItemID: podcastItem.ID,
+		Position:      podcastItem.PlaybackPosition,
+		Duration:      podcastItem.Duration,
+		Completion:    podcastItem.PlaybackCompletion,
+		UpdatedAt:     podcastItem.PlaybackUpdatedAt,
+		IsPlayed:      podcastItem.IsPlayed,
+	}
+}
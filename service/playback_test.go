@@ -0,0 +1,113 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func createPlaybackItem(t *testing.T, duration int) db.PodcastItem {
+	t.Helper()
+	podcast := createPodcast(t, "playback", false)
+	item := db.PodcastItem{
+		PodcastID: podcast.ID,
+		Title:     "playback-episode",
+		GUID:      "playback-episode",
+		Duration:  duration,
+		PubDate:   time.Now().UTC(),
+	}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	return item
+}
+
+func TestSetPodcastItemProgressStoresPosition(t *testing.T) {
+	setupRetentionTestDB(t)
+	item := createPlaybackItem(t, 1000)
+
+	progress, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 250})
+	if err != nil {
+		t.Fatalf("SetPodcastItemProgress failed: %v", err)
+	}
+	if progress.Position != 250 || progress.Completion != 25 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+	if progress.IsPlayed {
+		t.Fatalf("expected episode to remain unplayed below threshold")
+	}
+
+	stored, err := GetPodcastItemProgress(defaultUserID(t), item.ID)
+	if err != nil {
+		t.Fatalf("GetPodcastItemProgress failed: %v", err)
+	}
+	if stored.Position != 250 || stored.UpdatedAt.IsZero() {
+		t.Fatalf("expected stored progress, got %+v", stored)
+	}
+}
+
+func TestSetPodcastItemProgressIgnoresStaleUpdates(t *testing.T) {
+	setupRetentionTestDB(t)
+	item := createPlaybackItem(t, 1000)
+
+	now := time.Now().UTC()
+	if _, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 600, UpdatedAt: now}); err != nil {
+		t.Fatalf("SetPodcastItemProgress failed: %v", err)
+	}
+	progress, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 10, UpdatedAt: now.Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("SetPodcastItemProgress stale update failed: %v", err)
+	}
+	if progress.Position != 600 {
+		t.Fatalf("expected stale update to be ignored, got %+v", progress)
+	}
+}
+
+func TestSetPodcastItemProgressMarksPlayedAtThreshold(t *testing.T) {
+	setupRetentionTestDB(t)
+	item := createPlaybackItem(t, 0)
+
+	setting := db.GetOrCreateSetting()
+	setting.PlayedThresholdPercent = 90
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	progress, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: 95, Duration: 100})
+	if err != nil {
+		t.Fatalf("SetPodcastItemProgress failed: %v", err)
+	}
+	if !progress.IsPlayed {
+		t.Fatalf("expected progress above threshold to mark played, got %+v", progress)
+	}
+
+	var refreshed db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &refreshed); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if !refreshed.IsPlayed {
+		t.Fatalf("expected stored item to be played")
+	}
+}
+
+func TestSetPodcastItemProgressRejectsNegativePosition(t *testing.T) {
+	setupRetentionTestDB(t)
+	item := createPlaybackItem(t, 100)
+
+	if _, err := SetPodcastItemProgress(defaultUserID(t), item.ID, PlaybackProgressUpdate{Position: -1}); err != ErrInvalidPlaybackPosition {
+		t.Fatalf("expected ErrInvalidPlaybackPosition, got %v", err)
+	}
+}
+
+func TestReachedPlayedThreshold(t *testing.T) {
+	if reachedPlayedThreshold(99, 0) {
+		t.Fatalf("expected zero threshold to disable auto played")
+	}
+	if !reachedPlayedThreshold(95, 95) {
+		t.Fatalf("expected completion equal to threshold to count as played")
+	}
+	if reachedPlayedThreshold(94.9, 95) {
+		t.Fatalf("expected completion below threshold to stay unplayed")
+	}
+}
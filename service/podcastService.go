@@ -197,10 +197,10 @@ func getItunesImageUrl(body []byte) string {
 
 func AddPodcast(url string) (db.Podcast, error) {
 	var podcast db.Podcast
-	err := db.GetPodcastByURL(url, &podcast)
+	err := findPodcastByURL(url, &podcast)
 	setting := db.GetOrCreateSetting()
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		parsed, body, err := FetchFeedWithFeedparser(url)
+		parsed, body, err := FetchFeed(url)
 		if err != nil {
 			Logger.Errorw("Error adding podcast", "url", url, "error", err)
 			return db.Podcast{}, err
@@ -219,6 +219,7 @@ func AddPodcast(url string) (db.Podcast, error) {
 			URL:          url,
 			FeedMetadata: feedmeta.MarshalMetadata(feed),
 		}
+		applyPodcastingFeedFields(&podcast, feed)
 
 		if podcast.Image == "" {
 			podcast.Image = getItunesImageUrl(body)
@@ -228,6 +229,7 @@ func AddPodcast(url string) (db.Podcast, error) {
 		if err != nil {
 			return db.Podcast{}, err
 		}
+		recordSubscriptionChange(podcast.URL, subscriptionAdded)
 
 		_, coverErr := DownloadPodcastCoverImage(podcast.Image, podcast.Title)
 		if coverErr != nil {
@@ -248,13 +250,52 @@ func AddPodcast(url string) (db.Podcast, error) {
 
 func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 	//fmt.Println("Creating: " + podcast.ID)
-	parsed, _, err := FetchFeedWithFeedparser(podcast.URL)
+	previous := FeedValidators{
+		ETag:         podcast.FeedETag,
+		LastModified: podcast.FeedLastModified,
+		BodyHash:     podcast.FeedBodyHash,
+	}
+	body, validators, movedTo, err := FetchFeedBodyConditional(podcast.URL, previous)
+	if errors.Is(err, ErrFeedNotModified) {
+		// A body matching the stored hash is the feed parsed last time, so
+		// its redirect can be followed; a bare 304 proves nothing.
+		if movedTo != "" && body != nil && movePodcastFeed(podcast, movedTo, feedMoveRedirect) {
+			// The validators came from the new URL, so they are stored again.
+			previous = FeedValidators{BodyHash: previous.BodyHash}
+		}
+		Logger.Debugw("podcast feed not modified; skipping parse", "podcast_id", podcast.ID, "url", podcast.URL)
+		if validators != previous {
+			db.UpdatePodcastFeedValidators(podcast.ID, validators.ETag, validators.LastModified, validators.BodyHash)
+		}
+		return nil
+	}
 	if err != nil {
 		//log.Fatal(err)
 		return err
 	}
+	parsed, err := ParseFeed(body)
+	if err != nil {
+		if movedTo != "" {
+			rejectFeedMove(podcast, movedTo, fmt.Sprintf("%s to %s does not serve a feed: %v", feedMoveRedirect, movedTo, err))
+		}
+		return err
+	}
+	if movedTo != "" {
+		movePodcastFeed(podcast, movedTo, feedMoveRedirect)
+	}
 	feed := parsed.Feed
 	feedImage := feedmeta.ExtractImageURL(feed)
+	if checkNewFeedURL(podcast, feed) {
+		// This body came from the old URL; its validators do not apply to
+		// the new one.
+		validators = FeedValidators{BodyHash: validators.BodyHash}
+	}
+	if applyPodcastingFeedFields(podcast, feed) {
+		if err := db.UpdatePodcastPodcastingFields(podcast); err != nil {
+			Logger.Warnw("failed to store podcasting fields", "podcast_id", podcast.ID, "error", err)
+		}
+	}
+	applyWebSubFeedFields(podcast, feed)
 	setting := db.GetOrCreateSetting()
 	limit := setting.InitialDownloadCount
 	// if len(data.Channel.Item) < limit {
@@ -270,9 +311,15 @@ func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 
 	existingItems, err := db.GetPodcastItemsByPodcastIdAndGUIDs(podcast.ID, allGuids)
 	keyMap := make(map[string]int)
+	missingEnclosure := make(map[string]string)
+	existingByGUID := make(map[string]db.PodcastItem)
 
 	for _, item := range *existingItems {
 		keyMap[item.GUID] = 1
+		existingByGUID[item.GUID] = item
+		if item.EnclosureType == "" {
+			missingEnclosure[item.GUID] = item.ID
+		}
 	}
 	var latestDate = time.Time{}
 	var itemsAdded = make(map[string]string)
@@ -284,6 +331,35 @@ func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 			continue
 		}
 		_, keyExists := keyMap[guid]
+		// Episodes stored before enclosure types were kept pick them up
+		// from the next refresh.
+		if id, ok := missingEnclosure[guid]; ok {
+			if enclosure := feedmeta.ExtractEnclosure(entry); enclosure.Type != "" {
+				if err := db.UpdatePodcastItemEnclosure(id, enclosure.Type, enclosure.Length); err != nil {
+					Logger.Warnw("failed to store enclosure type", "podcast_item_id", id, "error", err)
+				}
+			}
+			delete(missingEnclosure, guid)
+		}
+		// Seasons, credits and the like can change after an episode is
+		// published, so existing episodes follow the feed.
+		if existing, ok := existingByGUID[guid]; ok {
+			if applyPodcastingEntryFields(&existing, entry) {
+				if err := db.UpdatePodcastItemPodcastingFields(&existing); err != nil {
+					Logger.Warnw("failed to store podcasting fields", "podcast_item_id", existing.ID, "error", err)
+				}
+			}
+			// Episodes waiting to download follow their file to a new host,
+			// for instance after the feed moved.
+			if existing.DownloadStatus == db.NotDownloaded || existing.DownloadStatus == db.Failed {
+				if fileURL := feedmeta.ExtractEnclosureURL(entry); fileURL != "" && fileURL != existing.FileURL {
+					if err := db.UpdatePodcastItemFileURL(existing.ID, fileURL); err != nil {
+						Logger.Warnw("failed to store episode file url", "podcast_item_id", existing.ID, "error", err)
+					}
+				}
+			}
+			delete(existingByGUID, guid)
+		}
 		if !keyExists {
 			duration := feedmeta.ParseDurationSeconds(feedmeta.PickFirstNonEmpty(feedmeta.GetString(entry, "itunes_duration"), feedmeta.GetString(entry, "duration")))
 			pubDate := feedmeta.ParseEntryDate(entry)
@@ -353,6 +429,7 @@ func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 				Logger.Infow("podcast transcript missing; queued for WhisperX", "podcast_id", podcast.ID, "episode_guid", guid)
 			}
 
+			enclosure := feedmeta.ExtractEnclosure(entry)
 			podcastItem = db.PodcastItem{
 				PodcastID:        podcast.ID,
 				Title:            feedmeta.GetString(entry, "title"),
@@ -361,7 +438,9 @@ func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 				EpisodeType:      feedmeta.PickFirstNonEmpty(feedmeta.GetString(entry, "itunes_episodetype"), feedmeta.GetString(entry, "episodetype")),
 				Duration:         duration,
 				PubDate:          pubDate,
-				FileURL:          feedmeta.ExtractEnclosureURL(entry),
+				FileURL:          enclosure.URL,
+				EnclosureType:    enclosure.Type,
+				EnclosureLength:  enclosure.Length,
 				GUID:             guid,
 				Image:            feedmeta.ExtractEntryImage(entry, feedImage),
 				DownloadStatus:   downloadStatus,
@@ -372,13 +451,27 @@ func AddPodcastItems(podcast *db.Podcast, newPodcast bool) error {
 				TranscriptJSON:   transcriptJSON,
 				TranscriptStatus: transcriptStatus,
 			}
-			db.CreatePodcastItem(&podcastItem)
+			applyPodcastingEntryFields(&podcastItem, entry)
+			// Hooks only hear about episodes found by a refresh, not the
+			// back catalogue imported with a new subscription.
+			if err := db.CreatePodcastItem(&podcastItem); err == nil && !newPodcast {
+				podcastItem.Podcast = *podcast
+				FireHookEvent(HookEventEpisodeDiscovered, podcastItem, nil)
+				if podcastItem.TranscriptStatus == "available" {
+					FireHookEvent(HookEventTranscriptAvailable, podcastItem, nil)
+				}
+			}
 			itemsAdded[podcastItem.ID] = podcastItem.FileURL
 		}
 	}
 	if (latestDate != time.Time{}) {
 		db.UpdateLastEpisodeDateForPodcast(podcast.ID, latestDate)
 	}
+	if err == nil {
+		if updateErr := db.UpdatePodcastFeedValidators(podcast.ID, validators.ETag, validators.LastModified, validators.BodyHash); updateErr != nil {
+			Logger.Warnw("failed to store feed validators", "podcast_id", podcast.ID, "error", updateErr)
+		}
+	}
 	//go updateSizeFromUrl(itemsAdded)
 	return err
 }
@@ -421,6 +514,7 @@ func SetPodcastItemAsQueuedForDownload(id string) error {
 	podcastItem.DownloadStatus = db.NotDownloaded
 	podcastItem.DownloadedBytes = 0
 	podcastItem.DownloadTotalBytes = 0
+	resetDownloadAttempts(&podcastItem)
 
 	return db.UpdatePodcastItem(&podcastItem)
 }
@@ -488,18 +582,17 @@ func downloadImageLocally(podcastItemId string) error {
 	return db.UpdatePodcastItem(&podcastItem)
 }
 
-func SetPodcastItemBookmarkStatus(id string, bookmark bool) error {
-	var podcastItem db.PodcastItem
-	err := db.GetPodcastItemById(id, &podcastItem)
+func SetPodcastItemBookmarkStatus(userID string, id string, bookmark bool) error {
+	_, state, err := getUserPodcastItem(userID, id)
 	if err != nil {
 		return err
 	}
 	if bookmark {
-		podcastItem.BookmarkDate = time.Now().UTC()
+		state.BookmarkDate = time.Now().UTC()
 	} else {
-		podcastItem.BookmarkDate = time.Time{}
+		state.BookmarkDate = time.Time{}
 	}
-	return db.UpdatePodcastItem(&podcastItem)
+	return saveUserEpisodeState(&state)
 }
 
 func SetPodcastItemAsDownloaded(id string, location string) error {
@@ -511,47 +604,66 @@ func SetPodcastItemAsDownloaded(id string, location string) error {
 		return err
 	}
 
-	size, err := GetFileSize(location)
-	if err == nil {
-		podcastItem.FileSize = size
-	}
-
 	podcastItem.DownloadDate = time.Now().UTC()
 	podcastItem.DownloadPath = location
 	podcastItem.DownloadStatus = db.Downloaded
-	if podcastItem.FileSize > 0 {
-		podcastItem.DownloadedBytes = podcastItem.FileSize
-		podcastItem.DownloadTotalBytes = podcastItem.FileSize
-	}
+	resetDownloadAttempts(&podcastItem)
+	podcastItem.LastDownloadError = ""
 	if podcastItem.TranscriptStatus == "" && podcastItem.TranscriptJSON == "" {
 		podcastItem.TranscriptStatus = "pending_whisperx"
 	}
+	podcastItem.ProcessingError = ""
+	if podcastProcessingSettings(podcastItem.Podcast).Enabled() {
+		podcastItem.ProcessingStatus = ProcessingPending
+	} else {
+		podcastItem.ProcessingStatus = ""
+	}
 
 	if id3meta.ShouldExtract(podcastItem.ChaptersJSON, podcastItem.ID3TagsJSON, podcastItem.ID3ChaptersJSON) {
 		raw, extractErr := ExtractID3Metadata(location)
 		if extractErr != nil {
 			Logger.Warnw("id3 metadata extraction failed", "podcast_item_id", id, "error", extractErr)
 		} else {
-			tagsJSON, chaptersJSON, hasTags, hasChapters, splitErr := id3meta.SplitRaw(raw)
-			if splitErr != nil {
-				Logger.Warnw("id3 metadata parse failed", "podcast_item_id", id, "error", splitErr)
-			} else {
-				if hasTags {
-					podcastItem.ID3TagsJSON = tagsJSON
-				}
-				if hasChapters {
-					podcastItem.ID3ChaptersJSON = chaptersJSON
-					if podcastItem.ChaptersJSON == "" {
-						podcastItem.ChaptersJSON = chaptersJSON
-						podcastItem.ChaptersType = "id3"
-					}
-				}
-			}
+			applyID3Metadata(&podcastItem, raw)
 		}
 	}
 
-	return db.UpdatePodcastItem(&podcastItem)
+	// Tags are written after the publisher's ones were extracted above, and
+	// the size and checksum are taken from the final file.
+	if db.GetOrCreateSetting().WriteID3Tags {
+		if err := WriteID3Tags(podcastItem); err != nil && !errors.Is(err, ErrNotID3Taggable) {
+			Logger.Warnw("id3 tag write failed", "podcast_item_id", id, "error", err)
+		}
+	}
+	refreshDownloadedFileStats(&podcastItem, podcastItem.DownloadDate)
+
+	if err := db.UpdatePodcastItem(&podcastItem); err != nil {
+		return err
+	}
+	FireHookEvent(HookEventDownloadCompleted, podcastItem, nil)
+	return nil
+}
+
+// applyID3Metadata stores the output of ExtractID3Metadata on an episode. The
+// embedded chapters are used when the feed has none.
+func applyID3Metadata(podcastItem *db.PodcastItem, raw []byte) {
+	tagsJSON, chaptersJSON, hasTags, hasChapters, err := id3meta.SplitRaw(raw)
+	if err != nil {
+		Logger.Warnw("id3 metadata parse failed", "podcast_item_id", podcastItem.ID, "error", err)
+		return
+	}
+	if hasTags {
+		podcastItem.ID3TagsJSON = tagsJSON
+	}
+	if hasChapters {
+		podcastItem.ID3ChaptersJSON = chaptersJSON
+		if podcastItem.ChaptersJSON == "" {
+			podcastItem.ChaptersJSON = chaptersJSON
+			podcastItem.ChaptersType = "id3"
+		}
+	}
 }
+
 func SetPodcastItemAsNotDownloaded(id string, downloadStatus db.DownloadStatus) error {
 	var podcastItem db.PodcastItem
 	err := db.GetPodcastItemById(id, &podcastItem)
@@ -563,18 +675,19 @@ func SetPodcastItemAsNotDownloaded(id string, downloadStatus db.DownloadStatus)
 	podcastItem.DownloadStatus = downloadStatus
 	podcastItem.DownloadedBytes = 0
 	podcastItem.DownloadTotalBytes = 0
+	podcastItem.OriginalPath = ""
+	podcastItem.ProcessingStatus = ""
 
 	return db.UpdatePodcastItem(&podcastItem)
 }
 
-func SetPodcastItemPlayedStatus(id string, isPlayed bool) error {
-	var podcastItem db.PodcastItem
-	err := db.GetPodcastItemById(id, &podcastItem)
+func SetPodcastItemPlayedStatus(userID string, id string, isPlayed bool) error {
+	_, state, err := getUserPodcastItem(userID, id)
 	if err != nil {
 		return err
 	}
-	podcastItem.IsPlayed = isPlayed
-	return db.UpdatePodcastItem(&podcastItem)
+	state.IsPlayed = isPlayed
+	return saveUserEpisodeState(&state)
 }
 func SetAllEpisodesToDownload(podcastId string) error {
 	var podcast db.Podcast
@@ -618,6 +731,13 @@ func DownloadMissingEpisodes() error {
 		return nil
 	}
 
+	setting := db.GetOrCreateSetting()
+	SetDownloadBandwidthLimit(setting.DownloadMaxBytesPerSecond)
+	if !DownloadWindowOpen(setting, downloadWindowNow()) {
+		jobLogger.Infow("outside_download_window", "window_start", setting.DownloadWindowStart, "window_end", setting.DownloadWindowEnd)
+		return nil
+	}
+
 	lock := db.GetLock(JOB_NAME)
 	if lock.IsLocked() {
 		jobLogger.Infow("job_skipped_lock_exists")
@@ -626,8 +746,10 @@ func DownloadMissingEpisodes() error {
 	db.Lock(JOB_NAME, 120)
 	defer db.Unlock(JOB_NAME)
 
-	setting := db.GetOrCreateSetting()
-
+	if err := db.SyncDownloadQueue(); err != nil {
+		jobLogger.Errorw("failed to sync download queue", "error", err)
+		return err
+	}
 	data, err := db.GetAllPodcastItemsToBeDownloaded()
 	if err != nil {
 		jobLogger.Errorw("failed to fetch episodes to download", "error", err)
@@ -659,7 +781,9 @@ func DownloadMissingEpisodes() error {
 	}
 
 	runWorkerPool(items, workers, func(item db.PodcastItem) {
-		if DownloadsPaused() {
+		// Downloads already running finish when the window closes; the rest
+		// stay queued for the next window.
+		if DownloadsPaused() || !DownloadWindowOpen(&settingSnapshot, downloadWindowNow()) {
 			return
 		}
 		if IsDownloadCancelled(item.ID) {
@@ -672,7 +796,7 @@ func DownloadMissingEpisodes() error {
 			jobLogger.Warnw("failed to mark episode downloading", "podcast_item_id", item.ID, "error", err)
 		}
 
-		url, downloadErr := Download(item.ID, item.FileURL, item.Title, item.Podcast.Title, GetPodcastPrefix(&item, &settingSnapshot))
+		url, downloadErr := Download(item.ID, item.FileURL, EpisodeDownloadTarget(&item, &settingSnapshot), item.EnclosureType)
 		if downloadErr != nil {
 			if downloadErr == ErrDownloadCancelled {
 				jobLogger.Infow("download cancelled", "podcast_item_id", item.ID)
@@ -685,7 +809,9 @@ func DownloadMissingEpisodes() error {
 				return
 			}
 			jobLogger.Errorw("failed to download episode", "podcast_item_id", item.ID, "error", downloadErr)
-			_ = SetPodcastItemAsNotDownloaded(item.ID, db.NotDownloaded)
+			if err := recordDownloadFailure(item.ID, downloadErr); err != nil {
+				jobLogger.Warnw("failed to record download failure", "podcast_item_id", item.ID, "error", err)
+			}
 			setError(downloadErr)
 			return
 		}
@@ -740,6 +866,9 @@ func DeleteEpisodeFile(podcastItemId string) error {
 		Logger.Errorw("failed to delete episode file", "podcast_item_id", podcastItemId, "path", podcastItem.DownloadPath, "error", err)
 		return err
 	}
+	if podcastItem.OriginalPath != "" {
+		DeleteFile(podcastItem.OriginalPath)
+	}
 
 	if podcastItem.LocalImage != "" {
 		go DeleteFile(podcastItem.LocalImage)
@@ -756,7 +885,10 @@ func DownloadSingleEpisode(podcastItemId string) error {
 		return err
 	}
 
+	// Downloads started by hand ignore the download window but still share
+	// the bandwidth cap.
 	setting := db.GetOrCreateSetting()
+	SetDownloadBandwidthLimit(setting.DownloadMaxBytesPerSecond)
 	if DownloadsPaused() {
 		return errors.New("downloads are paused")
 	}
@@ -764,7 +896,7 @@ func DownloadSingleEpisode(podcastItemId string) error {
 		Logger.Warnw("failed to mark episode downloading", "podcast_item_id", podcastItemId, "error", err)
 	}
 
-	url, err := Download(podcastItem.ID, podcastItem.FileURL, podcastItem.Title, podcastItem.Podcast.Title, GetPodcastPrefix(&podcastItem, setting))
+	url, err := Download(podcastItem.ID, podcastItem.FileURL, EpisodeDownloadTarget(&podcastItem, setting), podcastItem.EnclosureType)
 
 	if err != nil {
 		if err == ErrDownloadCancelled {
@@ -776,7 +908,9 @@ func DownloadSingleEpisode(podcastItemId string) error {
 			return nil
 		}
 		Logger.Errorw("failed to download single episode", "podcast_item_id", podcastItemId, "error", err)
-		_ = SetPodcastItemAsNotDownloaded(podcastItem.ID, db.NotDownloaded)
+		if recordErr := recordDownloadFailure(podcastItem.ID, err); recordErr != nil {
+			Logger.Warnw("failed to record download failure", "podcast_item_id", podcastItemId, "error", recordErr)
+		}
 		return err
 	}
 	err = SetPodcastItemAsDownloaded(podcastItem.ID, url)
@@ -817,9 +951,22 @@ func RefreshEpisodes() error {
 		return nil
 	}
 
+	now := time.Now()
+	var due []db.Podcast
+	for _, podcast := range data {
+		if podcastRefreshDue(podcast, now) {
+			due = append(due, podcast)
+		}
+	}
+	if len(due) == 0 {
+		jobLogger.Infow("no podcasts due for refresh", "podcast_count", len(data))
+		go DownloadMissingEpisodes()
+		return nil
+	}
+
 	setting := db.GetOrCreateSetting()
-	workers := boundedWorkerCount(setting.MaxDownloadConcurrency, 4, len(data))
-	jobLogger.Infow("refresh worker pool started", "podcast_count", len(data), "worker_count", workers)
+	workers := boundedWorkerCount(setting.MaxDownloadConcurrency, 4, len(due))
+	jobLogger.Infow("refresh worker pool started", "podcast_count", len(due), "not_due_count", len(data)-len(due), "worker_count", workers)
 	var (
 		firstErr error
 		errMutex sync.Mutex
@@ -835,15 +982,15 @@ func RefreshEpisodes() error {
 		errMutex.Unlock()
 	}
 
-	runWorkerPool(data, workers, func(item db.Podcast) {
-		isNewPodcast := item.LastEpisode == nil
-		if isNewPodcast {
-			jobLogger.Infow("forcing last episode date for new podcast", "podcast_id", item.ID, "title", item.Title)
-			db.ForceSetLastEpisodeDate(item.ID)
+	runWorkerPool(due, workers, func(item db.Podcast) {
+		refreshErr := refreshPodcast(&item)
+		if errors.Is(refreshErr, ErrPodcastRefreshing) {
+			jobLogger.Infow("podcast refresh skipped, already refreshing", "podcast_id", item.ID, "title", item.Title)
+			return
 		}
-		if addErr := AddPodcastItems(&item, isNewPodcast); addErr != nil {
-			jobLogger.Errorw("failed to refresh podcast feed", "podcast_id", item.ID, "title", item.Title, "error", addErr)
-			setError(addErr)
+		if refreshErr != nil {
+			jobLogger.Errorw("failed to refresh podcast feed", "podcast_id", item.ID, "title", item.Title, "error", refreshErr)
+			setError(refreshErr)
 		}
 	})
 
@@ -873,6 +1020,9 @@ func DeletePodcastEpisodes(id string) error {
 	}
 	for _, item := range podcastItems {
 		DeleteFile(item.DownloadPath)
+		if item.OriginalPath != "" {
+			DeleteFile(item.OriginalPath)
+		}
 		if item.LocalImage != "" {
 			DeleteFile(item.LocalImage)
 		}
@@ -898,6 +1048,9 @@ func DeletePodcast(id string, deleteFiles bool) error {
 	for _, item := range podcastItems {
 		if deleteFiles {
 			DeleteFile(item.DownloadPath)
+			if item.OriginalPath != "" {
+				DeleteFile(item.OriginalPath)
+			}
 			if item.LocalImage != "" {
 				DeleteFile(item.LocalImage)
 			}
@@ -916,6 +1069,13 @@ func DeletePodcast(id string, deleteFiles bool) error {
 	if err != nil {
 		return err
 	}
+	if webSubActive(podcast, time.Now()) {
+		go unsubscribeWebSub(podcast.ID, podcast.WebSubHub, podcast.WebSubTopic)
+	}
+	if err := db.DeletePodcastEvents(id); err != nil {
+		Logger.Warnw("failed to delete podcast events", "podcast_id", id, "error", err)
+	}
+	recordSubscriptionChange(podcast.URL, subscriptionRemoved)
 	return nil
 
 }
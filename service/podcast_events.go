@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
+	"gorm.io/gorm"
+)
+
+// Podcast event kinds.
+const (
+	PodcastEventURLChanged  = "url_changed"
+	PodcastEventURLRejected = "url_rejected"
+)
+
+// Sources of a feed move, kept in the event message.
+const (
+	feedMoveRedirect   = "permanent redirect"
+	feedMoveNewFeedURL = "itunes:new-feed-url"
+)
+
+func GetPodcastEvents(podcastID string, limit int) (*[]db.PodcastEvent, error) {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(podcastID, &podcast); err != nil {
+		return nil, err
+	}
+	return db.GetPodcastEvents(podcastID, limit)
+}
+
+// findPodcastByURL looks a podcast up by its feed URL, or by a URL it moved
+// away from.
+func findPodcastByURL(feedURL string, podcast *db.Podcast) error {
+	err := db.GetPodcastByURL(feedURL, podcast)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.GetPodcastByPreviousURL(feedURL, PodcastEventURLChanged, podcast)
+	}
+	return err
+}
+
+// recordPodcastEventOnce logs an event unless it repeats the podcast's latest
+// one, so a feed that keeps declaring the same rejected URL logs it once.
+func recordPodcastEventOnce(podcastID string, event db.PodcastEvent) {
+	var latest db.PodcastEvent
+	if err := db.GetLatestPodcastEvent(podcastID, &latest); err == nil && latest.Kind == event.Kind && latest.OldURL == event.OldURL && latest.NewURL == event.NewURL {
+		return
+	}
+	event.PodcastID = podcastID
+	if err := db.CreatePodcastEvent(&event); err != nil {
+		Logger.Warnw("failed to record podcast event", "podcast_id", podcastID, "kind", event.Kind, "error", err)
+	}
+}
+
+// checkNewFeedURL follows an itunes:new-feed-url declaration once the new
+// URL serves a feed. It reports whether the podcast moved.
+func checkNewFeedURL(podcast *db.Podcast, feed map[string]interface{}) bool {
+	newURL := feedmeta.ExtractNewFeedURL(feed)
+	if newURL == "" || newURL == podcast.URL {
+		return false
+	}
+	if _, _, err := FetchFeed(newURL); err != nil {
+		rejectFeedMove(podcast, newURL, fmt.Sprintf("%s %s does not serve a feed: %v", feedMoveNewFeedURL, newURL, err))
+		return false
+	}
+	return movePodcastFeed(podcast, newURL, feedMoveNewFeedURL)
+}
+
+// movePodcastFeed points a podcast at newURL. Episodes stay attached to the
+// podcast and keep being matched by GUID, so nothing is added or downloaded
+// twice. Moves to a URL another podcast uses, or back to one this podcast
+// left, are rejected and logged.
+func movePodcastFeed(podcast *db.Podcast, newURL string, source string) bool {
+	newURL = strings.TrimSpace(newURL)
+	if newURL == "" || newURL == podcast.URL {
+		return false
+	}
+	if parsed, err := url.Parse(newURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		rejectFeedMove(podcast, newURL, fmt.Sprintf("%s %q is not an http(s) URL", source, newURL))
+		return false
+	}
+	var other db.Podcast
+	if err := findPodcastByURL(newURL, &other); err == nil {
+		if other.ID == podcast.ID {
+			rejectFeedMove(podcast, newURL, fmt.Sprintf("%s points back to %s, which this podcast moved away from", source, newURL))
+		} else {
+			rejectFeedMove(podcast, newURL, fmt.Sprintf("%s points to %s, which belongs to %q", source, newURL, other.Title))
+		}
+		return false
+	}
+
+	oldURL := podcast.URL
+	event := db.PodcastEvent{
+		Kind:    PodcastEventURLChanged,
+		Message: fmt.Sprintf("Feed moved to %s (%s)", newURL, source),
+		OldURL:  oldURL,
+		NewURL:  newURL,
+	}
+	if err := db.MovePodcastURL(podcast.ID, &event); err != nil {
+		Logger.Errorw("failed to move podcast feed", "podcast_id", podcast.ID, "old_url", oldURL, "new_url", newURL, "error", err)
+		return false
+	}
+	podcast.URL = newURL
+	podcast.FeedETag = ""
+	podcast.FeedLastModified = ""
+	Logger.Infow("podcast feed moved", "podcast_id", podcast.ID, "old_url", oldURL, "new_url", newURL, "source", source)
+	recordSubscriptionChange(oldURL, subscriptionRemoved)
+	recordSubscriptionChange(newURL, subscriptionAdded)
+	return true
+}
+
+func rejectFeedMove(podcast *db.Podcast, newURL string, message string) {
+	Logger.Warnw("podcast feed move rejected", "podcast_id", podcast.ID, "url", podcast.URL, "new_url", newURL, "reason", message)
+	recordPodcastEventOnce(podcast.ID, db.PodcastEvent{
+		Kind:    PodcastEventURLRejected,
+		Message: message,
+		OldURL:  podcast.URL,
+		NewURL:  newURL,
+	})
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/model"
+)
+
+func movedFeedXML(newFeedURL string, guids ...string) string {
+	items := ""
+	for _, guid := range guids {
+		items += fmt.Sprintf(`<item><title>%s</title><guid>%s</guid><pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate><enclosure url="https://new.example.com/%s.mp3" type="audio/mpeg" length="1" /></item>`, guid, guid, guid)
+	}
+	newFeed := ""
+	if newFeedURL != "" {
+		newFeed = "<itunes:new-feed-url>" + newFeedURL + "</itunes:new-feed-url>"
+	}
+	return `<?xml version="1.0"?><rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Moved</title>` + newFeed + items + `</channel></rss>`
+}
+
+func podcastEvents(t *testing.T, podcastID string) []db.PodcastEvent {
+	t.Helper()
+	events, err := GetPodcastEvents(podcastID, 0)
+	if err != nil {
+		t.Fatalf("load podcast events failed: %v", err)
+	}
+	return *events
+}
+
+func TestAddPodcastItemsFollowsPermanentRedirect(t *testing.T) {
+	setupRetentionTestDB(t)
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.Handle("/temporary", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, movedFeedXML("", "ep-1", "ep-2"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	podcast := db.Podcast{Title: "Moved", URL: server.URL + "/old"}
+	if err := db.CreatePodcast(&podcast); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	existing := db.PodcastItem{PodcastID: podcast.ID, Title: "ep-1", GUID: "ep-1", FileURL: "https://old.example.com/ep-1.mp3", PubDate: time.Now(), DownloadStatus: db.NotDownloaded}
+	if err := db.CreatePodcastItem(&existing); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+
+	if err := AddPodcastItems(&podcast, false); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err != nil {
+		t.Fatalf("reload podcast failed: %v", err)
+	}
+	if stored.URL != server.URL+"/new" || podcast.URL != stored.URL {
+		t.Fatalf("expected the podcast to follow the redirect, got %q", stored.URL)
+	}
+	if len(stored.PodcastItems) != 2 {
+		t.Fatalf("expected the existing episode to be matched by GUID, got %d episodes", len(stored.PodcastItems))
+	}
+	if got := reloadPodcastItem(t, existing.ID); got.FileURL != "https://new.example.com/ep-1.mp3" {
+		t.Fatalf("expected the queued episode to use the new file URL, got %q", got.FileURL)
+	}
+	events := podcastEvents(t, podcast.ID)
+	if len(events) != 1 || events[0].Kind != PodcastEventURLChanged || events[0].OldURL != server.URL+"/old" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	if _, err := AddPodcast(server.URL + "/old"); err == nil {
+		t.Fatalf("expected the previous URL to match the existing podcast")
+	} else if _, ok := err.(*model.PodcastAlreadyExistsError); !ok {
+		t.Fatalf("expected PodcastAlreadyExistsError, got %v", err)
+	}
+
+	temporary := db.Podcast{Title: "Temporary", URL: server.URL + "/temporary"}
+	if err := db.CreatePodcast(&temporary); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&temporary, false); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if temporary.URL != server.URL+"/temporary" || len(podcastEvents(t, temporary.ID)) != 0 {
+		t.Fatalf("expected temporary redirects to keep the URL, got %q", temporary.URL)
+	}
+}
+
+func TestAddPodcastItemsIgnoresRedirectToNonFeed(t *testing.T) {
+	setupRetentionTestDB(t)
+	mux := http.NewServeMux()
+	mux.Handle("/retired", http.RedirectHandler("/home", http.StatusMovedPermanently))
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, "<html><body>This show has ended.</body></html>")
+	})
+	mux.Handle("/chain", http.RedirectHandler("/hop", http.StatusMovedPermanently))
+	mux.Handle("/hop", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, movedFeedXML("", "ep-1"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	retired := db.Podcast{Title: "Retired", URL: server.URL + "/retired"}
+	if err := db.CreatePodcast(&retired); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&retired, false); err == nil {
+		t.Fatalf("expected the HTML page to fail to parse")
+	}
+	var stored db.Podcast
+	if err := db.GetPodcastById(retired.ID, &stored); err != nil {
+		t.Fatalf("reload podcast failed: %v", err)
+	}
+	if stored.URL != server.URL+"/retired" || retired.URL != stored.URL {
+		t.Fatalf("expected a redirect to a non-feed to keep the URL, got %q", stored.URL)
+	}
+	if events := podcastEvents(t, retired.ID); len(events) != 1 || events[0].Kind != PodcastEventURLRejected {
+		t.Fatalf("expected one logged rejection, got %+v", events)
+	}
+
+	chained := db.Podcast{Title: "Chained", URL: server.URL + "/chain"}
+	if err := db.CreatePodcast(&chained); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&chained, false); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if chained.URL != server.URL+"/hop" {
+		t.Fatalf("expected the move to stop at the temporary redirect, got %q", chained.URL)
+	}
+}
+
+func TestAddPodcastItemsFollowsNewFeedURL(t *testing.T) {
+	setupRetentionTestDB(t)
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, movedFeedXML(server.URL+"/b", "ep-1"))
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		// The new feed still points back at the old one.
+		_, _ = fmt.Fprint(w, movedFeedXML(server.URL+"/a", "ep-1", "ep-2"))
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, movedFeedXML(server.URL+"/missing", "ep-1"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	podcast := db.Podcast{Title: "Moved", URL: server.URL + "/a"}
+	if err := db.CreatePodcast(&podcast); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&podcast, false); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if podcast.URL != server.URL+"/b" {
+		t.Fatalf("expected the podcast to follow new-feed-url, got %q", podcast.URL)
+	}
+	for i := 0; i < 2; i++ {
+		if err := AddPodcastItems(&podcast, false); err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+	}
+	if podcast.URL != server.URL+"/b" {
+		t.Fatalf("expected the move back to be rejected, got %q", podcast.URL)
+	}
+	events := podcastEvents(t, podcast.ID)
+	if len(events) != 2 || events[0].Kind != PodcastEventURLRejected || events[1].Kind != PodcastEventURLChanged {
+		t.Fatalf("expected one move and one logged rejection, got %+v", events)
+	}
+	var items []db.PodcastItem
+	if err := db.GetAllPodcastItemsByPodcastId(podcast.ID, &items); err != nil || len(items) != 2 {
+		t.Fatalf("expected two episodes after the move, got %d (%v)", len(items), err)
+	}
+
+	broken := db.Podcast{Title: "Broken", URL: server.URL + "/broken"}
+	if err := db.CreatePodcast(&broken); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if err := AddPodcastItems(&broken, false); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if events := podcastEvents(t, broken.ID); broken.URL != server.URL+"/broken" || len(events) != 1 || events[0].Kind != PodcastEventURLRejected {
+		t.Fatalf("expected a new-feed-url that is not a feed to be rejected, got %q %+v", broken.URL, events)
+	}
+}
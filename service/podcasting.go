@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
+)
+
+// applyPodcastingFeedFields reads the Podcasting 2.0 channel fields of a
+// parsed feed into podcast. It reports whether any of them changed.
+func applyPodcastingFeedFields(podcast *db.Podcast, feed map[string]interface{}) bool {
+	updated := *podcast
+	updated.PodcastGUID = feedmeta.ExtractPodcastGUID(feed)
+	updated.PersonsJSON = marshalPodcastingValue(feedmeta.ExtractPersons(feed))
+	updated.FundingJSON = marshalPodcastingValue(feedmeta.ExtractFunding(feed))
+	updated.LocationJSON = marshalPodcastingValue(feedmeta.ExtractLocation(feed))
+	changed := updated.PodcastGUID != podcast.PodcastGUID ||
+		updated.PersonsJSON != podcast.PersonsJSON ||
+		updated.FundingJSON != podcast.FundingJSON ||
+		updated.LocationJSON != podcast.LocationJSON
+	*podcast = updated
+	return changed
+}
+
+// applyPodcastingEntryFields reads the season, episode number and
+// Podcasting 2.0 fields of a feed entry into item. It reports whether any
+// of them changed.
+func applyPodcastingEntryFields(item *db.PodcastItem, entry map[string]interface{}) bool {
+	updated := *item
+	updated.Season = feedmeta.ExtractSeason(entry)
+	updated.EpisodeNumber = feedmeta.ExtractEpisodeNumber(entry)
+	updated.PersonsJSON = marshalPodcastingValue(feedmeta.ExtractPersons(entry))
+	updated.SoundbitesJSON = marshalPodcastingValue(feedmeta.ExtractSoundbites(entry))
+	updated.LocationJSON = marshalPodcastingValue(feedmeta.ExtractLocation(entry))
+	changed := updated.Season != item.Season ||
+		updated.EpisodeNumber != item.EpisodeNumber ||
+		updated.PersonsJSON != item.PersonsJSON ||
+		updated.SoundbitesJSON != item.SoundbitesJSON ||
+		updated.LocationJSON != item.LocationJSON
+	*item = updated
+	return changed
+}
+
+// marshalPodcastingValue stores empty lists and missing values as an empty
+// column.
+func marshalPodcastingValue(value interface{}) string {
+	switch typed := value.(type) {
+	case []feedmeta.Person:
+		if len(typed) == 0 {
+			return ""
+		}
+	case []feedmeta.Soundbite:
+		if len(typed) == 0 {
+			return ""
+		}
+	case []feedmeta.Funding:
+		if len(typed) == 0 {
+			return ""
+		}
+	case *feedmeta.Location:
+		if typed == nil {
+			return ""
+		}
+	}
+	return feedmeta.MarshalMetadata(value)
+}
+
+// BackfillPodcastingFields fills the season, episode number and Podcasting
+// 2.0 columns of podcasts and episodes stored before they existed, from the
+// feed metadata kept with them.
+func BackfillPodcastingFields() error {
+	podcasts, err := db.GetPodcastsWithoutPodcastingFields()
+	if err != nil {
+		return err
+	}
+	for _, podcast := range *podcasts {
+		var feed map[string]interface{}
+		if podcast.FeedMetadata != "" {
+			if err := json.Unmarshal([]byte(podcast.FeedMetadata), &feed); err != nil {
+				Logger.Warnw("failed to read feed metadata", "podcast_id", podcast.ID, "error", err)
+			}
+		}
+		applyPodcastingFeedFields(&podcast, feed)
+		if err := db.UpdatePodcastPodcastingFields(&podcast); err != nil {
+			return err
+		}
+	}
+
+	items, err := db.GetPodcastItemsWithoutPodcastingFields()
+	if err != nil {
+		return err
+	}
+	for _, item := range *items {
+		var entry map[string]interface{}
+		if item.ItemMetadata != "" {
+			if err := json.Unmarshal([]byte(item.ItemMetadata), &entry); err != nil {
+				Logger.Warnw("failed to read episode metadata", "podcast_item_id", item.ID, "error", err)
+			}
+		}
+		applyPodcastingEntryFields(&item, entry)
+		if err := db.UpdatePodcastItemPodcastingFields(&item); err != nil {
+			return err
+		}
+	}
+	if len(*podcasts) > 0 || len(*items) > 0 {
+		Logger.Infow("backfilled podcasting fields", "podcasts", len(*podcasts), "episodes", len(*items))
+	}
+	return nil
+}
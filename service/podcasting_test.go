@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestBackfillPodcastingFields(t *testing.T) {
+	setupRetentionTestDB(t)
+	podcast := createPodcast(t, "Show", false)
+	podcast.FeedMetadata = `{"podcast_guid": "917393e3-1b1e-5cef-ace4-edaa54e1f810"}`
+	if err := db.DB.Save(&podcast).Error; err != nil {
+		t.Fatalf("save podcast failed: %v", err)
+	}
+	item := db.PodcastItem{
+		PodcastID:    podcast.ID,
+		Title:        "Episode",
+		GUID:         "guid-1",
+		PubDate:      time.Now(),
+		ItemMetadata: `{"itunes_season": "3", "podcast_episode": {"value": "12"}, "podcast_soundbite": [{"starttime": "5", "duration": "20"}]}`,
+	}
+	if err := db.CreatePodcastItem(&item); err != nil {
+		t.Fatalf("create podcast item failed: %v", err)
+	}
+	// Rows stored before the columns existed have them null.
+	db.DB.Exec("update podcasts set persons_json = null")
+	db.DB.Exec("update podcast_items set persons_json = null")
+
+	if err := BackfillPodcastingFields(); err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	got := reloadPodcastItem(t, item.ID)
+	if got.Season != 3 || got.EpisodeNumber != 12 || len(got.Soundbites) != 1 {
+		t.Fatalf("expected the fields from the stored metadata, got %d %d %+v", got.Season, got.EpisodeNumber, got.Soundbites)
+	}
+	if got.Podcast.PodcastGUID != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("expected the podcast guid from the stored metadata, got %q", got.Podcast.PodcastGUID)
+	}
+	if remaining, err := db.GetPodcastItemsWithoutPodcastingFields(); err != nil || len(*remaining) != 0 {
+		t.Fatalf("expected the backfill to run once, got %v %v", remaining, err)
+	}
+}
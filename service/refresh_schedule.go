@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+var (
+	ErrPodcastRefreshing      = errors.New("podcast is already being refreshed")
+	ErrInvalidRefreshInterval = errors.New("refresh interval must be between 0 and 10080 minutes")
+)
+
+const (
+	defaultCheckFrequencyMinutes = 30
+	maxRefreshIntervalMinutes    = 7 * 24 * 60
+
+	// Active feeds are checked at least daily; dormant and failing feeds
+	// back off to at most a week.
+	maxActiveRefreshInterval = 24 * time.Hour
+	maxRefreshInterval       = maxRefreshIntervalMinutes * time.Minute
+	// A feed is dormant once its newest episode is older than this and
+	// than three of its usual gaps.
+	dormantAfter = 30 * 24 * time.Hour
+	// refreshCadenceEpisodes is how many recent episodes the publish cadence
+	// is measured over.
+	refreshCadenceEpisodes = 10
+)
+
+// refreshingPodcasts holds the IDs of podcasts being refreshed, so the
+// scheduled job and a manual refresh never work on the same feed at once.
+var refreshingPodcasts sync.Map
+
+// refreshBaseInterval is the CHECK_FREQUENCY tick of the RefreshEpisodes
+// job, the shortest interval a podcast can get.
+func refreshBaseInterval() time.Duration {
+	minutes := getEnvInt("CHECK_FREQUENCY", defaultCheckFrequencyMinutes)
+	if minutes <= 0 {
+		minutes = defaultCheckFrequencyMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func podcastRefreshDue(podcast db.Podcast, now time.Time) bool {
+	return podcast.NextRefreshAt == nil || !podcast.NextRefreshAt.After(now)
+}
+
+// adaptiveRefreshInterval checks a feed four times per typical gap between
+// its recent episodes, between base and a day. Dormant feeds are checked
+// about ten times over the time since their last episode, between a day and
+// a week.
+func adaptiveRefreshInterval(pubDates []time.Time, now time.Time, base time.Duration) time.Duration {
+	var dates []time.Time
+	for _, date := range pubDates {
+		if !date.IsZero() {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+	var gaps []time.Duration
+	for i := 1; i < len(dates); i++ {
+		if gap := dates[i-1].Sub(dates[i]); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return base
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	typicalGap := gaps[len(gaps)/2]
+
+	sinceLast := now.Sub(dates[0])
+	if sinceLast > dormantAfter && sinceLast > 3*typicalGap {
+		return clampDuration(sinceLast/10, max(base, maxActiveRefreshInterval), maxRefreshInterval)
+	}
+	return clampDuration(typicalGap/4, base, max(base, maxActiveRefreshInterval))
+}
+
+// nextRefreshInterval is the adaptive interval, or the podcast's fixed one,
+// stretched by exponential backoff after failed refreshes. Feeds that push
+// through WebSub are polled less, but again before their lease needs renewing.
+func nextRefreshInterval(podcast db.Podcast, pubDates []time.Time, now time.Time, base time.Duration) time.Duration {
+	interval := adaptiveRefreshInterval(pubDates, now, base)
+	pushed := webSubActive(podcast, now)
+	if podcast.RefreshIntervalMinutes > 0 {
+		interval = time.Duration(podcast.RefreshIntervalMinutes) * time.Minute
+	} else if pushed {
+		interval = max(interval, websubPollInterval)
+	}
+	if podcast.RefreshFailures > 0 {
+		backoff := clampDuration(base<<min(podcast.RefreshFailures, 10), base, maxActiveRefreshInterval)
+		interval = min(max(interval, backoff), maxRefreshInterval)
+	}
+	if pushed {
+		untilRenewal := podcast.WebSubExpiresAt.Add(-websubRenewBefore).Sub(now)
+		interval = min(interval, max(untilRenewal, base))
+	}
+	return interval
+}
+
+func clampDuration(value time.Duration, lower time.Duration, upper time.Duration) time.Duration {
+	return min(max(value, lower), upper)
+}
+
+// refreshPodcast refreshes one feed and schedules its next refresh from the
+// outcome.
+func refreshPodcast(podcast *db.Podcast) error {
+	if _, busy := refreshingPodcasts.LoadOrStore(podcast.ID, true); busy {
+		return ErrPodcastRefreshing
+	}
+	defer refreshingPodcasts.Delete(podcast.ID)
+
+	isNewPodcast := podcast.LastEpisode == nil
+	if isNewPodcast {
+		Logger.Infow("forcing last episode date for new podcast", "podcast_id", podcast.ID, "title", podcast.Title)
+		db.ForceSetLastEpisodeDate(podcast.ID)
+	}
+	refreshErr := AddPodcastItems(podcast, isNewPodcast)
+	if refreshErr == nil {
+		maintainWebSubSubscription(podcast)
+	}
+	scheduleNextRefresh(podcast, refreshErr)
+	return refreshErr
+}
+
+func scheduleNextRefresh(podcast *db.Podcast, refreshErr error) {
+	now := time.Now()
+	if refreshErr != nil {
+		podcast.RefreshFailures++
+		podcast.LastRefreshError = refreshErr.Error()
+	} else {
+		podcast.RefreshFailures = 0
+		podcast.LastRefreshError = ""
+	}
+	pubDates, err := db.GetRecentPubDates(podcast.ID, refreshCadenceEpisodes)
+	if err != nil {
+		Logger.Warnw("failed to load publish dates", "podcast_id", podcast.ID, "error", err)
+	}
+	next := now.Add(nextRefreshInterval(*podcast, pubDates, now, refreshBaseInterval()))
+	podcast.LastRefreshAt = &now
+	podcast.NextRefreshAt = &next
+	if err := db.UpdatePodcastRefreshSchedule(podcast.ID, now, next, podcast.RefreshFailures, podcast.LastRefreshError); err != nil {
+		Logger.Warnw("failed to store refresh schedule", "podcast_id", podcast.ID, "error", err)
+		return
+	}
+	if refreshErr != nil {
+		Logger.Warnw("podcast refresh failed", "podcast_id", podcast.ID, "failures", podcast.RefreshFailures, "next_refresh_at", next, "error", refreshErr)
+	}
+}
+
+// RefreshPodcast refreshes one podcast now, outside its schedule. A feed
+// error is returned along with the podcast, whose schedule already records it.
+func RefreshPodcast(id string) (*db.Podcast, error) {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(id, &podcast); err != nil {
+		return nil, err
+	}
+	refreshErr := refreshPodcast(&podcast)
+	if errors.Is(refreshErr, ErrPodcastRefreshing) {
+		return nil, refreshErr
+	}
+	if refreshErr == nil {
+		go DownloadMissingEpisodes()
+	}
+	var refreshed db.Podcast
+	if err := db.GetPodcastById(id, &refreshed); err != nil {
+		return nil, err
+	}
+	return &refreshed, refreshErr
+}
+
+// SetPodcastRefreshInterval fixes a podcast's refresh interval in minutes, or
+// returns it to the adaptive schedule with 0. The next refresh moves to match.
+func SetPodcastRefreshInterval(id string, minutes int) error {
+	if minutes < 0 || minutes > maxRefreshIntervalMinutes {
+		return ErrInvalidRefreshInterval
+	}
+	var podcast db.Podcast
+	if err := db.GetPodcastById(id, &podcast); err != nil {
+		return err
+	}
+	now := time.Now()
+	next := now
+	if podcast.LastRefreshAt != nil {
+		podcast.RefreshIntervalMinutes = minutes
+		pubDates, err := db.GetRecentPubDates(id, refreshCadenceEpisodes)
+		if err != nil {
+			return err
+		}
+		next = podcast.LastRefreshAt.Add(nextRefreshInterval(podcast, pubDates, now, refreshBaseInterval()))
+	}
+	return db.UpdatePodcastRefreshInterval(id, minutes, next)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestNextRefreshInterval(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	base := 30 * time.Minute
+	every := func(gap time.Duration, count int, since time.Duration) []time.Time {
+		var dates []time.Time
+		for i := 0; i < count; i++ {
+			dates = append(dates, now.Add(-since-time.Duration(i)*gap))
+		}
+		return dates
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		podcast  db.Podcast
+		pubDates []time.Time
+		want     time.Duration
+	}{
+		{name: "no history", want: base},
+		{name: "hourly", pubDates: every(time.Hour, 10, 0), want: base},
+		{name: "daily", pubDates: every(day, 10, time.Hour), want: 6 * time.Hour},
+		{name: "weekly", pubDates: every(7*day, 10, day), want: day},
+		{name: "dormant", pubDates: every(7*day, 10, 60*day), want: 6 * day},
+		{name: "long dormant", pubDates: every(7*day, 10, 365*day), want: 7 * day},
+		{name: "manual", podcast: db.Podcast{RefreshIntervalMinutes: 90}, pubDates: every(7*day, 10, 365*day), want: 90 * time.Minute},
+		{name: "failing", podcast: db.Podcast{RefreshFailures: 3}, pubDates: every(time.Hour, 10, 0), want: 4 * time.Hour},
+		{name: "failing often", podcast: db.Podcast{RefreshFailures: 20}, want: day},
+		{name: "failing dormant", podcast: db.Podcast{RefreshFailures: 1}, pubDates: every(7*day, 10, 365*day), want: 7 * day},
+	}
+	for _, tt := range tests {
+		if got := nextRefreshInterval(tt.podcast, tt.pubDates, now, base); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRefreshPodcastSchedulesNextRefresh(t *testing.T) {
+	setupRetentionTestDB(t)
+	t.Setenv("CHECK_FREQUENCY", "30")
+	var failing atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		_, _ = fmt.Fprint(w, movedFeedXML("", "ep-1"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	podcast := db.Podcast{Title: "Scheduled", URL: server.URL + "/feed"}
+	if err := db.CreatePodcast(&podcast); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	if !podcastRefreshDue(podcast, time.Now()) {
+		t.Fatalf("expected a podcast that was never refreshed to be due")
+	}
+
+	before := time.Now()
+	refreshed, err := RefreshPodcast(podcast.ID)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if refreshed.LastRefreshAt == nil || refreshed.NextRefreshAt == nil || refreshed.RefreshFailures != 0 {
+		t.Fatalf("expected the refresh to be scheduled, got %+v", refreshed)
+	}
+	if got := refreshed.NextRefreshAt.Sub(before); got < 30*time.Minute || got > 31*time.Minute {
+		t.Fatalf("expected the next refresh after the base interval, got %s", got)
+	}
+	if podcastRefreshDue(*refreshed, time.Now()) {
+		t.Fatalf("expected the podcast not to be due right after a refresh")
+	}
+
+	failing.Store(true)
+	for i := 1; i <= 2; i++ {
+		refreshed, err = RefreshPodcast(podcast.ID)
+		if err == nil || refreshed == nil {
+			t.Fatalf("expected the feed error with the podcast, got %v %v", refreshed, err)
+		}
+		if refreshed.RefreshFailures != i || refreshed.LastRefreshError == "" {
+			t.Fatalf("expected %d recorded failures, got %d %q", i, refreshed.RefreshFailures, refreshed.LastRefreshError)
+		}
+	}
+	if got := refreshed.NextRefreshAt.Sub(*refreshed.LastRefreshAt); got != 2*time.Hour {
+		t.Fatalf("expected failures to back off, got %s", got)
+	}
+
+	if err := SetPodcastRefreshInterval(podcast.ID, maxRefreshIntervalMinutes+1); !errors.Is(err, ErrInvalidRefreshInterval) {
+		t.Fatalf("expected an invalid interval error, got %v", err)
+	}
+	if err := SetPodcastRefreshInterval(podcast.ID, 600); err != nil {
+		t.Fatalf("set refresh interval failed: %v", err)
+	}
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err != nil {
+		t.Fatalf("reload podcast failed: %v", err)
+	}
+	if got := stored.NextRefreshAt.Sub(*stored.LastRefreshAt); stored.RefreshIntervalMinutes != 600 || got != 10*time.Hour {
+		t.Fatalf("expected the fixed interval to move the next refresh, got %d %s", stored.RefreshIntervalMinutes, got)
+	}
+
+	failing.Store(false)
+	refreshingPodcasts.Store(podcast.ID, true)
+	if _, err := RefreshPodcast(podcast.ID); !errors.Is(err, ErrPodcastRefreshing) {
+		t.Fatalf("expected a refresh in progress to be reported, got %v", err)
+	}
+	refreshingPodcasts.Delete(podcast.ID)
+	if refreshed, err = RefreshPodcast(podcast.ID); err != nil || refreshed.RefreshFailures != 0 || refreshed.LastRefreshError != "" {
+		t.Fatalf("expected a successful refresh to reset failures, got %+v %v", refreshed, err)
+	}
+}
@@ -12,13 +12,187 @@ var retentionNow = func() time.Time {
 	return time.Now().UTC()
 }
 
-// ApplyRetentionPolicies enforces global retention settings with this precedence:
-//  1. per-podcast keep-all override
+const retentionBytesPerGB = 1 << 30
+
+// RetentionPolicy is the set of retention rules applied to a single podcast.
+// MaxDiskGB is the podcast's own storage budget; the global budget in
+// db.Setting applies to the whole library and is resolved separately.
+type RetentionPolicy struct {
+	KeepAll          bool    `json:"keepAll"`
+	KeepLatest       int     `json:"keepLatest"`
+	DeleteAfterDays  int     `json:"deleteAfterDays"`
+	DeleteOnlyPlayed bool    `json:"deleteOnlyPlayed"`
+	MaxDiskGB        float64 `json:"maxDiskGB"`
+}
+
+func (policy RetentionPolicy) hasRules() bool {
+	return policy.hasEpisodeRules() || policy.MaxDiskGB > 0
+}
+
+func (policy RetentionPolicy) hasEpisodeRules() bool {
+	return policy.KeepLatest > 0 || policy.DeleteAfterDays > 0
+}
+
+// PodcastRetentionOverrides holds the per-podcast retention values. Nil fields
+// inherit the global setting.
+type PodcastRetentionOverrides struct {
+	KeepAll          bool     `json:"keepAll"`
+	KeepLatest       *int     `json:"keepLatest"`
+	DeleteAfterDays  *int     `json:"deleteAfterDays"`
+	DeleteOnlyPlayed *bool    `json:"deleteOnlyPlayed"`
+	MaxDiskGB        *float64 `json:"maxDiskGB"`
+}
+
+type PodcastRetention struct {
+	PodcastID string                    `json:"podcastId"`
+	Overrides PodcastRetentionOverrides `json:"overrides"`
+	Effective RetentionPolicy           `json:"effective"`
+}
+
+// EffectiveRetentionPolicy resolves the retention rules for a podcast. Override
+// values replace the matching global values; a podcast with its own keep-latest,
+// delete-after-days or disk budget rule is not covered by the global keep-all
+// switch.
+func EffectiveRetentionPolicy(setting *db.Setting, podcast db.Podcast) RetentionPolicy {
+	policy := RetentionPolicy{
+		KeepAll:          setting.RetentionKeepAll,
+		KeepLatest:       setting.RetentionKeepLatest,
+		DeleteAfterDays:  setting.RetentionDeleteAfterDays,
+		DeleteOnlyPlayed: setting.RetentionDeleteOnlyPlayed,
+	}
+	if podcast.RetentionKeepLatest != nil {
+		policy.KeepLatest = *podcast.RetentionKeepLatest
+		policy.KeepAll = false
+	}
+	if podcast.RetentionDeleteAfterDays != nil {
+		policy.DeleteAfterDays = *podcast.RetentionDeleteAfterDays
+		policy.KeepAll = false
+	}
+	if podcast.RetentionDeleteOnlyPlayed != nil {
+		policy.DeleteOnlyPlayed = *podcast.RetentionDeleteOnlyPlayed
+	}
+	if podcast.RetentionMaxDiskGB != nil && *podcast.RetentionMaxDiskGB > 0 {
+		policy.MaxDiskGB = *podcast.RetentionMaxDiskGB
+		policy.KeepAll = false
+	}
+	if podcast.RetentionKeepAll {
+		policy.KeepAll = true
+	}
+	return policy
+}
+
+func podcastRetentionFromPodcast(setting *db.Setting, podcast db.Podcast) PodcastRetention {
+	return PodcastRetention{
+		PodcastID: podcast.ID,
+		Overrides: PodcastRetentionOverrides{
+			KeepAll:          podcast.RetentionKeepAll,
+			KeepLatest:       podcast.RetentionKeepLatest,
+			DeleteAfterDays:  podcast.RetentionDeleteAfterDays,
+			DeleteOnlyPlayed: podcast.RetentionDeleteOnlyPlayed,
+			MaxDiskGB:        podcast.RetentionMaxDiskGB,
+		},
+		Effective: EffectiveRetentionPolicy(setting, podcast),
+	}
+}
+
+func GetPodcastRetention(podcastId string) (PodcastRetention, error) {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(podcastId, &podcast); err != nil {
+		return PodcastRetention{}, err
+	}
+	return podcastRetentionFromPodcast(db.GetOrCreateSetting(), podcast), nil
+}
+
+// SetPodcastRetention replaces the retention overrides of a podcast and returns
+// the resulting effective policy.
+func SetPodcastRetention(podcastId string, overrides PodcastRetentionOverrides) (PodcastRetention, error) {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(podcastId, &podcast); err != nil {
+		return PodcastRetention{}, err
+	}
+	if err := db.UpdatePodcastRetention(podcastId, overrides.KeepAll, overrides.KeepLatest, overrides.DeleteAfterDays, overrides.DeleteOnlyPlayed, overrides.MaxDiskGB); err != nil {
+		return PodcastRetention{}, err
+	}
+	podcast.RetentionKeepAll = overrides.KeepAll
+	podcast.RetentionKeepLatest = overrides.KeepLatest
+	podcast.RetentionDeleteAfterDays = overrides.DeleteAfterDays
+	podcast.RetentionDeleteOnlyPlayed = overrides.DeleteOnlyPlayed
+	podcast.RetentionMaxDiskGB = overrides.MaxDiskGB
+	return podcastRetentionFromPodcast(db.GetOrCreateSetting(), podcast), nil
+}
+
+const (
+	RetentionActionDelete = "delete"
+	RetentionActionKeep   = "keep"
+)
+
+// Reasons reported for each retention decision.
+const (
+	RetentionReasonKeepAll          = "keep_all"
+	RetentionReasonNoRules          = "no_rules_configured"
+	RetentionReasonWithinKeepLatest = "within_keep_latest"
+	RetentionReasonBeyondKeepLatest = "beyond_keep_latest"
+	RetentionReasonOlderThanDays    = "older_than_delete_after_days"
+	RetentionReasonWithinDays       = "within_delete_after_days"
+	RetentionReasonUnplayed         = "unplayed"
+	RetentionReasonNoReferenceDate  = "no_reference_date"
+	RetentionReasonOverPodcastQuota = "over_podcast_disk_quota"
+	RetentionReasonOverGlobalQuota  = "over_global_disk_quota"
+)
+
+type RetentionDecision struct {
+	PodcastItemID string    `json:"podcastItemId"`
+	Title         string    `json:"title"`
+	PubDate       time.Time `json:"pubDate"`
+	IsPlayed      bool      `json:"isPlayed"`
+	Action        string    `json:"action"`
+	Reason        string    `json:"reason"`
+	Bytes         int64     `json:"bytes"`
+
+	reference time.Time
+}
+
+type PodcastRetentionPlan struct {
+	PodcastID      string              `json:"podcastId"`
+	PodcastTitle   string              `json:"podcastTitle"`
+	Policy         RetentionPolicy     `json:"policy"`
+	Delete         []RetentionDecision `json:"delete"`
+	Keep           []RetentionDecision `json:"keep"`
+	BytesReclaimed int64               `json:"bytesReclaimed"`
+	DiskUsage      int64               `json:"diskUsageBytes"`
+}
+
+// RetentionPlan lists what the retention job would do with every downloaded
+// episode. It backs both the dry-run preview and the enforcement job.
+type RetentionPlan struct {
+	GeneratedAt    time.Time              `json:"generatedAt"`
+	Podcasts       []PodcastRetentionPlan `json:"podcasts"`
+	DeleteCount    int                    `json:"deleteCount"`
+	KeepCount      int                    `json:"keepCount"`
+	BytesReclaimed int64                  `json:"bytesReclaimed"`
+	DiskUsage      int64                  `json:"diskUsageBytes"`
+	DiskQuota      int64                  `json:"diskQuotaBytes"`
+}
+
+// PreviewRetention returns the retention plan without deleting anything.
+func PreviewRetention() (RetentionPlan, error) {
+	return buildRetentionPlan(db.GetOrCreateSetting(), retentionNow())
+}
+
+// ApplyRetentionPolicies enforces retention rules with this precedence:
+//  1. keep-all (per podcast, or global when the podcast has no own rules)
 //  2. keep-latest rule (if configured)
 //  3. age-based deletion rule (if configured)
+//  4. per-podcast disk budget, then the global disk budget (if configured)
 //
-// This ordering keeps behavior deterministic across runs and avoids conflicting
-// deletes when multiple retention knobs are enabled.
+// Disk budgets delete played episodes before unplayed ones and the oldest
+// first, until usage falls under the cap. Both budgets measure episodes with
+// retentionItemBytes, so the preview and the run agree. Only a podcast's own
+// keep-all switch exempts it from the global budget.
+//
+// Each rule is resolved per podcast from its overrides, falling back to the
+// global settings. This ordering keeps behavior deterministic across runs and
+// avoids conflicting deletes when multiple retention knobs are enabled.
 func ApplyRetentionPolicies() error {
 	const jobName = "RetentionCleanup"
 	jobLogger, _ := logging.NewJobSugar(jobName)
@@ -36,30 +210,52 @@ func ApplyRetentionPolicies() error {
 	db.Lock(jobName, 120)
 	defer db.Unlock(jobName)
 
-	setting := db.GetOrCreateSetting()
-	if setting.RetentionKeepAll {
-		jobLogger.Infow("retention_skipped", "reason", "keep_all_enabled")
-		return nil
+	plan, err := buildRetentionPlan(db.GetOrCreateSetting(), retentionNow())
+	if err != nil {
+		jobLogger.Errorw("failed_to_build_retention_plan", "error", err)
+		return err
 	}
-	if setting.RetentionKeepLatest <= 0 && setting.RetentionDeleteAfterDays <= 0 {
-		jobLogger.Infow("retention_skipped", "reason", "no_rules_configured")
+	if len(plan.Podcasts) == 0 {
+		jobLogger.Infow("no_downloaded_items")
 		return nil
 	}
 
+	keptCount := 0
+	deletedCount := 0
+	skippedCount := 0
+	for _, podcastPlan := range plan.Podcasts {
+		for _, decision := range podcastPlan.Keep {
+			if decision.Reason == RetentionReasonKeepAll || decision.Reason == RetentionReasonNoRules {
+				skippedCount++
+			} else {
+				keptCount++
+			}
+		}
+		for _, decision := range podcastPlan.Delete {
+			if err := DeleteEpisodeFile(decision.PodcastItemID); err != nil {
+				jobLogger.Warnw("retention_delete_failed", "podcast_item_id", decision.PodcastItemID, "error", err)
+				skippedCount++
+				continue
+			}
+			deletedCount++
+		}
+	}
+
+	jobLogger.Infow("retention_completed", "deleted", deletedCount, "kept", keptCount, "skipped", skippedCount)
+	return nil
+}
+
+func buildRetentionPlan(setting *db.Setting, now time.Time) (RetentionPlan, error) {
+	plan := RetentionPlan{GeneratedAt: now, Podcasts: []PodcastRetentionPlan{}}
+
 	var podcasts []db.Podcast
 	if err := db.FindAllPodcastsPlain(&podcasts); err != nil {
-		jobLogger.Errorw("failed_to_fetch_podcasts", "error", err)
-		return err
+		return plan, err
 	}
 
 	var items []db.PodcastItem
 	if err := db.FindDownloadedPodcastItems(&items); err != nil {
-		jobLogger.Errorw("failed_to_fetch_downloaded_items", "error", err)
-		return err
-	}
-	if len(items) == 0 {
-		jobLogger.Infow("no_downloaded_items")
-		return nil
+		return plan, err
 	}
 
 	itemsByPodcast := make(map[string][]db.PodcastItem, len(podcasts))
@@ -67,75 +263,181 @@ func ApplyRetentionPolicies() error {
 		itemsByPodcast[item.PodcastID] = append(itemsByPodcast[item.PodcastID], item)
 	}
 
-	now := retentionNow()
-	keptCount := 0
-	deletedCount := 0
-	skippedCount := 0
+	sort.SliceStable(podcasts, func(i, j int) bool {
+		return podcasts[i].Title < podcasts[j].Title
+	})
 
+	type podcastDecisions struct {
+		podcast   db.Podcast
+		policy    RetentionPolicy
+		decisions []RetentionDecision
+	}
+	planned := make([]podcastDecisions, 0, len(podcasts))
 	for _, podcast := range podcasts {
-		if podcast.RetentionKeepAll {
-			skippedCount += len(itemsByPodcast[podcast.ID])
-			continue
-		}
-
 		podcastItems := itemsByPodcast[podcast.ID]
 		if len(podcastItems) == 0 {
 			continue
 		}
-
-		sort.Slice(podcastItems, func(i, j int) bool {
-			return retentionReferenceTime(podcastItems[i]).After(retentionReferenceTime(podcastItems[j]))
+		policy := EffectiveRetentionPolicy(setting, podcast)
+		planned = append(planned, podcastDecisions{
+			podcast:   podcast,
+			policy:    policy,
+			decisions: planPodcastRetention(policy, podcastItems, now),
 		})
+	}
 
-		protected := make(map[string]struct{})
-		if setting.RetentionKeepLatest > 0 {
-			limit := setting.RetentionKeepLatest
-			if limit > len(podcastItems) {
-				limit = len(podcastItems)
-			}
-			for i := 0; i < limit; i++ {
-				protected[podcastItems[i].ID] = struct{}{}
-				keptCount++
-			}
+	if setting.RetentionMaxDiskGB > 0 {
+		for _, item := range items {
+			plan.DiskUsage += retentionItemBytes(item)
 		}
+		plan.DiskQuota = int64(setting.RetentionMaxDiskGB * retentionBytesPerGB)
 
-		for i := 0; i < len(podcastItems); i++ {
-			item := podcastItems[i]
-			if _, ok := protected[item.ID]; ok {
-				continue
-			}
-
-			shouldDelete := false
-			if setting.RetentionKeepLatest > 0 {
-				shouldDelete = true
-			} else if setting.RetentionDeleteAfterDays > 0 {
-				ref := retentionReferenceTime(item)
-				if !ref.IsZero() {
-					cutoff := now.Add(-time.Duration(setting.RetentionDeleteAfterDays) * 24 * time.Hour)
-					if ref.Before(cutoff) {
-						if !setting.RetentionDeleteOnlyPlayed || item.IsPlayed {
-							shouldDelete = true
-						}
-					}
+		usage := plan.DiskUsage
+		var candidates []*RetentionDecision
+		for i := range planned {
+			for j := range planned[i].decisions {
+				decision := &planned[i].decisions[j]
+				if decision.Action == RetentionActionDelete {
+					usage -= decision.Bytes
+				} else if !planned[i].podcast.RetentionKeepAll {
+					candidates = append(candidates, decision)
 				}
 			}
+		}
+		trimToDiskQuota(candidates, usage, plan.DiskQuota, RetentionReasonOverGlobalQuota)
+	}
 
-			if !shouldDelete {
-				keptCount++
-				continue
+	for _, entry := range planned {
+		podcastPlan := PodcastRetentionPlan{
+			PodcastID:    entry.podcast.ID,
+			PodcastTitle: entry.podcast.Title,
+			Policy:       entry.policy,
+			Delete:       []RetentionDecision{},
+			Keep:         []RetentionDecision{},
+		}
+		for _, decision := range entry.decisions {
+			podcastPlan.DiskUsage += decision.Bytes
+			if decision.Action == RetentionActionDelete {
+				podcastPlan.Delete = append(podcastPlan.Delete, decision)
+				podcastPlan.BytesReclaimed += decision.Bytes
+			} else {
+				podcastPlan.Keep = append(podcastPlan.Keep, decision)
 			}
+		}
+		plan.Podcasts = append(plan.Podcasts, podcastPlan)
+		plan.DeleteCount += len(podcastPlan.Delete)
+		plan.KeepCount += len(podcastPlan.Keep)
+		plan.BytesReclaimed += podcastPlan.BytesReclaimed
+	}
+	return plan, nil
+}
 
-			if err := DeleteEpisodeFile(item.ID); err != nil {
-				jobLogger.Warnw("retention_delete_failed", "podcast_item_id", item.ID, "error", err)
-				skippedCount++
-				continue
+// planPodcastRetention decides the fate of every downloaded episode of a
+// podcast under its episode rules and its own disk budget.
+func planPodcastRetention(policy RetentionPolicy, podcastItems []db.PodcastItem, now time.Time) []RetentionDecision {
+	sort.Slice(podcastItems, func(i, j int) bool {
+		return retentionReferenceTime(podcastItems[i]).After(retentionReferenceTime(podcastItems[j]))
+	})
+
+	decisions := make([]RetentionDecision, 0, len(podcastItems))
+	var usage int64
+	for i, item := range podcastItems {
+		action, reason := retentionDecisionFor(policy, item, i, now)
+		decision := RetentionDecision{
+			PodcastItemID: item.ID,
+			Title:         item.Title,
+			PubDate:       item.PubDate,
+			IsPlayed:      item.IsPlayed,
+			Action:        action,
+			Reason:        reason,
+			Bytes:         retentionItemBytes(item),
+			reference:     retentionReferenceTime(item),
+		}
+		if action == RetentionActionKeep {
+			usage += decision.Bytes
+		}
+		decisions = append(decisions, decision)
+	}
+
+	if !policy.KeepAll && policy.MaxDiskGB > 0 {
+		candidates := make([]*RetentionDecision, 0, len(decisions))
+		for i := range decisions {
+			if decisions[i].Action == RetentionActionKeep {
+				candidates = append(candidates, &decisions[i])
 			}
-			deletedCount++
 		}
+		trimToDiskQuota(candidates, usage, int64(policy.MaxDiskGB*retentionBytesPerGB), RetentionReasonOverPodcastQuota)
 	}
+	return decisions
+}
 
-	jobLogger.Infow("retention_completed", "deleted", deletedCount, "kept", keptCount, "skipped", skippedCount)
-	return nil
+// trimToDiskQuota marks kept episodes for deletion, played before unplayed and
+// oldest first, until usage is within quota.
+func trimToDiskQuota(candidates []*RetentionDecision, usage int64, quota int64, reason string) {
+	if usage <= quota {
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].IsPlayed != candidates[j].IsPlayed {
+			return candidates[i].IsPlayed
+		}
+		return candidates[i].reference.Before(candidates[j].reference)
+	})
+	for _, decision := range candidates {
+		if usage <= quota {
+			return
+		}
+		if decision.Bytes <= 0 {
+			continue
+		}
+		decision.Action = RetentionActionDelete
+		decision.Reason = reason
+		usage -= decision.Bytes
+	}
+}
+
+// retentionDecisionFor decides the fate of the item at the given position in
+// the newest-first list of a podcast's downloaded episodes.
+func retentionDecisionFor(policy RetentionPolicy, item db.PodcastItem, position int, now time.Time) (string, string) {
+	if policy.KeepAll {
+		return RetentionActionKeep, RetentionReasonKeepAll
+	}
+	if !policy.hasEpisodeRules() {
+		return RetentionActionKeep, RetentionReasonNoRules
+	}
+	if policy.KeepLatest > 0 {
+		if position < policy.KeepLatest {
+			return RetentionActionKeep, RetentionReasonWithinKeepLatest
+		}
+		return RetentionActionDelete, RetentionReasonBeyondKeepLatest
+	}
+
+	ref := retentionReferenceTime(item)
+	if ref.IsZero() {
+		return RetentionActionKeep, RetentionReasonNoReferenceDate
+	}
+	cutoff := now.Add(-time.Duration(policy.DeleteAfterDays) * 24 * time.Hour)
+	if !ref.Before(cutoff) {
+		return RetentionActionKeep, RetentionReasonWithinDays
+	}
+	if policy.DeleteOnlyPlayed && !item.IsPlayed {
+		return RetentionActionKeep, RetentionReasonUnplayed
+	}
+	return RetentionActionDelete, RetentionReasonOlderThanDays
+}
+
+// retentionItemBytes uses the recorded file size and falls back to the size
+// of the file on disk.
+func retentionItemBytes(item db.PodcastItem) int64 {
+	if item.FileSize > 0 {
+		return item.FileSize
+	}
+	if item.DownloadPath != "" {
+		if size, err := GetFileSize(item.DownloadPath); err == nil {
+			return size
+		}
+	}
+	return 0
 }
 
 func retentionReferenceTime(item db.PodcastItem) time.Time {
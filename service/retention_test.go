@@ -297,3 +297,257 @@ func TestRetentionDeleteAfterDaysUsesInjectedClock(t *testing.T) {
 		t.Fatalf("expected older item to be deleted")
 	}
 }
+
+func TestEffectiveRetentionPolicyInheritsGlobalSettings(t *testing.T) {
+	setting := &db.Setting{
+		RetentionKeepAll:          true,
+		RetentionKeepLatest:       5,
+		RetentionDeleteAfterDays:  30,
+		RetentionDeleteOnlyPlayed: true,
+	}
+
+	inherited := EffectiveRetentionPolicy(setting, db.Podcast{})
+	if !inherited.KeepAll || inherited.KeepLatest != 5 || inherited.DeleteAfterDays != 30 || !inherited.DeleteOnlyPlayed {
+		t.Fatalf("expected podcast without overrides to inherit global policy, got %+v", inherited)
+	}
+
+	keepLatest, onlyPlayed := 2, false
+	overridden := EffectiveRetentionPolicy(setting, db.Podcast{RetentionKeepLatest: &keepLatest, RetentionDeleteOnlyPlayed: &onlyPlayed})
+	if overridden.KeepAll || overridden.KeepLatest != 2 || overridden.DeleteAfterDays != 30 || overridden.DeleteOnlyPlayed {
+		t.Fatalf("expected overrides to replace global values, got %+v", overridden)
+	}
+
+	keepAll := EffectiveRetentionPolicy(setting, db.Podcast{RetentionKeepAll: true, RetentionKeepLatest: &keepLatest})
+	if !keepAll.KeepAll {
+		t.Fatalf("expected per-podcast keep-all to win, got %+v", keepAll)
+	}
+}
+
+func TestRetentionPerPodcastRuleOverridesGlobal(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	overridePodcast := createPodcast(t, "override-rule", false)
+	inheritPodcast := createPodcast(t, "inherit-rule", false)
+	now := time.Now()
+	overrideNew := createDownloadedItem(t, overridePodcast, "override-new", now, false, dataDir)
+	overrideOld := createDownloadedItem(t, overridePodcast, "override-old", now.Add(-48*time.Hour), false, dataDir)
+	inheritOld := createDownloadedItem(t, inheritPodcast, "inherit-old", now.Add(-48*time.Hour), false, dataDir)
+
+	keepLatest := 1
+	if _, err := SetPodcastRetention(overridePodcast.ID, PodcastRetentionOverrides{KeepLatest: &keepLatest}); err != nil {
+		t.Fatalf("SetPodcastRetention failed: %v", err)
+	}
+
+	setting := db.GetOrCreateSetting()
+	setting.RetentionKeepAll = true
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	if err := ApplyRetentionPolicies(); err != nil {
+		t.Fatalf("apply retention failed: %v", err)
+	}
+
+	assertStatus := func(item db.PodcastItem, expected db.DownloadStatus) {
+		t.Helper()
+		var refreshed db.PodcastItem
+		if err := db.GetPodcastItemById(item.ID, &refreshed); err != nil {
+			t.Fatalf("reload item failed: %v", err)
+		}
+		if refreshed.DownloadStatus != expected {
+			t.Fatalf("expected %s to be %v, got %v", item.Title, expected, refreshed.DownloadStatus)
+		}
+	}
+
+	assertStatus(overrideNew, db.Downloaded)
+	assertStatus(overrideOld, db.Deleted)
+	assertStatus(inheritOld, db.Downloaded)
+
+	retention, err := SetPodcastRetention(overridePodcast.ID, PodcastRetentionOverrides{})
+	if err != nil {
+		t.Fatalf("SetPodcastRetention failed: %v", err)
+	}
+	if retention.Overrides.KeepLatest != nil || !retention.Effective.KeepAll {
+		t.Fatalf("expected cleared override to inherit global keep-all, got %+v", retention)
+	}
+}
+
+func TestPreviewRetentionMatchesEnforcement(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	podcast := createPodcast(t, "preview", false)
+	keepAllPodcast := createPodcast(t, "preview-keep", true)
+	now := time.Now()
+	newest := createDownloadedItem(t, podcast, "preview-new", now, false, dataDir)
+	oldest := createDownloadedItem(t, podcast, "preview-old", now.Add(-48*time.Hour), false, dataDir)
+	createDownloadedItem(t, keepAllPodcast, "preview-keep-old", now.Add(-48*time.Hour), false, dataDir)
+
+	setting := db.GetOrCreateSetting()
+	setting.RetentionKeepAll = false
+	setting.RetentionKeepLatest = 1
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	plan, err := PreviewRetention()
+	if err != nil {
+		t.Fatalf("PreviewRetention failed: %v", err)
+	}
+	if plan.DeleteCount != 1 || plan.KeepCount != 2 {
+		t.Fatalf("expected one deletion and two kept episodes, got %+v", plan)
+	}
+	if plan.BytesReclaimed != int64(len("audio")) {
+		t.Fatalf("expected reclaimed bytes to match file size, got %d", plan.BytesReclaimed)
+	}
+	reasons := map[string]string{}
+	for _, podcastPlan := range plan.Podcasts {
+		for _, decision := range append(podcastPlan.Keep, podcastPlan.Delete...) {
+			reasons[decision.Title] = decision.Reason
+		}
+	}
+	if reasons["preview-new"] != RetentionReasonWithinKeepLatest ||
+		reasons["preview-old"] != RetentionReasonBeyondKeepLatest ||
+		reasons["preview-keep-old"] != RetentionReasonKeepAll {
+		t.Fatalf("unexpected retention reasons: %+v", reasons)
+	}
+
+	if _, err := os.Stat(oldest.DownloadPath); err != nil {
+		t.Fatalf("expected preview to leave files untouched, got %v", err)
+	}
+
+	if err := ApplyRetentionPolicies(); err != nil {
+		t.Fatalf("apply retention failed: %v", err)
+	}
+	var refreshed db.PodcastItem
+	if err := db.GetPodcastItemById(oldest.ID, &refreshed); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if refreshed.DownloadStatus != db.Deleted {
+		t.Fatalf("expected previewed episode to be deleted by enforcement")
+	}
+	var refreshedNewest db.PodcastItem
+	if err := db.GetPodcastItemById(newest.ID, &refreshedNewest); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if refreshedNewest.DownloadStatus != db.Downloaded {
+		t.Fatalf("expected kept episode to remain downloaded")
+	}
+}
+
+func setRetentionTestFileSize(t *testing.T, item db.PodcastItem, megabytes int64) {
+	t.Helper()
+	if err := db.UpdatePodcastItemFileSize(item.ID, megabytes<<20); err != nil {
+		t.Fatalf("update file size failed: %v", err)
+	}
+}
+
+func TestRetentionGlobalDiskQuotaDeletesPlayedThenOldest(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	podcast := createPodcast(t, "quota", false)
+	keepAllPodcast := createPodcast(t, "quota-keep", true)
+	now := time.Now()
+	played := createDownloadedItem(t, podcast, "quota-played", now.Add(-72*time.Hour), true, dataDir)
+	oldestUnplayed := createDownloadedItem(t, podcast, "quota-oldest", now.Add(-96*time.Hour), false, dataDir)
+	newest := createDownloadedItem(t, podcast, "quota-newest", now, false, dataDir)
+	protected := createDownloadedItem(t, keepAllPodcast, "quota-protected", now.Add(-120*time.Hour), true, dataDir)
+	for _, item := range []db.PodcastItem{played, oldestUnplayed, newest, protected} {
+		setRetentionTestFileSize(t, item, 400)
+	}
+
+	setting := db.GetOrCreateSetting()
+	setting.RetentionKeepAll = true
+	setting.RetentionMaxDiskGB = 1
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	if err := ApplyRetentionPolicies(); err != nil {
+		t.Fatalf("apply retention failed: %v", err)
+	}
+
+	assertStatus := func(item db.PodcastItem, expected db.DownloadStatus) {
+		t.Helper()
+		var refreshed db.PodcastItem
+		if err := db.GetPodcastItemById(item.ID, &refreshed); err != nil {
+			t.Fatalf("reload item failed: %v", err)
+		}
+		if refreshed.DownloadStatus != expected {
+			t.Fatalf("expected %s to be %v, got %v", item.Title, expected, refreshed.DownloadStatus)
+		}
+	}
+
+	assertStatus(played, db.Deleted)
+	assertStatus(oldestUnplayed, db.Deleted)
+	assertStatus(newest, db.Downloaded)
+	assertStatus(protected, db.Downloaded)
+}
+
+func TestRetentionPodcastDiskQuota(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	podcast := createPodcast(t, "podcast-quota", false)
+	now := time.Now()
+	items := []db.PodcastItem{
+		createDownloadedItem(t, podcast, "podcast-quota-new", now, false, dataDir),
+		createDownloadedItem(t, podcast, "podcast-quota-mid", now.Add(-24*time.Hour), false, dataDir),
+		createDownloadedItem(t, podcast, "podcast-quota-old", now.Add(-48*time.Hour), false, dataDir),
+	}
+	for _, item := range items {
+		setRetentionTestFileSize(t, item, 300)
+	}
+
+	maxDiskGB := 0.5
+	if _, err := SetPodcastRetention(podcast.ID, PodcastRetentionOverrides{MaxDiskGB: &maxDiskGB}); err != nil {
+		t.Fatalf("SetPodcastRetention failed: %v", err)
+	}
+
+	plan, err := PreviewRetention()
+	if err != nil {
+		t.Fatalf("PreviewRetention failed: %v", err)
+	}
+	if len(plan.Podcasts) != 1 || len(plan.Podcasts[0].Delete) != 2 {
+		t.Fatalf("expected two deletions to fit the podcast budget, got %+v", plan.Podcasts)
+	}
+	for _, decision := range plan.Podcasts[0].Delete {
+		if decision.Reason != RetentionReasonOverPodcastQuota || decision.PodcastItemID == items[0].ID {
+			t.Fatalf("expected the oldest episodes to exceed the podcast budget, got %+v", decision)
+		}
+	}
+	if plan.BytesReclaimed != 600<<20 {
+		t.Fatalf("expected 600MB reclaimed, got %d", plan.BytesReclaimed)
+	}
+}
+
+func TestRetentionDiskBudgetsShareUsage(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	dataDir := filepath.Join(tempDir, "assets")
+
+	podcast := createPodcast(t, "shared-usage", false)
+	now := time.Now()
+	recorded := createDownloadedItem(t, podcast, "recorded", now, false, dataDir)
+	setRetentionTestFileSize(t, recorded, 400)
+	// The second file has no recorded size, so its size on disk is used.
+	createDownloadedItem(t, podcast, "unrecorded", now.Add(-time.Hour), false, dataDir)
+
+	setting := db.GetOrCreateSetting()
+	setting.RetentionMaxDiskGB = 1
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	plan, err := PreviewRetention()
+	if err != nil {
+		t.Fatalf("PreviewRetention failed: %v", err)
+	}
+	if expected := int64(400<<20 + len("audio")); plan.DiskUsage != expected {
+		t.Fatalf("expected global usage of %d, got %d", expected, plan.DiskUsage)
+	}
+	if len(plan.Podcasts) != 1 || plan.Podcasts[0].DiskUsage != plan.DiskUsage {
+		t.Fatalf("expected the podcast usage to match the global usage, got %+v", plan.Podcasts)
+	}
+}
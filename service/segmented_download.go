@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+var ErrRangeNotSupported = errors.New("server ignored the range request")
+
+const segmentPartsSuffix = ".parts"
+
+type downloadSegment struct {
+	index int
+	start int64
+	end   int64 // inclusive
+	path  string
+}
+
+// segmentedDownloadPlan decides whether a download should be split. Segments
+// are capped by the per-host concurrency of the request limiter so a segmented
+// download never holds more connections than the host budget allows. It
+// returns 0 when the file should be fetched over one connection.
+func segmentedDownloadPlan(setting *db.Setting, totalBytes int64) int {
+	segments := setting.DownloadSegments
+	if maxConcurrency := getOutboundRequestLimiter().maxConcurrency; segments > maxConcurrency {
+		segments = maxConcurrency
+	}
+	if segments < 2 || totalBytes <= 0 {
+		return 0
+	}
+	if totalBytes < int64(setting.SegmentedDownloadMinMB)*1024*1024 {
+		return 0
+	}
+	return segments
+}
+
+// probeRangeSupport asks the host for the size of a file and whether it
+// serves byte ranges.
+func probeRangeSupport(client *http.Client, link string) (int64, bool) {
+	req, err := getRequestWithMethod(http.MethodHead, link)
+	if err != nil {
+		return 0, false
+	}
+	resp, err := doRequestWithHostLimit(client, req)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" {
+		return 0, false
+	}
+	return resp.ContentLength, resp.ContentLength > 0
+}
+
+// segmentedDownloadFor returns the number of segments to fetch a file with
+// and its size, or 0 to use a single connection. Downloads with parts left
+// from a pause continue with the same split.
+func segmentedDownloadFor(client *http.Client, link string, finalPath string, resumeOffset int64) (int, int64) {
+	setting := db.GetOrCreateSetting()
+	partsDir := finalPath + segmentPartsSuffix
+	existing, _ := filepath.Glob(filepath.Join(partsDir, "part-*"))
+	if len(existing) == 0 && (resumeOffset > 0 || setting.DownloadSegments < 2) {
+		return 0, 0
+	}
+	totalBytes, ranges := probeRangeSupport(client, link)
+	if !ranges {
+		_ = os.RemoveAll(partsDir)
+		return 0, 0
+	}
+	if len(existing) > 0 {
+		return len(existing), totalBytes
+	}
+	return segmentedDownloadPlan(setting, totalBytes), totalBytes
+}
+
+func splitDownloadSegments(partsDir string, totalBytes int64, count int) []downloadSegment {
+	size := totalBytes / int64(count)
+	segments := make([]downloadSegment, 0, count)
+	for i := 0; i < count; i++ {
+		start := int64(i) * size
+		end := start + size - 1
+		if i == count-1 {
+			end = totalBytes - 1
+		}
+		segments = append(segments, downloadSegment{
+			index: i,
+			start: start,
+			end:   end,
+			path:  filepath.Join(partsDir, fmt.Sprintf("part-%03d", i)),
+		})
+	}
+	return segments
+}
+
+// downloadSegmented fetches a file as concurrent byte ranges into a parts
+// folder next to finalPath, then joins them into a temporary file and renames
+// it into place. Pausing keeps the parts so every segment resumes where it
+// stopped; cancelling removes them.
+func downloadSegmented(client *http.Client, downloadID string, link string, finalPath string, totalBytes int64, count int) error {
+	partsDir := finalPath + segmentPartsSuffix
+	if err := os.MkdirAll(partsDir, 0o755); err != nil {
+		return err
+	}
+	segments := splitDownloadSegments(partsDir, totalBytes, count)
+
+	// Every part file is created up front so a paused download can be
+	// resumed with the same split.
+	var downloaded atomic.Int64
+	for _, segment := range segments {
+		part, err := os.OpenFile(segment.path, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		if info, err := part.Stat(); err == nil {
+			downloaded.Add(info.Size())
+		}
+		part.Close()
+	}
+	report := newProgressReporter(downloadID, totalBytes)
+	report(downloaded.Load(), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		firstErr error
+	)
+	for _, segment := range segments {
+		wg.Add(1)
+		go func(segment downloadSegment) {
+			defer wg.Done()
+			err := fetchSegment(ctx, client, downloadID, link, segment, func(n int) {
+				report(downloaded.Add(int64(n)), false)
+			})
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				cancel()
+			}
+		}(segment)
+	}
+	wg.Wait()
+	report(downloaded.Load(), true)
+
+	switch {
+	case errors.Is(firstErr, ErrDownloadPaused):
+		ClearDownloadPause(downloadID)
+		return ErrDownloadPaused
+	case errors.Is(firstErr, ErrDownloadCancelled):
+		_ = os.RemoveAll(partsDir)
+		ClearDownloadCancellation(downloadID)
+		return ErrDownloadCancelled
+	case errors.Is(firstErr, ErrRangeNotSupported):
+		_ = os.RemoveAll(partsDir)
+		return firstErr
+	case firstErr != nil:
+		return firstErr
+	}
+
+	if err := joinDownloadSegments(segments, finalPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(partsDir)
+}
+
+// fetchSegment downloads the missing tail of one segment, appending to its
+// part file.
+func fetchSegment(ctx context.Context, client *http.Client, downloadID string, link string, segment downloadSegment, progress func(int)) error {
+	var offset int64
+	if info, err := os.Stat(segment.path); err == nil {
+		offset = info.Size()
+	}
+	if segment.start+offset > segment.end {
+		return nil
+	}
+
+	req, err := getRequest(link)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", segment.start+offset, segment.end))
+	// The host slot stays taken until the body is closed, so streaming
+	// segments count against the per-host budget.
+	resp, err := doStreamingRequestWithHostLimit(client, req)
+	if err != nil {
+		return segmentInterruption(downloadID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("download failed with status %s", resp.Status)
+		}
+		return ErrRangeNotSupported
+	}
+
+	file, err := os.OpenFile(segment.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 32*1024)
+	for {
+		if err := segmentInterruption(downloadID, ctx.Err()); err != nil {
+			return err
+		}
+		n, readErr := resp.Body.Read(buffer[:downloadBandwidth.chunkSize(len(buffer))])
+		if n > 0 {
+			downloadBandwidth.wait(n)
+			if _, err := file.Write(buffer[:n]); err != nil {
+				return err
+			}
+			progress(n)
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return segmentInterruption(downloadID, readErr)
+		}
+	}
+}
+
+// segmentInterruption reports a pause or cancel request in preference to
+// fallback, so segments stopped by a sibling's context report why.
+func segmentInterruption(downloadID string, fallback error) error {
+	if downloadID != "" && IsDownloadCancelled(downloadID) {
+		return ErrDownloadCancelled
+	}
+	if DownloadsPaused() || (downloadID != "" && IsDownloadPaused(downloadID)) {
+		return ErrDownloadPaused
+	}
+	return fallback
+}
+
+func joinDownloadSegments(segments []downloadSegment, finalPath string) error {
+	tempPath := finalPath + ".tmp"
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		part, err := os.Open(segment.path)
+		if err != nil {
+			out.Close()
+			os.Remove(tempPath)
+			return err
+		}
+		written, err := io.Copy(out, part)
+		part.Close()
+		if err == nil && written != segment.end-segment.start+1 {
+			err = fmt.Errorf("%w: segment %d has %d of %d bytes", ErrIncompleteDownload, segment.index, written, segment.end-segment.start+1)
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(tempPath)
+			return err
+		}
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, finalPath)
+}
+
+// newProgressReporter throttles download progress updates the same way the
+// single connection download does.
+func newProgressReporter(downloadID string, totalBytes int64) func(downloaded int64, force bool) {
+	const minReportBytes = int64(256 * 1024)
+	const minReportInterval = 750 * time.Millisecond
+	var (
+		mu           sync.Mutex
+		lastReport   time.Time
+		lastReported int64
+	)
+	return func(downloaded int64, force bool) {
+		if downloadID == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !force && downloaded-lastReported < minReportBytes && time.Since(lastReport) < minReportInterval {
+			return
+		}
+		_ = db.UpdatePodcastItemDownloadProgress(downloadID, downloaded, totalBytes)
+		lastReport = time.Now()
+		lastReported = downloaded
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func setupSegmentedDownloads(t *testing.T) ([]byte, *httptest.Server, *atomic.Int32) {
+	t.Helper()
+	setupRetentionTestDB(t)
+	t.Setenv("PER_HOST_RATE_LIMIT_RPS", "0")
+	t.Setenv("PER_HOST_MAX_CONCURRENCY", "4")
+	resetOutboundRequestLimiterForTests()
+	t.Cleanup(resetOutboundRequestLimiterForTests)
+
+	setting := db.GetOrCreateSetting()
+	setting.DownloadSegments = 4
+	setting.SegmentedDownloadMinMB = 0
+	if err := db.UpdateSettings(setting); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+
+	content := append([]byte("ID3"), bytes.Repeat([]byte("0123456789abcdef"), 4096)...)
+	var rangeRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			rangeRequests.Add(1)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return content, server, &rangeRequests
+}
+
+func TestSegmentedDownloadReassemblesFile(t *testing.T) {
+	content, server, rangeRequests := setupSegmentedDownloads(t)
+
+	finalPath, err := Download("", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Segmented", "Episode"), "")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	saved, err := os.ReadFile(finalPath)
+	if err != nil {
+		t.Fatalf("read downloaded file failed: %v", err)
+	}
+	if !bytes.Equal(saved, content) {
+		t.Fatalf("expected reassembled file to match, got %d of %d bytes", len(saved), len(content))
+	}
+	if got := rangeRequests.Load(); got != 4 {
+		t.Fatalf("expected 4 range requests, got %d", got)
+	}
+	if FileExists(finalPath + segmentPartsSuffix) {
+		t.Fatalf("expected parts folder to be removed")
+	}
+}
+
+func TestSegmentedDownloadResumesParts(t *testing.T) {
+	content, server, _ := setupSegmentedDownloads(t)
+	finalPath := filepath.Join(os.Getenv("DATA"), "Segmented", "Episode.mp3")
+	partsDir := finalPath + segmentPartsSuffix
+	if err := os.MkdirAll(partsDir, 0o755); err != nil {
+		t.Fatalf("create parts folder failed: %v", err)
+	}
+	// Two segments left over from a paused download, the first one half done.
+	segments := splitDownloadSegments(partsDir, int64(len(content)), 2)
+	half := segments[0].start + (segments[0].end-segments[0].start)/2
+	if err := os.WriteFile(segments[0].path, content[segments[0].start:half], 0o644); err != nil {
+		t.Fatalf("write part failed: %v", err)
+	}
+	if err := os.WriteFile(segments[1].path, nil, 0o644); err != nil {
+		t.Fatalf("write part failed: %v", err)
+	}
+
+	if _, err := Download("", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Segmented", "Episode"), ""); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	saved, err := os.ReadFile(finalPath)
+	if err != nil {
+		t.Fatalf("read downloaded file failed: %v", err)
+	}
+	if !bytes.Equal(saved, content) {
+		t.Fatalf("expected resumed file to match, got %d of %d bytes", len(saved), len(content))
+	}
+}
+
+func TestSegmentedDownloadCancelRemovesParts(t *testing.T) {
+	_, server, _ := setupSegmentedDownloads(t)
+	CancelDownload("segmented-cancel")
+	t.Cleanup(func() { ClearDownloadCancellation("segmented-cancel") })
+
+	_, err := Download("segmented-cancel", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Segmented", "Episode"), "")
+	if err != ErrDownloadCancelled {
+		t.Fatalf("expected ErrDownloadCancelled, got %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(os.Getenv("DATA"), "Segmented"))
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), segmentPartsSuffix) {
+			t.Fatalf("expected parts folder to be removed after cancel")
+		}
+	}
+}
+
+// slowBodyWriter sends the headers straight away and then trickles the body,
+// so a client that frees its host slot on headers overlaps the next request.
+type slowBodyWriter struct {
+	http.ResponseWriter
+}
+
+func (w slowBodyWriter) WriteHeader(status int) {
+	w.ResponseWriter.WriteHeader(status)
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w slowBodyWriter) Write(p []byte) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return w.ResponseWriter.Write(p)
+}
+
+func TestSegmentedDownloadsStayWithinHostConcurrency(t *testing.T) {
+	content, _, _ := setupSegmentedDownloads(t)
+	t.Setenv("PER_HOST_MAX_CONCURRENCY", "2")
+	resetOutboundRequestLimiterForTests()
+
+	var active, maxActive atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			current := active.Add(1)
+			defer active.Add(-1)
+			for {
+				recorded := maxActive.Load()
+				if current <= recorded || maxActive.CompareAndSwap(recorded, current) {
+					break
+				}
+			}
+			w = slowBodyWriter{w}
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+
+	// Two downloads from the same host each plan two segments.
+	var wg sync.WaitGroup
+	for _, name := range []string{"First", "Second"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := Download("", server.URL+"/episode.mp3", filepath.Join(os.Getenv("DATA"), "Segmented", name), ""); err != nil {
+				t.Errorf("Download %s failed: %v", name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+	if got := maxActive.Load(); got > 2 {
+		t.Fatalf("expected at most 2 concurrent connections to the host, got %d", got)
+	}
+}
@@ -1,23 +1,55 @@
 package service
 
 import (
+	"os"
 	"os/exec"
 	"strings"
 	"testing"
 )
 
+// requireFeedparserEnv turns a missing python or feedparser module into a
+// test failure instead of a skip, so CI cannot pass without the python legs.
+const requireFeedparserEnv = "BRIEFCAST_REQUIRE_FEEDPARSER"
+
+func skipWithoutPython(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+	if strings.TrimSpace(os.Getenv(requireFeedparserEnv)) != "" {
+		t.Fatalf(format, args...)
+	}
+	t.Skipf(format, args...)
+}
+
 func requireWorkingPython(t *testing.T) string {
 	t.Helper()
 	pythonPath, err := resolvePython()
 	if err != nil {
-		t.Skipf("python not available: %v", err)
+		skipWithoutPython(t, "python not available: %v", err)
 	}
 
 	cmd := exec.Command(pythonPath, "-c", "print('ok')")
 	output, runErr := cmd.CombinedOutput()
 	if runErr != nil {
-		t.Skipf("python is not runnable (%s): %v (%s)", pythonPath, runErr, strings.TrimSpace(string(output)))
+		skipWithoutPython(t, "python is not runnable (%s): %v (%s)", pythonPath, runErr, strings.TrimSpace(string(output)))
 	}
 
 	return pythonPath
 }
+
+func requireFeedparserModule(t *testing.T) string {
+	t.Helper()
+	pythonPath := requireWorkingPython(t)
+	cmd := exec.Command(pythonPath, "-c", "import feedparser")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		skipWithoutPython(t, "python feedparser module not installed: %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+	return pythonPath
+}
+
+func defaultUserID(t *testing.T) string {
+	t.Helper()
+	user, err := GetDefaultUser()
+	if err != nil {
+		t.Fatalf("GetDefaultUser failed: %v", err)
+	}
+	return user.ID
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DefaultUsername is the account created on first start. It takes over the
+// listening state stored before per-user state existed and its password is
+// kept in sync with the PASSWORD environment variable.
+const DefaultUsername = "briefcast"
+
+const (
+	userSessionLifetime = 30 * 24 * time.Hour
+	credentialCacheTTL  = 5 * time.Minute
+)
+
+var usersNow = func() time.Time {
+	return time.Now().UTC()
+}
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidUser        = errors.New("username and password are required")
+	ErrUserExists         = errors.New("username is already taken")
+	ErrLastAdmin          = errors.New("the last admin cannot be deleted")
+)
+
+var defaultUserMutex sync.Mutex
+
+// credentialCache remembers recently verified basic auth credentials so every
+// request does not pay for a bcrypt comparison.
+var credentialCache = struct {
+	sync.Mutex
+	entries map[string]credentialCacheEntry
+}{entries: map[string]credentialCacheEntry{}}
+
+type credentialCacheEntry struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// GetDefaultUser returns the default account, creating it on first use.
+func GetDefaultUser() (db.User, error) {
+	defaultUserMutex.Lock()
+	defer defaultUserMutex.Unlock()
+
+	var user db.User
+	err := db.GetUserByUsername(DefaultUsername, &user)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+
+	user = db.User{Username: DefaultUsername, IsAdmin: true}
+	if err := db.CreateUser(&user); err != nil {
+		return user, err
+	}
+	if err := db.ImportLegacyEpisodeState(user.ID); err != nil {
+		Logger.Errorw("failed to import episode state for default user", "user_id", user.ID, "error", err)
+		return user, err
+	}
+	return user, db.RefreshPodcastItemsPlayed()
+}
+
+// EnsureDefaultUser creates the default account if needed and updates its
+// password to match the configured one. Changing the password ends the
+// account's sessions.
+func EnsureDefaultUser(password string) (db.User, error) {
+	user, err := GetDefaultUser()
+	if err != nil || password == "" {
+		return user, err
+	}
+	if user.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil {
+		return user, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return user, err
+	}
+	user.PasswordHash = string(hash)
+	clearCredentialCache()
+	if err := db.UpdateUserPasswordHash(user.ID, user.PasswordHash); err != nil {
+		return user, err
+	}
+	return user, db.DeleteUserSessionsByUserId(user.ID)
+}
+
+func GetAllUsers() (*[]db.User, error) {
+	return db.GetAllUsers()
+}
+
+func GetUserById(id string) (db.User, error) {
+	var user db.User
+	err := db.GetUserById(id, &user)
+	return user, err
+}
+
+// CreateUser adds an account. A new listener has not played anything yet, so
+// the library-wide played flags are recomputed.
+func CreateUser(username string, password string, isAdmin bool) (db.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return db.User{}, ErrInvalidUser
+	}
+	var existing db.User
+	if err := db.GetUserByUsername(username, &existing); err == nil {
+		return db.User{}, ErrUserExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return db.User{}, err
+	}
+	user := db.User{Username: username, PasswordHash: string(hash), IsAdmin: isAdmin}
+	if err := db.CreateUser(&user); err != nil {
+		return db.User{}, err
+	}
+	return user, db.RefreshPodcastItemsPlayed()
+}
+
+// DeleteUser removes an account with its sessions and listening state.
+func DeleteUser(id string) error {
+	user, err := GetUserById(id)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin {
+		users, err := db.GetAllUsers()
+		if err != nil {
+			return err
+		}
+		admins := 0
+		for _, other := range *users {
+			if other.IsAdmin {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+	if err := db.DeleteUserById(id); err != nil {
+		return err
+	}
+	clearCredentialCache()
+	return db.RefreshPodcastItemsPlayed()
+}
+
+// AuthenticateUser checks a username and password against the users table.
+func AuthenticateUser(username string, password string) (db.User, error) {
+	key := credentialCacheKey(username, password)
+	now := usersNow()
+	credentialCache.Lock()
+	entry, found := credentialCache.entries[key]
+	credentialCache.Unlock()
+	if found && now.Before(entry.expiresAt) {
+		if user, err := GetUserById(entry.userID); err == nil {
+			return user, nil
+		}
+	}
+
+	var user db.User
+	if err := db.GetUserByUsername(username, &user); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return db.User{}, ErrInvalidCredentials
+		}
+		return db.User{}, err
+	}
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return db.User{}, ErrInvalidCredentials
+	}
+
+	credentialCache.Lock()
+	credentialCache.entries[key] = credentialCacheEntry{userID: user.ID, expiresAt: now.Add(credentialCacheTTL)}
+	credentialCache.Unlock()
+	return user, nil
+}
+
+// CreateUserSession starts a login session and returns its token. Only a hash
+// of the token is stored.
+func CreateUserSession(userID string) (string, db.UserSession, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", db.UserSession{}, err
+	}
+	token := hex.EncodeToString(buf)
+	now := usersNow()
+	session := db.UserSession{UserID: userID, TokenHash: hashToken(token), ExpiresAt: now.Add(userSessionLifetime)}
+	if err := db.CreateUserSession(&session); err != nil {
+		return "", db.UserSession{}, err
+	}
+	if err := db.DeleteExpiredUserSessions(now); err != nil {
+		Logger.Warnw("failed to delete expired sessions", "error", err)
+	}
+	return token, session, nil
+}
+
+// GetSessionUser returns the user owning an unexpired session token.
+func GetSessionUser(token string) (db.User, error) {
+	var session db.UserSession
+	if err := db.GetUserSessionByTokenHash(hashToken(token), usersNow(), &session); err != nil {
+		return db.User{}, err
+	}
+	return GetUserById(session.UserID)
+}
+
+func DeleteUserSession(token string) error {
+	return db.DeleteUserSessionByTokenHash(hashToken(token))
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func credentialCacheKey(username string, password string) string {
+	return hashToken(username + "\x00" + password)
+}
+
+func clearCredentialCache() {
+	credentialCache.Lock()
+	credentialCache.entries = map[string]credentialCacheEntry{}
+	credentialCache.Unlock()
+}
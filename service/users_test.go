@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+func TestGetDefaultUserImportsLegacyState(t *testing.T) {
+	tempDir := setupRetentionTestDB(t)
+	podcast := createPodcast(t, "legacy", false)
+	item := createDownloadedItem(t, podcast, "legacy-episode", time.Now().UTC(), true, tempDir)
+
+	userID := defaultUserID(t)
+	state, err := db.GetUserEpisodeState(userID, item.ID)
+	if err != nil {
+		t.Fatalf("GetUserEpisodeState failed: %v", err)
+	}
+	if state.ID == "" || !state.IsPlayed {
+		t.Fatalf("expected legacy played state to be imported, got %+v", state)
+	}
+}
+
+func TestPlayedStateIsTrackedPerUser(t *testing.T) {
+	setupRetentionTestDB(t)
+	item := createPlaybackItem(t, 1000)
+	ownerID := defaultUserID(t)
+	listener, err := CreateUser("listener", "secret", false)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	if err := SetPodcastItemPlayedStatus(ownerID, item.ID, true); err != nil {
+		t.Fatalf("SetPodcastItemPlayedStatus failed: %v", err)
+	}
+	if err := SetPodcastItemBookmarkStatus(listener.ID, item.ID, true); err != nil {
+		t.Fatalf("SetPodcastItemBookmarkStatus failed: %v", err)
+	}
+
+	owned := []db.PodcastItem{item}
+	listened := []db.PodcastItem{item}
+	if err := ApplyUserEpisodeState(ownerID, owned); err != nil {
+		t.Fatalf("ApplyUserEpisodeState failed: %v", err)
+	}
+	if err := ApplyUserEpisodeState(listener.ID, listened); err != nil {
+		t.Fatalf("ApplyUserEpisodeState failed: %v", err)
+	}
+	if !owned[0].IsPlayed || !owned[0].BookmarkDate.IsZero() {
+		t.Fatalf("expected owner to see played and unbookmarked, got %+v", owned[0])
+	}
+	if listened[0].IsPlayed || listened[0].BookmarkDate.IsZero() {
+		t.Fatalf("expected listener to see unplayed and bookmarked, got %+v", listened[0])
+	}
+
+	var stored db.PodcastItem
+	if err := db.GetPodcastItemById(item.ID, &stored); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if stored.IsPlayed {
+		t.Fatalf("expected episode to stay unplayed until every user played it")
+	}
+	if err := SetPodcastItemPlayedStatus(listener.ID, item.ID, true); err != nil {
+		t.Fatalf("SetPodcastItemPlayedStatus failed: %v", err)
+	}
+	if err := db.GetPodcastItemById(item.ID, &stored); err != nil {
+		t.Fatalf("reload item failed: %v", err)
+	}
+	if !stored.IsPlayed {
+		t.Fatalf("expected episode to be played once every user played it")
+	}
+}
+
+func TestUserAuthenticationAndSessions(t *testing.T) {
+	setupRetentionTestDB(t)
+	admin, err := EnsureDefaultUser("secret")
+	if err != nil {
+		t.Fatalf("EnsureDefaultUser failed: %v", err)
+	}
+	if _, err := AuthenticateUser(DefaultUsername, "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if user, err := AuthenticateUser(DefaultUsername, "secret"); err != nil || user.ID != admin.ID {
+		t.Fatalf("expected default user to authenticate, got %+v, %v", user, err)
+	}
+	if _, err := CreateUser(DefaultUsername, "other", false); err != ErrUserExists {
+		t.Fatalf("expected ErrUserExists, got %v", err)
+	}
+
+	token, _, err := CreateUserSession(admin.ID)
+	if err != nil {
+		t.Fatalf("CreateUserSession failed: %v", err)
+	}
+	if user, err := GetSessionUser(token); err != nil || user.ID != admin.ID {
+		t.Fatalf("expected session to resolve to the default user, got %+v, %v", user, err)
+	}
+	if err := DeleteUserSession(token); err != nil {
+		t.Fatalf("DeleteUserSession failed: %v", err)
+	}
+	if _, err := GetSessionUser(token); err == nil {
+		t.Fatalf("expected deleted session to be rejected")
+	}
+
+	if err := DeleteUser(admin.ID); err != ErrLastAdmin {
+		t.Fatalf("expected ErrLastAdmin, got %v", err)
+	}
+}
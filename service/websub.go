@@ -0,0 +1,304 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+	"github.com/ctaylor1/briefcast/internal/feedmeta"
+)
+
+var (
+	ErrWebSubNotSubscribed = errors.New("podcast has no websub subscription")
+	ErrWebSubTopicMismatch = errors.New("websub topic does not match the subscription")
+	ErrWebSubSignature     = errors.New("websub signature is missing or invalid")
+)
+
+// Podcast event kinds for WebSub subscriptions.
+const (
+	PodcastEventWebSubSubscribed = "websub_subscribed"
+	PodcastEventWebSubFailed     = "websub_failed"
+)
+
+const (
+	websubCallbackEnv = "WEBSUB_CALLBACK_URL"
+	// websubLeaseSeconds is the lease asked for; hubs may grant another.
+	websubLeaseSeconds = 7 * 24 * 60 * 60
+	// Subscriptions are renewed once their lease has less than a day left,
+	// and a request the hub never verified is sent again after an hour.
+	websubRenewBefore = 24 * time.Hour
+	websubRetryAfter  = time.Hour
+	// Podcasts with a verified subscription are still polled, at most this
+	// often, in case a push is lost.
+	websubPollInterval = 12 * time.Hour
+	// websubPushLimit caps the pushed body read to check its signature.
+	websubPushLimit = 32 << 20
+)
+
+// websubCallbackBase is the public URL hubs reach Briefcast on. WebSub is
+// off while it is unset.
+func websubCallbackBase() string {
+	return strings.TrimRight(strings.TrimSpace(os.Getenv(websubCallbackEnv)), "/")
+}
+
+func websubCallbackURL(podcastID string) string {
+	return websubCallbackBase() + "/websub/" + podcastID
+}
+
+// webSubActive reports whether the hub has verified a subscription that has
+// not expired.
+func webSubActive(podcast db.Podcast, now time.Time) bool {
+	return podcast.WebSubHub != "" && podcast.WebSubExpiresAt != nil && podcast.WebSubExpiresAt.After(now)
+}
+
+// applyWebSubFeedFields stores the hub and topic a refreshed feed advertises.
+// The topic is the feed's self link, or its URL when it has none.
+func applyWebSubFeedFields(podcast *db.Podcast, feed map[string]interface{}) {
+	hub, topic := feedmeta.ExtractWebSubLinks(feed)
+	if hub == "" {
+		topic = ""
+	} else if topic == "" {
+		topic = podcast.URL
+	}
+	if hub == podcast.WebSubHub && topic == podcast.WebSubTopic {
+		return
+	}
+	if webSubActive(*podcast, time.Now()) {
+		go unsubscribeWebSub(podcast.ID, podcast.WebSubHub, podcast.WebSubTopic)
+	}
+	if err := db.UpdatePodcastWebSubHub(podcast.ID, hub, topic); err != nil {
+		Logger.Warnw("failed to store websub hub", "podcast_id", podcast.ID, "hub", hub, "error", err)
+		return
+	}
+	Logger.Infow("podcast websub hub changed", "podcast_id", podcast.ID, "hub", hub, "topic", topic)
+	podcast.WebSubHub = hub
+	podcast.WebSubTopic = topic
+	podcast.WebSubRequestedAt = nil
+	podcast.WebSubExpiresAt = nil
+}
+
+// maintainWebSubSubscription subscribes to the podcast's hub, or renews the
+// subscription before its lease ends or when its secret was lost, as with a
+// restored backup. Hubs confirm through the callback, so the subscription
+// only becomes active once VerifyWebSubChallenge runs.
+func maintainWebSubSubscription(podcast *db.Podcast) {
+	if podcast.WebSubHub == "" || websubCallbackBase() == "" {
+		return
+	}
+	now := time.Now()
+	if webSubActive(*podcast, now) && podcast.WebSubSecret != "" && podcast.WebSubExpiresAt.Sub(now) > websubRenewBefore {
+		return
+	}
+	if podcast.WebSubRequestedAt != nil && now.Sub(*podcast.WebSubRequestedAt) < websubRetryAfter {
+		return
+	}
+
+	secret := podcast.WebSubSecret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			Logger.Warnw("failed to create websub secret", "podcast_id", podcast.ID, "error", err)
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	// The request time is stored first so hubs that verify before answering
+	// find the secret in place.
+	if err := db.UpdatePodcastWebSubRequest(podcast.ID, secret, now); err != nil {
+		Logger.Warnw("failed to store websub request", "podcast_id", podcast.ID, "error", err)
+		return
+	}
+	podcast.WebSubSecret = secret
+	podcast.WebSubRequestedAt = &now
+	if err := sendWebSubRequest(podcast.WebSubHub, "subscribe", podcast.WebSubTopic, websubCallbackURL(podcast.ID), secret); err != nil {
+		Logger.Warnw("websub subscription request failed", "podcast_id", podcast.ID, "hub", podcast.WebSubHub, "error", err)
+		recordPodcastEventOnce(podcast.ID, db.PodcastEvent{
+			Kind:    PodcastEventWebSubFailed,
+			Message: fmt.Sprintf("WebSub hub %s refused the subscription: %v", podcast.WebSubHub, err),
+			OldURL:  podcast.WebSubTopic,
+			NewURL:  podcast.WebSubHub,
+		})
+		return
+	}
+	Logger.Infow("websub subscription requested", "podcast_id", podcast.ID, "hub", podcast.WebSubHub, "topic", podcast.WebSubTopic)
+	var stored db.Podcast
+	if err := db.GetPodcastById(podcast.ID, &stored); err == nil {
+		podcast.WebSubExpiresAt = stored.WebSubExpiresAt
+	}
+}
+
+func unsubscribeWebSub(podcastID string, hub string, topic string) {
+	if hub == "" || websubCallbackBase() == "" {
+		return
+	}
+	if err := sendWebSubRequest(hub, "unsubscribe", topic, websubCallbackURL(podcastID), ""); err != nil {
+		Logger.Warnw("websub unsubscribe request failed", "podcast_id", podcastID, "hub", hub, "error", err)
+	}
+}
+
+func sendWebSubRequest(hub string, mode string, topic string, callback string, secret string) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {topic},
+		"hub.callback": {callback},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.lease_seconds", strconv.Itoa(websubLeaseSeconds))
+		form.Set("hub.secret", secret)
+	}
+	req, err := http.NewRequest(http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if setting := db.GetOrCreateSetting(); setting.UserAgent != "" {
+		req.Header.Set("User-Agent", setting.UserAgent)
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// VerifyWebSubChallenge answers a hub's verification request and returns
+// the challenge to echo. A subscription is confirmed only for the podcast's
+// current hub topic. Unsubscribes are confirmed for podcasts that no longer
+// want the topic, including deleted ones. A denial ends the subscription.
+func VerifyWebSubChallenge(podcastID string, mode string, topic string, challenge string, leaseSeconds string, reason string) (string, error) {
+	var podcast db.Podcast
+	err := db.GetPodcastById(podcastID, &podcast)
+	switch mode {
+	case "subscribe":
+		if err != nil {
+			return "", err
+		}
+		if podcast.WebSubHub == "" || podcast.WebSubRequestedAt == nil || websubCallbackBase() == "" {
+			return "", ErrWebSubNotSubscribed
+		}
+		if topic != podcast.WebSubTopic {
+			return "", ErrWebSubTopicMismatch
+		}
+		lease, convErr := strconv.Atoi(leaseSeconds)
+		if convErr != nil || lease <= 0 {
+			lease = websubLeaseSeconds
+		}
+		expiresAt := time.Now().Add(time.Duration(lease) * time.Second)
+		if err := db.UpdatePodcastWebSubLease(podcast.ID, &expiresAt); err != nil {
+			return "", err
+		}
+		Logger.Infow("websub subscription verified", "podcast_id", podcast.ID, "hub", podcast.WebSubHub, "expires_at", expiresAt)
+		recordPodcastEventOnce(podcast.ID, db.PodcastEvent{
+			Kind:    PodcastEventWebSubSubscribed,
+			Message: fmt.Sprintf("Subscribed to push updates from %s", podcast.WebSubHub),
+			OldURL:  podcast.WebSubTopic,
+			NewURL:  podcast.WebSubHub,
+		})
+		return challenge, nil
+	case "unsubscribe":
+		if err == nil && podcast.WebSubHub != "" && topic == podcast.WebSubTopic {
+			return "", ErrWebSubTopicMismatch
+		}
+		return challenge, nil
+	case "denied":
+		if err != nil {
+			return "", err
+		}
+		if topic != podcast.WebSubTopic {
+			return "", ErrWebSubTopicMismatch
+		}
+		if err := db.UpdatePodcastWebSubLease(podcast.ID, nil); err != nil {
+			return "", err
+		}
+		Logger.Warnw("websub subscription denied", "podcast_id", podcast.ID, "hub", podcast.WebSubHub, "reason", reason)
+		recordPodcastEventOnce(podcast.ID, db.PodcastEvent{
+			Kind:    PodcastEventWebSubFailed,
+			Message: strings.TrimSpace(fmt.Sprintf("WebSub hub %s denied the subscription. %s", podcast.WebSubHub, reason)),
+			OldURL:  podcast.WebSubTopic,
+			NewURL:  podcast.WebSubHub,
+		})
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown websub mode %q", mode)
+}
+
+// HandleWebSubPush checks a pushed update's X-Hub-Signature against the
+// subscription secret and refreshes the podcast in the background.
+func HandleWebSubPush(podcastID string, signature string, body io.Reader) error {
+	var podcast db.Podcast
+	if err := db.GetPodcastById(podcastID, &podcast); err != nil {
+		return err
+	}
+	if !webSubActive(podcast, time.Now()) || podcast.WebSubSecret == "" {
+		return ErrWebSubNotSubscribed
+	}
+	payload, err := io.ReadAll(io.LimitReader(body, websubPushLimit))
+	if err != nil {
+		return err
+	}
+	if !validWebSubSignature(podcast.WebSubSecret, signature, payload) {
+		Logger.Warnw("websub push rejected", "podcast_id", podcast.ID, "hub", podcast.WebSubHub)
+		return ErrWebSubSignature
+	}
+	Logger.Infow("websub push received", "podcast_id", podcast.ID, "bytes", len(payload))
+	go refreshPushedPodcast(podcast)
+	return nil
+}
+
+func refreshPushedPodcast(podcast db.Podcast) {
+	if err := refreshPodcast(&podcast); err != nil {
+		if !errors.Is(err, ErrPodcastRefreshing) {
+			Logger.Warnw("refresh after websub push failed", "podcast_id", podcast.ID, "error", err)
+		}
+		return
+	}
+	go DownloadMissingEpisodes()
+}
+
+// validWebSubSignature checks a "method=hex" signature over the body with
+// any of the hash methods WebSub hubs use.
+func validWebSubSignature(secret string, signature string, body []byte) bool {
+	method, digest, found := strings.Cut(strings.TrimSpace(signature), "=")
+	if !found {
+		return false
+	}
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ctaylor1/briefcast/db"
+)
+
+// websubTestHub stands in for a WebSub hub. It verifies subscriptions
+// through the callback before answering, and publishes signed pushes.
+type websubTestHub struct {
+	t        *testing.T
+	mu       sync.Mutex
+	callback string
+	secret   string
+	modes    []string
+}
+
+func (hub *websubTestHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode := r.PostForm.Get("hub.mode")
+	query := url.Values{
+		"hub.mode":          {mode},
+		"hub.topic":         {r.PostForm.Get("hub.topic")},
+		"hub.challenge":     {"challenge-" + mode},
+		"hub.lease_seconds": {"172800"},
+	}
+	resp, err := http.Get(r.PostForm.Get("hub.callback") + "?" + query.Encode())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	echoed, _ := io.ReadAll(resp.Body)
+	hub.mu.Lock()
+	hub.modes = append(hub.modes, mode)
+	if resp.StatusCode == http.StatusOK && string(echoed) == "challenge-"+mode && mode == "subscribe" {
+		hub.callback = r.PostForm.Get("hub.callback")
+		hub.secret = r.PostForm.Get("hub.secret")
+	}
+	hub.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (hub *websubTestHub) publish(body string, secret string) int {
+	hub.t.Helper()
+	hub.mu.Lock()
+	callback := hub.callback
+	if secret == "" {
+		secret = hub.secret
+	}
+	hub.mu.Unlock()
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req, _ := http.NewRequest(http.MethodPost, callback, strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		hub.t.Fatalf("push failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// websubCallbackHandler serves the callback the way the controllers do.
+func websubCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	podcastID := strings.TrimPrefix(r.URL.Path, "/websub/")
+	if r.Method == http.MethodPost {
+		if err := HandleWebSubPush(podcastID, r.Header.Get("X-Hub-Signature"), r.Body); err != nil && !errors.Is(err, ErrWebSubSignature) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	query := r.URL.Query()
+	challenge, err := VerifyWebSubChallenge(podcastID, query.Get("hub.mode"), query.Get("hub.topic"), query.Get("hub.challenge"), query.Get("hub.lease_seconds"), query.Get("hub.reason"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	_, _ = fmt.Fprint(w, challenge)
+}
+
+func TestWebSubSubscriptionAndPush(t *testing.T) {
+	setupRetentionTestDB(t)
+	if err := db.DB.Model(&db.Setting{}).Where("1 = 1").Update("auto_download", false).Error; err != nil {
+		t.Fatalf("disable auto download failed: %v", err)
+	}
+	hub := &websubTestHub{t: t}
+	hubServer := httptest.NewServer(hub)
+	t.Cleanup(hubServer.Close)
+	callbackServer := httptest.NewServer(http.HandlerFunc(websubCallbackHandler))
+	t.Cleanup(callbackServer.Close)
+	t.Setenv("WEBSUB_CALLBACK_URL", callbackServer.URL+"/")
+
+	var (
+		feedMu sync.Mutex
+		guids  = []string{"ep-1"}
+	)
+	var feedServer *httptest.Server
+	feedXML := func() string {
+		feedMu.Lock()
+		defer feedMu.Unlock()
+		items := ""
+		for _, guid := range guids {
+			items += fmt.Sprintf(`<item><title>%s</title><guid>%s</guid><pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate><enclosure url="%s/%s.mp3" type="audio/mpeg" length="1" /></item>`, guid, guid, feedServer.URL, guid)
+		}
+		return `<?xml version="1.0"?><rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Pushed</title>` +
+			`<atom:link rel="hub" href="` + hubServer.URL + `/"/><atom:link rel="self" href="` + feedServer.URL + `/self"/>` + items + `</channel></rss>`
+	}
+	feedServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, feedXML())
+	}))
+	t.Cleanup(feedServer.Close)
+
+	podcast := db.Podcast{Title: "Pushed", URL: feedServer.URL + "/feed"}
+	if err := db.CreatePodcast(&podcast); err != nil {
+		t.Fatalf("create podcast failed: %v", err)
+	}
+	refreshed, err := RefreshPodcast(podcast.ID)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if refreshed.WebSubHub != hubServer.URL+"/" || refreshed.WebSubTopic != feedServer.URL+"/self" || refreshed.WebSubSecret == "" {
+		t.Fatalf("expected the hub and self link to be stored, got %q %q", refreshed.WebSubHub, refreshed.WebSubTopic)
+	}
+	if !webSubActive(*refreshed, time.Now()) || refreshed.WebSubExpiresAt.Sub(time.Now()) < 47*time.Hour {
+		t.Fatalf("expected the hub to verify a two day lease, got %v", refreshed.WebSubExpiresAt)
+	}
+	hub.mu.Lock()
+	secret, modes := hub.secret, len(hub.modes)
+	hub.mu.Unlock()
+	if secret != refreshed.WebSubSecret || modes != 1 {
+		t.Fatalf("expected one verified subscription with the stored secret, got %d requests", modes)
+	}
+	if got := refreshed.NextRefreshAt.Sub(*refreshed.LastRefreshAt); got != websubPollInterval {
+		t.Fatalf("expected push-enabled feeds to be polled less, got %s", got)
+	}
+	if events := podcastEvents(t, podcast.ID); len(events) != 1 || events[0].Kind != PodcastEventWebSubSubscribed {
+		t.Fatalf("expected the subscription in the event log, got %+v", events)
+	}
+
+	// Another refresh inside the lease does not subscribe again.
+	if _, err := RefreshPodcast(podcast.ID); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	hub.mu.Lock()
+	modes = len(hub.modes)
+	hub.mu.Unlock()
+	if modes != 1 {
+		t.Fatalf("expected one subscription request, got %d", modes)
+	}
+
+	feedMu.Lock()
+	guids = append(guids, "ep-2")
+	feedMu.Unlock()
+	if status := hub.publish(feedXML(), "wrong-secret"); status != http.StatusAccepted {
+		t.Fatalf("expected a bad signature to be acknowledged, got %d", status)
+	}
+	if err := HandleWebSubPush(podcast.ID, "sha256=00", bytes.NewReader([]byte(feedXML()))); !errors.Is(err, ErrWebSubSignature) {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+	if status := hub.publish(feedXML(), ""); status != http.StatusAccepted {
+		t.Fatalf("expected the push to be accepted, got %d", status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var items []db.PodcastItem
+		_, refreshing := refreshingPodcasts.Load(podcast.ID)
+		if err := db.GetAllPodcastItemsByPodcastId(podcast.ID, &items); err == nil && len(items) == 2 && !refreshing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the push to add the new episode")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := VerifyWebSubChallenge(podcast.ID, "unsubscribe", refreshed.WebSubTopic, "c", "", ""); !errors.Is(err, ErrWebSubTopicMismatch) {
+		t.Fatalf("expected an unsubscribe of the current topic to be refused, got %v", err)
+	}
+	if challenge, err := VerifyWebSubChallenge("missing", "unsubscribe", refreshed.WebSubTopic, "c", "", ""); err != nil || challenge != "c" {
+		t.Fatalf("expected an unsubscribe of a deleted podcast to be confirmed, got %q %v", challenge, err)
+	}
+	if _, err := VerifyWebSubChallenge(podcast.ID, "denied", refreshed.WebSubTopic, "", "", "blocked"); err != nil {
+		t.Fatalf("denial failed: %v", err)
+	}
+	if err := HandleWebSubPush(podcast.ID, "", strings.NewReader("")); !errors.Is(err, ErrWebSubNotSubscribed) {
+		t.Fatalf("expected pushes after a denial to be refused, got %v", err)
+	}
+}
+
+func TestValidWebSubSignature(t *testing.T) {
+	body := []byte("<rss/>")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !validWebSubSignature("secret", signature, body) {
+		t.Fatalf("expected the signature to match")
+	}
+	for _, bad := range []string{"", "sha256", "md5=00", "sha256=zz", signature + "00"} {
+		if validWebSubSignature("secret", bad, body) {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+	if validWebSubSignature("other", signature, body) {
+		t.Fatalf("expected another secret to be rejected")
+	}
+}
@@ -209,7 +209,9 @@ func TranscribePendingEpisodes() error {
 		if err := db.UpdatePodcastItem(&item); err != nil {
 			jobLogger.Warnw("failed to save transcript output", "podcast_item_id", item.ID, "error", err)
 			setError(err)
+			return
 		}
+		FireHookEvent(HookEventTranscriptAvailable, item, nil)
 	})
 
 	return firstErr